@@ -1,17 +1,28 @@
 package main
 
 import (
+	"github.com/alpemreelmas/sysara/internal/audit"
 	"github.com/alpemreelmas/sysara/internal/auth"
+	"github.com/alpemreelmas/sysara/internal/config"
+	"github.com/alpemreelmas/sysara/internal/crontab"
+	"github.com/alpemreelmas/sysara/internal/docker"
+	"github.com/alpemreelmas/sysara/internal/envstore"
 	"github.com/alpemreelmas/sysara/internal/handlers"
+	"github.com/alpemreelmas/sysara/internal/mail"
 	"github.com/alpemreelmas/sysara/internal/middleware"
 	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/oidc"
+	"github.com/alpemreelmas/sysara/internal/teams"
+	"github.com/alpemreelmas/sysara/internal/webauthn"
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/sessions"
 	"log"
 	"net/http"
 )
 
 func main() {
+	// Load configuration from the environment
+	cfg := config.Load()
+
 	// Initialize database
 	db, err := models.InitDB()
 	if err != nil {
@@ -19,38 +30,119 @@ func main() {
 	}
 
 	// Initialize session store
-	store := sessions.NewCookieStore([]byte("sysara-secret-key-change-in-production"))
-	// Configure session options
-	store.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   86400 * 7, // 7 days
-		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
-		SameSite: http.SameSiteLaxMode,
-	}
+	store := auth.NewSessionStore(db, cfg.SessionIdleTimeout, cfg.SessionMaxAge)
+	store.Options.Secure = false // Set to true in production with HTTPS
 
 	// Initialize auth service
-	authService := auth.NewAuthService(db, store)
+	policy, err := auth.NewPasswordPolicy(cfg.PasswordMinLength, cfg.PasswordMinClasses, cfg.PasswordMaxAge, cfg.PasswordHistory, cfg.PasswordBlocklistFile)
+	if err != nil {
+		log.Fatal("Failed to load password policy:", err)
+	}
+	var authenticators []auth.Authenticator
+	if cfg.LDAPURL != "" {
+		authenticators = append(authenticators, auth.NewLDAPAuthenticator(auth.LDAPConfig{
+			URL:                cfg.LDAPURL,
+			StartTLS:           cfg.LDAPStartTLS,
+			InsecureSkipVerify: cfg.LDAPInsecureSkipVerify,
+			BindDN:             cfg.LDAPBindDN,
+			BindPassword:       cfg.LDAPBindPassword,
+			BaseDN:             cfg.LDAPBaseDN,
+			UserFilter:         cfg.LDAPUserFilter,
+			IDAttribute:        cfg.LDAPIDAttribute,
+			EmailAttribute:     cfg.LDAPEmailAttribute,
+			NameAttribute:      cfg.LDAPNameAttribute,
+			GroupAttribute:     cfg.LDAPGroupAttribute,
+			TrustEmail:         cfg.LDAPTrustEmail,
+			Groups:             auth.GroupMapping{AdminGroups: cfg.LDAPAdminGroups, AllowedGroups: cfg.LDAPAllowedGroups},
+		}))
+	}
+	authService := auth.NewAuthService(db, store, auth.Lockout{Threshold: cfg.LoginLockoutThreshold, Duration: cfg.LoginLockoutDuration}, policy, cfg.PasswordLogin, authenticators...)
+
+	// Create the first administrator from the environment, or wait for the setup wizard
+	if authService.NeedsSetup() {
+		if cfg.AdminEmail != "" {
+			if _, err := authService.CreateFirstAdmin(cfg.AdminEmail, cfg.AdminName, cfg.AdminPassword, cfg.AdminPasswordChange); err != nil {
+				log.Fatal("Failed to create the administrator from ADMIN_EMAIL:", err)
+			}
+			log.Printf("Created administrator %s", cfg.AdminEmail)
+		}
+	}
+	if token, err := authService.BeginSetup(cfg.SetupToken); err != nil {
+		log.Fatal("Failed to issue the setup token:", err)
+	} else if token != "" {
+		log.Printf("No users exist yet, open %s/setup?token=%s to create the administrator", cfg.AppURL, token)
+	} else if authService.NeedsSetup() {
+		log.Printf("No users exist yet, open %s/setup with SETUP_TOKEN to create the administrator", cfg.AppURL)
+	}
+	if token, err := authService.BeginClaim(); err != nil {
+		log.Fatal("Failed to issue the setup token:", err)
+	} else if token != "" {
+		log.Printf("%s is disabled because it uses the default password, open %s/setup?token=%s to claim it", models.DefaultAdminEmail, cfg.AppURL, token)
+	}
+
+	// Initialize env file revision store
+	envStore, err := envstore.NewStore(db, cfg.EnvRevisionLimit, cfg.EnvRevisionMaxAge, cfg.EnvEncryptionKey)
+	if err != nil {
+		log.Fatal("Failed to initialize env revision store:", err)
+	}
+	if !envStore.Encrypted() {
+		log.Println("Warning: ENV_ENCRYPTION_KEY is not set, env file revisions are stored unencrypted")
+	} else if n, err := envStore.EncryptExisting(); err != nil {
+		log.Fatal("Failed to encrypt env file revisions:", err)
+	} else if n > 0 {
+		log.Printf("Encrypted %d existing env file revisions", n)
+	}
 
 	// Initialize handlers
-	userHandler := handlers.NewUserHandler(db, authService)
+	loginOptions := handlers.LoginOptions{Registration: cfg.EnableRegistration, LocalPasswords: cfg.PasswordLogin, Directory: cfg.LDAPURL != "", Passkeys: cfg.PasswordLogin}
+	var ssoHandler *handlers.SSOHandler
+	if cfg.OIDCIssuer != "" {
+		loginOptions.SSOName = cfg.OIDCProviderName
+		provider := oidc.New(cfg.OIDCIssuer, cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCRedirectURL, cfg.OIDCScopes, cfg.OIDCGroupsClaim)
+		mapping := auth.GroupMapping{AdminGroups: cfg.OIDCAdminGroups, AllowedGroups: cfg.OIDCAllowedGroups}
+		ssoHandler = handlers.NewSSOHandler(authService, provider, mapping, loginOptions, audit.NewLogger(db))
+	} else if !cfg.PasswordLogin && cfg.LDAPURL == "" {
+		log.Fatal("PASSWORD_LOGIN_ENABLED=false requires another way to sign in, set OIDC_ISSUER or LDAP_URL")
+	}
+	setupHandler := handlers.NewSetupHandler(authService)
+	userHandler := handlers.NewUserHandler(db, authService, audit.NewLogger(db), loginOptions)
+	mailer := mail.New(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.MailLogBody)
+	passwordResetHandler := handlers.NewPasswordResetHandler(authService, mailer, audit.NewLogger(db), cfg.AppURL, cfg.PasswordResetTTL)
+	invitationHandler := handlers.NewInvitationHandler(authService, mailer, audit.NewLogger(db), cfg.AppURL, cfg.InvitationTTL)
+	profileHandler := handlers.NewProfileHandler(db, authService, mailer, audit.NewLogger(db), cfg.AppURL, cfg.EmailChangeTTL, loginOptions)
+	sessionHandler := handlers.NewSessionHandler(db, authService, audit.NewLogger(db))
+	webauthnHandler := handlers.NewWebAuthnHandler(authService, audit.NewLogger(db), &webauthn.RelyingParty{ID: cfg.WebAuthnRPID, Name: cfg.WebAuthnRPName, Origins: cfg.WebAuthnOrigins})
 	dashboardHandler := handlers.NewDashboardHandler(db)
-	envHandler := handlers.NewEnvHandler()
-	sshHandler := handlers.NewSSHHandler(db)
+	envProjects := envstore.NewProjects(db)
+	envHandler := handlers.NewEnvHandler(envProjects, envStore, envstore.NewSecrets(db, cfg.EnvSecretPatterns), envstore.NewSchemas(db, envProjects), envstore.NewHooks(db, cfg.EnvHookCommands, cfg.EnvHookTimeout), audit.NewLogger(db))
+	teamManager := teams.NewManager(db)
+	teamHandler := handlers.NewTeamHandler(db, teamManager, audit.NewLogger(db))
+	envProjectHandler := handlers.NewEnvProjectHandler(db, envProjects, teamManager)
+	sshHandler := handlers.NewSSHHandler(db, teamManager)
+	serverHandler := handlers.NewServerHandler(db, teamManager)
 	monitorHandler := handlers.NewMonitorHandler()
+	dockerHandler := handlers.NewDockerHandler(docker.NewClient(cfg.DockerSocket))
+	cronHandler := handlers.NewCronHandler(crontab.NewManager(cfg.CronSystemFile, cfg.CronSystemDir, cfg.CronSpoolDir, cfg.CronBackupDir))
 
 	// Set Gin to release mode in production
 	gin.SetMode(gin.DebugMode) // Change to gin.ReleaseMode in production
 
 	// Initialize Gin router
 	r := gin.Default()
+	// Client IPs drive login throttling and the audit log, so forwarded
+	// headers are only believed from the configured proxies
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 
 	// Serve static files
 	r.Static("/static", "./static")
 	// Note: No longer loading HTML templates - using templ instead
 	// Middleware
 	r.Use(middleware.SessionMiddleware(store))
-	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.CORSMiddleware(cfg.CORSAllowedOrigins))
+	r.Use(middleware.CSRFMiddleware(authService))
+	r.Use(middleware.SetupMiddleware(authService))
 
 	// Public routes
 	public := r.Group("/")
@@ -58,37 +150,140 @@ func main() {
 		public.GET("/", func(c *gin.Context) {
 			c.Redirect(http.StatusMovedPermanently, "/login")
 		})
+		public.GET("/setup", setupHandler.ShowSetup)
+		public.POST("/setup", setupHandler.Setup)
 		public.GET("/login", userHandler.ShowLogin)
 		public.POST("/login", userHandler.Login)
+		public.GET("/login/verify", webauthnHandler.ShowVerify)
+		public.POST("/login/verify/begin", webauthnHandler.BeginVerify)
+		public.POST("/login/verify/finish", webauthnHandler.FinishVerify)
+		public.POST("/login/passkey/begin", webauthnHandler.BeginPasskey)
+		public.POST("/login/passkey/finish", webauthnHandler.FinishPasskey)
+		if ssoHandler != nil {
+			public.GET("/auth/oidc/login", ssoHandler.Login)
+			public.GET("/auth/oidc/callback", ssoHandler.Callback)
+		}
 		public.GET("/register", userHandler.ShowRegister)
 		public.POST("/register", userHandler.Register)
+		public.GET("/invite", invitationHandler.ShowAccept)
+		public.POST("/invite", invitationHandler.Accept)
+		public.GET("/forgot-password", passwordResetHandler.ShowForgotPassword)
+		public.POST("/forgot-password", passwordResetHandler.ForgotPassword)
+		public.GET("/reset-password", passwordResetHandler.ShowResetPassword)
+		public.POST("/reset-password", passwordResetHandler.ResetPassword)
+		public.GET("/verify-email", profileHandler.VerifyEmail)
 	}
 
 	// Protected routes (require authentication)
 	protected := r.Group("/")
 	protected.Use(middleware.AuthMiddleware(authService))
+	protected.Use(middleware.RequirePasswordChange(authService))
 	{
 		// Dashboard
 		protected.GET("/dashboard", dashboardHandler.ShowDashboard)
 
 		// User management
 		users := protected.Group("/users")
+		users.Use(middleware.RequireAdmin())
 		{
 			users.GET("/", userHandler.ListUsers)
 			users.GET("/create", userHandler.ShowCreateUser)
 			users.POST("/create", userHandler.CreateUser)
+			users.GET("/invite", invitationHandler.ShowInvite)
+			users.POST("/invite", invitationHandler.Invite)
+			users.POST("/invitations/:id/resend", invitationHandler.ResendInvitation)
+			users.POST("/invitations/:id/revoke", invitationHandler.RevokeInvitation)
 			users.GET("/:id/edit", userHandler.ShowEditUser)
 			users.POST("/:id/edit", userHandler.UpdateUser)
+			users.POST("/:id/status", userHandler.ChangeUserStatus)
 			users.POST("/:id/delete", userHandler.DeleteUser)
+			users.POST("/:id/restore", userHandler.RestoreUser)
+			users.POST("/:id/purge", userHandler.PurgeUser)
+			users.POST("/:id/unlock", userHandler.UnlockUser)
+			users.POST("/:id/security-keys/reset", userHandler.ResetSecurityKeys)
+			users.GET("/:id/sessions", sessionHandler.ShowUserSessions)
+			users.POST("/:id/sessions/revoke-all", sessionHandler.RevokeAllUserSessions)
+			users.POST("/:id/sessions/:session/revoke", sessionHandler.RevokeUserSession)
+		}
+
+		// Teams, managed by admins and their maintainers
+		teamRoutes := protected.Group("/teams")
+		{
+			teamRoutes.GET("/", teamHandler.ListTeams)
+			teamRoutes.GET("/create", middleware.RequireAdmin(), teamHandler.ShowCreateTeam)
+			teamRoutes.POST("/create", middleware.RequireAdmin(), teamHandler.CreateTeam)
+			teamRoutes.GET("/:id", teamHandler.ShowTeam)
+			teamRoutes.POST("/:id/edit", middleware.RequireAdmin(), teamHandler.UpdateTeam)
+			teamRoutes.POST("/:id/delete", middleware.RequireAdmin(), teamHandler.DeleteTeam)
+			teamRoutes.POST("/:id/members", teamHandler.SetMember)
+			teamRoutes.POST("/:id/members/:user/delete", teamHandler.RemoveMember)
+		}
+
+		// Profile, password, sessions and keys of the current user, which
+		// API tokens cannot change
+		account := protected.Group("/", middleware.RequireSession())
+		{
+			account.GET("/profile", profileHandler.ShowProfile)
+			account.POST("/profile", profileHandler.UpdateProfile)
+			account.POST("/profile/email/cancel", profileHandler.CancelEmailChange)
+			account.POST("/profile/tokens", profileHandler.CreateAPIToken)
+			account.POST("/profile/tokens/:id/delete", profileHandler.DeleteAPIToken)
+			account.POST("/profile/link/directory", profileHandler.LinkDirectory)
+			if ssoHandler != nil {
+				account.POST("/profile/link/sso", ssoHandler.Link)
+			}
+			account.GET("/password", userHandler.ShowChangePassword)
+			account.POST("/password", userHandler.ChangePassword)
+			account.GET("/sessions", sessionHandler.ShowSessions)
+			account.POST("/sessions/revoke-all", sessionHandler.RevokeOtherSessions)
+			account.POST("/sessions/:id/revoke", sessionHandler.RevokeSession)
+			account.GET("/security-keys", webauthnHandler.ShowSecurityKeys)
+			account.POST("/security-keys/register/begin", webauthnHandler.BeginRegistration)
+			account.POST("/security-keys/register/finish", webauthnHandler.FinishRegistration)
+			account.POST("/security-keys/:id/delete", webauthnHandler.DeleteSecurityKey)
 		}
 
 		// Environment management
 		env := protected.Group("/env")
 		{
 			env.GET("/", envHandler.ShowEnvFiles)
-			env.GET("/edit/:filename", envHandler.ShowEditEnv)
-			env.POST("/edit/:filename", envHandler.UpdateEnv)
-			env.POST("/create", envHandler.CreateEnvFile)
+			env.GET("/compare", envHandler.ShowCompare)
+			env.POST("/compare/promote", envHandler.PromoteKeys)
+			env.GET("/search", envHandler.ShowSearch)
+			env.POST("/search/bulk", envHandler.BulkEdit)
+			env.POST("/:project/create", envHandler.CreateEnvFile)
+			env.GET("/:project/edit/:filename", envHandler.ShowEditEnv)
+			env.POST("/:project/edit/:filename", envHandler.UpdateEnv)
+			env.GET("/:project/history/:filename", envHandler.ShowHistory)
+			env.GET("/:project/diff/:filename", envHandler.ShowDiff)
+			env.POST("/:project/rollback/:filename", envHandler.RollbackEnv)
+			env.POST("/:project/reveal/:filename", envHandler.RevealSecrets)
+			env.POST("/:project/api/reveal/:filename", envHandler.RevealValue) // HTMX endpoint
+			env.GET("/:project/schema/:filename", envHandler.ShowSchema)
+			env.POST("/:project/schema/:filename", envHandler.SaveSchemaRule)
+			env.POST("/:project/schema/:filename/delete", envHandler.DeleteSchemaRule)
+			env.POST("/:project/fill/:filename", envHandler.AddMissingKeys)
+			env.GET("/:project/import/:filename", envHandler.ShowTransfer)
+			env.POST("/:project/import/:filename", envHandler.PreviewImport)
+			env.POST("/:project/import/:filename/apply", envHandler.ApplyImport)
+			env.POST("/:project/export/:filename", envHandler.ExportEnv)
+			env.GET("/:project/hooks/:filename", middleware.RequireAdmin(), envHandler.ShowHooks)
+			env.POST("/:project/hooks/:filename", middleware.RequireAdmin(), envHandler.CreateHook)
+			env.POST("/:project/hooks/:filename/delete", middleware.RequireAdmin(), envHandler.DeleteHook)
+
+			// Project roots and permissions (admin only)
+			projects := env.Group("/projects")
+			projects.Use(middleware.RequireAdmin())
+			{
+				projects.GET("/", envProjectHandler.ListProjects)
+				projects.GET("/create", envProjectHandler.ShowCreateProject)
+				projects.POST("/create", envProjectHandler.CreateProject)
+				projects.GET("/:id/edit", envProjectHandler.ShowEditProject)
+				projects.POST("/:id/edit", envProjectHandler.UpdateProject)
+				projects.POST("/:id/delete", envProjectHandler.DeleteProject)
+				projects.POST("/:id/permissions", envProjectHandler.SetPermission)
+				projects.POST("/:id/permissions/:user/delete", envProjectHandler.RemovePermission)
+			}
 		}
 
 		// SSH Key management
@@ -100,6 +295,17 @@ func main() {
 			ssh.POST("/:id/delete", sshHandler.DeleteKey)
 		}
 
+		// Servers, managed by admins and the maintainers of their team
+		servers := protected.Group("/servers")
+		{
+			servers.GET("/", serverHandler.ListServers)
+			servers.GET("/create", serverHandler.ShowCreateServer)
+			servers.POST("/create", serverHandler.CreateServer)
+			servers.GET("/:id/edit", serverHandler.ShowEditServer)
+			servers.POST("/:id/edit", serverHandler.UpdateServer)
+			servers.POST("/:id/delete", serverHandler.DeleteServer)
+		}
+
 		// System monitoring
 		monitor := protected.Group("/monitor")
 		{
@@ -108,6 +314,35 @@ func main() {
 			monitor.GET("/api/processes", monitorHandler.GetProcesses) // HTMX endpoint
 		}
 
+		// Cron job management
+		cron := protected.Group("/cron")
+		cron.Use(middleware.RequireAdmin())
+		{
+			cron.GET("/", cronHandler.ListEntries)
+			cron.GET("/api/preview", cronHandler.PreviewSchedule) // HTMX endpoint
+			cron.GET("/create", cronHandler.ShowCreateEntry)
+			cron.POST("/create", cronHandler.CreateEntry)
+			cron.GET("/edit", cronHandler.ShowEditEntry)
+			cron.POST("/edit", cronHandler.UpdateEntry)
+			cron.POST("/toggle", cronHandler.ToggleEntry)
+			cron.POST("/delete", cronHandler.DeleteEntry)
+		}
+
+		// Docker container management
+		containers := protected.Group("/containers")
+		containers.Use(middleware.RequireAdmin())
+		{
+			containers.GET("/", dockerHandler.ListContainers)
+			containers.GET("/api/list", dockerHandler.GetContainers) // HTMX endpoint
+			containers.GET("/:id", dockerHandler.ShowContainer)
+			containers.GET("/:id/logs", dockerHandler.ShowLogs)
+			containers.GET("/:id/logs/stream", dockerHandler.StreamLogs) // Server-sent events
+			containers.POST("/:id/start", dockerHandler.StartContainer)
+			containers.POST("/:id/stop", dockerHandler.StopContainer)
+			containers.POST("/:id/restart", dockerHandler.RestartContainer)
+			containers.POST("/:id/remove", dockerHandler.RemoveContainer)
+		}
+
 		// Logout
 		protected.POST("/logout", userHandler.Logout)
 	}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"log"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/gorm"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionEnvReveal        = "env.reveal"
+	ActionEnvHookCreate    = "env.hook.create"
+	ActionEnvHookDelete    = "env.hook.delete"
+	ActionEnvSearch        = "env.search"
+	ActionPasswordReset    = "user.password_reset"
+	ActionSessionRevoke    = "user.session.revoke"
+	ActionUserUnlock       = "user.unlock"
+	ActionUserStatus       = "user.status"
+	ActionUserDelete       = "user.delete"
+	ActionUserRestore      = "user.restore"
+	ActionUserPurge        = "user.purge"
+	ActionUserInvite       = "user.invite"
+	ActionUserInviteRevoke = "user.invite.revoke"
+	ActionWebAuthnAdd      = "user.webauthn.add"
+	ActionWebAuthnDelete   = "user.webauthn.delete"
+	ActionWebAuthnReset    = "user.webauthn.reset"
+	ActionProfileUpdate    = "user.profile.update"
+	ActionEmailChange      = "user.email.change"
+	ActionAPITokenCreate   = "user.api_token.create"
+	ActionAPITokenDelete   = "user.api_token.delete"
+	ActionAccountLink      = "user.account.link"
+	ActionTeamCreate       = "team.create"
+	ActionTeamUpdate       = "team.update"
+	ActionTeamDelete       = "team.delete"
+	ActionTeamMember       = "team.member"
+	ActionTeamMemberRemove = "team.member.remove"
+)
+
+// Logger writes audit log entries
+type Logger struct {
+	db *gorm.DB
+}
+
+// NewLogger creates a new audit logger
+func NewLogger(db *gorm.DB) *Logger {
+	return &Logger{db: db}
+}
+
+// Record stores an audit entry
+func (l *Logger) Record(user *models.User, action, target, detail, ip string) error {
+	entry := models.AuditLog{
+		Action:    action,
+		Target:    target,
+		Detail:    detail,
+		IPAddress: ip,
+	}
+	if user != nil {
+		entry.UserID = &user.ID
+	}
+	if err := l.db.Create(&entry).Error; err != nil {
+		log.Printf("audit: failed to record %s on %s: %v", action, target, err)
+		return err
+	}
+	return nil
+}
+
+// Recent returns the latest entries for an action and target, newest first
+func (l *Logger) Recent(action, target string, limit int) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	err := l.db.Preload("User").
+		Where("action = ? AND target = ?", action, target).
+		Order("id DESC").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// apiTokenPrefix starts every API token so that leaked tokens are easy
+	// to recognise
+	apiTokenPrefix = "sysara_"
+	// apiTokenTouchInterval limits how often the last use of a token is saved
+	apiTokenTouchInterval = time.Minute
+)
+
+// ErrInvalidAPIToken is returned for unknown and expired API tokens
+var ErrInvalidAPIToken = errors.New("invalid or expired API token")
+
+// BearerToken returns the API token sent in the Authorization header of r,
+// or "" if there is none
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// CreateAPIToken issues a personal API token for user. The token is only
+// returned here; a nil expiresAt never expires.
+func (s *AuthService) CreateAPIToken(user *models.User, name string, expiresAt *time.Time) (*models.APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("name is required")
+	}
+	secret, err := inviteToken()
+	if err != nil {
+		return nil, "", err
+	}
+	token := apiTokenPrefix + secret
+	apiToken := models.APIToken{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    token[:len(apiTokenPrefix)+6],
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.db.Create(&apiToken).Error; err != nil {
+		return nil, "", err
+	}
+	return &apiToken, token, nil
+}
+
+// APITokens returns the API tokens of a user, newest first
+func (s *AuthService) APITokens(userID uint) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteAPIToken revokes an API token of a user
+func (s *AuthService) DeleteAPIToken(userID, id uint) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&token).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Delete(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// apiTokenUser returns the active user an API token belongs to and records
+// its use
+func (s *AuthService) apiTokenUser(token, ip string) (*models.User, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, ErrInvalidAPIToken
+	}
+	var apiToken models.APIToken
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&apiToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIToken
+		}
+		return nil, err
+	}
+	if apiToken.Expired() {
+		return nil, ErrInvalidAPIToken
+	}
+
+	var user models.User
+	if err := s.db.First(&user, apiToken.UserID).Error; err != nil {
+		return nil, err
+	}
+	if err := accountError(&user); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) > apiTokenTouchInterval || apiToken.LastUsedIP != ip {
+		s.db.Model(&apiToken).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+	return &user, nil
+}
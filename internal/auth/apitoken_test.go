@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestNewPasswordRevokesAPITokens(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sysara.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Session{}, &models.PasswordHistory{}, &models.APIToken{}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPasswordPolicy(8, 1, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthService(db, NewSessionStore(db, time.Hour, time.Hour), Lockout{}, policy, true)
+
+	users := []models.User{
+		{Email: "user@example.com", Name: "User", Role: models.RoleUser},
+		{Email: "other@example.com", Name: "Other", Role: models.RoleUser},
+	}
+	tokens := make([]string, len(users))
+	for i := range users {
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, tokens[i], err = auth.CreateAPIToken(&users[i], "deploy", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := auth.apiTokenUser(tokens[0], "192.0.2.1"); err != nil {
+		t.Fatalf("the token does not work before the change: %v", err)
+	}
+
+	if err := auth.SetPassword(&users[0], "New-Passw0rd!"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.apiTokenUser(tokens[0], "192.0.2.1"); !errors.Is(err, ErrInvalidAPIToken) {
+		t.Errorf("token after a password change: got %v, want ErrInvalidAPIToken", err)
+	}
+	// Tokens of other users are kept
+	if _, err := auth.apiTokenUser(tokens[1], "192.0.2.1"); err != nil {
+		t.Errorf("the token of another user was revoked: %v", err)
+	}
+}
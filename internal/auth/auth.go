@@ -3,28 +3,61 @@ package auth
 import (
 	"errors"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/alpemreelmas/sysara/internal/models"
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/sessions"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// sessionName is the name of the session cookie
+const sessionName = "sysara-session"
+
 // AuthService handles authentication operations
 type AuthService struct {
-	db    *gorm.DB
-	store sessions.Store
+	db      *gorm.DB
+	store   *SessionStore
+	lockout Lockout
+	policy  *PasswordPolicy
+	byIP    *Throttle
+	unknown *unknownLogins
+
+	localPasswords bool            // users can sign in with a Sysara password
+	authenticators []Authenticator // external identity sources tried in order
+
+	setupMu    sync.Mutex
+	setupDone  atomic.Bool
+	setupToken string // hash of the token the setup wizard requires
+	claimToken string // hash of the token that claims the default account
 }
 
-// NewAuthService creates a new authentication service
-func NewAuthService(db *gorm.DB, store sessions.Store) *AuthService {
+// NewAuthService creates a new authentication service. Sign-ins with a
+// password are checked against the authenticators in order for users who
+// do not have a Sysara password.
+func NewAuthService(db *gorm.DB, store *SessionStore, lockout Lockout, policy *PasswordPolicy, localPasswords bool, authenticators ...Authenticator) *AuthService {
+	// Hash once up front so the first unknown email is not slower than a wrong password
+	dummyHash()
 	return &AuthService{
-		db:    db,
-		store: store,
+		db:      db,
+		store:   store,
+		lockout: lockout,
+		policy:  policy,
+		byIP:    NewThrottle(ipFreeFailures, backoffBase, backoffMax),
+		unknown: &unknownLogins{logins: map[string]*models.User{}},
+
+		localPasswords: localPasswords,
+		authenticators: authenticators,
 	}
 }
 
+// LocalPasswords reports whether users can sign in with a Sysara password
+func (s *AuthService) LocalPasswords() bool {
+	return s.localPasswords
+}
+
 // HashPassword hashes a password using bcrypt
 func (s *AuthService) HashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -39,7 +72,8 @@ func (s *AuthService) VerifyPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-// RegisterUser creates a new user with hashed password
+// RegisterUser creates a new user with hashed password. The password must
+// satisfy the password policy.
 func (s *AuthService) RegisterUser(email, name, password string) (*models.User, error) {
 	// Check if user already exists
 	var existingUser models.User
@@ -47,6 +81,10 @@ func (s *AuthService) RegisterUser(email, name, password string) (*models.User,
 		return nil, errors.New("user with this email already exists")
 	}
 
+	if err := s.policy.Check(password, email, name); err != nil {
+		return nil, err
+	}
+
 	// Hash password
 	hashedPassword, err := s.HashPassword(password)
 	if err != nil {
@@ -60,47 +98,92 @@ func (s *AuthService) RegisterUser(email, name, password string) (*models.User,
 		Password: hashedPassword,
 	}
 
-	if err := s.db.Create(&user).Error; err != nil {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return s.remember(tx, user.ID, hashedPassword)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
 
-// AuthenticateUser verifies user credentials
-func (s *AuthService) AuthenticateUser(email, password string) (*models.User, error) {
+// AuthenticateUser verifies user credentials. Failures are throttled per
+// IP address and per account, and lock the account after too many in a row.
+// Users without a Sysara password are checked by the authenticators.
+func (s *AuthService) AuthenticateUser(email, password, ip string) (*models.User, error) {
+	if !s.localPasswords && len(s.authenticators) == 0 {
+		return nil, ErrPasswordLoginDisabled
+	}
+	if s.byIP.Wait(ip) > 0 {
+		return nil, ErrTooManyAttempts
+	}
+
 	var user models.User
 	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid email or password")
+			return s.authenticateExternal(email, password, ip, nil)
 		}
 		return nil, err
 	}
+	if user.IsExternal() {
+		return s.authenticateExternal(email, password, ip, &user)
+	}
 
-	if err := s.VerifyPassword(user.Password, password); err != nil {
-		return nil, errors.New("invalid email or password")
+	if s.accountWait(&user) > 0 {
+		// Spend the time of a password check, as for unknown emails
+		s.VerifyPassword(dummyHash(), password)
+		s.byIP.Fail(ip)
+		s.recordAttempt(&user.ID, email, ip, false)
+		return nil, ErrTooManyAttempts
 	}
 
+	// Inactive accounts fail like a wrong password, so that the response
+	// does not tell whether the password was right
+	if err := s.VerifyPassword(user.Password, password); err != nil || !s.localPasswords || accountError(&user) != nil {
+		s.byIP.Fail(ip)
+		if err := s.failLogin(&user, ip); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	s.byIP.Reset(ip)
+	if err := s.succeedLogin(&user, ip); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
-// Login creates a user session
+// Login creates a user session. A new session ID is issued so that an
+// ID known before login cannot be used afterwards.
 func (s *AuthService) Login(c *gin.Context, user *models.User) error {
-	session, err := s.store.Get(c.Request, "sysara-session")
+	session, err := s.store.Get(c.Request, sessionName)
 	if err != nil {
 		return err
 	}
 
+	if session.ID != "" {
+		if err := s.store.Delete(session.ID); err != nil {
+			return err
+		}
+		session.ID = ""
+	}
+	session.Values = make(map[interface{}]interface{})
 	session.Values["user_id"] = user.ID
 	session.Values["user_email"] = user.Email
 	session.Values["user_name"] = user.Name
+	session.Values["issued_at"] = time.Now().UnixNano()
 
 	return session.Save(c.Request, c.Writer)
 }
 
 // Logout destroys the user session
 func (s *AuthService) Logout(c *gin.Context) error {
-	session, err := s.store.Get(c.Request, "sysara-session")
+	session, err := s.store.Get(c.Request, sessionName)
 	if err != nil {
 		return err
 	}
@@ -112,9 +195,15 @@ func (s *AuthService) Logout(c *gin.Context) error {
 	return session.Save(c.Request, c.Writer)
 }
 
-// GetCurrentUser returns the currently logged-in user
+// GetCurrentUser returns the currently logged-in user. Requests with an
+// API token in the Authorization header are authenticated by the token
+// only, never by the session cookie.
 func (s *AuthService) GetCurrentUser(c *gin.Context) (*models.User, error) {
-	session, err := s.store.Get(c.Request, "sysara-session")
+	if token := BearerToken(c.Request); token != "" {
+		return s.apiTokenUser(token, c.ClientIP())
+	}
+
+	session, err := s.store.Get(c.Request, sessionName)
 	if err != nil {
 		return nil, err
 	}
@@ -128,19 +217,39 @@ func (s *AuthService) GetCurrentUser(c *gin.Context) (*models.User, error) {
 	if err := s.db.First(&user, userID).Error; err != nil {
 		return nil, err
 	}
+	if err := accountError(&user); err != nil {
+		return nil, err
+	}
+
+	// Sessions created before a password change are no longer valid
+	if user.PasswordChangedAt != nil {
+		issuedAt, _ := session.Values["issued_at"].(int64)
+		if issuedAt < user.PasswordChangedAt.UnixNano() {
+			return nil, errors.New("session expired")
+		}
+	}
 
 	return &user, nil
 }
 
-// IsAuthenticated checks if user is logged in
-func (s *AuthService) IsAuthenticated(c *gin.Context) bool {
-	session, err := s.store.Get(c.Request, "sysara-session")
+// CurrentSession returns the stored session of the request
+func (s *AuthService) CurrentSession(c *gin.Context) (*models.Session, error) {
+	session, err := s.store.Get(c.Request, sessionName)
 	if err != nil {
-		return false
+		return nil, err
 	}
+	return s.store.Current(session.ID)
+}
 
-	_, ok := session.Values["user_id"].(uint)
-	return ok
+// Sessions returns the session store
+func (s *AuthService) Sessions() *SessionStore {
+	return s.store
+}
+
+// IsAuthenticated checks if user is logged in
+func (s *AuthService) IsAuthenticated(c *gin.Context) bool {
+	_, err := s.GetCurrentUser(c)
+	return err == nil
 }
 
 // RequireAuth middleware function
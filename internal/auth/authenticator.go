@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/oidc"
+	"gorm.io/gorm"
+)
+
+// Errors of external identity sources
+var (
+	ErrUnknownUser   = errors.New("user is not known to this identity source")
+	ErrAccessDenied  = errors.New("your account is not allowed to use Sysara")
+	ErrNoEmail       = errors.New("the identity source did not return an email address")
+	ErrAmbiguousUser = errors.New("the sign-in name matches more than one user")
+	ErrAlreadyLinked = errors.New("your account already signs in with an identity provider")
+	ErrIdentityInUse = errors.New("this identity is already linked to another account")
+)
+
+// Authenticator checks a sign-in name and password against an identity
+// source other than the Sysara database, such as a directory. It returns
+// ErrUnknownUser for users it does not know, so that the next authenticator
+// of the chain is tried, and ErrInvalidCredentials for a wrong password.
+type Authenticator interface {
+	// Provider is stored as the AuthProvider of users it signs in
+	Provider() string
+	Authenticate(ctx context.Context, login, password string) (*Identity, error)
+}
+
+// Identity is a user as described by an external identity source
+type Identity struct {
+	Provider      string
+	Subject       string // stable ID at the source
+	Email         string
+	EmailVerified bool // the source vouches for the email, so it may replace the stored one
+	Name          string
+	Role          string // "" keeps the current role
+}
+
+// GroupMapping maps external groups to access and roles
+type GroupMapping struct {
+	AdminGroups   []string // members become administrators, others users; empty leaves roles alone
+	AllowedGroups []string // only members may sign in; empty allows everyone
+}
+
+// Role returns the role for a member of groups, or "" to keep the current one
+func (m GroupMapping) Role(groups []string) string {
+	if len(m.AdminGroups) == 0 {
+		return ""
+	}
+	if memberOf(groups, m.AdminGroups) {
+		return models.RoleAdmin
+	}
+	return models.RoleUser
+}
+
+// Allowed reports whether a member of groups may sign in
+func (m GroupMapping) Allowed(groups []string) bool {
+	return len(m.AllowedGroups) == 0 || memberOf(groups, m.AllowedGroups)
+}
+
+// memberOf reports whether groups contains any of wanted
+func memberOf(groups, wanted []string) bool {
+	for _, group := range groups {
+		for _, name := range wanted {
+			if strings.EqualFold(group, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authenticateExternal signs in with the authenticator chain. known is the
+// local user with that email, if any; only its own provider is asked then.
+// Failures of unknown names are throttled and locked like those of
+// accounts, with the same errors and timing.
+func (s *AuthService) authenticateExternal(login, password, ip string, known *models.User) (*models.User, error) {
+	failures := known
+	if known == nil {
+		failures = s.unknownLogin(login)
+	}
+	if s.accountWait(failures) > 0 {
+		s.VerifyPassword(dummyHash(), password)
+		s.byIP.Fail(ip)
+		if known != nil {
+			s.recordAttempt(&known.ID, login, ip, false)
+		} else {
+			s.recordAttempt(nil, login, ip, false)
+		}
+		return nil, ErrTooManyAttempts
+	}
+
+	for _, authenticator := range s.authenticators {
+		if known != nil && known.AuthProvider != authenticator.Provider() {
+			continue
+		}
+		identity, err := authenticator.Authenticate(context.Background(), login, password)
+		switch {
+		case errors.Is(err, ErrUnknownUser):
+			continue
+		case errors.Is(err, ErrInvalidCredentials):
+			s.byIP.Fail(ip)
+			if known != nil {
+				if err := s.failLogin(known, ip); err != nil {
+					return nil, err
+				}
+			} else {
+				s.failUnknownLogin(login)
+				s.recordAttempt(nil, login, ip, false)
+			}
+			return nil, ErrInvalidCredentials
+		case errors.Is(err, ErrAccessDenied):
+			s.byIP.Fail(ip)
+			s.recordAttempt(nil, login, ip, false)
+			return nil, err
+		case err != nil:
+			return nil, err
+		}
+
+		user, err := s.syncExternalUser(context.Background(), identity)
+		if err != nil {
+			return nil, err
+		}
+		// Inactive accounts fail like a wrong password, as in AuthenticateUser
+		if accountError(user) != nil {
+			s.byIP.Fail(ip)
+			if err := s.failLogin(user, ip); err != nil {
+				return nil, err
+			}
+			return nil, ErrInvalidCredentials
+		}
+		s.byIP.Reset(ip)
+		if known == nil {
+			s.resetUnknownLogin(login)
+		}
+		if err := s.succeedLogin(user, ip); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	// Nobody knows the user. Spend the same time as for a wrong password
+	// so that response times do not reveal which emails have an account.
+	s.VerifyPassword(dummyHash(), password)
+	s.byIP.Fail(ip)
+	if known != nil {
+		if err := s.failLogin(known, ip); err != nil {
+			return nil, err
+		}
+	} else {
+		s.failUnknownLogin(login)
+		s.recordAttempt(nil, login, ip, false)
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// syncExternalUser returns the user for an external identity and updates
+// its name, email and role. A user seen before is found by subject;
+// otherwise a new account is created. Existing accounts are never taken
+// over by email, their owner links them with LinkExternalUser.
+func (s *AuthService) syncExternalUser(ctx context.Context, identity *Identity) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("auth_provider = ? AND external_id = ?", identity.Provider, identity.Subject).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		found := err == nil
+
+		if !found {
+			if identity.Email == "" {
+				return ErrNoEmail
+			}
+			var count int64
+			if err := tx.Model(&models.User{}).Where("email = ?", identity.Email).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w; sign in to Sysara and link this identity from your profile", ErrUserExists)
+			}
+			// The account gets a random password nobody knows
+			password, err := oidc.RandomString()
+			if err != nil {
+				return err
+			}
+			hashedPassword, err := s.HashPassword(password)
+			if err != nil {
+				return err
+			}
+			user = models.User{
+				Email:    identity.Email,
+				Name:     externalName(identity),
+				Password: hashedPassword,
+				Role:     models.RoleUser,
+			}
+		}
+
+		user.AuthProvider = identity.Provider
+		user.ExternalID = identity.Subject
+		user.MustChangePassword = false
+		if name := strings.TrimSpace(identity.Name); name != "" {
+			user.Name = name
+		}
+		if identity.Email != "" && identity.EmailVerified && identity.Email != user.Email {
+			var count int64
+			if err := tx.Model(&models.User{}).Where("email = ? AND id <> ?", identity.Email, user.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				user.Email = identity.Email
+			}
+		}
+		if identity.Role != "" {
+			user.Role = identity.Role
+		}
+		return tx.Save(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// LinkDirectoryAccount links the Sysara account of user to the directory
+// account with login and password, after which user signs in with the
+// directory. The directory password proves that both accounts belong to
+// the same person.
+func (s *AuthService) LinkDirectoryAccount(user *models.User, login, password, ip string) error {
+	if user.IsExternal() {
+		return ErrAlreadyLinked
+	}
+	if s.byIP.Wait(ip) > 0 {
+		return ErrTooManyAttempts
+	}
+
+	for _, authenticator := range s.authenticators {
+		identity, err := authenticator.Authenticate(context.Background(), login, password)
+		switch {
+		case errors.Is(err, ErrUnknownUser):
+			continue
+		case errors.Is(err, ErrInvalidCredentials):
+			s.byIP.Fail(ip)
+			return ErrInvalidCredentials
+		case err != nil:
+			return err
+		}
+		s.byIP.Reset(ip)
+		return s.LinkExternalUser(context.Background(), user, identity)
+	}
+
+	s.byIP.Fail(ip)
+	return ErrInvalidCredentials
+}
+
+// LinkExternalUser makes user sign in with an external identity it has
+// proven to own. Its name, email and role are synced at the next sign-in.
+func (s *AuthService) LinkExternalUser(ctx context.Context, user *models.User, identity *Identity) error {
+	if user.IsExternal() {
+		return ErrAlreadyLinked
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("auth_provider = ? AND external_id = ? AND id <> ?", identity.Provider, identity.Subject, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrIdentityInUse
+		}
+		user.AuthProvider = identity.Provider
+		user.ExternalID = identity.Subject
+		user.MustChangePassword = false
+		return tx.Model(user).Updates(map[string]interface{}{
+			"auth_provider":        user.AuthProvider,
+			"external_id":          user.ExternalID,
+			"must_change_password": false,
+		}).Error
+	})
+}
+
+// externalName returns the display name of an external user
+func externalName(identity *Identity) string {
+	if name := strings.TrimSpace(identity.Name); name != "" {
+		return name
+	}
+	if at := strings.Index(identity.Email, "@"); at > 0 {
+		return identity.Email[:at]
+	}
+	return identity.Email
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+// csrfKey is the session value holding the CSRF token
+const csrfKey = "csrf_token"
+
+// csrfCookieName is the cookie holding the CSRF token of visitors without a
+// session
+const csrfCookieName = "sysara-csrf"
+
+// csrfSecret signs the CSRF cookie. It changes on restart, after which
+// forms opened before have to be reloaded.
+var csrfSecret = func() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}()
+
+// CSRFToken returns the CSRF token of the current session. Visitors without
+// a session, such as on the login page, get the token in a signed cookie
+// instead (double-submit), so that no session is stored for them.
+func (s *AuthService) CSRFToken(c *gin.Context) (string, error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return "", err
+	}
+	if token, ok := session.Values[csrfKey].(string); ok && token != "" {
+		return token, nil
+	}
+
+	token, fromCookie := csrfCookieToken(c.Request)
+	if session.IsNew {
+		if !fromCookie {
+			token = newCSRFToken()
+			http.SetCookie(c.Writer, s.csrfCookie(signCSRFToken(token), 0))
+		}
+		return token, nil
+	}
+
+	// A session started, e.g. by signing in. It takes over the token of the
+	// cookie so that forms open in other tabs keep working.
+	if !fromCookie {
+		token = newCSRFToken()
+	}
+	session.Values[csrfKey] = token
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		return "", err
+	}
+	if _, err := c.Request.Cookie(csrfCookieName); err == nil {
+		http.SetCookie(c.Writer, s.csrfCookie("", -1))
+	}
+	return token, nil
+}
+
+// ValidCSRFToken reports whether a submitted token matches the session token
+func ValidCSRFToken(expected, submitted string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(submitted)) == 1
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signCSRFToken returns the cookie value for a token
+func signCSRFToken(token string) string {
+	mac := hmac.New(sha256.New, csrfSecret)
+	mac.Write([]byte(token))
+	return token + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// csrfCookieToken returns the token of the CSRF cookie if its signature is
+// valid
+func csrfCookieToken(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil {
+		return "", false
+	}
+	token, _, ok := strings.Cut(cookie.Value, ".")
+	if !ok || token == "" || !hmac.Equal([]byte(cookie.Value), []byte(signCSRFToken(token))) {
+		return "", false
+	}
+	return token, true
+}
+
+// csrfCookie returns the CSRF cookie with the options of the session cookie.
+// It ends with the browser session unless maxAge is negative, which
+// deletes it.
+func (s *AuthService) csrfCookie(value string, maxAge int) *http.Cookie {
+	opts := *s.store.Options
+	opts.MaxAge = maxAge
+	return sessions.NewCookie(csrfCookieName, value, &opts)
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newCSRFTest(t *testing.T) (*AuthService, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sysara.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Session{}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPasswordPolicy(8, 1, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthService(db, NewSessionStore(db, time.Hour, time.Hour), Lockout{}, policy, true), db
+}
+
+// browse runs fn for a request with the cookies of jar and stores the
+// cookies of the response in jar, like a browser
+func browse(jar map[string]*http.Cookie, fn func(c *gin.Context)) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/login", nil)
+	for _, cookie := range jar {
+		c.Request.AddCookie(cookie)
+	}
+	fn(c)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(jar, cookie.Name)
+		} else {
+			jar[cookie.Name] = cookie
+		}
+	}
+	return w
+}
+
+func TestCSRFTokenWithoutSession(t *testing.T) {
+	auth, db := newCSRFTest(t)
+	jar := map[string]*http.Cookie{}
+
+	var token string
+	var err error
+	browse(jar, func(c *gin.Context) { token, err = auth.CSRFToken(c) })
+	if err != nil || token == "" {
+		t.Fatalf("CSRFToken: %q, %v", token, err)
+	}
+	if jar[csrfCookieName] == nil || jar[sessionName] != nil {
+		t.Errorf("cookies = %v, want only the CSRF cookie", jar)
+	}
+
+	// The cookie keeps the token across requests
+	var again string
+	w := browse(jar, func(c *gin.Context) { again, err = auth.CSRFToken(c) })
+	if err != nil || again != token {
+		t.Errorf("second request: %q, %v, want %q", again, err, token)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("the CSRF cookie was set again")
+	}
+
+	var sessions int64
+	db.Model(&models.Session{}).Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("%d sessions were stored for an anonymous visitor", sessions)
+	}
+}
+
+func TestCSRFCookieIsSigned(t *testing.T) {
+	auth, _ := newCSRFTest(t)
+
+	// A cookie planted by someone else, e.g. from a sibling subdomain,
+	// does not set the token
+	for _, value := range []string{"chosen-token", "chosen-token.", "chosen-token" + signCSRFToken("other")[len("other"):]} {
+		jar := map[string]*http.Cookie{csrfCookieName: {Name: csrfCookieName, Value: value}}
+		var token string
+		browse(jar, func(c *gin.Context) { token, _ = auth.CSRFToken(c) })
+		if token == "" || token == "chosen-token" {
+			t.Errorf("cookie %q: token %q", value, token)
+		}
+		if jar[csrfCookieName].Value == value {
+			t.Errorf("cookie %q was kept", value)
+		}
+	}
+}
+
+func TestCSRFTokenMovesIntoSession(t *testing.T) {
+	auth, db := newCSRFTest(t)
+	user := models.User{Email: "jane@example.com", Name: "Jane", Role: models.RoleUser}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	jar := map[string]*http.Cookie{}
+
+	var token string
+	browse(jar, func(c *gin.Context) { token, _ = auth.CSRFToken(c) })
+
+	// The login form is submitted with the token of the cookie
+	var err error
+	browse(jar, func(c *gin.Context) {
+		if submitted, _ := auth.CSRFToken(c); submitted != token {
+			t.Errorf("token at login = %q, want %q", submitted, token)
+		}
+		err = auth.Login(c, &user)
+	})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	// The session takes the token over and the cookie is removed
+	var signedIn string
+	browse(jar, func(c *gin.Context) { signedIn, err = auth.CSRFToken(c) })
+	if err != nil || signedIn != token {
+		t.Errorf("token after login: %q, %v, want %q", signedIn, err, token)
+	}
+	if jar[csrfCookieName] != nil || jar[sessionName] == nil {
+		t.Errorf("cookies after login = %v", jar)
+	}
+	var again string
+	browse(jar, func(c *gin.Context) { again, _ = auth.CSRFToken(c) })
+	if again != token {
+		t.Errorf("token from the session = %q, want %q", again, token)
+	}
+
+	var sessions int64
+	db.Model(&models.Session{}).Count(&sessions)
+	if sessions != 1 {
+		t.Errorf("%d sessions stored, want 1", sessions)
+	}
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/gorm"
+)
+
+// Email change errors
+var (
+	ErrInvalidEmail       = errors.New("enter a valid email address")
+	ErrEmailUnchanged     = errors.New("this is already your email address")
+	ErrInvalidEmailChange = errors.New("this confirmation link is invalid or has expired")
+)
+
+// RequestEmailChange starts changing the email address of user. The
+// address changes once the returned token is confirmed with
+// ConfirmEmailChange; an earlier pending change is replaced.
+func (s *AuthService) RequestEmailChange(user *models.User, email string, ttl time.Duration) (*models.EmailChange, string, error) {
+	email = strings.TrimSpace(email)
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return nil, "", ErrInvalidEmail
+	}
+	if strings.EqualFold(email, user.Email) {
+		return nil, "", ErrEmailUnchanged
+	}
+	var count int64
+	if err := s.db.Model(&models.User{}).Where("email = ? AND id <> ?", email, user.ID).Count(&count).Error; err != nil {
+		return nil, "", err
+	}
+	if count > 0 {
+		return nil, "", ErrUserExists
+	}
+
+	token, err := inviteToken()
+	if err != nil {
+		return nil, "", err
+	}
+	change := models.EmailChange{
+		UserID:    user.ID,
+		Email:     email,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.EmailChange{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&change).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &change, token, nil
+}
+
+// PendingEmailChange returns the unconfirmed email change of a user, or nil
+// if there is none
+func (s *AuthService) PendingEmailChange(userID uint) (*models.EmailChange, error) {
+	var change models.EmailChange
+	err := s.db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).First(&change).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// CancelEmailChange drops the pending email change of a user
+func (s *AuthService) CancelEmailChange(userID uint) error {
+	return s.db.Where("user_id = ?", userID).Delete(&models.EmailChange{}).Error
+}
+
+// ConfirmEmailChange consumes a confirmation token and sets the new email
+// address of its user. It returns the user and their previous address.
+func (s *AuthService) ConfirmEmailChange(token string) (*models.User, string, error) {
+	var user models.User
+	var previous string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var change models.EmailChange
+		if err := tx.Where("token_hash = ? AND expires_at > ?", hashToken(token), time.Now()).First(&change).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidEmailChange
+			}
+			return err
+		}
+		// Deleting the change first makes the token single-use under concurrency
+		result := tx.Delete(&models.EmailChange{}, change.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidEmailChange
+		}
+
+		if err := tx.First(&user, change.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidEmailChange
+			}
+			return err
+		}
+		if err := accountError(&user); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ? AND id <> ?", change.Email, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUserExists
+		}
+
+		previous = user.Email
+		user.Email = change.Email
+		return tx.Model(&user).Update("email", change.Email).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, previous, nil
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/gorm"
+)
+
+// Invitation errors
+var (
+	ErrInvalidInvitation = errors.New("this invitation is invalid or has expired")
+	ErrUserExists        = errors.New("a user with this email already exists")
+)
+
+// CreateInvitation invites email to create an account with role. A pending
+// invitation for the same email is replaced.
+func (s *AuthService) CreateInvitation(email, role string, invitedBy *models.User, ttl time.Duration) (*models.Invitation, string, error) {
+	email = strings.TrimSpace(email)
+	var count int64
+	if err := s.db.Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return nil, "", err
+	}
+	if count > 0 {
+		return nil, "", ErrUserExists
+	}
+
+	token, err := inviteToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	invitation := models.Invitation{
+		Email:       email,
+		Role:        role,
+		TokenHash:   hashToken(token),
+		InvitedByID: invitedBy.ID,
+		ExpiresAt:   now.Add(ttl),
+		SentAt:      now,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("email = ? AND accepted_at IS NULL", email).Delete(&models.Invitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &invitation, token, nil
+}
+
+// ResendInvitation issues a new token for a pending invitation and extends
+// its expiry. The previous link stops working.
+func (s *AuthService) ResendInvitation(id uint, ttl time.Duration) (*models.Invitation, string, error) {
+	var invitation models.Invitation
+	if err := s.db.Where("id = ? AND accepted_at IS NULL", id).First(&invitation).Error; err != nil {
+		return nil, "", err
+	}
+	token, err := inviteToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	if err := s.db.Model(&invitation).Updates(map[string]interface{}{
+		"token_hash": hashToken(token),
+		"expires_at": now.Add(ttl),
+		"sent_at":    now,
+	}).Error; err != nil {
+		return nil, "", err
+	}
+	return &invitation, token, nil
+}
+
+// RevokeInvitation deletes a pending invitation
+func (s *AuthService) RevokeInvitation(id uint) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := s.db.Where("id = ? AND accepted_at IS NULL", id).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Delete(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// PendingInvitations returns the invitations not accepted yet, including expired ones
+func (s *AuthService) PendingInvitations() ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := s.db.Preload("InvitedBy").Where("accepted_at IS NULL").Order("created_at DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+// InvitationByToken returns the pending, unexpired invitation for a token
+func (s *AuthService) InvitationByToken(token string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := s.db.Where("token_hash = ? AND accepted_at IS NULL AND expires_at > ?", hashToken(token), time.Now()).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// AcceptInvitation creates the invited account. The password must satisfy
+// the password policy. An invitation can only be accepted once.
+func (s *AuthService) AcceptInvitation(token, name, password string) (*models.User, error) {
+	invitation, err := s.InvitationByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, &PolicyError{"Enter your name"}
+	}
+	if err := s.policy.Check(password, invitation.Email, name); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Email:    invitation.Email,
+		Name:     strings.TrimSpace(name),
+		Password: hashedPassword,
+		Role:     invitation.Role,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// The conditional update makes the invitation single-use under concurrency
+		result := tx.Model(&models.Invitation{}).Where("id = ? AND accepted_at IS NULL", invitation.ID).Update("accepted_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidInvitation
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ?", invitation.Email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUserExists
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return s.remember(tx, user.ID, hashedPassword)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// inviteToken returns a random invite token
+func inviteToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
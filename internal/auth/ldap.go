@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alpemreelmas/sysara/internal/ldap"
+	"github.com/alpemreelmas/sysara/internal/models"
+)
+
+// ldapTimeout limits each step of a directory sign-in
+const ldapTimeout = 10 * time.Second
+
+// LDAPConfig describes how users are found and checked in a directory
+type LDAPConfig struct {
+	URL                string // ldap://host:389 or ldaps://host:636
+	StartTLS           bool   // upgrade ldap:// connections to TLS before binding
+	InsecureSkipVerify bool   // accept any server certificate, for testing only
+	BindDN             string // account used to search for users; empty searches anonymously
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // {username} is replaced by the escaped sign-in name
+	IDAttribute        string // stable user ID such as entryUUID or objectGUID; empty uses the DN
+	EmailAttribute     string
+	NameAttribute      string
+	GroupAttribute     string // attribute listing the groups of a user, such as memberOf
+	TrustEmail         bool   // the directory controls who can set an email, so it replaces the stored one
+	Groups             GroupMapping
+}
+
+// LDAPAuthenticator signs users in with a directory such as OpenLDAP or
+// Active Directory. The user is searched for with the service account and
+// the password is checked by binding as the user.
+type LDAPAuthenticator struct {
+	config LDAPConfig
+	tls    *tls.Config
+}
+
+// NewLDAPAuthenticator creates a directory authenticator
+func NewLDAPAuthenticator(config LDAPConfig) *LDAPAuthenticator {
+	if config.UserFilter == "" {
+		config.UserFilter = "(|(uid={username})(mail={username}))"
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+	if config.NameAttribute == "" {
+		config.NameAttribute = "cn"
+	}
+	return &LDAPAuthenticator{
+		config: config,
+		tls:    &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify},
+	}
+}
+
+// Provider implements Authenticator
+func (a *LDAPAuthenticator) Provider() string {
+	return models.AuthLDAP
+}
+
+// Authenticate implements Authenticator
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, login, password string) (*Identity, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return nil, ErrUnknownUser
+	}
+
+	conn, err := a.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	attributes := []string{a.config.EmailAttribute, a.config.NameAttribute}
+	if a.config.IDAttribute != "" {
+		attributes = append(attributes, a.config.IDAttribute)
+	}
+	if a.config.GroupAttribute != "" {
+		attributes = append(attributes, a.config.GroupAttribute)
+	}
+	entries, err := conn.Search(ldap.SearchRequest{
+		BaseDN:     a.config.BaseDN,
+		Scope:      ldap.ScopeSubtree,
+		Filter:     strings.ReplaceAll(a.config.UserFilter, "{username}", ldap.EscapeFilter(login)),
+		Attributes: attributes,
+		SizeLimit:  2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch len(entries) {
+	case 0:
+		return nil, ErrUnknownUser
+	case 1:
+	default:
+		return nil, ErrAmbiguousUser
+	}
+	entry := entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if errors.Is(err, ldap.ErrInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	groups := ldapGroups(entry.Values(a.config.GroupAttribute))
+	if !a.config.Groups.Allowed(groups) {
+		return nil, ErrAccessDenied
+	}
+
+	subject := entry.DN
+	if a.config.IDAttribute != "" {
+		if id := entry.Value(a.config.IDAttribute); id != "" {
+			subject = id
+			if !utf8.ValidString(id) {
+				// Binary IDs such as the objectGUID of Active Directory
+				subject = hex.EncodeToString([]byte(id))
+			}
+		}
+	}
+	return &Identity{
+		Provider:      models.AuthLDAP,
+		Subject:       subject,
+		Email:         entry.Value(a.config.EmailAttribute),
+		EmailVerified: a.config.TrustEmail,
+		Name:          entry.Value(a.config.NameAttribute),
+		Role:          a.config.Groups.Role(groups),
+	}, nil
+}
+
+// connect opens a connection, upgrades it to TLS when configured and binds
+// the service account
+func (a *LDAPAuthenticator) connect(ctx context.Context) (*ldap.Conn, error) {
+	conn, err := ldap.Dial(ctx, a.config.URL, ldapTimeout, a.tls)
+	if err != nil {
+		return nil, err
+	}
+	if a.config.StartTLS {
+		if err := conn.StartTLS(a.tls); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if a.config.BindDN != "" {
+		if err := conn.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// ldapGroups returns group DNs together with their common names, so that
+// groups can be configured either way
+func ldapGroups(dns []string) []string {
+	groups := make([]string, 0, 2*len(dns))
+	for _, dn := range dns {
+		groups = append(groups, dn)
+		first, _, _ := strings.Cut(dn, ",")
+		if _, name, ok := strings.Cut(first, "="); ok {
+			groups = append(groups, strings.TrimSpace(name))
+		}
+	}
+	return groups
+}
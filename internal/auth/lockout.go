@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Login errors
+var (
+	ErrInvalidCredentials    = errors.New("invalid email or password")
+	ErrTooManyAttempts       = errors.New("too many failed login attempts, please try again later")
+	ErrPasswordLoginDisabled = errors.New("password sign-in is disabled, use single sign-on")
+)
+
+const (
+	// ipFreeFailures is how many failures an IP address may have before
+	// its attempts are delayed
+	ipFreeFailures = 5
+	// accountFreeFailures is how many failures an account may have before
+	// its attempts are delayed
+	accountFreeFailures = 3
+	// backoffBase is the first delay, doubled by each further failure
+	backoffBase = time.Second
+	// backoffMax caps the delay
+	backoffMax = 15 * time.Minute
+	// attemptRetention is how long login attempts are kept
+	attemptRetention = 30 * 24 * time.Hour
+)
+
+// Lockout configures when accounts are locked after failed logins
+type Lockout struct {
+	Threshold int           // consecutive failures that lock the account, 0 disables locking
+	Duration  time.Duration // how long the account stays locked
+}
+
+var (
+	dummyHashOnce  sync.Once
+	dummyHashValue []byte
+)
+
+// dummyHash returns a bcrypt hash to compare against when the user does not exist
+func dummyHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHashValue, _ = bcrypt.GenerateFromPassword([]byte("sysara-dummy-password"), bcrypt.DefaultCost)
+	})
+	return string(dummyHashValue)
+}
+
+// accountWait returns how long the user has to wait before the next
+// attempt, because the account is locked or recently failed
+func (s *AuthService) accountWait(user *models.User) time.Duration {
+	if user.IsLocked() {
+		return time.Until(*user.LockedUntil)
+	}
+	if user.LastFailedLoginAt == nil {
+		return 0
+	}
+	wait := time.Until(user.LastFailedLoginAt.Add(Backoff(user.FailedLogins, accountFreeFailures, backoffBase, backoffMax)))
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// failLogin counts a wrong password and locks the account at the threshold.
+// The count is increased in the database and read back, so that parallel
+// attempts cannot overwrite each other's failures.
+func (s *AuthService) failLogin(user *models.User, ip string) error {
+	now := time.Now()
+	err := s.db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_logins":        gorm.Expr("failed_logins + 1"),
+		"last_failed_login_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	var failures int
+	if err := s.db.Model(&models.User{}).Where("id = ?", user.ID).Select("failed_logins").Scan(&failures).Error; err != nil {
+		return err
+	}
+	user.FailedLogins = failures
+	user.LastFailedLoginAt = &now
+
+	if until := s.lockUntil(failures, now); until != nil {
+		if err := s.db.Model(&models.User{}).Where("id = ?", user.ID).Update("locked_until", *until).Error; err != nil {
+			return err
+		}
+		user.LockedUntil = until
+		log.Printf("Account %s locked after %d failed logins", user.Email, failures)
+	}
+	s.recordAttempt(&user.ID, user.Email, ip, false)
+	return nil
+}
+
+// lockUntil returns until when an account with failures in a row is
+// locked, or nil if it is not
+func (s *AuthService) lockUntil(failures int, now time.Time) *time.Time {
+	if s.lockout.Threshold == 0 || failures < s.lockout.Threshold {
+		return nil
+	}
+	until := now.Add(s.lockout.Duration)
+	return &until
+}
+
+// unknownLogins counts the failed logins of names without an account like
+// those of accounts, so that throttling and locking do not reveal which
+// accounts exist
+type unknownLogins struct {
+	mu     sync.Mutex
+	logins map[string]*models.User
+}
+
+// unknownLogin returns the failed logins of a name without an account
+func (s *AuthService) unknownLogin(login string) *models.User {
+	s.unknown.mu.Lock()
+	defer s.unknown.mu.Unlock()
+
+	if user, ok := s.unknown.logins[strings.ToLower(login)]; ok {
+		copied := *user
+		return &copied
+	}
+	return &models.User{}
+}
+
+// failUnknownLogin counts a failed login of a name without an account
+func (s *AuthService) failUnknownLogin(login string) {
+	s.unknown.mu.Lock()
+	defer s.unknown.mu.Unlock()
+
+	now := time.Now()
+	for name, user := range s.unknown.logins {
+		// Forget names that are neither locked nor waiting any more
+		if !user.IsLocked() && now.Sub(*user.LastFailedLoginAt) > backoffMax {
+			delete(s.unknown.logins, name)
+		}
+	}
+	login = strings.ToLower(login)
+	user, ok := s.unknown.logins[login]
+	if !ok {
+		user = &models.User{}
+		s.unknown.logins[login] = user
+	}
+	user.FailedLogins++
+	user.LastFailedLoginAt = &now
+	if until := s.lockUntil(user.FailedLogins, now); until != nil {
+		user.LockedUntil = until
+	}
+}
+
+// resetUnknownLogin forgets the failed logins of a name
+func (s *AuthService) resetUnknownLogin(login string) {
+	s.unknown.mu.Lock()
+	defer s.unknown.mu.Unlock()
+	delete(s.unknown.logins, strings.ToLower(login))
+}
+
+// succeedLogin clears the failure count after a correct password
+func (s *AuthService) succeedLogin(user *models.User, ip string) error {
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := s.Unlock(user.ID); err != nil {
+			return err
+		}
+	}
+	s.recordAttempt(&user.ID, user.Email, ip, true)
+	return nil
+}
+
+// Unlock clears the failed login count and lock of a user
+func (s *AuthService) Unlock(userID uint) error {
+	return s.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"failed_logins":        0,
+		"last_failed_login_at": nil,
+		"locked_until":         nil,
+	}).Error
+}
+
+// LoginAttempts returns the most recent login attempts for a user
+func (s *AuthService) LoginAttempts(userID uint, limit int) ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
+
+// recordAttempt stores a login attempt and prunes old ones. Failing to
+// record does not fail the login.
+func (s *AuthService) recordAttempt(userID *uint, email, ip string, success bool) {
+	attempt := models.LoginAttempt{
+		UserID:    userID,
+		Email:     email,
+		IPAddress: ip,
+		Success:   success,
+	}
+	if err := s.db.Create(&attempt).Error; err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+		return
+	}
+	if err := s.db.Where("created_at < ?", time.Now().Add(-attemptRetention)).Delete(&models.LoginAttempt{}).Error; err != nil {
+		log.Printf("Failed to prune login attempts: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/oidc"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrSSOState is returned for a callback that does not belong to a
+// sign-in started in this browser
+var ErrSSOState = errors.New("the sign-in request expired or was not started here, please try again")
+
+// ssoStateTTL is how long a started single sign-on may take
+const ssoStateTTL = 10 * time.Minute
+
+// Session values of a started single sign-on
+const (
+	ssoStateKey    = "oidc_state"
+	ssoNonceKey    = "oidc_nonce"
+	ssoVerifierKey = "oidc_verifier"
+	ssoStartedKey  = "oidc_started"
+	ssoLinkKey     = "oidc_link" // ID of the signed-in user linking the identity
+)
+
+// BeginSSO stores the state, nonce and PKCE verifier of a new single
+// sign-on in the session and returns the URL of the identity provider
+func (s *AuthService) BeginSSO(c *gin.Context, provider *oidc.Provider) (string, error) {
+	return s.beginSSO(c, provider, 0)
+}
+
+// BeginSSOLink starts a single sign-on that links the identity to user,
+// who is signed in, instead of signing in
+func (s *AuthService) BeginSSOLink(c *gin.Context, provider *oidc.Provider, user *models.User) (string, error) {
+	if user.IsExternal() {
+		return "", ErrAlreadyLinked
+	}
+	return s.beginSSO(c, provider, user.ID)
+}
+
+func (s *AuthService) beginSSO(c *gin.Context, provider *oidc.Provider, linkUserID uint) (string, error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return "", err
+	}
+
+	values := map[string]string{}
+	for _, key := range []string{ssoStateKey, ssoNonceKey, ssoVerifierKey} {
+		if values[key], err = oidc.RandomString(); err != nil {
+			return "", err
+		}
+	}
+	url, err := provider.AuthCodeURL(c.Request.Context(), values[ssoStateKey], values[ssoNonceKey], values[ssoVerifierKey])
+	if err != nil {
+		return "", err
+	}
+
+	for key, value := range values {
+		session.Values[key] = value
+	}
+	session.Values[ssoStartedKey] = time.Now().Unix()
+	if linkUserID != 0 {
+		session.Values[ssoLinkKey] = linkUserID
+	} else {
+		delete(session.Values, ssoLinkKey)
+	}
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// FinishSSO completes a single sign-on started with BeginSSO or
+// BeginSSOLink. It verifies the state and redeems the code. A sign-in
+// returns the provisioned user; call Login to create the session. A link
+// returns the signed-in user now linked to the identity, and linked is true
+// even when linking failed.
+func (s *AuthService) FinishSSO(c *gin.Context, provider *oidc.Provider, mapping GroupMapping) (user *models.User, linked bool, err error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	state, _ := session.Values[ssoStateKey].(string)
+	nonce, _ := session.Values[ssoNonceKey].(string)
+	verifier, _ := session.Values[ssoVerifierKey].(string)
+	started, _ := session.Values[ssoStartedKey].(int64)
+	linkUserID, linked := session.Values[ssoLinkKey].(uint)
+
+	// The values are single-use
+	for _, key := range []string{ssoStateKey, ssoNonceKey, ssoVerifierKey, ssoStartedKey, ssoLinkKey} {
+		delete(session.Values, key)
+	}
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		return nil, linked, err
+	}
+
+	if !ValidCSRFToken(state, c.Query("state")) || time.Since(time.Unix(started, 0)) > ssoStateTTL {
+		return nil, linked, ErrSSOState
+	}
+	if linked {
+		// The user who started the link must still be signed in
+		current, err := s.GetCurrentUser(c)
+		if err != nil || current.ID != linkUserID {
+			return nil, true, ErrSSOState
+		}
+		user = current
+	}
+	claims, err := provider.Exchange(c.Request.Context(), c.Query("code"), verifier, nonce)
+	if err != nil {
+		return nil, linked, err
+	}
+	if !linked {
+		user, err = s.ProvisionSSOUser(c.Request.Context(), claims, mapping, c.ClientIP())
+		return user, false, err
+	}
+
+	if !mapping.Allowed(claims.Groups) {
+		return nil, true, ErrAccessDenied
+	}
+	err = s.LinkExternalUser(c.Request.Context(), user, &Identity{
+		Provider: models.AuthOIDC,
+		Subject:  claims.Subject,
+	})
+	if err != nil {
+		return nil, true, err
+	}
+	return user, true, nil
+}
+
+// ProvisionSSOUser returns the user for verified identity provider claims,
+// creating or updating it
+func (s *AuthService) ProvisionSSOUser(ctx context.Context, claims *oidc.Claims, mapping GroupMapping, ip string) (*models.User, error) {
+	if !mapping.Allowed(claims.Groups) {
+		s.recordAttempt(nil, claims.Email, ip, false)
+		return nil, ErrAccessDenied
+	}
+
+	user, err := s.syncExternalUser(ctx, &Identity{
+		Provider:      models.AuthOIDC,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Role:          mapping.Role(claims.Groups),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := accountError(user); err != nil {
+		s.recordAttempt(&user.ID, user.Email, ip, false)
+		return nil, err
+	}
+	if err := s.succeedLogin(user, ip); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/oidc"
+	"github.com/alpemreelmas/sysara/internal/oidc/oidctest"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ssoTest signs in through a mock identity provider
+type ssoTest struct {
+	auth     *AuthService
+	db       *gorm.DB
+	mock     *oidctest.Provider
+	provider *oidc.Provider
+}
+
+func newSSOTest(t *testing.T) *ssoTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sysara.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Session{}, &models.LoginAttempt{}, &models.PasswordHistory{}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPasswordPolicy(8, 1, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock, err := oidctest.NewProvider("sysara", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mock.Close)
+	mock.Claims["email"] = "jane@example.com"
+	mock.Claims["email_verified"] = true
+	mock.Claims["name"] = "Jane Doe"
+
+	return &ssoTest{
+		auth:     NewAuthService(db, NewSessionStore(db, time.Hour, time.Hour), Lockout{}, policy, true),
+		db:       db,
+		mock:     mock,
+		provider: oidc.New(mock.Issuer(), "sysara", "s3cret", "http://sysara.test/auth/oidc/callback", nil, ""),
+	}
+}
+
+// request runs fn in a gin context for a request carrying cookies and
+// returns the cookies of the response
+func request(target string, cookies []*http.Cookie, fn func(c *gin.Context)) []*http.Cookie {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range cookies {
+		c.Request.AddCookie(cookie)
+	}
+	fn(c)
+	if returned := w.Result().Cookies(); len(returned) > 0 {
+		return returned
+	}
+	return cookies
+}
+
+// begin starts a sign-in and returns the session cookies and the code and
+// state the provider redirects back with
+func (st *ssoTest) begin(t *testing.T, cookies []*http.Cookie, link *models.User) ([]*http.Cookie, string, string) {
+	t.Helper()
+	var authURL string
+	var err error
+	cookies = request("/auth/oidc/login", cookies, func(c *gin.Context) {
+		if link != nil {
+			authURL, err = st.auth.BeginSSOLink(c, st.provider, link)
+		} else {
+			authURL, err = st.auth.BeginSSO(c, st.provider)
+		}
+	})
+	if err != nil {
+		t.Fatalf("BeginSSO: %v", err)
+	}
+	code, state, err := st.mock.Authorize(authURL, "user-1")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return cookies, code, state
+}
+
+// finish completes a sign-in with the callback parameters
+func (st *ssoTest) finish(cookies []*http.Cookie, code, state string) (user *models.User, linked bool, err error) {
+	target := "/auth/oidc/callback?" + url.Values{"code": {code}, "state": {state}}.Encode()
+	request(target, cookies, func(c *gin.Context) {
+		user, linked, err = st.auth.FinishSSO(c, st.provider, GroupMapping{})
+	})
+	return user, linked, err
+}
+
+func TestSSOSignIn(t *testing.T) {
+	st := newSSOTest(t)
+	cookies, code, state := st.begin(t, nil, nil)
+
+	user, linked, err := st.finish(cookies, code, state)
+	if err != nil {
+		t.Fatalf("FinishSSO: %v", err)
+	}
+	if linked || user.Email != "jane@example.com" || user.AuthProvider != models.AuthOIDC || user.ExternalID != "user-1" {
+		t.Errorf("user = %+v, linked = %v", user, linked)
+	}
+}
+
+func TestSSOState(t *testing.T) {
+	st := newSSOTest(t)
+
+	// A state that does not match the session is refused before the code
+	// is redeemed
+	cookies, code, _ := st.begin(t, nil, nil)
+	if _, _, err := st.finish(cookies, code, "forged-state"); !errors.Is(err, ErrSSOState) {
+		t.Errorf("forged state: got %v, want ErrSSOState", err)
+	}
+	if st.mock.Tokens() != 0 {
+		t.Error("the code was redeemed for a forged state")
+	}
+
+	// The state is single-use, also after a failed attempt
+	cookies, code, state := st.begin(t, nil, nil)
+	st.finish(cookies, code, "forged-state")
+	if _, _, err := st.finish(cookies, code, state); !errors.Is(err, ErrSSOState) {
+		t.Errorf("state after a failed attempt: got %v, want ErrSSOState", err)
+	}
+
+	// A callback in another browser has no state at all
+	_, code, state = st.begin(t, nil, nil)
+	if _, _, err := st.finish(nil, code, state); !errors.Is(err, ErrSSOState) {
+		t.Errorf("callback without the session: got %v, want ErrSSOState", err)
+	}
+	if _, _, err := st.finish(nil, code, ""); !errors.Is(err, ErrSSOState) {
+		t.Errorf("empty state without the session: got %v, want ErrSSOState", err)
+	}
+}
+
+func TestSSOReplay(t *testing.T) {
+	st := newSSOTest(t)
+	cookies, code, state := st.begin(t, nil, nil)
+	if _, _, err := st.finish(cookies, code, state); err != nil {
+		t.Fatalf("FinishSSO: %v", err)
+	}
+	if _, _, err := st.finish(cookies, code, state); !errors.Is(err, ErrSSOState) {
+		t.Errorf("replayed callback: got %v, want ErrSSOState", err)
+	}
+}
+
+func TestSSONonceIsBoundToTheSession(t *testing.T) {
+	st := newSSOTest(t)
+
+	// The provider answers with the nonce of another sign-in
+	cookies, code, state := st.begin(t, nil, nil)
+	st.mock.Modify = func(claims map[string]interface{}) { claims["nonce"] = "other" }
+	if _, _, err := st.finish(cookies, code, state); !errors.Is(err, oidc.ErrInvalidToken) {
+		t.Errorf("foreign nonce: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestSSOLinkRequiresTheSameUser(t *testing.T) {
+	st := newSSOTest(t)
+	hash, _ := st.auth.HashPassword("Passw0rd!")
+	user := models.User{Email: "jane.doe@example.com", Name: "Jane", Password: hash, Role: models.RoleUser}
+	if err := st.db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	cookies := request("/login", nil, func(c *gin.Context) { err = st.auth.Login(c, &user) })
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	// Signed out before the callback
+	linkCookies, code, state := st.begin(t, cookies, &user)
+	signedOut := request("/logout", linkCookies, func(c *gin.Context) { st.auth.Logout(c) })
+	if _, _, err := st.finish(signedOut, code, state); !errors.Is(err, ErrSSOState) {
+		t.Errorf("link after sign-out: got %v, want ErrSSOState", err)
+	}
+
+	cookies = request("/login", nil, func(c *gin.Context) { err = st.auth.Login(c, &user) })
+	linkCookies, code, state = st.begin(t, cookies, &user)
+	got, linked, err := st.finish(linkCookies, code, state)
+	if err != nil || !linked {
+		t.Fatalf("FinishSSO: linked %v, %v", linked, err)
+	}
+	if got.ID != user.ID || got.AuthProvider != models.AuthOIDC || got.ExternalID != "user-1" {
+		t.Errorf("linked user = %+v", got)
+	}
+}
+
+func TestSSODoesNotTakeOverAccountsByEmail(t *testing.T) {
+	st := newSSOTest(t)
+	hash, _ := st.auth.HashPassword("Passw0rd!")
+	user := models.User{Email: "jane@example.com", Name: "Jane", Password: hash, Role: models.RoleAdmin}
+	if err := st.db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider vouches for the email, but anyone may be able to set it there
+	cookies, code, state := st.begin(t, nil, nil)
+	if _, _, err := st.finish(cookies, code, state); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("existing email: got %v, want ErrUserExists", err)
+	}
+	var stored models.User
+	st.db.First(&stored, user.ID)
+	if stored.AuthProvider == models.AuthOIDC || stored.ExternalID != "" {
+		t.Errorf("the account was linked: %+v", stored)
+	}
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PolicyError is a password rejected by the password policy. Its message
+// is meant to be shown to the user.
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+// PasswordPolicy holds the rules new passwords must follow
+type PasswordPolicy struct {
+	MinLength  int           // minimum number of characters
+	MinClasses int           // minimum number of character classes (lower, upper, digit, symbol)
+	MaxAge     time.Duration // passwords older than this must be changed, 0 never expires
+	History    int           // number of previous passwords that cannot be reused, 0 allows reuse
+	blocked    map[string]bool
+}
+
+// NewPasswordPolicy creates a password policy. The bundled list of common
+// passwords is always blocked; blocklistFile adds more, one per line.
+func NewPasswordPolicy(minLength, minClasses int, maxAge time.Duration, history int, blocklistFile string) (*PasswordPolicy, error) {
+	p := &PasswordPolicy{
+		MinLength:  minLength,
+		MinClasses: minClasses,
+		MaxAge:     maxAge,
+		History:    history,
+		blocked:    map[string]bool{},
+	}
+	p.addBlocked(strings.NewReader(commonPasswords))
+
+	if blocklistFile != "" {
+		f, err := os.Open(blocklistFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open password blocklist: %w", err)
+		}
+		defer f.Close()
+		if err := p.addBlocked(f); err != nil {
+			return nil, fmt.Errorf("failed to read password blocklist: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// addBlocked adds the passwords listed in r, ignoring blank lines and comments
+func (p *PasswordPolicy) addBlocked(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.blocked[strings.ToLower(line)] = true
+	}
+	return scanner.Err()
+}
+
+// Rules describes the policy for display next to password fields
+func (p *PasswordPolicy) Rules() []string {
+	rules := []string{fmt.Sprintf("At least %d characters", p.MinLength)}
+	if p.MinClasses > 1 {
+		rules = append(rules, fmt.Sprintf("At least %d of: lowercase letters, uppercase letters, digits, symbols", p.MinClasses))
+	}
+	rules = append(rules, "Not a common or previously breached password, and not your name or email")
+	if p.History > 0 {
+		rules = append(rules, fmt.Sprintf("Different from your last %d passwords", p.History))
+	}
+	return rules
+}
+
+// Check validates a password against the rules that do not need the
+// password history. email and name may be empty.
+func (p *PasswordPolicy) Check(password, email, name string) error {
+	if len([]rune(password)) < p.MinLength {
+		return &PolicyError{fmt.Sprintf("Password must be at least %d characters long", p.MinLength)}
+	}
+	if classes := characterClasses(password); classes < p.MinClasses {
+		return &PolicyError{fmt.Sprintf("Password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", p.MinClasses)}
+	}
+
+	lower := strings.ToLower(password)
+	if p.blocked[lower] {
+		return &PolicyError{"This password is too common, choose another one"}
+	}
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, personal := range []string{strings.ToLower(email), local, strings.ToLower(name)} {
+		if personal != "" && lower == personal {
+			return &PolicyError{"Password must not be your name or email address"}
+		}
+	}
+	return nil
+}
+
+// Expired reports whether the password of a user is older than MaxAge
+func (p *PasswordPolicy) Expired(user *models.User) bool {
+	if p.MaxAge <= 0 {
+		return false
+	}
+	changed := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changed = *user.PasswordChangedAt
+	}
+	return time.Since(changed) > p.MaxAge
+}
+
+// characterClasses counts the character classes used in a password
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// Policy returns the password policy
+func (s *AuthService) Policy() *PasswordPolicy {
+	return s.policy
+}
+
+// ValidatePassword checks a new password for user against the policy,
+// including the password history. user may be a user not yet created.
+func (s *AuthService) ValidatePassword(user *models.User, password string) error {
+	if err := s.policy.Check(password, user.Email, user.Name); err != nil {
+		return err
+	}
+	if user.ID == 0 || s.policy.History <= 0 {
+		return nil
+	}
+
+	var history []models.PasswordHistory
+	if err := s.db.Where("user_id = ?", user.ID).Order("created_at DESC, id DESC").Limit(s.policy.History).Find(&history).Error; err != nil {
+		return err
+	}
+	// The history includes the current password, except for passwords set
+	// before the history was kept
+	hashes := []string{}
+	for _, entry := range history {
+		hashes = append(hashes, entry.Hash)
+	}
+	if len(history) == 0 {
+		hashes = append(hashes, user.Password)
+	}
+	for _, hash := range hashes {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return &PolicyError{fmt.Sprintf("Password must differ from your last %d passwords", s.policy.History)}
+		}
+	}
+	return nil
+}
+
+// PasswordChangeRequired reports whether the user must choose a new
+// password before using the application. Users without a Sysara password
+// never have to.
+func (s *AuthService) PasswordChangeRequired(user *models.User) bool {
+	if !s.localPasswords || user.IsExternal() {
+		return false
+	}
+	return user.MustChangePassword || s.policy.Expired(user)
+}
+
+// SetPassword stores a new password for user, clears a forced change and
+// revokes the API tokens of user. Validate the password with
+// ValidatePassword first. Sessions issued before the change are no longer
+// valid; log the user in again to keep the current one.
+func (s *AuthService) SetPassword(user *models.User, password string) error {
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.storePassword(tx, user, hashedPassword)
+	})
+}
+
+// storePassword saves a hashed password, clears a forced change and keeps
+// the hash in the password history. The API tokens of the user are
+// revoked, as a new password is often set because the old one leaked.
+func (s *AuthService) storePassword(tx *gorm.DB, user *models.User, hashedPassword string) error {
+	now := time.Now()
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"password":             hashedPassword,
+		"password_changed_at":  now,
+		"must_change_password": false,
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.APIToken{}).Error; err != nil {
+		return err
+	}
+	return s.remember(tx, user.ID, hashedPassword)
+}
+
+// remember adds a password hash to the history of a user and drops
+// entries beyond the configured history length
+func (s *AuthService) remember(tx *gorm.DB, userID uint, hashedPassword string) error {
+	if s.policy.History <= 0 {
+		return tx.Where("user_id = ?", userID).Delete(&models.PasswordHistory{}).Error
+	}
+	if err := tx.Create(&models.PasswordHistory{UserID: userID, Hash: hashedPassword}).Error; err != nil {
+		return err
+	}
+	var keep []uint
+	if err := tx.Model(&models.PasswordHistory{}).Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(s.policy.History).Pluck("id", &keep).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ? AND id NOT IN ?", userID, keep).Delete(&models.PasswordHistory{}).Error
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter allows a number of events per key within a sliding window
+type RateLimiter struct {
+	mu     sync.Mutex
+	max    int
+	window time.Duration
+	events map[string][]time.Time
+}
+
+// NewRateLimiter creates a limiter allowing max events per window
+func NewRateLimiter(max int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		max:    max,
+		window: window,
+		events: map[string][]time.Time{},
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-l.window)
+	for k, times := range l.events {
+		// Drop expired events so that the map does not grow without bound
+		recent := times[:0]
+		for _, t := range times {
+			if t.After(cutoff) {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) == 0 {
+			delete(l.events, k)
+		} else {
+			l.events[k] = recent
+		}
+	}
+
+	if len(l.events[key]) >= l.max {
+		return false
+	}
+	l.events[key] = append(l.events[key], now)
+	return true
+}
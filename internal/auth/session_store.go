@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/gorilla/sessions"
+	"gorm.io/gorm"
+)
+
+// touchInterval limits how often the last seen time of a session is written
+const touchInterval = time.Minute
+
+// SessionStore keeps sessions in the database. The cookie only holds a
+// random session ID, of which the database stores a hash, so sessions
+// can be listed and revoked.
+type SessionStore struct {
+	db      *gorm.DB
+	Options *sessions.Options
+	idle    time.Duration // sessions unused for this long expire
+	maxAge  time.Duration // sessions expire this long after login
+}
+
+// NewSessionStore creates a database session store
+func NewSessionStore(db *gorm.DB, idle, maxAge time.Duration) *SessionStore {
+	return &SessionStore{
+		db:     db,
+		idle:   idle,
+		maxAge: maxAge,
+		Options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   int(maxAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+	}
+}
+
+// Get returns a session for the given name after adding it to the registry
+func (s *SessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns the session of the request's cookie, or a new session if
+// there is none or it expired
+func (s *SessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		return session, nil
+	}
+	row, err := s.load(cookie.Value)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, nil
+		}
+		return session, err
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(row.Data)).Decode(&session.Values); err != nil {
+		log.Printf("auth: discarding undecodable session %d: %v", row.ID, err)
+		return session, nil
+	}
+	session.ID = cookie.Value
+	session.IsNew = false
+
+	if now := time.Now(); now.Sub(row.LastSeenAt) > touchInterval {
+		s.db.Model(row).Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"ip_address":   remoteIP(r),
+		})
+	}
+	return session, nil
+}
+
+// Save stores the session and sets its cookie. A session with a
+// negative MaxAge is deleted.
+func (s *SessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.Delete(session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(session.Values); err != nil {
+		return err
+	}
+	userID, _ := session.Values["user_id"].(uint)
+
+	if session.ID != "" {
+		result := s.db.Model(&models.Session{}).Where("token_hash = ?", hashToken(session.ID)).Updates(map[string]interface{}{
+			"data":    data.Bytes(),
+			"user_id": nullableID(userID),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			http.SetCookie(w, sessions.NewCookie(session.Name(), session.ID, session.Options))
+			return nil
+		}
+		// The session was revoked meanwhile, start a new one
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	id := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	s.prune(now)
+	row := models.Session{
+		TokenHash:  hashToken(id),
+		UserID:     nullableID(userID),
+		Data:       data.Bytes(),
+		UserAgent:  r.UserAgent(),
+		IPAddress:  remoteIP(r),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.maxAge),
+	}
+	if err := s.db.Create(&row).Error; err != nil {
+		return err
+	}
+	session.ID = id
+	http.SetCookie(w, sessions.NewCookie(session.Name(), session.ID, session.Options))
+	return nil
+}
+
+// Delete removes the session with the given ID
+func (s *SessionStore) Delete(id string) error {
+	return s.db.Where("token_hash = ?", hashToken(id)).Delete(&models.Session{}).Error
+}
+
+// Current returns the stored session of a session ID
+func (s *SessionStore) Current(id string) (*models.Session, error) {
+	if id == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.load(id)
+}
+
+// List returns the active sessions of a user, most recently used first
+func (s *SessionStore) List(userID uint) ([]models.Session, error) {
+	now := time.Now()
+	var list []models.Session
+	err := s.db.Where("user_id = ? AND expires_at > ? AND last_seen_at > ?", userID, now, now.Add(-s.idle)).
+		Order("last_seen_at DESC").
+		Find(&list).Error
+	return list, err
+}
+
+// Revoke deletes a session of a user
+func (s *SessionStore) Revoke(userID, id uint) error {
+	result := s.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeUser deletes every session of a user except keep, which may be 0
+func (s *SessionStore) RevokeUser(userID, keep uint) (int64, error) {
+	result := s.db.Where("user_id = ? AND id <> ?", userID, keep).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
+
+// load returns the stored session of a session ID if it has not expired
+func (s *SessionStore) load(id string) (*models.Session, error) {
+	var row models.Session
+	if err := s.db.Where("token_hash = ?", hashToken(id)).First(&row).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if now.After(row.ExpiresAt) || now.Sub(row.LastSeenAt) > s.idle {
+		s.db.Delete(&row)
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &row, nil
+}
+
+// prune deletes expired sessions
+func (s *SessionStore) prune(now time.Time) {
+	if err := s.db.Where("expires_at < ? OR last_seen_at < ?", now, now.Add(-s.idle)).Delete(&models.Session{}).Error; err != nil {
+		log.Printf("auth: failed to prune expired sessions: %v", err)
+	}
+}
+
+// nullableID returns nil for the zero ID
+func nullableID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// remoteIP returns the address of the client without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
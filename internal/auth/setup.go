@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/oidc"
+	"gorm.io/gorm"
+)
+
+// Errors of the setup wizard
+var (
+	ErrSetupDone         = errors.New("setup has already been completed")
+	ErrInvalidSetupToken = errors.New("invalid setup token, use SETUP_TOKEN or the one printed in the server log at startup")
+	ErrInvalidClaimToken = errors.New("invalid setup token, use the one printed in the server log at startup")
+)
+
+// NeedsSetup reports whether no user exists yet, so that the first
+// administrator has to be created before anything else
+func (s *AuthService) NeedsSetup() bool {
+	if s.setupDone.Load() {
+		return false
+	}
+	var count int64
+	if err := s.db.Model(&models.User{}).Count(&count).Error; err != nil {
+		// Fail closed: without knowing, keep the application locked
+		return true
+	}
+	if count > 0 {
+		s.setupDone.Store(true)
+		return false
+	}
+	return true
+}
+
+// BeginSetup sets the token the setup wizard requires to create the first
+// administrator, so that nobody else who reaches a new install first can
+// take it over. Without a configured token a random one is issued and
+// returned to be printed; "" is returned otherwise or when no setup is
+// needed.
+func (s *AuthService) BeginSetup(configured string) (string, error) {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+
+	s.setupToken = ""
+	if !s.NeedsSetup() {
+		return "", nil
+	}
+	if configured != "" {
+		s.setupToken = hashToken(configured)
+		return "", nil
+	}
+	token, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	s.setupToken = hashToken(token)
+	return token, nil
+}
+
+// CheckSetupToken returns ErrInvalidSetupToken unless token is the one set
+// by BeginSetup. Without BeginSetup no token is accepted.
+func (s *AuthService) CheckSetupToken(token string) error {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+
+	if s.setupToken == "" || subtle.ConstantTimeCompare([]byte(s.setupToken), []byte(hashToken(token))) != 1 {
+		return ErrInvalidSetupToken
+	}
+	return nil
+}
+
+// CreateFirstAdmin creates the first administrator of a new install. The
+// password must satisfy the password policy; mustChange requires a new
+// password at the first sign-in.
+func (s *AuthService) CreateFirstAdmin(email, name, password string, mustChange bool) (*models.User, error) {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+
+	if !s.NeedsSetup() {
+		return nil, ErrSetupDone
+	}
+	if email == "" || name == "" {
+		return nil, &PolicyError{"Name and email are required"}
+	}
+	if err := s.policy.Check(password, email, name); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Email:              email,
+		Name:               name,
+		Password:           hashedPassword,
+		Role:               models.RoleAdmin,
+		MustChangePassword: mustChange,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return s.remember(tx, user.ID, hashedPassword)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.setupDone.Store(true)
+	s.setupToken = ""
+	return &user, nil
+}
+
+// defaultAdmin returns the account old installs were seeded with while it
+// is disabled because of its default password, or nil
+func (s *AuthService) defaultAdmin() (*models.User, error) {
+	var user models.User
+	result := s.db.Where("email = ? AND status = ? AND status_reason = ?", models.DefaultAdminEmail, models.UserDisabled, models.DefaultAdminReason).Limit(1).Find(&user)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// BeginClaim issues the one-time token that claims the disabled default
+// account of an old install through the setup wizard. It returns "" when
+// there is no such account. Earlier tokens stop working.
+func (s *AuthService) BeginClaim() (string, error) {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+
+	user, err := s.defaultAdmin()
+	if err != nil || user == nil {
+		s.claimToken = ""
+		return "", err
+	}
+	token, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	s.claimToken = hashToken(token)
+	return token, nil
+}
+
+// NeedsClaim reports whether the default account waits to be claimed
+func (s *AuthService) NeedsClaim() bool {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+	return s.claimToken != ""
+}
+
+// ClaimDefaultAdmin gives the disabled default account of an old install
+// a new email, name and password and enables it. token is the one issued
+// by BeginClaim; it works once. All earlier sessions of the account are
+// revoked.
+func (s *AuthService) ClaimDefaultAdmin(token, email, name, password string) (*models.User, error) {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+
+	if s.claimToken == "" {
+		return nil, ErrSetupDone
+	}
+	if subtle.ConstantTimeCompare([]byte(s.claimToken), []byte(hashToken(token))) != 1 {
+		return nil, ErrInvalidClaimToken
+	}
+	email, name = strings.TrimSpace(email), strings.TrimSpace(name)
+	if email == "" || name == "" {
+		return nil, &PolicyError{"Name and email are required"}
+	}
+	if err := s.policy.Check(password, email, name); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.defaultAdmin()
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		s.claimToken = ""
+		return nil, ErrSetupDone
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ? AND id <> ?", email, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUserExists
+		}
+		now := time.Now()
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"email":             email,
+			"name":              name,
+			"role":              models.RoleAdmin,
+			"status":            models.UserActive,
+			"status_reason":     "",
+			"status_changed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := s.storePassword(tx, user, hashedPassword); err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.Session{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.Email, user.Name, user.Role = email, name, models.RoleAdmin
+	user.Status, user.StatusReason = models.UserActive, ""
+	s.claimToken = ""
+	return user, nil
+}
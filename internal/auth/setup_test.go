@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newSetupTest(t *testing.T) *AuthService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sysara.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Session{}, &models.PasswordHistory{}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPasswordPolicy(8, 1, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthService(db, NewSessionStore(db, time.Hour, time.Hour), Lockout{}, policy, true)
+}
+
+func TestSetupTokenIsGeneratedWhenNotConfigured(t *testing.T) {
+	auth := newSetupTest(t)
+
+	// Nothing is accepted before a token is issued
+	if err := auth.CheckSetupToken(""); !errors.Is(err, ErrInvalidSetupToken) {
+		t.Errorf("empty token before BeginSetup: got %v", err)
+	}
+
+	token, err := auth.BeginSetup("")
+	if err != nil || token == "" {
+		t.Fatalf("BeginSetup() = %q, %v", token, err)
+	}
+	for _, wrong := range []string{"", "guess", token + "x"} {
+		if err := auth.CheckSetupToken(wrong); !errors.Is(err, ErrInvalidSetupToken) {
+			t.Errorf("CheckSetupToken(%q) = %v", wrong, err)
+		}
+	}
+	if err := auth.CheckSetupToken(token); err != nil {
+		t.Errorf("CheckSetupToken(issued) = %v", err)
+	}
+
+	// Each start issues a new token and the old one stops working
+	next, err := auth.BeginSetup("")
+	if err != nil || next == token {
+		t.Fatalf("second BeginSetup() = %q, %v", next, err)
+	}
+	if err := auth.CheckSetupToken(token); !errors.Is(err, ErrInvalidSetupToken) {
+		t.Errorf("earlier token still works: %v", err)
+	}
+
+	// Other services do not share the token
+	if err := newSetupTest(t).CheckSetupToken(next); !errors.Is(err, ErrInvalidSetupToken) {
+		t.Errorf("token of another service accepted: %v", err)
+	}
+
+	if _, err := auth.CreateFirstAdmin("admin@example.com", "Admin", "Passw0rd!", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.CheckSetupToken(next); !errors.Is(err, ErrInvalidSetupToken) {
+		t.Errorf("token works after setup: %v", err)
+	}
+	if token, err := auth.BeginSetup(""); err != nil || token != "" {
+		t.Errorf("BeginSetup() after setup = %q, %v", token, err)
+	}
+}
+
+func TestConfiguredSetupToken(t *testing.T) {
+	auth := newSetupTest(t)
+	token, err := auth.BeginSetup("from-the-environment")
+	if err != nil || token != "" {
+		t.Fatalf("BeginSetup() = %q, %v", token, err)
+	}
+	if err := auth.CheckSetupToken("from-the-environment"); err != nil {
+		t.Errorf("CheckSetupToken(configured) = %v", err)
+	}
+	if err := auth.CheckSetupToken("other"); !errors.Is(err, ErrInvalidSetupToken) {
+		t.Errorf("CheckSetupToken(other) = %v", err)
+	}
+}
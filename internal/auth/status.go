@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+)
+
+// Errors of inactive accounts and account state changes
+var (
+	ErrAccountDisabled  = errors.New("your account is disabled, contact an administrator")
+	ErrAccountSuspended = errors.New("your account is suspended")
+	ErrAccountExpired   = errors.New("your account has expired, contact an administrator")
+	ErrInvalidStatus    = errors.New("invalid account status")
+	ErrSuspensionEnded  = errors.New("a suspension must end in the future")
+	ErrLastAdmin        = errors.New("the last active administrator cannot be deactivated")
+)
+
+// AccountChange is a change of the account state of a user
+type AccountChange struct {
+	Status         string     // models.UserActive, UserDisabled, UserSuspended or UserDeleted
+	SuspendedUntil *time.Time // end of a suspension, nil for an open-ended one
+	ExpiresAt      *time.Time // nil never expires
+	Reason         string
+}
+
+// accountError returns why user may not sign in, or nil for active users
+func accountError(user *models.User) error {
+	switch user.State() {
+	case models.UserActive:
+		return nil
+	case models.UserSuspended:
+		if user.SuspendedUntil != nil {
+			return fmt.Errorf("%w until %s", ErrAccountSuspended, user.SuspendedUntil.Format("January 2, 2006 15:04"))
+		}
+		return ErrAccountSuspended
+	case models.UserExpired:
+		return ErrAccountExpired
+	}
+	return ErrAccountDisabled
+}
+
+// ChangeAccountState sets the status and expiry of user. The last active
+// administrator cannot be deactivated, and users who are no longer active
+// are signed out of all sessions at once.
+func (s *AuthService) ChangeAccountState(user *models.User, change AccountChange) error {
+	switch change.Status {
+	case models.UserActive, models.UserDisabled, models.UserDeleted:
+		change.SuspendedUntil = nil
+	case models.UserSuspended:
+		if change.SuspendedUntil != nil && !change.SuspendedUntil.After(time.Now()) {
+			return ErrSuspensionEnded
+		}
+	default:
+		return ErrInvalidStatus
+	}
+
+	updated := *user
+	now := time.Now()
+	if updated.Status != change.Status {
+		updated.StatusChangedAt = &now
+	}
+	updated.Status = change.Status
+	updated.StatusReason = change.Reason
+	updated.SuspendedUntil = change.SuspendedUntil
+	updated.ExpiresAt = change.ExpiresAt
+
+	if user.IsAdmin() && !updated.IsActive() {
+		var others int64
+		err := s.db.Model(&models.User{}).
+			Where("role = ? AND id <> ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)", models.RoleAdmin, user.ID, models.UserActive, now).
+			Count(&others).Error
+		if err != nil {
+			return err
+		}
+		if others == 0 {
+			return ErrLastAdmin
+		}
+	}
+
+	if err := s.db.Model(user).Updates(map[string]interface{}{
+		"status":            updated.Status,
+		"status_reason":     updated.StatusReason,
+		"status_changed_at": updated.StatusChangedAt,
+		"suspended_until":   updated.SuspendedUntil,
+		"expires_at":        updated.ExpiresAt,
+	}).Error; err != nil {
+		return err
+	}
+	*user = updated
+
+	if !user.IsActive() {
+		if _, err := s.store.RevokeUser(user.ID, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
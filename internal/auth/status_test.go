@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestInactiveAccountsFailLikeWrongPasswords(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sysara.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Session{}, &models.LoginAttempt{}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPasswordPolicy(8, 1, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthService(db, NewSessionStore(db, time.Hour, time.Hour), Lockout{Threshold: 2, Duration: time.Hour}, policy, true)
+
+	hash, err := auth.HashPassword("Passw0rd!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	until := time.Now().Add(time.Hour)
+	expired := time.Now().Add(-time.Hour)
+	users := []models.User{
+		{Email: "disabled@example.com", Status: models.UserDisabled},
+		{Email: "suspended@example.com", Status: models.UserSuspended, SuspendedUntil: &until},
+		{Email: "expired@example.com", ExpiresAt: &expired},
+	}
+	for i := range users {
+		users[i].Name = "User"
+		users[i].Password = hash
+		users[i].Role = models.RoleUser
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, user := range users {
+		ip := fmt.Sprintf("192.0.2.%d", i+1)
+		if _, err := auth.AuthenticateUser(user.Email, "Passw0rd!", ip); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s with the right password: got %v, want ErrInvalidCredentials", user.Email, err)
+		}
+		if _, err := auth.AuthenticateUser(user.Email, "wrong", ip); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s with a wrong password: got %v, want ErrInvalidCredentials", user.Email, err)
+		}
+		// Both attempts count towards the lockout, as for active accounts
+		if _, err := auth.AuthenticateUser(user.Email, "Passw0rd!", ip); !errors.Is(err, ErrTooManyAttempts) {
+			t.Errorf("%s after two failures: got %v, want ErrTooManyAttempts", user.Email, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle delays repeated failures per key with exponential backoff.
+// The first free failures are not delayed; after that each failure
+// doubles the wait, up to max.
+type Throttle struct {
+	mu       sync.Mutex
+	free     int
+	base     time.Duration
+	max      time.Duration
+	failures map[string]*throttleEntry
+}
+
+type throttleEntry struct {
+	count int
+	last  time.Time
+}
+
+// NewThrottle creates a throttle
+func NewThrottle(free int, base, max time.Duration) *Throttle {
+	return &Throttle{
+		free:     free,
+		base:     base,
+		max:      max,
+		failures: map[string]*throttleEntry{},
+	}
+}
+
+// Wait returns how long key has to wait before its next attempt
+func (t *Throttle) Wait(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.failures[key]
+	if !ok {
+		return 0
+	}
+	wait := time.Until(entry.last.Add(Backoff(entry.count, t.free, t.base, t.max)))
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// Fail records a failure of key
+func (t *Throttle) Fail(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range t.failures {
+		// Forget keys that have been quiet for longer than the longest wait
+		if now.Sub(entry.last) > t.max {
+			delete(t.failures, k)
+		}
+	}
+	entry, ok := t.failures[key]
+	if !ok {
+		entry = &throttleEntry{}
+		t.failures[key] = entry
+	}
+	entry.count++
+	entry.last = now
+}
+
+// Reset forgets the failures of key
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, key)
+}
+
+// Backoff returns the wait after count failures, of which the first free
+// ones are not delayed
+func Backoff(count, free int, base, max time.Duration) time.Duration {
+	if count <= free {
+		return 0
+	}
+	wait := base
+	for i := free + 1; i < count && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		return max
+	}
+	return wait
+}
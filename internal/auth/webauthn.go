@@ -0,0 +1,336 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/webauthn"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Errors of security key ceremonies
+var (
+	ErrWebAuthnState     = errors.New("the security key request expired or was not started here, please try again")
+	ErrUnknownCredential = errors.New("this security key is not registered")
+	ErrCredentialExists  = errors.New("this security key is already registered")
+)
+
+// webauthnTTL is how long a started ceremony or a pending second factor
+// may take
+const webauthnTTL = 5 * time.Minute
+
+// credentialNameLength limits the name of a security key
+const credentialNameLength = 64
+
+// Session values of a started ceremony and of a password login that waits
+// for its second factor
+const (
+	webauthnChallengeKey = "webauthn_challenge"
+	webauthnStartedKey   = "webauthn_started"
+	secondFactorUserKey  = "second_factor_user"
+	secondFactorAtKey    = "second_factor_started"
+)
+
+// knownTransports are the transport hints stored with a credential
+var knownTransports = map[string]bool{"usb": true, "nfc": true, "ble": true, "smart-card": true, "hybrid": true, "internal": true}
+
+// WebAuthnUserHandle returns the opaque user handle stored on passkeys
+func WebAuthnUserHandle(userID uint) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+// WebAuthnCredentials returns the security keys of a user, newest first
+func (s *AuthService) WebAuthnCredentials(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&credentials).Error
+	return credentials, err
+}
+
+// SecondFactorRequired reports whether a password login of user must be
+// confirmed with a security key
+func (s *AuthService) SecondFactorRequired(user *models.User) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.WebAuthnCredential{}).Where("user_id = ?", user.ID).Count(&count).Error
+	return count > 0, err
+}
+
+// BeginWebAuthnRegistration returns the options for registering a new
+// security key for user
+func (s *AuthService) BeginWebAuthnRegistration(c *gin.Context, rp *webauthn.RelyingParty, user *models.User) (*webauthn.CreationOptions, error) {
+	credentials, err := s.WebAuthnCredentials(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		exclude = append(exclude, descriptor(credential))
+	}
+
+	challenge, err := s.storeChallenge(c)
+	if err != nil {
+		return nil, err
+	}
+	options := rp.CreationOptions(challenge, webauthn.User{
+		ID:          WebAuthnUserHandle(user.ID),
+		Name:        user.Email,
+		DisplayName: user.Name,
+	}, exclude)
+	return &options, nil
+}
+
+// FinishWebAuthnRegistration verifies the response to
+// BeginWebAuthnRegistration and stores the security key
+func (s *AuthService) FinishWebAuthnRegistration(c *gin.Context, rp *webauthn.RelyingParty, user *models.User, name string, response *webauthn.AttestationResponse) (*models.WebAuthnCredential, error) {
+	challenge, err := s.takeChallenge(c)
+	if err != nil {
+		return nil, err
+	}
+	verified, err := rp.VerifyRegistration(challenge, response, false)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Security key"
+	}
+	if len([]rune(name)) > credentialNameLength {
+		name = string([]rune(name)[:credentialNameLength])
+	}
+	var transports []string
+	for _, transport := range verified.Transports {
+		if knownTransports[transport] {
+			transports = append(transports, transport)
+		}
+	}
+
+	credential := models.WebAuthnCredential{
+		UserID:       user.ID,
+		Name:         name,
+		CredentialID: base64.RawURLEncoding.EncodeToString(verified.ID),
+		PublicKey:    verified.PublicKey,
+		SignCount:    verified.SignCount,
+		Transports:   strings.Join(transports, ","),
+	}
+	var count int64
+	if err := s.db.Model(&models.WebAuthnCredential{}).Where("credential_id = ?", credential.CredentialID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrCredentialExists
+	}
+	if err := s.db.Create(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// DeleteWebAuthnCredential removes a security key of a user and returns it
+func (s *AuthService) DeleteWebAuthnCredential(userID, id uint) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Delete(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// DeleteWebAuthnCredentials removes all security keys of a user, for a user
+// who lost them, and returns how many there were
+func (s *AuthService) DeleteWebAuthnCredentials(userID uint) (int64, error) {
+	result := s.db.Where("user_id = ?", userID).Delete(&models.WebAuthnCredential{})
+	return result.RowsAffected, result.Error
+}
+
+// BeginSecondFactor remembers in the session that user passed the password
+// check and must now confirm the login with a security key
+func (s *AuthService) BeginSecondFactor(c *gin.Context, user *models.User) error {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return err
+	}
+	session.Values[secondFactorUserKey] = user.ID
+	session.Values[secondFactorAtKey] = time.Now().Unix()
+	return session.Save(c.Request, c.Writer)
+}
+
+// PendingSecondFactor returns the user whose login waits for a security key
+func (s *AuthService) PendingSecondFactor(c *gin.Context) (*models.User, error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return nil, err
+	}
+	userID, ok := session.Values[secondFactorUserKey].(uint)
+	started, _ := session.Values[secondFactorAtKey].(int64)
+	if !ok || time.Since(time.Unix(started, 0)) > webauthnTTL {
+		return nil, ErrWebAuthnState
+	}
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebAuthnState
+		}
+		return nil, err
+	}
+	if err := accountError(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// BeginWebAuthnLogin returns the options for signing in with a security
+// key. For a second factor, user is the user who passed the password check;
+// a nil user starts a passwordless sign-in with any passkey.
+func (s *AuthService) BeginWebAuthnLogin(c *gin.Context, rp *webauthn.RelyingParty, user *models.User) (*webauthn.RequestOptions, error) {
+	var allow []webauthn.CredentialDescriptor
+	verification := webauthn.VerificationRequired
+	if user != nil {
+		credentials, err := s.WebAuthnCredentials(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(credentials) == 0 {
+			return nil, ErrUnknownCredential
+		}
+		for _, credential := range credentials {
+			allow = append(allow, descriptor(credential))
+		}
+		verification = webauthn.VerificationPreferred
+	}
+
+	challenge, err := s.storeChallenge(c)
+	if err != nil {
+		return nil, err
+	}
+	options := rp.RequestOptions(challenge, allow, verification)
+	return &options, nil
+}
+
+// FinishWebAuthnLogin verifies the response to BeginWebAuthnLogin and
+// returns the user it signs in; call Login to create the session.
+// Passwordless sign-in requires user verification and is only offered to
+// users with a Sysara password, so that it cannot bypass the checks of an
+// identity provider.
+func (s *AuthService) FinishWebAuthnLogin(c *gin.Context, rp *webauthn.RelyingParty, user *models.User, response *webauthn.AssertionResponse) (*models.User, error) {
+	ip := c.ClientIP()
+	passwordless := user == nil
+	if passwordless && s.byIP.Wait(ip) > 0 {
+		return nil, ErrTooManyAttempts
+	}
+	challenge, err := s.takeChallenge(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var credential models.WebAuthnCredential
+	err = s.db.Where("credential_id = ?", base64.RawURLEncoding.EncodeToString(response.RawID)).First(&credential).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !passwordless && credential.UserID != user.ID) {
+		s.byIP.Fail(ip)
+		return nil, ErrUnknownCredential
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if passwordless {
+		if handle := response.Response.UserHandle; len(handle) > 0 && string(handle) != string(WebAuthnUserHandle(credential.UserID)) {
+			s.byIP.Fail(ip)
+			return nil, ErrUnknownCredential
+		}
+		user = &models.User{}
+		if err := s.db.First(user, credential.UserID).Error; err != nil {
+			return nil, err
+		}
+		if !s.localPasswords || user.IsExternal() {
+			return nil, ErrPasswordLoginDisabled
+		}
+		if s.accountWait(user) > 0 {
+			return nil, ErrTooManyAttempts
+		}
+		if err := accountError(user); err != nil {
+			return nil, err
+		}
+	}
+
+	signCount, err := rp.VerifyAssertion(challenge, response, credential.PublicKey, credential.SignCount, passwordless)
+	if err != nil {
+		s.byIP.Fail(ip)
+		if passwordless {
+			s.recordAttempt(&user.ID, user.Email, ip, false)
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.db.Model(&credential).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"last_used_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	if passwordless {
+		s.byIP.Reset(ip)
+		if err := s.succeedLogin(user, ip); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// storeChallenge creates the challenge of a new ceremony and stores it in
+// the session
+func (s *AuthService) storeChallenge(c *gin.Context) ([]byte, error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	session.Values[webauthnChallengeKey] = base64.RawURLEncoding.EncodeToString(challenge)
+	session.Values[webauthnStartedKey] = time.Now().Unix()
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// takeChallenge returns the challenge of the started ceremony. Challenges
+// are single-use.
+func (s *AuthService) takeChallenge(c *gin.Context) ([]byte, error) {
+	session, err := s.store.Get(c.Request, sessionName)
+	if err != nil {
+		return nil, err
+	}
+	encoded, _ := session.Values[webauthnChallengeKey].(string)
+	started, _ := session.Values[webauthnStartedKey].(int64)
+	delete(session.Values, webauthnChallengeKey)
+	delete(session.Values, webauthnStartedKey)
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		return nil, err
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(challenge) == 0 || time.Since(time.Unix(started, 0)) > webauthnTTL {
+		return nil, ErrWebAuthnState
+	}
+	return challenge, nil
+}
+
+// descriptor describes a stored credential to the browser
+func descriptor(credential models.WebAuthnCredential) webauthn.CredentialDescriptor {
+	id, _ := base64.RawURLEncoding.DecodeString(credential.CredentialID)
+	var transports []string
+	if credential.Transports != "" {
+		transports = strings.Split(credential.Transports, ",")
+	}
+	return webauthn.NewCredentialDescriptor(id, transports)
+}
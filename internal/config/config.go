@@ -0,0 +1,229 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/crontab"
+)
+
+// Config holds runtime settings read from environment variables
+type Config struct {
+	DockerSocket string
+
+	AdminEmail          string // creates the first administrator on an empty database
+	AdminName           string
+	AdminPassword       string
+	AdminPasswordChange bool   // the initial administrator must change the password at first sign-in
+	SetupToken          string // required by the setup wizard; generated and logged when empty
+
+	EnableRegistration bool          // anyone can create an account at /register
+	InvitationTTL      time.Duration // lifetime of an invite link
+	PasswordLogin      bool          // users can sign in with a Sysara password
+
+	OIDCIssuer        string // single sign-on is enabled when set
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string // defaults to APP_URL/auth/oidc/callback
+	OIDCScopes        []string
+	OIDCProviderName  string   // shown on the sign-in button
+	OIDCGroupsClaim   string   // claim holding the groups of a user
+	OIDCAdminGroups   []string // members become administrators, others users
+	OIDCAllowedGroups []string // only members may sign in
+
+	LDAPURL                string // directory sign-in is enabled when set
+	LDAPStartTLS           bool
+	LDAPInsecureSkipVerify bool
+	LDAPBindDN             string // service account that searches for users
+	LDAPBindPassword       string
+	LDAPBaseDN             string
+	LDAPUserFilter         string // {username} is replaced by the sign-in name
+	LDAPIDAttribute        string // stable user ID, the DN when empty
+	LDAPEmailAttribute     string
+	LDAPNameAttribute      string
+	LDAPGroupAttribute     string
+	LDAPTrustEmail         bool     // directory emails replace the stored ones at sign-in
+	LDAPAdminGroups        []string // group names or DNs separated by semicolons; members become administrators
+	LDAPAllowedGroups      []string // only members may sign in
+
+	WebAuthnRPID    string   // domain security keys are registered for, defaults to the host of APP_URL
+	WebAuthnRPName  string   // shown by the browser when a key is registered
+	WebAuthnOrigins []string // origins keys may be used on, defaults to the origin of APP_URL
+
+	CronSystemFile string
+	CronSystemDir  string
+	CronSpoolDir   string
+	CronBackupDir  string
+
+	EnvRevisionLimit  int           // revisions kept per file, 0 keeps all
+	EnvRevisionMaxAge time.Duration // age after which revisions are pruned, 0 keeps all
+	EnvEncryptionKey  string        // encrypts revision history at rest
+	EnvSecretPatterns []string      // key patterns whose values are masked
+	EnvHookCommands   []string      // absolute paths of commands post-save hooks may run
+	EnvHookTimeout    time.Duration // default timeout of a post-save hook
+
+	AppURL             string        // public base URL used in links sent by email
+	CORSAllowedOrigins []string      // origins allowed to make cross-origin requests
+	TrustedProxies     []string      // reverse proxies whose X-Forwarded-For is believed, none by default
+	PasswordResetTTL   time.Duration // lifetime of a password reset link
+	EmailChangeTTL     time.Duration // lifetime of the link confirming a new email address
+
+	SessionIdleTimeout time.Duration // sessions unused for this long expire
+	SessionMaxAge      time.Duration // sessions expire this long after login
+
+	PasswordMinLength     int           // minimum password length
+	PasswordMinClasses    int           // character classes (lower, upper, digit, symbol) a password needs
+	PasswordMaxAge        time.Duration // passwords must be changed after this long, 0 never
+	PasswordHistory       int           // previous passwords that cannot be reused
+	PasswordBlocklistFile string        // extra common or breached passwords, one per line
+
+	LoginLockoutThreshold int           // consecutive failed logins that lock an account, 0 disables locking
+	LoginLockoutDuration  time.Duration // how long a locked account stays locked
+
+	SMTPHost     string // mail is written to the log when empty
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	MailLogBody  bool // without SMTP, log message bodies including their links (development only)
+}
+
+// Load reads the configuration from the environment, applying defaults
+func Load() *Config {
+	cfg := &Config{
+		DockerSocket: getEnv("DOCKER_SOCKET", "/var/run/docker.sock"),
+
+		AdminEmail:          getEnv("ADMIN_EMAIL", ""),
+		AdminName:           getEnv("ADMIN_NAME", "Administrator"),
+		AdminPassword:       getEnv("ADMIN_PASSWORD", ""),
+		AdminPasswordChange: getEnvBool("ADMIN_PASSWORD_CHANGE", true),
+		SetupToken:          getEnv("SETUP_TOKEN", ""),
+
+		EnableRegistration: getEnvBool("ENABLE_REGISTRATION", false),
+		InvitationTTL:      time.Duration(getEnvInt("INVITATION_TTL_HOURS", 72)) * time.Hour,
+		PasswordLogin:      getEnvBool("PASSWORD_LOGIN_ENABLED", true),
+
+		OIDCIssuer:        getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:   getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:        getEnvList("OIDC_SCOPES"),
+		OIDCProviderName:  getEnv("OIDC_PROVIDER_NAME", "Single Sign-On"),
+		OIDCGroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCAdminGroups:   getEnvList("OIDC_ADMIN_GROUPS"),
+		OIDCAllowedGroups: getEnvList("OIDC_ALLOWED_GROUPS"),
+
+		LDAPURL:                getEnv("LDAP_URL", ""),
+		LDAPStartTLS:           getEnvBool("LDAP_START_TLS", false),
+		LDAPInsecureSkipVerify: getEnvBool("LDAP_INSECURE_SKIP_VERIFY", false),
+		LDAPBindDN:             getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:       getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:             getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:         getEnv("LDAP_USER_FILTER", "(|(uid={username})(mail={username}))"),
+		LDAPIDAttribute:        getEnv("LDAP_ID_ATTRIBUTE", ""),
+		LDAPEmailAttribute:     getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		LDAPNameAttribute:      getEnv("LDAP_NAME_ATTRIBUTE", "cn"),
+		LDAPGroupAttribute:     getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		LDAPTrustEmail:         getEnvBool("LDAP_TRUST_EMAIL", false),
+		LDAPAdminGroups:        getEnvSplit("LDAP_ADMIN_GROUPS", ";"),
+		LDAPAllowedGroups:      getEnvSplit("LDAP_ALLOWED_GROUPS", ";"),
+
+		WebAuthnRPID:    getEnv("WEBAUTHN_RP_ID", ""),
+		WebAuthnRPName:  getEnv("WEBAUTHN_RP_NAME", "Sysara"),
+		WebAuthnOrigins: getEnvList("WEBAUTHN_ORIGINS"),
+
+		CronSystemFile: getEnv("CRON_SYSTEM_FILE", "/etc/crontab"),
+		CronSystemDir:  getEnv("CRON_SYSTEM_DIR", "/etc/cron.d"),
+		CronSpoolDir:   getEnv("CRON_SPOOL_DIR", crontab.DefaultSpoolDir()),
+		CronBackupDir:  getEnv("CRON_BACKUP_DIR", "data/backups/cron"),
+
+		EnvRevisionLimit:  getEnvInt("ENV_REVISION_LIMIT", 50),
+		EnvRevisionMaxAge: time.Duration(getEnvInt("ENV_REVISION_MAX_AGE_DAYS", 0)) * 24 * time.Hour,
+		EnvEncryptionKey:  getEnv("ENV_ENCRYPTION_KEY", ""),
+		EnvSecretPatterns: getEnvList("ENV_SECRET_PATTERNS"),
+		EnvHookCommands:   getEnvList("ENV_HOOK_COMMANDS"),
+		EnvHookTimeout:    time.Duration(getEnvInt("ENV_HOOK_TIMEOUT_SECONDS", 30)) * time.Second,
+
+		AppURL:             strings.TrimRight(getEnv("APP_URL", "http://localhost:8080"), "/"),
+		CORSAllowedOrigins: getEnvList("CORS_ALLOWED_ORIGINS"),
+		TrustedProxies:     getEnvList("TRUSTED_PROXIES"),
+		PasswordResetTTL:   time.Duration(getEnvInt("PASSWORD_RESET_TTL_MINUTES", 60)) * time.Minute,
+		EmailChangeTTL:     time.Duration(getEnvInt("EMAIL_CHANGE_TTL_HOURS", 24)) * time.Hour,
+
+		SessionIdleTimeout: time.Duration(getEnvInt("SESSION_IDLE_TIMEOUT_MINUTES", 480)) * time.Minute,
+		SessionMaxAge:      time.Duration(getEnvInt("SESSION_MAX_AGE_HOURS", 168)) * time.Hour,
+
+		PasswordMinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMinClasses:    getEnvInt("PASSWORD_MIN_CLASSES", 1),
+		PasswordMaxAge:        time.Duration(getEnvInt("PASSWORD_MAX_AGE_DAYS", 0)) * 24 * time.Hour,
+		PasswordHistory:       getEnvInt("PASSWORD_HISTORY", 5),
+		PasswordBlocklistFile: getEnv("PASSWORD_BLOCKLIST_FILE", ""),
+
+		LoginLockoutThreshold: getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LoginLockoutDuration:  time.Duration(getEnvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute,
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "sysara@localhost"),
+		MailLogBody:  getEnvBool("MAIL_LOG_BODY", false),
+	}
+	if cfg.OIDCRedirectURL == "" {
+		cfg.OIDCRedirectURL = cfg.AppURL + "/auth/oidc/callback"
+	}
+	if appURL, err := url.Parse(cfg.AppURL); err == nil {
+		if cfg.WebAuthnRPID == "" {
+			cfg.WebAuthnRPID = appURL.Hostname()
+		}
+		if len(cfg.WebAuthnOrigins) == 0 {
+			cfg.WebAuthnOrigins = []string{appURL.Scheme + "://" + appURL.Host}
+		}
+	}
+	return cfg
+}
+
+// getEnv returns the value of an environment variable or a fallback
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt returns an integer environment variable or a fallback
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvBool returns a boolean environment variable or a fallback
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList returns a comma separated environment variable as a list
+func getEnvList(key string) []string {
+	return getEnvSplit(key, ",")
+}
+
+// getEnvSplit returns an environment variable split by sep as a list
+func getEnvSplit(key, sep string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), sep) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
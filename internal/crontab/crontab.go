@@ -0,0 +1,233 @@
+package crontab
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// disabledMarker prefixes entries that were disabled through Sysara
+const disabledMarker = "#sysara:disabled "
+
+var (
+	envLinePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\s*=`)
+	usernamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_.-]*\$?$`)
+)
+
+// Entry is a single job line of a crontab
+type Entry struct {
+	Index    int    // Line number (zero-based) within the file
+	Schedule string // Five cron fields or a macro such as @daily
+	User     string // Run-as user, only present in system crontabs
+	Command  string
+	Disabled bool
+	Error    string // Set when the line could not be parsed
+}
+
+// File is a parsed crontab file
+type File struct {
+	Path      string
+	Owner     string // Owner of a per-user crontab; empty for system files
+	System    bool   // System crontabs have a user column
+	Lines     []string
+	Entries   []Entry
+	Variables []string
+}
+
+// Parse parses crontab content. System crontabs (/etc/crontab and
+// /etc/cron.d) carry an extra user field between schedule and command.
+func Parse(path, content string, system bool) *File {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.TrimSuffix(content, "\n")
+
+	f := &File{Path: path, System: system}
+	if content != "" {
+		f.Lines = strings.Split(content, "\n")
+	}
+	f.reindex()
+	return f
+}
+
+// String serializes the crontab; cron requires a trailing newline
+func (f *File) String() string {
+	if len(f.Lines) == 0 {
+		return ""
+	}
+	return strings.Join(f.Lines, "\n") + "\n"
+}
+
+// Entry returns the entry at the given line index
+func (f *File) Entry(index int) (Entry, bool) {
+	for _, e := range f.Entries {
+		if e.Index == index {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Add appends a new entry to the file
+func (f *File) Add(e Entry) error {
+	if err := f.Validate(e); err != nil {
+		return err
+	}
+	f.Lines = append(f.Lines, f.format(e))
+	f.reindex()
+	return nil
+}
+
+// Update replaces the entry at index, keeping its enabled state
+func (f *File) Update(index int, e Entry) error {
+	existing, ok := f.Entry(index)
+	if !ok {
+		return errors.New("entry not found")
+	}
+	if err := f.Validate(e); err != nil {
+		return err
+	}
+	e.Disabled = existing.Disabled
+	f.Lines[index] = f.format(e)
+	f.reindex()
+	return nil
+}
+
+// SetDisabled comments an entry out or restores it
+func (f *File) SetDisabled(index int, disabled bool) error {
+	existing, ok := f.Entry(index)
+	if !ok {
+		return errors.New("entry not found")
+	}
+	existing.Disabled = disabled
+	f.Lines[index] = f.format(existing)
+	f.reindex()
+	return nil
+}
+
+// Remove deletes the entry at index
+func (f *File) Remove(index int) error {
+	if _, ok := f.Entry(index); !ok {
+		return errors.New("entry not found")
+	}
+	f.Lines = append(f.Lines[:index], f.Lines[index+1:]...)
+	f.reindex()
+	return nil
+}
+
+// Validate checks an entry before it is written to this file
+func (f *File) Validate(e Entry) error {
+	if err := ValidateSchedule(e.Schedule); err != nil {
+		return err
+	}
+	if f.System {
+		if e.User == "" {
+			return errors.New("a run-as user is required for system crontabs")
+		}
+		if !ValidUsername(e.User) {
+			return fmt.Errorf("invalid user name %q", e.User)
+		}
+	}
+	command := strings.TrimSpace(e.Command)
+	if command == "" {
+		return errors.New("command is required")
+	}
+	if strings.ContainsAny(command, "\r\n") {
+		return errors.New("command must be a single line")
+	}
+	return nil
+}
+
+// format renders an entry as a crontab line
+func (f *File) format(e Entry) string {
+	fields := []string{strings.Join(strings.Fields(e.Schedule), " ")}
+	if f.System {
+		fields = append(fields, e.User)
+	}
+	fields = append(fields, strings.TrimSpace(e.Command))
+
+	line := strings.Join(fields, " ")
+	if e.Disabled {
+		line = disabledMarker + line
+	}
+	return line
+}
+
+// reindex re-parses all lines into entries and variables
+func (f *File) reindex() {
+	f.Entries = nil
+	f.Variables = nil
+
+	for i, raw := range f.Lines {
+		line := strings.TrimSpace(raw)
+		disabled := false
+		if strings.HasPrefix(line, disabledMarker) {
+			disabled = true
+			line = strings.TrimSpace(strings.TrimPrefix(line, disabledMarker))
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if envLinePattern.MatchString(line) {
+			f.Variables = append(f.Variables, line)
+			continue
+		}
+
+		entry := parseEntry(line, f.System)
+		entry.Index = i
+		entry.Disabled = disabled
+		f.Entries = append(f.Entries, entry)
+	}
+}
+
+// parseEntry splits a job line into schedule, user and command
+func parseEntry(line string, system bool) Entry {
+	scheduleFields := 5
+	if strings.HasPrefix(line, "@") {
+		scheduleFields = 1
+	}
+	wanted := scheduleFields
+	if system {
+		wanted++
+	}
+
+	fields, rest := splitFields(line, wanted)
+	if len(fields) < wanted || rest == "" {
+		return Entry{Command: line, Error: "incomplete crontab line"}
+	}
+
+	entry := Entry{
+		Schedule: strings.Join(fields[:scheduleFields], " "),
+		Command:  rest,
+	}
+	if system {
+		entry.User = fields[scheduleFields]
+	}
+	if err := ValidateSchedule(entry.Schedule); err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// splitFields returns the first n whitespace separated fields of s and the
+// untouched remainder, so spacing inside the command is preserved
+func splitFields(s string, n int) ([]string, string) {
+	var fields []string
+	rest := strings.TrimLeft(s, " \t")
+	for len(fields) < n && rest != "" {
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			fields = append(fields, rest)
+			rest = ""
+			break
+		}
+		fields = append(fields, rest[:end])
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+	return fields, rest
+}
+
+// ValidUsername reports whether name is safe to use as a crontab owner
+func ValidUsername(name string) bool {
+	return len(name) <= 32 && usernamePattern.MatchString(name)
+}
@@ -0,0 +1,166 @@
+package crontab
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		system    bool
+		entries   []Entry
+		variables []string
+	}{
+		{
+			name:    "empty",
+			content: "",
+		},
+		{
+			name:      "user crontab",
+			content:   "# backups\nMAILTO=ops@example.com\n\n30 2 * * 1-5 /usr/local/bin/backup  --full\n@daily  /usr/bin/cleanup\n",
+			variables: []string{"MAILTO=ops@example.com"},
+			entries: []Entry{
+				{Index: 3, Schedule: "30 2 * * 1-5", Command: "/usr/local/bin/backup  --full"},
+				{Index: 4, Schedule: "@daily", Command: "/usr/bin/cleanup"},
+			},
+		},
+		{
+			name:    "system crontab",
+			content: "SHELL=/bin/sh\n17 * * * * root cd / && run-parts --report /etc/cron.hourly\n@reboot www-data /srv/start.sh\n",
+			system:  true,
+			entries: []Entry{
+				{Index: 1, Schedule: "17 * * * *", User: "root", Command: "cd / && run-parts --report /etc/cron.hourly"},
+				{Index: 2, Schedule: "@reboot", User: "www-data", Command: "/srv/start.sh"},
+			},
+			variables: []string{"SHELL=/bin/sh"},
+		},
+		{
+			name:    "disabled entry",
+			content: "#sysara:disabled */5 * * * * /bin/true\n# 0 0 * * * not an entry\n",
+			entries: []Entry{{Index: 0, Schedule: "*/5 * * * *", Command: "/bin/true", Disabled: true}},
+		},
+		{
+			name:    "invalid lines are kept",
+			content: "61 * * * * /bin/true\n* * *\n",
+			entries: []Entry{
+				{Index: 0, Schedule: "61 * * * *", Command: "/bin/true", Error: `invalid schedule "61 * * * *": end of range (61) above maximum (59): 61`},
+				{Index: 1, Command: "* * *", Error: "incomplete crontab line"},
+			},
+		},
+		{
+			name:    "system entry without user",
+			content: "0 0 * * * \n",
+			system:  true,
+			entries: []Entry{{Index: 0, Command: "0 0 * * *", Error: "incomplete crontab line"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse("/etc/crontab", tt.content, tt.system)
+			if !reflect.DeepEqual(f.Entries, tt.entries) {
+				t.Errorf("entries = %+v, want %+v", f.Entries, tt.entries)
+			}
+			if !reflect.DeepEqual(f.Variables, tt.variables) {
+				t.Errorf("variables = %q, want %q", f.Variables, tt.variables)
+			}
+			if s := f.String(); s != tt.content {
+				t.Errorf("String() = %q, want %q", s, tt.content)
+			}
+		})
+	}
+}
+
+func TestParseNormalizesLineEndings(t *testing.T) {
+	f := Parse("", "0 * * * * a\r\n0 * * * * b", false)
+	if got := f.String(); got != "0 * * * * a\n0 * * * * b\n" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		system  bool
+		entry   Entry
+		wantErr string
+	}{
+		{"valid", false, Entry{Schedule: "0 * * * *", Command: "/bin/true"}, ""},
+		{"valid system", true, Entry{Schedule: "@hourly", User: "backup", Command: "/bin/true"}, ""},
+		{"bad schedule", false, Entry{Schedule: "0 * * *", Command: "/bin/true"}, `invalid schedule "0 * * *": expected exactly 5 fields, found 4: [0 * * *]`},
+		{"no command", false, Entry{Schedule: "0 * * * *", Command: "  "}, "command is required"},
+		{"multiline command", false, Entry{Schedule: "0 * * * *", Command: "a\nb"}, "command must be a single line"},
+		{"no user", true, Entry{Schedule: "0 * * * *", Command: "/bin/true"}, "a run-as user is required for system crontabs"},
+		{"bad user", true, Entry{Schedule: "0 * * * *", User: "root /bin/sh", Command: "/bin/true"}, `invalid user name "root /bin/sh"`},
+		// The user column is only written to system crontabs
+		{"user ignored", false, Entry{Schedule: "0 * * * *", User: "root /bin/sh", Command: "/bin/true"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse("", "", tt.system)
+			err := f.Validate(tt.entry)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("Validate() = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEditsFormatLines(t *testing.T) {
+	f := Parse("/etc/cron.d/app", "# app jobs\nPATH=/usr/bin\n0   3 * * *  root  /srv/nightly.sh\n", true)
+
+	if err := f.Add(Entry{Schedule: " */10  *  * * * ", User: "app", Command: "  /srv/poll.sh  -q "}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetDisabled(2, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "# app jobs\nPATH=/usr/bin\n#sysara:disabled 0 3 * * * root /srv/nightly.sh\n*/10 * * * * app /srv/poll.sh  -q\n"
+	if got := f.String(); got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+
+	// Updating a disabled entry keeps it disabled
+	if err := f.Update(2, Entry{Schedule: "@weekly", User: "root", Command: "/srv/weekly.sh"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove(3); err != nil {
+		t.Fatal(err)
+	}
+	want = "# app jobs\nPATH=/usr/bin\n#sysara:disabled @weekly root /srv/weekly.sh\n"
+	if got := f.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if e, _ := f.Entry(2); !e.Disabled || e.Schedule != "@weekly" {
+		t.Errorf("entry = %+v", e)
+	}
+
+	// Comments and variables are not entries
+	for _, index := range []int{0, 1, 5} {
+		if err := f.Remove(index); err == nil {
+			t.Errorf("Remove(%d) succeeded", index)
+		}
+	}
+	if err := f.Update(2, Entry{Schedule: "@weekly", Command: "/srv/weekly.sh"}); err == nil {
+		t.Error("Update without a user succeeded")
+	}
+}
+
+func TestValidUsername(t *testing.T) {
+	for _, name := range []string{"root", "www-data", "_apt", "svc.backup", "machine$"} {
+		if !ValidUsername(name) {
+			t.Errorf("ValidUsername(%q) = false", name)
+		}
+	}
+	for _, name := range []string{"", "Root", "1user", "../etc", "a b", "user\n", "averyveryveryverylongusernameof33"} {
+		if ValidUsername(name) {
+			t.Errorf("ValidUsername(%q) = true", name)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package crontab
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/utils"
+)
+
+// ErrUnknownFile is returned for paths that are not a managed crontab
+var ErrUnknownFile = errors.New("not a managed crontab file")
+
+// Manager discovers, reads and writes crontab files
+type Manager struct {
+	SystemFile string // usually /etc/crontab
+	SystemDir  string // usually /etc/cron.d
+	SpoolDir   string // per-user crontabs, e.g. /var/spool/cron/crontabs
+	BackupDir  string // where copies are stored before each write
+}
+
+// NewManager creates a crontab manager
+func NewManager(systemFile, systemDir, spoolDir, backupDir string) *Manager {
+	return &Manager{
+		SystemFile: systemFile,
+		SystemDir:  systemDir,
+		SpoolDir:   spoolDir,
+		BackupDir:  backupDir,
+	}
+}
+
+// Files loads every crontab that cron would read. Unreadable files are
+// returned with an error entry instead of failing the whole listing.
+func (m *Manager) Files() ([]*File, []error) {
+	var files []*File
+	var errs []error
+
+	for _, path := range m.paths() {
+		f, err := m.Load(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		files = append(files, f)
+	}
+	return files, errs
+}
+
+// Load reads and parses a managed crontab
+func (m *Manager) Load(path string) (*File, error) {
+	system, owner, ok := m.classify(path)
+	if !ok {
+		return nil, ErrUnknownFile
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && owner != "" {
+			content = nil
+		} else {
+			return nil, err
+		}
+	}
+
+	f := Parse(path, string(content), system)
+	f.Owner = owner
+	return f, nil
+}
+
+// UserFile loads the crontab of a user, which may not exist yet
+func (m *Manager) UserFile(owner string) (*File, error) {
+	if m.SpoolDir == "" {
+		return nil, errors.New("per-user crontabs are not configured")
+	}
+	if !ValidUsername(owner) {
+		return nil, fmt.Errorf("invalid user name %q", owner)
+	}
+	// crond ignores crontabs of users that do not exist
+	if _, err := user.Lookup(owner); err != nil {
+		return nil, fmt.Errorf("unknown system user %q", owner)
+	}
+	return m.Load(filepath.Join(m.SpoolDir, owner))
+}
+
+// Save backs up the current file and atomically writes the new content
+func (m *Manager) Save(f *File) error {
+	if _, _, ok := m.classify(f.Path); !ok {
+		return ErrUnknownFile
+	}
+
+	_, err := os.Stat(f.Path)
+	exists := err == nil
+	if exists {
+		if err := m.backup(f.Path); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+
+	// Per-user crontabs must stay private to their owner for crond to accept them
+	mode := os.FileMode(0644)
+	if f.Owner != "" {
+		mode = 0600
+	}
+	if err := utils.WriteFileAtomic(f.Path, []byte(f.String()), mode); err != nil {
+		return err
+	}
+	// Existing files keep their owner; new ones are given to the user
+	if !exists && f.Owner != "" {
+		if err := chownToUser(f.Path, f.Owner); err != nil {
+			return fmt.Errorf("failed to give the crontab to %s: %w", f.Owner, err)
+		}
+	}
+	return nil
+}
+
+// backup copies path into the backup directory with a timestamp suffix.
+// Backups are kept out of the cron directories because crond would
+// otherwise treat them as crontabs.
+func (m *Manager) backup(path string) error {
+	if err := os.MkdirAll(m.BackupDir, 0700); err != nil {
+		return err
+	}
+	name := strings.ReplaceAll(strings.TrimPrefix(filepath.ToSlash(path), "/"), "/", "_")
+	dst := filepath.Join(m.BackupDir, fmt.Sprintf("%s.%s", name, time.Now().Format("20060102-150405.000000000")))
+	return utils.CopyFile(path, dst, 0600)
+}
+
+// paths lists the crontab files present on the system
+func (m *Manager) paths() []string {
+	var paths []string
+	if m.SystemFile != "" {
+		if _, err := os.Stat(m.SystemFile); err == nil {
+			paths = append(paths, m.SystemFile)
+		}
+	}
+	paths = append(paths, listDir(m.SystemDir)...)
+	paths = append(paths, listDir(m.SpoolDir)...)
+	return paths
+}
+
+// classify reports whether path is a managed crontab, whether it is a
+// system crontab, and the owning user for per-user crontabs
+func (m *Manager) classify(path string) (system bool, owner string, ok bool) {
+	clean := filepath.Clean(path)
+	if m.SystemFile != "" && clean == filepath.Clean(m.SystemFile) {
+		return true, "", true
+	}
+
+	dir, name := filepath.Split(clean)
+	dir = filepath.Clean(dir)
+	if !isCrontabName(name) {
+		return false, "", false
+	}
+	if m.SystemDir != "" && dir == filepath.Clean(m.SystemDir) {
+		return true, "", true
+	}
+	if m.SpoolDir != "" && dir == filepath.Clean(m.SpoolDir) && ValidUsername(name) {
+		return false, name, true
+	}
+	return false, "", false
+}
+
+// listDir returns the crontab files in dir in name order
+func listDir(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isCrontabName(entry.Name()) {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// isCrontabName mirrors crond, which skips hidden files, editor backups
+// and names containing dots
+func isCrontabName(name string) bool {
+	if name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return false
+	}
+	return !strings.Contains(name, ".")
+}
+
+// DefaultSpoolDir returns the per-user crontab directory of the running
+// distribution (Debian uses crontabs/, Red Hat uses the spool root)
+func DefaultSpoolDir() string {
+	if info, err := os.Stat("/var/spool/cron/crontabs"); err == nil && info.IsDir() {
+		return "/var/spool/cron/crontabs"
+	}
+	return "/var/spool/cron"
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package crontab
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// chownToUser gives path the uid of the system user owner. Debian's crond
+// expects per-user crontabs in the crontab group, so that group is used
+// where it exists and the user's primary group elsewhere.
+func chownToUser(path, owner string) error {
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return err
+	}
+	group := u.Gid
+	if g, err := user.LookupGroup("crontab"); err == nil {
+		group = g.Gid
+	}
+	gid, err := strconv.Atoi(group)
+	if err != nil {
+		return err
+	}
+	return os.Chown(path, uid, gid)
+}
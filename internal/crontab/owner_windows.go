@@ -0,0 +1,8 @@
+//go:build windows
+
+package crontab
+
+// chownToUser is a no-op on Windows, which has no per-user crontabs
+func chownToUser(path, owner string) error {
+	return nil
+}
@@ -0,0 +1,65 @@
+package crontab
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// parser accepts the five-field syntax and the macros understood by crond
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ValidateSchedule checks that a schedule expression is understood by cron
+func ValidateSchedule(schedule string) error {
+	schedule = strings.TrimSpace(schedule)
+	if schedule == "" {
+		return errors.New("schedule is required")
+	}
+	if schedule == "@reboot" {
+		return nil
+	}
+	// robfig/cron reads a time zone prefix that crond does not; cronie
+	// only takes CRON_TZ as a variable line of its own
+	if strings.HasPrefix(schedule, "CRON_TZ=") || strings.HasPrefix(schedule, "TZ=") {
+		return errors.New("time zone prefixes are not supported by cron, set CRON_TZ on its own line")
+	}
+	// @every is a robfig/cron extension that crond does not support
+	if strings.HasPrefix(schedule, "@every") {
+		return errors.New("@every is not supported by cron")
+	}
+	if _, err := parser.Parse(schedule); err != nil {
+		return fmt.Errorf("invalid schedule %q: %v", schedule, err)
+	}
+	return nil
+}
+
+// NextRuns returns up to n upcoming run times after from. Entries that only
+// run at boot have no next run time.
+func NextRuns(schedule string, from time.Time, n int) ([]time.Time, error) {
+	schedule = strings.TrimSpace(schedule)
+	if schedule == "@reboot" {
+		return nil, nil
+	}
+	if err := ValidateSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	sched, err := parser.Parse(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]time.Time, 0, n)
+	next := from
+	for i := 0; i < n; i++ {
+		next = sched.Next(next)
+		if next.IsZero() {
+			break
+		}
+		runs = append(runs, next)
+	}
+	return runs, nil
+}
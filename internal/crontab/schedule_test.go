@@ -0,0 +1,68 @@
+package crontab
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		wantErr  string
+	}{
+		{"* * * * *", ""},
+		{"*/15 0-6 1,15 jan-jun mon-fri", ""},
+		{"@daily", ""},
+		{"@reboot", ""},
+		{"", "schedule is required"},
+		{"@every 5m", "@every is not supported by cron"},
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", "time zone prefixes are not supported by cron, set CRON_TZ on its own line"},
+		{"TZ=UTC @daily", "time zone prefixes are not supported by cron, set CRON_TZ on its own line"},
+		{"0 0 * * * *", `invalid schedule "0 0 * * * *": expected exactly 5 fields, found 6: [0 0 * * * *]`},
+		{"@sometimes", `invalid schedule "@sometimes": unrecognized descriptor: @sometimes`},
+	}
+	for _, tt := range tests {
+		err := ValidateSchedule(tt.schedule)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.wantErr {
+			t.Errorf("ValidateSchedule(%q) = %q, want %q", tt.schedule, got, tt.wantErr)
+		}
+	}
+}
+
+func TestTimeZonePrefixIsNotAnEntry(t *testing.T) {
+	f := Parse("", "CRON_TZ=Europe/Berlin\n0 9 * * * /bin/true\n", false)
+	if err := f.Add(Entry{Schedule: "CRON_TZ=UTC 0 9 * * *", Command: "/bin/true"}); err == nil {
+		t.Error("Add accepted a time zone prefix")
+	}
+	if !reflect.DeepEqual(f.Variables, []string{"CRON_TZ=Europe/Berlin"}) || len(f.Entries) != 1 {
+		t.Errorf("variables = %q, entries = %+v", f.Variables, f.Entries)
+	}
+}
+
+func TestNextRuns(t *testing.T) {
+	from := time.Date(2024, 1, 31, 23, 50, 0, 0, time.UTC)
+
+	runs, err := NextRuns("0 0 1 * *", from, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{
+		time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("NextRuns() = %v, want %v", runs, want)
+	}
+
+	if runs, err := NextRuns("@reboot", from, 3); err != nil || runs != nil {
+		t.Errorf("NextRuns(@reboot) = %v, %v", runs, err)
+	}
+	if _, err := NextRuns("CRON_TZ=UTC * * * * *", from, 3); err == nil {
+		t.Error("NextRuns accepted a time zone prefix")
+	}
+}
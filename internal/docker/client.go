@@ -0,0 +1,278 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// ErrUnavailable is returned when the Docker socket cannot be reached
+var ErrUnavailable = errors.New("docker daemon is not available")
+
+// APIError is an error response returned by the Docker Engine API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// validID matches container IDs and names as Docker accepts them
+var validID = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// containerPath returns the API path of a container endpoint. Anything but
+// a valid ID or name is reported as a missing container, so that it never
+// ends up in the request path.
+func containerPath(id, endpoint string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", &APIError{StatusCode: http.StatusNotFound, Message: "No such container: " + id}
+	}
+	return "/containers/" + id + endpoint, nil
+}
+
+// IsNotFound reports whether err is a 404 from the Docker API
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client talks to the Docker Engine API over a unix socket
+type Client struct {
+	socketPath string
+	http       *http.Client
+}
+
+// NewClient creates a client for the Docker socket at socketPath
+func NewClient(socketPath string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &Client{
+		socketPath: socketPath,
+		http:       &http.Client{Transport: transport},
+	}
+}
+
+// SocketPath returns the path of the socket the client connects to
+func (c *Client) SocketPath() string {
+	return c.socketPath
+}
+
+// Ping checks that the daemon is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	if _, err := os.Stat(c.socketPath); err != nil {
+		return ErrUnavailable
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/_ping", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListContainers returns containers, including stopped ones when all is true
+func (c *Client) ListContainers(ctx context.Context, all bool) ([]Container, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+
+	var containers []Container
+	if err := c.getJSON(ctx, "/containers/json", query, &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+// InspectContainer returns low-level information about a container
+func (c *Client) InspectContainer(ctx context.Context, id string) (*ContainerDetails, error) {
+	path, err := containerPath(id, "/json")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var details ContainerDetails
+	if err := json.Unmarshal(raw, &details); err != nil {
+		return nil, err
+	}
+	details.Raw = raw
+	return &details, nil
+}
+
+// ContainerStats returns a single resource usage sample for a running container
+func (c *Client) ContainerStats(ctx context.Context, id string) (*ContainerStats, error) {
+	query := url.Values{}
+	query.Set("stream", "false")
+	query.Set("one-shot", "true")
+
+	path, err := containerPath(id, "/stats")
+	if err != nil {
+		return nil, err
+	}
+	var raw statsResponse
+	if err := c.getJSON(ctx, path, query, &raw); err != nil {
+		return nil, err
+	}
+	return raw.toStats(), nil
+}
+
+// StartContainer starts a stopped container
+func (c *Client) StartContainer(ctx context.Context, id string) error {
+	path, err := containerPath(id, "/start")
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, path, nil)
+}
+
+// StopContainer stops a running container
+func (c *Client) StopContainer(ctx context.Context, id string) error {
+	path, err := containerPath(id, "/stop")
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, path, nil)
+}
+
+// RestartContainer restarts a container
+func (c *Client) RestartContainer(ctx context.Context, id string) error {
+	path, err := containerPath(id, "/restart")
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, path, nil)
+}
+
+// RemoveContainer removes a container, killing it first when force is true
+func (c *Client) RemoveContainer(ctx context.Context, id string, force bool) error {
+	query := url.Values{}
+	if force {
+		query.Set("force", "1")
+	}
+	path, err := containerPath(id, "")
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodDelete, path, query)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ContainerLogs returns the container's combined stdout and stderr.
+// When follow is true the reader stays open until ctx is cancelled or the
+// container exits. Multiplexed streams are decoded into plain text.
+func (c *Client) ContainerLogs(ctx context.Context, id string, tail int, follow bool) (io.ReadCloser, error) {
+	details, err := c.InspectContainer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("stdout", "1")
+	query.Set("stderr", "1")
+	query.Set("timestamps", "1")
+	if tail > 0 {
+		query.Set("tail", strconv.Itoa(tail))
+	}
+	if follow {
+		query.Set("follow", "1")
+	}
+
+	path, err := containerPath(id, "/logs")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, http.MethodGet, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if details.Config.Tty {
+		return resp.Body, nil
+	}
+	return newDemuxReader(resp.Body), nil
+}
+
+// getJSON performs a GET request and decodes the JSON response into v
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// post performs a POST request that has no response body of interest
+func (c *Client) post(ctx context.Context, path string, query url.Values) error {
+	resp, err := c.do(ctx, http.MethodPost, path, query)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a request to the daemon and converts error responses to APIError
+func (c *Client) do(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	u := url.URL{Scheme: "http", Host: "docker", Path: path}
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return nil, ErrUnavailable
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var body struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.Message != "" {
+			apiErr.Message = body.Message
+		}
+		return nil, apiErr
+	}
+
+	// A 304 (already started/stopped) falls through as success
+	return resp, nil
+}
@@ -0,0 +1,323 @@
+package docker
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDaemon is a Docker Engine API stand-in served on a unix socket
+type fakeDaemon struct {
+	mu         sync.Mutex
+	containers map[string]*ContainerDetails
+	requests   []string // method and escaped path of every request
+	logs       [][]byte // chunks written by the logs endpoint, flushed one by one
+}
+
+// newFakeDaemon starts a fake daemon and returns a client connected to it
+func newFakeDaemon(t *testing.T) (*fakeDaemon, *Client) {
+	t.Helper()
+
+	// Socket paths are limited to about 100 bytes, so keep it short
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &fakeDaemon{containers: map[string]*ContainerDetails{}}
+	server := httptest.NewUnstartedServer(d)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return d, NewClient(socket)
+}
+
+// add registers a container with the daemon
+func (d *fakeDaemon) add(id, name string, running, tty bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	details := &ContainerDetails{ID: id, Name: "/" + name, Image: "alpine"}
+	details.State.Running = running
+	details.State.Status = "exited"
+	if running {
+		details.State.Status = "running"
+	}
+	details.Config.Tty = tty
+	d.containers[id] = details
+}
+
+// state returns the status of a container, or "" once it was removed
+func (d *fakeDaemon) state(id string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if details, ok := d.containers[id]; ok {
+		return details.State.Status
+	}
+	return ""
+}
+
+// sent returns the requests the daemon received
+func (d *fakeDaemon) sent() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.requests...)
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, r.Method+" "+r.URL.EscapedPath())
+
+	if r.URL.Path == "/_ping" {
+		io.WriteString(w, "OK")
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/containers/json" {
+		list := []Container{}
+		for _, details := range d.containers {
+			if details.State.Running || r.URL.Query().Get("all") == "1" {
+				list = append(list, Container{ID: details.ID, Names: []string{details.Name}, Image: details.Image, State: details.State.Status})
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.Path, "/containers/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	id, endpoint, _ := strings.Cut(rest, "/")
+	details, ok := d.containers[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "No such container: " + id})
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && endpoint == "json":
+		json.NewEncoder(w).Encode(details)
+	case r.Method == http.MethodPost && endpoint == "start":
+		if details.State.Running {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		details.State.Running, details.State.Status = true, "running"
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && endpoint == "stop":
+		details.State.Running, details.State.Status = false, "exited"
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && endpoint == "":
+		if details.State.Running && r.URL.Query().Get("force") != "1" {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"message": "cannot remove a running container"})
+			return
+		}
+		delete(d.containers, id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && endpoint == "logs":
+		for _, chunk := range d.logs {
+			w.Write(chunk)
+			w.(http.Flusher).Flush()
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// frame encodes payload as a frame of Docker's multiplexed stream format
+func frame(stream byte, payload string) []byte {
+	header := make([]byte, 8, 8+len(payload))
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestListContainers(t *testing.T) {
+	d, client := newFakeDaemon(t)
+	d.add("aaa111", "web", true, false)
+	d.add("bbb222", "worker", false, false)
+	ctx := context.Background()
+
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	running, err := client.ListContainers(ctx, false)
+	if err != nil {
+		t.Fatalf("ListContainers: %v", err)
+	}
+	if len(running) != 1 || running[0].Name() != "web" {
+		t.Errorf("running containers = %+v, want only web", running)
+	}
+	all, err := client.ListContainers(ctx, true)
+	if err != nil {
+		t.Fatalf("ListContainers(all): %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("got %d containers, want 2", len(all))
+	}
+}
+
+func TestInspectContainer(t *testing.T) {
+	d, client := newFakeDaemon(t)
+	d.add("aaa111", "web", true, true)
+	ctx := context.Background()
+
+	details, err := client.InspectContainer(ctx, "aaa111")
+	if err != nil {
+		t.Fatalf("InspectContainer: %v", err)
+	}
+	if details.Name != "/web" || !details.State.Running || !details.Config.Tty {
+		t.Errorf("details = %+v", details)
+	}
+	if !json.Valid(details.Raw) {
+		t.Errorf("Raw is not the inspect JSON: %q", details.Raw)
+	}
+
+	_, err = client.InspectContainer(ctx, "missing")
+	if !IsNotFound(err) {
+		t.Errorf("missing container: got %v, want a 404", err)
+	}
+	if !strings.Contains(err.Error(), "No such container") {
+		t.Errorf("error %q does not carry the daemon message", err)
+	}
+}
+
+func TestInvalidIDsNeverReachTheDaemon(t *testing.T) {
+	d, client := newFakeDaemon(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"", "../../_ping", "a/b", "a b", "-rm", ".hidden", "a%2Fb"} {
+		if _, err := client.InspectContainer(ctx, id); !IsNotFound(err) {
+			t.Errorf("InspectContainer(%q) = %v, want a 404", id, err)
+		}
+		if err := client.StopContainer(ctx, id); !IsNotFound(err) {
+			t.Errorf("StopContainer(%q) = %v, want a 404", id, err)
+		}
+	}
+	if requests := d.sent(); len(requests) > 0 {
+		t.Errorf("daemon received %v", requests)
+	}
+
+	// Names with dots, dashes and underscores are sent unescaped
+	d.add("my_app-1.web", "web", false, false)
+	if err := client.StartContainer(ctx, "my_app-1.web"); err != nil {
+		t.Fatalf("StartContainer: %v", err)
+	}
+	if requests := d.sent(); requests[len(requests)-1] != "POST /containers/my_app-1.web/start" {
+		t.Errorf("request = %q", requests[len(requests)-1])
+	}
+}
+
+func TestStartStopContainer(t *testing.T) {
+	d, client := newFakeDaemon(t)
+	d.add("aaa111", "web", false, false)
+	ctx := context.Background()
+
+	if err := client.StartContainer(ctx, "aaa111"); err != nil {
+		t.Fatalf("StartContainer: %v", err)
+	}
+	if got := d.state("aaa111"); got != "running" {
+		t.Errorf("state after start = %q", got)
+	}
+	// Starting a running container answers 304, which is not an error
+	if err := client.StartContainer(ctx, "aaa111"); err != nil {
+		t.Errorf("second StartContainer: %v", err)
+	}
+	if err := client.StopContainer(ctx, "aaa111"); err != nil {
+		t.Fatalf("StopContainer: %v", err)
+	}
+	if got := d.state("aaa111"); got != "exited" {
+		t.Errorf("state after stop = %q", got)
+	}
+	if err := client.StopContainer(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("stopping a missing container: got %v, want a 404", err)
+	}
+}
+
+func TestRemoveContainer(t *testing.T) {
+	d, client := newFakeDaemon(t)
+	d.add("aaa111", "web", true, false)
+	ctx := context.Background()
+
+	err := client.RemoveContainer(ctx, "aaa111", false)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Fatalf("removing a running container: got %v, want a 409", err)
+	}
+	if err := client.RemoveContainer(ctx, "aaa111", true); err != nil {
+		t.Fatalf("RemoveContainer(force): %v", err)
+	}
+	if got := d.state("aaa111"); got != "" {
+		t.Errorf("container still exists with state %q", got)
+	}
+}
+
+func TestContainerLogsDemuxed(t *testing.T) {
+	d, client := newFakeDaemon(t)
+	d.add("aaa111", "web", true, false)
+
+	stdout := frame(1, "2024-01-01T00:00:00Z hello\n")
+	stderr := frame(2, "2024-01-01T00:00:01Z oops\n")
+	// The second frame is split inside its header and its payload
+	d.logs = [][]byte{stdout, stderr[:3], stderr[3:12], stderr[12:]}
+
+	logs, err := client.ContainerLogs(context.Background(), "aaa111", 100, false)
+	if err != nil {
+		t.Fatalf("ContainerLogs: %v", err)
+	}
+	defer logs.Close()
+	content, err := io.ReadAll(logs)
+	if err != nil {
+		t.Fatalf("reading logs: %v", err)
+	}
+	want := "2024-01-01T00:00:00Z hello\n2024-01-01T00:00:01Z oops\n"
+	if string(content) != want {
+		t.Errorf("logs = %q, want %q", content, want)
+	}
+	if requests := d.sent(); requests[len(requests)-1] != "GET /containers/aaa111/logs" {
+		t.Errorf("request = %q", requests[len(requests)-1])
+	}
+}
+
+func TestContainerLogsTTY(t *testing.T) {
+	d, client := newFakeDaemon(t)
+	d.add("aaa111", "web", true, true)
+	// Containers with a TTY send a plain stream
+	d.logs = [][]byte{[]byte("plain output\n")}
+
+	logs, err := client.ContainerLogs(context.Background(), "aaa111", 0, false)
+	if err != nil {
+		t.Fatalf("ContainerLogs: %v", err)
+	}
+	defer logs.Close()
+	content, _ := io.ReadAll(logs)
+	if string(content) != "plain output\n" {
+		t.Errorf("logs = %q", content)
+	}
+}
+
+func TestPingUnavailable(t *testing.T) {
+	client := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+	if err := client.Ping(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Ping = %v, want ErrUnavailable", err)
+	}
+}
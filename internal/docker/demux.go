@@ -0,0 +1,42 @@
+package docker
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// demuxReader decodes Docker's multiplexed stdout/stderr stream format.
+// Each frame starts with an 8 byte header: stream type, three zero bytes,
+// and the big-endian payload length.
+type demuxReader struct {
+	src       io.ReadCloser
+	remaining uint32
+}
+
+func newDemuxReader(src io.ReadCloser) io.ReadCloser {
+	return &demuxReader{src: src}
+}
+
+func (r *demuxReader) Read(p []byte) (int, error) {
+	for r.remaining == 0 {
+		var header [8]byte
+		if _, err := io.ReadFull(r.src, header[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		r.remaining = binary.BigEndian.Uint32(header[4:])
+	}
+
+	if uint32(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.src.Read(p)
+	r.remaining -= uint32(n)
+	return n, err
+}
+
+func (r *demuxReader) Close() error {
+	return r.src.Close()
+}
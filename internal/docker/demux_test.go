@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDemuxReader(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(frame(1, "first line\n"))
+	stream.Write(frame(2, "")) // empty frames are skipped
+	stream.Write(frame(2, "second line\n"))
+	want := "first line\nsecond line\n"
+
+	tests := []struct {
+		name string
+		src  func() io.Reader
+	}{
+		{"whole", func() io.Reader { return bytes.NewReader(stream.Bytes()) }},
+		// Every header and payload is split across reads
+		{"one byte reads", func() io.Reader { return iotest.OneByteReader(bytes.NewReader(stream.Bytes())) }},
+		{"half reads", func() io.Reader { return iotest.HalfReader(bytes.NewReader(stream.Bytes())) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(newDemuxReader(io.NopCloser(tt.src())))
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+			if string(got) != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDemuxReaderTruncatedHeader(t *testing.T) {
+	stream := append(frame(1, "complete\n"), 1, 0, 0)
+	got, err := io.ReadAll(newDemuxReader(io.NopCloser(bytes.NewReader(stream))))
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "complete\n" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,214 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Port is a port mapping of a container
+type Port struct {
+	IP          string `json:"IP"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort"`
+	Type        string `json:"Type"`
+}
+
+// String formats the port as shown by `docker ps`
+func (p Port) String() string {
+	if p.PublicPort == 0 {
+		return fmt.Sprintf("%d/%s", p.PrivatePort, p.Type)
+	}
+	ip := p.IP
+	if ip == "" {
+		ip = "0.0.0.0"
+	}
+	return fmt.Sprintf("%s:%d->%d/%s", ip, p.PublicPort, p.PrivatePort, p.Type)
+}
+
+// Container is a summary entry returned by the container list endpoint
+type Container struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	Command string            `json:"Command"`
+	Created int64             `json:"Created"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Ports   []Port            `json:"Ports"`
+	Labels  map[string]string `json:"Labels"`
+}
+
+// Name returns the primary container name without the leading slash
+func (c Container) Name() string {
+	if len(c.Names) == 0 {
+		return ShortID(c.ID)
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// PortList returns the container's port mappings, de-duplicated and sorted
+func (c Container) PortList() []string {
+	seen := map[string]bool{}
+	var ports []string
+	for _, p := range c.Ports {
+		s := p.String()
+		if !seen[s] {
+			seen[s] = true
+			ports = append(ports, s)
+		}
+	}
+	sort.Strings(ports)
+	return ports
+}
+
+// ContainerState describes the runtime state of a container
+type ContainerState struct {
+	Status     string `json:"Status"`
+	Running    bool   `json:"Running"`
+	Paused     bool   `json:"Paused"`
+	Restarting bool   `json:"Restarting"`
+	OOMKilled  bool   `json:"OOMKilled"`
+	Pid        int    `json:"Pid"`
+	ExitCode   int    `json:"ExitCode"`
+	Error      string `json:"Error"`
+	StartedAt  string `json:"StartedAt"`
+	FinishedAt string `json:"FinishedAt"`
+}
+
+// ContainerConfig is the portion of the container configuration Sysara shows
+type ContainerConfig struct {
+	Hostname   string            `json:"Hostname"`
+	Image      string            `json:"Image"`
+	Env        []string          `json:"Env"`
+	Cmd        []string          `json:"Cmd"`
+	Entrypoint []string          `json:"Entrypoint"`
+	WorkingDir string            `json:"WorkingDir"`
+	Labels     map[string]string `json:"Labels"`
+	Tty        bool              `json:"Tty"`
+}
+
+// Mount is a volume or bind mount attached to a container
+type Mount struct {
+	Type        string `json:"Type"`
+	Name        string `json:"Name"`
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+	Mode        string `json:"Mode"`
+	RW          bool   `json:"RW"`
+}
+
+// EndpointSettings describes a container's attachment to a network
+type EndpointSettings struct {
+	IPAddress  string `json:"IPAddress"`
+	Gateway    string `json:"Gateway"`
+	MacAddress string `json:"MacAddress"`
+}
+
+// ContainerDetails is the response of the container inspect endpoint
+type ContainerDetails struct {
+	ID           string          `json:"Id"`
+	Name         string          `json:"Name"`
+	Created      string          `json:"Created"`
+	Path         string          `json:"Path"`
+	Args         []string        `json:"Args"`
+	Image        string          `json:"Image"`
+	RestartCount int             `json:"RestartCount"`
+	State        ContainerState  `json:"State"`
+	Config       ContainerConfig `json:"Config"`
+	HostConfig   struct {
+		RestartPolicy struct {
+			Name string `json:"Name"`
+		} `json:"RestartPolicy"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]EndpointSettings `json:"Networks"`
+	} `json:"NetworkSettings"`
+	Mounts []Mount `json:"Mounts"`
+
+	// Raw holds the unmodified inspect JSON
+	Raw []byte `json:"-"`
+}
+
+// DisplayName returns the container name without the leading slash
+func (d ContainerDetails) DisplayName() string {
+	return strings.TrimPrefix(d.Name, "/")
+}
+
+// ContainerStats is a single resource usage sample
+type ContainerStats struct {
+	CPUPercent    float64
+	MemoryUsage   uint64
+	MemoryLimit   uint64
+	MemoryPercent float64
+	NetworkRx     uint64
+	NetworkTx     uint64
+}
+
+// statsResponse mirrors the fields of the stats endpoint used to compute usage
+type statsResponse struct {
+	CPUStats    cpuStats `json:"cpu_stats"`
+	PreCPUStats cpuStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+}
+
+type cpuStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// toStats computes usage the same way the docker CLI does
+func (s statsResponse) toStats() *ContainerStats {
+	stats := &ContainerStats{
+		MemoryLimit: s.MemoryStats.Limit,
+	}
+
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(s.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+
+	// Page cache is not counted as used memory (cgroup v1 and v2 keys)
+	usage := s.MemoryStats.Usage
+	if cache, ok := s.MemoryStats.Stats["total_inactive_file"]; ok && cache < usage {
+		usage -= cache
+	} else if cache, ok := s.MemoryStats.Stats["inactive_file"]; ok && cache < usage {
+		usage -= cache
+	}
+	stats.MemoryUsage = usage
+	if s.MemoryStats.Limit > 0 {
+		stats.MemoryPercent = float64(usage) / float64(s.MemoryStats.Limit) * 100
+	}
+
+	for _, n := range s.Networks {
+		stats.NetworkRx += n.RxBytes
+		stats.NetworkTx += n.TxBytes
+	}
+
+	return stats
+}
+
+// ShortID returns the 12 character form of a container ID
+func ShortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,40 @@
+package envfile
+
+// KeyComparison is a key of two compared files. Kind is DiffRemoved for
+// keys only in the left file and DiffAdded for keys only in the right one.
+type KeyComparison struct {
+	Key   string
+	Kind  DiffKind
+	Left  string
+	Right string
+}
+
+// Compare aligns two files by key. Keys are listed in the order of the
+// left file, followed by the keys that only the right file defines.
+func Compare(left, right *File) []KeyComparison {
+	leftValues := left.Map()
+	rightValues := right.Map()
+
+	var rows []KeyComparison
+	for _, key := range left.Keys() {
+		row := KeyComparison{Key: key, Left: leftValues[key]}
+		value, ok := rightValues[key]
+		switch {
+		case !ok:
+			row.Kind = DiffRemoved
+		case value == row.Left:
+			row.Kind = DiffEqual
+			row.Right = value
+		default:
+			row.Kind = DiffChanged
+			row.Right = value
+		}
+		rows = append(rows, row)
+	}
+	for _, key := range right.Keys() {
+		if _, ok := leftValues[key]; !ok {
+			rows = append(rows, KeyComparison{Key: key, Kind: DiffAdded, Right: rightValues[key]})
+		}
+	}
+	return rows
+}
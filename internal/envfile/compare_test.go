@@ -0,0 +1,59 @@
+package envfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name  string
+		left  string
+		right string
+		want  []KeyComparison
+	}{
+		{
+			name:  "equal, changed, removed and added",
+			left:  "A=1\nB=2\nC=3\n",
+			right: "D=4\nB=20\nA=1\n",
+			want: []KeyComparison{
+				{Key: "A", Kind: DiffEqual, Left: "1", Right: "1"},
+				{Key: "B", Kind: DiffChanged, Left: "2", Right: "20"},
+				{Key: "C", Kind: DiffRemoved, Left: "3"},
+				{Key: "D", Kind: DiffAdded, Right: "4"},
+			},
+		},
+		{
+			name:  "empty value is not a missing key",
+			left:  "A=\n",
+			right: "A=x\n",
+			want:  []KeyComparison{{Key: "A", Kind: DiffChanged, Right: "x"}},
+		},
+		{
+			name:  "duplicate keys compare their last definition",
+			left:  "A=1\nA=2\n",
+			right: "A=2\n",
+			want:  []KeyComparison{{Key: "A", Kind: DiffEqual, Left: "2", Right: "2"}},
+		},
+		{
+			name:  "quoting does not matter",
+			left:  "A='x y'\n",
+			right: "A=\"x y\" # comment\n",
+			want:  []KeyComparison{{Key: "A", Kind: DiffEqual, Left: "x y", Right: "x y"}},
+		},
+		{
+			name:  "both empty",
+			left:  "# only comments\n",
+			right: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, _ := Parse(tt.left)
+			right, _ := Parse(tt.right)
+			if got := Compare(left, right); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package envfile
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats an env file can be exported to or imported from
+const (
+	FormatDotenv    = "dotenv"
+	FormatJSON      = "json"
+	FormatYAML      = "yaml"
+	FormatSecret    = "k8s-secret"
+	FormatConfigMap = "k8s-configmap"
+	FormatCompose   = "compose"
+	FormatShell     = "shell"
+)
+
+// ExportFormats lists the export formats in display order
+var ExportFormats = []string{FormatJSON, FormatYAML, FormatSecret, FormatConfigMap, FormatCompose, FormatShell, FormatDotenv}
+
+// ImportFormats lists the import formats in display order
+var ImportFormats = []string{FormatDotenv, FormatJSON, FormatYAML}
+
+// Pair is a key and its value in file order
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// Pairs returns the effective variables of the file in order
+func (f *File) Pairs() []Pair {
+	values := f.Map()
+	keys := f.Keys()
+	pairs := make([]Pair, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, Pair{Key: key, Value: values[key]})
+	}
+	return pairs
+}
+
+// Apply sets the pairs in the file, appending new keys at the end. With
+// replace, keys that are not among the pairs are removed.
+func (f *File) Apply(pairs []Pair, replace bool) error {
+	keep := make(map[string]bool, len(pairs))
+	for _, pair := range pairs {
+		if err := f.Set(pair.Key, pair.Value); err != nil {
+			return err
+		}
+		keep[pair.Key] = true
+	}
+	if replace {
+		for _, key := range f.Keys() {
+			if !keep[key] {
+				f.Delete(key)
+			}
+		}
+	}
+	return nil
+}
+
+// ExportExtension returns the file extension used for a format
+func ExportExtension(format string) string {
+	switch format {
+	case FormatJSON:
+		return ".json"
+	case FormatYAML, FormatSecret, FormatConfigMap, FormatCompose:
+		return ".yaml"
+	case FormatShell:
+		return ".sh"
+	}
+	return ".env"
+}
+
+// Export renders the pairs in the given format. name is used for the
+// Kubernetes resource and the docker-compose service.
+func Export(pairs []Pair, format, name string) ([]byte, error) {
+	switch format {
+	case FormatDotenv:
+		var b strings.Builder
+		for _, pair := range pairs {
+			b.WriteString(pair.Key + "=" + FormatValue(pair.Value) + "\n")
+		}
+		return []byte(b.String()), nil
+	case FormatJSON:
+		return exportJSON(pairs)
+	case FormatYAML:
+		return encodeYAML(mappingNode(pairs, nil))
+	case FormatSecret:
+		return encodeYAML(manifest("Secret", ResourceName(name), "data", mappingNode(pairs, func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		}), "type", "Opaque"))
+	case FormatConfigMap:
+		return encodeYAML(manifest("ConfigMap", ResourceName(name), "data", mappingNode(pairs, nil)))
+	case FormatCompose:
+		// Compose interpolates $ in values, $$ is a literal dollar sign
+		environment := mappingNode(pairs, func(value string) string {
+			return strings.ReplaceAll(value, "$", "$$")
+		})
+		service := mapping("environment", environment)
+		return encodeYAML(mapping("services", mapping(ResourceName(name), service)))
+	case FormatShell:
+		var b strings.Builder
+		b.WriteString("#!/bin/sh\n")
+		for _, pair := range pairs {
+			// Dotted and dashed keys are valid in dotenv files but not in sh
+			if !shellNamePattern.MatchString(pair.Key) {
+				return nil, fmt.Errorf("%s is not a valid shell variable name, export it in another format", pair.Key)
+			}
+			b.WriteString("export " + pair.Key + "=" + shellQuote(pair.Value) + "\n")
+		}
+		return []byte(b.String()), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// Import reads variables from a JSON or YAML object or a dotenv file.
+// Kubernetes Secret and ConfigMap manifests are read from their data.
+// An empty format is detected from the file name and content.
+func Import(data []byte, format, filename string) ([]Pair, error) {
+	if format == "" {
+		format = detectFormat(data, filename)
+	}
+
+	var pairs []Pair
+	switch format {
+	case FormatDotenv:
+		file, issues := Parse(string(data))
+		if len(issues) > 0 {
+			return nil, fmt.Errorf("line %d: %s", issues[0].Line, issues[0].Message)
+		}
+		pairs = file.Pairs()
+	case FormatJSON, FormatYAML:
+		// JSON is a subset of YAML, the node tree keeps the key order
+		var document yaml.Node
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", strings.ToUpper(format), err)
+		}
+		if len(document.Content) == 0 {
+			return nil, errors.New("the file is empty")
+		}
+		var err error
+		if pairs, err = importNode(document.Content[0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+
+	seen := map[string]bool{}
+	for _, pair := range pairs {
+		if !ValidKey(pair.Key) {
+			return nil, fmt.Errorf("invalid key %q", pair.Key)
+		}
+		if seen[pair.Key] {
+			return nil, fmt.Errorf("duplicate key %s", pair.Key)
+		}
+		seen[pair.Key] = true
+	}
+	return pairs, nil
+}
+
+// ResourceName turns a name into a valid Kubernetes resource name
+func ResourceName(name string) string {
+	name = strings.Trim(invalidResourceChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(name) > 63 {
+		name = strings.Trim(name[:63], "-")
+	}
+	if name == "" {
+		return "env"
+	}
+	return name
+}
+
+var invalidResourceChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// detectFormat guesses the format of an upload
+func detectFormat(data []byte, filename string) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatJSON
+	}
+	// A dotenv file has no "key: value" lines
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if colon := strings.Index(line, ":"); colon > 0 && (!strings.Contains(line, "=") || colon < strings.Index(line, "=")) {
+			return FormatYAML
+		}
+	}
+	return FormatDotenv
+}
+
+// importNode reads the pairs of a mapping, unwrapping Kubernetes manifests
+func importNode(node *yaml.Node) ([]Pair, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, errors.New("expected an object of keys and values")
+	}
+
+	if kind := lookupNode(node, "kind"); kind != nil && (kind.Value == "Secret" || kind.Value == "ConfigMap") {
+		var pairs []Pair
+		if data := lookupNode(node, "data"); data != nil {
+			values, err := scalarPairs(data)
+			if err != nil {
+				return nil, err
+			}
+			if kind.Value == "Secret" {
+				for i := range values {
+					decoded, err := base64.StdEncoding.DecodeString(values[i].Value)
+					if err != nil {
+						return nil, fmt.Errorf("%s is not valid base64", values[i].Key)
+					}
+					values[i].Value = string(decoded)
+				}
+			}
+			pairs = append(pairs, values...)
+		}
+		if stringData := lookupNode(node, "stringData"); stringData != nil {
+			values, err := scalarPairs(stringData)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, values...)
+		}
+		return pairs, nil
+	}
+	return scalarPairs(node)
+}
+
+// scalarPairs reads a mapping whose values are all scalars
+func scalarPairs(node *yaml.Node) ([]Pair, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, errors.New("expected an object of keys and values")
+	}
+	var pairs []Pair
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("the value of %s must be a string, number or boolean", key.Value)
+		}
+		if value.Tag == "!!null" {
+			pairs = append(pairs, Pair{Key: key.Value})
+			continue
+		}
+		pairs = append(pairs, Pair{Key: key.Value, Value: value.Value})
+	}
+	return pairs, nil
+}
+
+// lookupNode returns the value of key in a mapping
+func lookupNode(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// exportJSON writes an object keeping the order of the pairs
+func exportJSON(pairs []Pair) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, pair := range pairs {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		key, _ := json.Marshal(pair.Key)
+		value, _ := json.Marshal(pair.Value)
+		b.WriteString("\n  ")
+		b.Write(key)
+		b.WriteString(": ")
+		b.Write(value)
+	}
+	if len(pairs) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return b.Bytes(), nil
+}
+
+// mappingNode builds a YAML mapping of string values in pair order
+func mappingNode(pairs []Pair, transform func(string) string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, pair := range pairs {
+		value := pair.Value
+		if transform != nil {
+			value = transform(value)
+		}
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: pair.Key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+		)
+	}
+	return node
+}
+
+// mapping builds a YAML mapping from alternating keys and values, which
+// are either strings or nodes
+func mapping(entries ...interface{}) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, entry := range entries {
+		switch v := entry.(type) {
+		case *yaml.Node:
+			node.Content = append(node.Content, v)
+		case string:
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: v})
+		}
+	}
+	return node
+}
+
+// manifest builds a Kubernetes manifest with the given fields
+func manifest(kind, name string, fields ...interface{}) *yaml.Node {
+	entries := []interface{}{
+		"apiVersion", "v1",
+		"kind", kind,
+		"metadata", mapping("name", name),
+	}
+	return mapping(append(entries, fields...)...)
+}
+
+// encodeYAML writes a node as a YAML document
+func encodeYAML(node *yaml.Node) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// shellNamePattern matches the variable names POSIX shells accept
+var shellNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// shellQuote quotes a value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
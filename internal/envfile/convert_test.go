@@ -0,0 +1,199 @@
+package envfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var convertPairs = []Pair{
+	{Key: "NAME", Value: "my app"},
+	{Key: "PRICE", Value: "$5 'each'"},
+	{Key: "EMPTY", Value: ""},
+	{Key: "PORT", Value: "8080"},
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatDotenv, "NAME='my app'\nPRICE=\"\\$5 'each'\"\nEMPTY=\nPORT=8080\n"},
+		{FormatJSON, "{\n  \"NAME\": \"my app\",\n  \"PRICE\": \"$5 'each'\",\n  \"EMPTY\": \"\",\n  \"PORT\": \"8080\"\n}\n"},
+		{FormatYAML, "NAME: my app\nPRICE: $5 'each'\nEMPTY: \"\"\nPORT: \"8080\"\n"},
+		{FormatSecret, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: my-app\ndata:\n  NAME: bXkgYXBw\n  PRICE: JDUgJ2VhY2gn\n  EMPTY: \"\"\n  PORT: ODA4MA==\ntype: Opaque\n"},
+		{FormatConfigMap, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: my-app\ndata:\n  NAME: my app\n  PRICE: $5 'each'\n  EMPTY: \"\"\n  PORT: \"8080\"\n"},
+		{FormatCompose, "services:\n  my-app:\n    environment:\n      NAME: my app\n      PRICE: $$5 'each'\n      EMPTY: \"\"\n      PORT: \"8080\"\n"},
+		{FormatShell, "#!/bin/sh\nexport NAME='my app'\nexport PRICE='$5 '\\''each'\\'''\nexport EMPTY=''\nexport PORT='8080'\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := Export(convertPairs, tt.format, "My App")
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Export() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Export(convertPairs, "toml", "app"); err == nil {
+		t.Error("Export to an unknown format succeeded")
+	}
+}
+
+func TestExportShellRejectsInvalidNames(t *testing.T) {
+	for _, key := range []string{"spring.datasource.url", "feature-flag"} {
+		pairs := []Pair{{Key: "OK", Value: "1"}, {Key: key, Value: "x"}}
+		_, err := Export(pairs, FormatShell, "app")
+		want := key + " is not a valid shell variable name, export it in another format"
+		if err == nil || err.Error() != want {
+			t.Errorf("Export(%s) = %v, want %q", key, err, want)
+		}
+		// Other formats carry any key
+		if _, err := Export(pairs, FormatDotenv, "app"); err != nil {
+			t.Errorf("Export(%s) as dotenv: %v", key, err)
+		}
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatDotenv, FormatJSON, FormatYAML, FormatSecret, FormatConfigMap} {
+		t.Run(format, func(t *testing.T) {
+			data, err := Export(convertPairs, format, "app")
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			got, err := Import(data, "", "upload"+ExportExtension(format))
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if !reflect.DeepEqual(got, convertPairs) {
+				t.Errorf("Import() = %+v, want %+v", got, convertPairs)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		format   string
+		filename string
+		want     []Pair
+	}{
+		{
+			name: "json detected from content",
+			data: `{"B": "2", "A": 1, "ON": true, "NULL": null}`,
+			want: []Pair{{"B", "2"}, {"A", "1"}, {"ON", "true"}, {"NULL", ""}},
+		},
+		{
+			name: "yaml detected from content",
+			data: "# settings\nA: one\nB: 'two: three'\n",
+			want: []Pair{{"A", "one"}, {"B", "two: three"}},
+		},
+		{
+			name: "dotenv with a colon in a value",
+			data: "URL=http://example.com\n",
+			want: []Pair{{"URL", "http://example.com"}},
+		},
+		{
+			name:     "format from the file name",
+			data:     "A: 1\n",
+			filename: "values.yml",
+			want:     []Pair{{"A", "1"}},
+		},
+		{
+			name: "secret with string data",
+			data: "kind: Secret\ndata:\n  A: b25l\nstringData:\n  B: two\n",
+			want: []Pair{{"A", "one"}, {"B", "two"}},
+		},
+		{
+			name:   "explicit format",
+			data:   "A=1\n",
+			format: FormatDotenv,
+			want:   []Pair{{"A", "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Import([]byte(tt.data), tt.format, tt.filename)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Import() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		format  string
+		wantErr string
+	}{
+		{"dotenv syntax", "A=1\nB\n", FormatDotenv, "line 2: expected KEY=value"},
+		{"invalid json", `{"A": `, FormatJSON, ""},
+		{"empty document", "", FormatYAML, "the file is empty"},
+		{"not an object", "- a\n- b\n", FormatYAML, "expected an object of keys and values"},
+		{"nested value", "A:\n  B: 1\n", FormatYAML, "the value of A must be a string, number or boolean"},
+		{"invalid base64", "kind: Secret\ndata:\n  A: '!!'\n", FormatYAML, "A is not valid base64"},
+		{"invalid key", `{"MY KEY": "x"}`, FormatJSON, `invalid key "MY KEY"`},
+		{"duplicate key", "A: 1\nA: 2\n", FormatYAML, ""},
+		{"unknown format", "A=1", "toml", `unknown import format "toml"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import([]byte(tt.data), tt.format, "")
+			if err == nil {
+				t.Fatal("Import succeeded")
+			}
+			if tt.wantErr != "" && err.Error() != tt.wantErr {
+				t.Errorf("error = %q, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	tests := map[string]string{
+		"My App":              "my-app",
+		".env.production":     "env-production",
+		"--":                  "env",
+		"":                    "env",
+		"Ünïcode_and spaces!": "n-code-and-spaces",
+		// Names are cut to 63 characters without a trailing dash
+		strings.Repeat("a", 62) + "-b": strings.Repeat("a", 62),
+	}
+	for name, want := range tests {
+		if got := ResourceName(name); got != want {
+			t.Errorf("ResourceName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	f, _ := Parse("# keep\nA=1\nB=2\n")
+	if err := f.Apply([]Pair{{"B", "20"}, {"C", "3"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.String(); got != "# keep\nA=1\nB=20\nC=3\n" {
+		t.Errorf("merge: %q", got)
+	}
+
+	if err := f.Apply([]Pair{{"C", "30"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.String(); got != "# keep\nC=30\n" {
+		t.Errorf("replace: %q", got)
+	}
+
+	if err := f.Apply([]Pair{{"BAD KEY", "x"}}, false); err == nil {
+		t.Error("Apply with an invalid key succeeded")
+	}
+}
@@ -0,0 +1,127 @@
+package envfile
+
+import "strings"
+
+// DiffKind describes how a row of a side-by-side diff changed
+type DiffKind int
+
+const (
+	DiffEqual DiffKind = iota
+	DiffAdded
+	DiffRemoved
+	DiffChanged
+)
+
+// DiffRow is one row of a side-by-side diff. Line numbers are 1-based and
+// zero when the side has no line in this row.
+type DiffRow struct {
+	Kind      DiffKind
+	LeftLine  int
+	Left      string
+	RightLine int
+	Right     string
+}
+
+// maxDiffCells bounds the size of the LCS table for very large files
+const maxDiffCells = 4_000_000
+
+// Diff compares two texts line by line and returns side-by-side rows.
+// Adjacent removals and additions are paired up as changed rows.
+func Diff(left, right string) []DiffRow {
+	a := splitLines(left)
+	b := splitLines(right)
+
+	// Strip the common prefix and suffix to keep the table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var rows []DiffRow
+	for i := 0; i < prefix; i++ {
+		rows = append(rows, DiffRow{Kind: DiffEqual, LeftLine: i + 1, Left: a[i], RightLine: i + 1, Right: b[i]})
+	}
+	rows = append(rows, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := 0; i < suffix; i++ {
+		ai := len(a) - suffix + i
+		bi := len(b) - suffix + i
+		rows = append(rows, DiffRow{Kind: DiffEqual, LeftLine: ai + 1, Left: a[ai], RightLine: bi + 1, Right: b[bi]})
+	}
+	return rows
+}
+
+// diffMiddle diffs the differing middle section using a longest common
+// subsequence; offsets are the number of lines before the section
+func diffMiddle(a, b []string, offsetA, offsetB int) []DiffRow {
+	var removed, added []DiffRow
+	var rows []DiffRow
+	flush := func() {
+		for len(removed) > 0 && len(added) > 0 {
+			r, ad := removed[0], added[0]
+			rows = append(rows, DiffRow{Kind: DiffChanged, LeftLine: r.LeftLine, Left: r.Left, RightLine: ad.RightLine, Right: ad.Right})
+			removed, added = removed[1:], added[1:]
+		}
+		rows = append(rows, removed...)
+		rows = append(rows, added...)
+		removed, added = nil, nil
+	}
+
+	if len(a)*len(b) > maxDiffCells {
+		for i, line := range a {
+			removed = append(removed, DiffRow{Kind: DiffRemoved, LeftLine: offsetA + i + 1, Left: line})
+		}
+		for j, line := range b {
+			added = append(added, DiffRow{Kind: DiffAdded, RightLine: offsetB + j + 1, Right: line})
+		}
+		flush()
+		return rows
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			rows = append(rows, DiffRow{Kind: DiffEqual, LeftLine: offsetA + i + 1, Left: a[i], RightLine: offsetB + j + 1, Right: b[j]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			added = append(added, DiffRow{Kind: DiffAdded, RightLine: offsetB + j + 1, Right: b[j]})
+			j++
+		default:
+			removed = append(removed, DiffRow{Kind: DiffRemoved, LeftLine: offsetA + i + 1, Left: a[i]})
+			i++
+		}
+	}
+	flush()
+	return rows
+}
+
+// splitLines splits text into lines without a trailing empty line
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,421 @@
+package envfile
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LineKind identifies what a line of an env file contains
+type LineKind int
+
+const (
+	Blank LineKind = iota
+	Comment
+	Variable
+	Invalid
+)
+
+var (
+	keyPattern       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+	bareValueSafe    = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=\-]*$`)
+	doubleQuoteEsc   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, `$`, `\$`)
+	doubleQuoteUnesc = map[byte]string{'n': "\n", 'r': "\r", 't': "\t", '"': `"`, '\\': `\`, '$': `$`}
+)
+
+// Line is a logical line of an env file. Multiline quoted values span
+// several physical lines but are a single Line.
+type Line struct {
+	Kind    LineKind
+	Number  int    // 1-based number of the first physical line
+	Raw     string // Original text, reused when the line is unchanged
+	Key     string
+	Value   string // Decoded value
+	Export  bool
+	Quote   byte   // Quote character used for the value, 0 for bare values
+	Comment string // Inline comment after the value, without the leading #
+
+	dirty bool
+}
+
+// File is a parsed env file that preserves comments, blank lines and
+// ordering so that it can be written back with minimal changes
+type File struct {
+	Lines []*Line
+}
+
+// ParseError describes a problem on a specific line
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Parse parses dotenv content. Lines that cannot be parsed are kept
+// verbatim as Invalid lines and reported as errors, as are duplicate keys.
+func Parse(content string) (*File, []ParseError) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.TrimSuffix(content, "\n")
+
+	f := &File{}
+	var errs []ParseError
+	if content == "" {
+		return f, nil
+	}
+
+	physical := strings.Split(content, "\n")
+	for i := 0; i < len(physical); {
+		line, consumed, err := parseLine(physical, i)
+		f.Lines = append(f.Lines, line)
+		if err != "" {
+			errs = append(errs, ParseError{Line: i + 1, Message: err})
+		}
+		i += consumed
+	}
+
+	errs = append(errs, f.duplicateErrors()...)
+	sort.SliceStable(errs, func(a, b int) bool { return errs[a].Line < errs[b].Line })
+	return f, errs
+}
+
+// String serializes the file, rewriting only lines that were changed
+func (f *File) String() string {
+	if len(f.Lines) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range f.Lines {
+		if line.dirty {
+			b.WriteString(line.format())
+		} else {
+			b.WriteString(line.Raw)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Variables returns the variable lines in file order
+func (f *File) Variables() []*Line {
+	var vars []*Line
+	for _, line := range f.Lines {
+		if line.Kind == Variable {
+			vars = append(vars, line)
+		}
+	}
+	return vars
+}
+
+// Keys returns the variable names in file order without duplicates
+func (f *File) Keys() []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, line := range f.Variables() {
+		if !seen[line.Key] {
+			seen[line.Key] = true
+			keys = append(keys, line.Key)
+		}
+	}
+	return keys
+}
+
+// Map returns the variables as a map; later definitions win like in most loaders
+func (f *File) Map() map[string]string {
+	values := map[string]string{}
+	for _, line := range f.Variables() {
+		values[line.Key] = line.Value
+	}
+	return values
+}
+
+// Lookup returns the effective (last) definition of key
+func (f *File) Lookup(key string) (*Line, bool) {
+	for i := len(f.Lines) - 1; i >= 0; i-- {
+		if f.Lines[i].Kind == Variable && f.Lines[i].Key == key {
+			return f.Lines[i], true
+		}
+	}
+	return nil, false
+}
+
+// Get returns the value of key
+func (f *File) Get(key string) (string, bool) {
+	line, ok := f.Lookup(key)
+	if !ok {
+		return "", false
+	}
+	return line.Value, true
+}
+
+// Set updates the effective definition of key, or appends a new variable
+func (f *File) Set(key, value string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	if line, ok := f.Lookup(key); ok {
+		line.SetValue(value)
+		return nil
+	}
+	_, err := f.Append(key, value)
+	return err
+}
+
+// Append adds a new variable at the end of the file, even if key exists
+func (f *File) Append(key, value string) (*Line, error) {
+	if !ValidKey(key) {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	line := &Line{Kind: Variable, Key: key, Value: value, dirty: true}
+	f.Lines = append(f.Lines, line)
+	return line, nil
+}
+
+// Remove deletes a single line from the file
+func (f *File) Remove(target *Line) {
+	for i, line := range f.Lines {
+		if line == target {
+			f.Lines = append(f.Lines[:i], f.Lines[i+1:]...)
+			return
+		}
+	}
+}
+
+// Delete removes every definition of key
+func (f *File) Delete(key string) {
+	lines := f.Lines[:0]
+	for _, line := range f.Lines {
+		if line.Kind == Variable && line.Key == key {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	f.Lines = lines
+}
+
+// SetValue changes the value of a variable line
+func (l *Line) SetValue(value string) {
+	if l.Value != value {
+		l.Value = value
+		l.dirty = true
+	}
+}
+
+// Rename changes the key of a variable line
+func (l *Line) Rename(key string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	if l.Key != key {
+		l.Key = key
+		l.dirty = true
+	}
+	return nil
+}
+
+// SetExport toggles the export prefix of a variable line
+func (l *Line) SetExport(export bool) {
+	if l.Export != export {
+		l.Export = export
+		l.dirty = true
+	}
+}
+
+// SetComment changes the inline comment of a variable line
+func (l *Line) SetComment(comment string) {
+	comment = strings.TrimSpace(comment)
+	if l.Comment != comment {
+		l.Comment = comment
+		l.dirty = true
+	}
+}
+
+// format renders a variable line, keeping the original quote style when
+// it can represent the value
+func (l *Line) format() string {
+	var b strings.Builder
+	if l.Export {
+		b.WriteString("export ")
+	}
+	b.WriteString(l.Key)
+	b.WriteByte('=')
+	b.WriteString(formatValue(l.Value, l.Quote))
+	if l.Comment != "" {
+		b.WriteString(" # ")
+		b.WriteString(l.Comment)
+	}
+	return b.String()
+}
+
+// FormatValue quotes a value so that it parses back to the same string
+func FormatValue(value string) string {
+	return formatValue(value, 0)
+}
+
+func formatValue(value string, preferred byte) string {
+	switch preferred {
+	case '\'':
+		if !strings.Contains(value, "'") {
+			return "'" + value + "'"
+		}
+	case '`':
+		if !strings.Contains(value, "`") {
+			return "`" + value + "`"
+		}
+	case '"':
+		return `"` + doubleQuoteEsc.Replace(value) + `"`
+	}
+
+	if bareValueSafe.MatchString(value) {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+	return `"` + doubleQuoteEsc.Replace(value) + `"`
+}
+
+// ValidKey reports whether key is a valid variable name
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// duplicateErrors reports keys that are defined more than once
+func (f *File) duplicateErrors() []ParseError {
+	var errs []ParseError
+	first := map[string]int{}
+	for _, line := range f.Lines {
+		if line.Kind != Variable {
+			continue
+		}
+		if n, ok := first[line.Key]; ok {
+			errs = append(errs, ParseError{
+				Line:    line.Number,
+				Message: fmt.Sprintf("duplicate key %s (first defined on line %d)", line.Key, n),
+			})
+			continue
+		}
+		first[line.Key] = line.Number
+	}
+	return errs
+}
+
+// parseLine parses the logical line starting at physical[start] and
+// returns it with the number of physical lines it consumed
+func parseLine(physical []string, start int) (*Line, int, string) {
+	raw := physical[start]
+	line := &Line{Number: start + 1, Raw: raw}
+	text := strings.TrimSpace(raw)
+
+	switch {
+	case text == "":
+		line.Kind = Blank
+		return line, 1, ""
+	case strings.HasPrefix(text, "#"):
+		line.Kind = Comment
+		return line, 1, ""
+	}
+
+	if rest, ok := strings.CutPrefix(text, "export "); ok {
+		line.Export = true
+		text = strings.TrimLeft(rest, " \t")
+	}
+
+	eq := strings.IndexByte(text, '=')
+	if eq < 0 {
+		line.Kind = Invalid
+		return line, 1, "expected KEY=value"
+	}
+	key := strings.TrimSpace(text[:eq])
+	if !ValidKey(key) {
+		line.Kind = Invalid
+		return line, 1, fmt.Sprintf("invalid key %q", key)
+	}
+	line.Key = key
+	line.Kind = Variable
+
+	value := strings.TrimLeft(text[eq+1:], " \t")
+	if value == "" {
+		return line, 1, ""
+	}
+
+	quote := value[0]
+	if quote != '"' && quote != '\'' && quote != '`' {
+		// Bare value: an inline comment must be preceded by whitespace
+		if idx := strings.Index(value, " #"); idx >= 0 {
+			line.Comment = strings.TrimSpace(value[idx+2:])
+			value = value[:idx]
+		} else if idx := strings.Index(value, "\t#"); idx >= 0 {
+			line.Comment = strings.TrimSpace(value[idx+2:])
+			value = value[:idx]
+		}
+		line.Value = strings.TrimSpace(value)
+		return line, 1, ""
+	}
+
+	// Quoted value, possibly continuing on the following physical lines
+	line.Quote = quote
+	body := value[1:]
+	consumed := 1
+	for {
+		end := closingQuote(body, quote)
+		if end >= 0 {
+			line.Value = decodeQuoted(body[:end], quote)
+			trailing := strings.TrimSpace(body[end+1:])
+			if trailing != "" {
+				if !strings.HasPrefix(trailing, "#") {
+					line.Kind = Invalid
+					line.Raw = strings.Join(physical[start:start+consumed], "\n")
+					return line, consumed, "unexpected characters after closing quote"
+				}
+				line.Comment = strings.TrimSpace(trailing[1:])
+			}
+			line.Raw = strings.Join(physical[start:start+consumed], "\n")
+			return line, consumed, ""
+		}
+
+		if start+consumed >= len(physical) {
+			line.Kind = Invalid
+			line.Raw = raw
+			return line, 1, fmt.Sprintf("unterminated %c quote", quote)
+		}
+		body += "\n" + physical[start+consumed]
+		consumed++
+	}
+}
+
+// closingQuote finds the unescaped closing quote in s
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+// decodeQuoted resolves escape sequences; only double quotes support them
+func decodeQuoted(s string, quote byte) string {
+	if quote != '"' {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			if r, ok := doubleQuoteUnesc[s[i+1]]; ok {
+				b.WriteString(r)
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,319 @@
+package envfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Line // Kind, Key, Value, Export, Quote and Comment are compared
+	}{
+		{
+			name:    "empty",
+			content: "",
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# database\n\n  # indented\n",
+			want:    []Line{{Kind: Comment}, {Kind: Blank}, {Kind: Comment}},
+		},
+		{
+			name:    "bare values",
+			content: "A=1\nB = two\nEMPTY=\nURL=postgres://u@host:5432/db?sslmode=disable\n",
+			want: []Line{
+				{Kind: Variable, Key: "A", Value: "1"},
+				{Kind: Variable, Key: "B", Value: "two"},
+				{Kind: Variable, Key: "EMPTY"},
+				{Kind: Variable, Key: "URL", Value: "postgres://u@host:5432/db?sslmode=disable"},
+			},
+		},
+		{
+			name:    "inline comments",
+			content: "PORT=8080 # http\nTAB=x\t# tabbed\nHASH=a#b\nQUOTED='x' # quoted\n",
+			want: []Line{
+				{Kind: Variable, Key: "PORT", Value: "8080", Comment: "http"},
+				{Kind: Variable, Key: "TAB", Value: "x", Comment: "tabbed"},
+				{Kind: Variable, Key: "HASH", Value: "a#b"},
+				{Kind: Variable, Key: "QUOTED", Value: "x", Quote: '\'', Comment: "quoted"},
+			},
+		},
+		{
+			name:    "quotes",
+			content: "S='it has # and $HOME'\nD=\"say \\\"hi\\\"\"\nB=`back`\n",
+			want: []Line{
+				{Kind: Variable, Key: "S", Value: "it has # and $HOME", Quote: '\''},
+				{Kind: Variable, Key: "D", Value: `say "hi"`, Quote: '"'},
+				{Kind: Variable, Key: "B", Value: "back", Quote: '`'},
+			},
+		},
+		{
+			name:    "double quote escapes",
+			content: `ESC="a\nb\tc\\d\$e\qf"` + "\n",
+			want: []Line{
+				// Unknown escapes are kept as written
+				{Kind: Variable, Key: "ESC", Value: "a\nb\tc\\d$e\\qf", Quote: '"'},
+			},
+		},
+		{
+			name:    "single quotes keep backslashes",
+			content: `RAW='a\nb'` + "\n",
+			want:    []Line{{Kind: Variable, Key: "RAW", Value: `a\nb`, Quote: '\''}},
+		},
+		{
+			name:    "multiline values",
+			content: "KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nNEXT='one\ntwo' # two lines\n",
+			want: []Line{
+				{Kind: Variable, Key: "KEY", Value: "-----BEGIN-----\nabc\n-----END-----", Quote: '"'},
+				{Kind: Variable, Key: "NEXT", Value: "one\ntwo", Quote: '\'', Comment: "two lines"},
+			},
+		},
+		{
+			name:    "export prefix",
+			content: "export A=1\nexport   B='two'\n",
+			want: []Line{
+				{Kind: Variable, Key: "A", Value: "1", Export: true},
+				{Kind: Variable, Key: "B", Value: "two", Export: true, Quote: '\''},
+			},
+		},
+		{
+			name:    "dotted and dashed keys",
+			content: "spring.datasource.url=jdbc\nfeature-flag=on\n",
+			want: []Line{
+				{Kind: Variable, Key: "spring.datasource.url", Value: "jdbc"},
+				{Kind: Variable, Key: "feature-flag", Value: "on"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, errs := Parse(tt.content)
+			if len(errs) != 0 {
+				t.Fatalf("Parse errors: %v", errs)
+			}
+			var got []Line
+			for _, line := range f.Lines {
+				got = append(got, Line{
+					Kind:    line.Kind,
+					Key:     line.Key,
+					Value:   line.Value,
+					Export:  line.Export,
+					Quote:   line.Quote,
+					Comment: line.Comment,
+				})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %+v, want %+v", got, tt.want)
+			}
+			if s := f.String(); s != tt.content {
+				t.Errorf("String() = %q, want %q", s, tt.content)
+			}
+		})
+	}
+}
+
+func TestParseNormalizesLineEndings(t *testing.T) {
+	f, errs := Parse("A=1\r\nB=2")
+	if len(errs) != 0 {
+		t.Fatalf("Parse errors: %v", errs)
+	}
+	if got := f.String(); got != "A=1\nB=2\n" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []ParseError
+	}{
+		{
+			name:    "missing equals sign",
+			content: "A=1\nNOT A VARIABLE\n",
+			want:    []ParseError{{Line: 2, Message: "expected KEY=value"}},
+		},
+		{
+			name:    "invalid key",
+			content: "1ABC=x\nMY KEY=y\n",
+			want: []ParseError{
+				{Line: 1, Message: `invalid key "1ABC"`},
+				{Line: 2, Message: `invalid key "MY KEY"`},
+			},
+		},
+		{
+			name:    "text after closing quote",
+			content: "A='x' y\n",
+			want:    []ParseError{{Line: 1, Message: "unexpected characters after closing quote"}},
+		},
+		{
+			name:    "unterminated quote",
+			content: "A=\"open\nB=2\n",
+			want:    []ParseError{{Line: 1, Message: `unterminated " quote`}},
+		},
+		{
+			name:    "duplicate key",
+			content: "A=1\nB=2\nA=3\n",
+			want:    []ParseError{{Line: 3, Message: "duplicate key A (first defined on line 1)"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, errs := Parse(tt.content)
+			if !reflect.DeepEqual(errs, tt.want) {
+				t.Errorf("errors = %v, want %v", errs, tt.want)
+			}
+			// Lines that cannot be parsed are written back unchanged
+			if s := f.String(); s != tt.content {
+				t.Errorf("String() = %q, want %q", s, tt.content)
+			}
+		})
+	}
+}
+
+func TestParseUnterminatedQuoteKeepsFollowingLines(t *testing.T) {
+	f, _ := Parse("A='open\nB=2\n")
+	if f.Lines[0].Kind != Invalid {
+		t.Errorf("first line kind = %v, want Invalid", f.Lines[0].Kind)
+	}
+	if got, ok := f.Get("B"); !ok || got != "2" {
+		t.Errorf("B = %q, %v", got, ok)
+	}
+}
+
+func TestEditsRewriteOnlyChangedLines(t *testing.T) {
+	content := "# app\nexport   NAME = 'old'   # keep spacing\nPORT=8080\n\nSECRET=\"a b\"\n"
+	f, errs := Parse(content)
+	if len(errs) != 0 {
+		t.Fatalf("Parse errors: %v", errs)
+	}
+
+	if err := f.Set("PORT", "9090"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("NEW", "with space"); err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := f.Lookup("SECRET")
+	if err := secret.Rename("TOKEN"); err != nil {
+		t.Fatal(err)
+	}
+	secret.SetComment("  rotated ")
+
+	want := "# app\nexport   NAME = 'old'   # keep spacing\nPORT=9090\n\nTOKEN=\"a b\" # rotated\nNEW='with space'\n"
+	if got := f.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// Setting the same value does not rewrite the line
+	name, _ := f.Lookup("NAME")
+	name.SetValue("old")
+	name.SetExport(true)
+	if got := f.String(); got != want {
+		t.Errorf("unchanged line was rewritten: %q", got)
+	}
+}
+
+func TestEditsKeepQuoteStyle(t *testing.T) {
+	tests := []struct {
+		content string
+		value   string
+		want    string
+	}{
+		{"A='x'\n", "new value", "A='new value'\n"},
+		{"A='x'\n", "it's", "A=\"it's\"\n"}, // single quotes cannot hold a quote
+		{"A=\"x\"\n", "line\nbreak", "A=\"line\\nbreak\"\n"},
+		{"A=`x`\n", "$HOME", "A=`$HOME`\n"},
+		{"A=x\n", "simple", "A=simple\n"},
+		{"A=x\n", "two words", "A='two words'\n"},
+	}
+	for _, tt := range tests {
+		f, _ := Parse(tt.content)
+		line, _ := f.Lookup("A")
+		line.SetValue(tt.value)
+		got := f.String()
+		if got != tt.want {
+			t.Errorf("%q set to %q = %q, want %q", tt.content, tt.value, got, tt.want)
+		}
+		// The rewritten line parses back to the new value
+		back, errs := Parse(got)
+		if v, _ := back.Get("A"); len(errs) != 0 || v != tt.value {
+			t.Errorf("%q parses back to %q (errors %v)", got, v, errs)
+		}
+	}
+}
+
+func TestFormatValueRoundTrip(t *testing.T) {
+	values := []string{
+		"",
+		"plain",
+		"with space",
+		"it's",
+		`quote " and \ backslash`,
+		"line\nbreak\r\nand\ttab",
+		"$NOT_EXPANDED",
+		"# not a comment",
+		"trailing space ",
+	}
+	for _, value := range values {
+		content := "KEY=" + FormatValue(value) + "\n"
+		f, errs := Parse(content)
+		if len(errs) != 0 {
+			t.Errorf("%q: Parse errors: %v", content, errs)
+			continue
+		}
+		if got, _ := f.Get("KEY"); got != value {
+			t.Errorf("%q parses to %q, want %q", content, got, value)
+		}
+	}
+}
+
+func TestFileAccessors(t *testing.T) {
+	f, _ := Parse("A=1\n# c\nB=2\nA=3\n")
+
+	if got := f.Keys(); !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Errorf("Keys() = %v", got)
+	}
+	if got := f.Map(); !reflect.DeepEqual(got, map[string]string{"A": "3", "B": "2"}) {
+		t.Errorf("Map() = %v", got)
+	}
+	if got, _ := f.Get("A"); got != "3" {
+		t.Errorf("Get(A) = %q, want the last definition", got)
+	}
+	if _, ok := f.Get("MISSING"); ok {
+		t.Error("Get(MISSING) found a value")
+	}
+
+	f.Delete("A")
+	if got := f.String(); got != "# c\nB=2\n" {
+		t.Errorf("after Delete: %q", got)
+	}
+	line, _ := f.Lookup("B")
+	f.Remove(line)
+	if got := f.String(); got != "# c\n" {
+		t.Errorf("after Remove: %q", got)
+	}
+}
+
+func TestInvalidKeysAreRejected(t *testing.T) {
+	f, _ := Parse("A=1\n")
+	for _, key := range []string{"", "1A", "MY KEY", "A=B", "$A"} {
+		if err := f.Set(key, "x"); err == nil {
+			t.Errorf("Set(%q) succeeded", key)
+		}
+		if _, err := f.Append(key, "x"); err == nil {
+			t.Errorf("Append(%q) succeeded", key)
+		}
+		line, _ := f.Lookup("A")
+		if err := line.Rename(key); err == nil {
+			t.Errorf("Rename(%q) succeeded", key)
+		}
+	}
+	if got := f.String(); got != "A=1\n" {
+		t.Errorf("file changed: %q", got)
+	}
+}
@@ -0,0 +1,58 @@
+package envfile
+
+// MergeConflict is a key that both sides changed differently since the
+// common base version
+type MergeConflict struct {
+	Key           string
+	Theirs        string
+	Yours         string
+	TheirsDeleted bool
+	YoursDeleted  bool
+}
+
+// Merge applies the variable changes made from base to yours onto theirs,
+// keeping the layout of theirs. Keys that theirs changed differently keep
+// their value in theirs and are reported as conflicts. Comment and order
+// changes in yours are not carried over.
+func Merge(base, theirs, yours *File) (*File, []MergeConflict) {
+	merged, _ := Parse(theirs.String())
+	baseValues, theirValues, yourValues := base.Map(), theirs.Map(), yours.Map()
+
+	keys := yours.Keys()
+	for _, key := range base.Keys() {
+		if _, ok := yourValues[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	var conflicts []MergeConflict
+	for _, key := range keys {
+		baseValue, inBase := baseValues[key]
+		yourValue, inYours := yourValues[key]
+		if inBase == inYours && baseValue == yourValue {
+			continue // not changed by you
+		}
+		theirValue, inTheirs := theirValues[key]
+		if inTheirs == inYours && theirValue == yourValue {
+			continue // same change on both sides
+		}
+		if inTheirs != inBase || theirValue != baseValue {
+			conflicts = append(conflicts, MergeConflict{
+				Key:           key,
+				Theirs:        theirValue,
+				Yours:         yourValue,
+				TheirsDeleted: !inTheirs,
+				YoursDeleted:  !inYours,
+			})
+			continue
+		}
+
+		if inYours {
+			// Keys of a parsed file are always valid
+			_ = merged.Set(key, yourValue)
+		} else {
+			merged.Delete(key)
+		}
+	}
+	return merged, conflicts
+}
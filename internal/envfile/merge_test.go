@@ -0,0 +1,97 @@
+package envfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		theirs    string
+		yours     string
+		want      string
+		conflicts []MergeConflict
+	}{
+		{
+			name:   "unchanged by you",
+			base:   "A=1\nB=2\n",
+			theirs: "# new comment\nA=10\nB=2\n",
+			yours:  "A=1\nB=2\n",
+			want:   "# new comment\nA=10\nB=2\n",
+		},
+		{
+			name:   "different keys changed",
+			base:   "A=1\nB=2\n",
+			theirs: "A=10 # theirs\nB=2\n",
+			yours:  "A=1\nB=20\nC=3\n",
+			want:   "A=10 # theirs\nB=20\nC=3\n",
+		},
+		{
+			name:   "deleted by you",
+			base:   "A=1\nB=2\n",
+			theirs: "A=1\nB=2\nC=3\n",
+			yours:  "A=1\n",
+			want:   "A=1\nC=3\n",
+		},
+		{
+			name:   "same change on both sides",
+			base:   "A=1\n",
+			theirs: "A=2\n",
+			yours:  "A=2\n",
+			want:   "A=2\n",
+		},
+		{
+			name:      "both changed",
+			base:      "A=1\nB=2\n",
+			theirs:    "A=10\nB=2\n",
+			yours:     "A=11\nB=20\n",
+			want:      "A=10\nB=20\n",
+			conflicts: []MergeConflict{{Key: "A", Theirs: "10", Yours: "11"}},
+		},
+		{
+			name:      "changed by you, deleted by them",
+			base:      "A=1\nB=2\n",
+			theirs:    "B=2\n",
+			yours:     "A=5\nB=2\n",
+			want:      "B=2\n",
+			conflicts: []MergeConflict{{Key: "A", Yours: "5", TheirsDeleted: true}},
+		},
+		{
+			name:      "deleted by you, changed by them",
+			base:      "A=1\n",
+			theirs:    "A=2\n",
+			yours:     "",
+			want:      "A=2\n",
+			conflicts: []MergeConflict{{Key: "A", Theirs: "2", YoursDeleted: true}},
+		},
+		{
+			name:      "added on both sides with different values",
+			base:      "",
+			theirs:    "A=1\n",
+			yours:     "A=2\n",
+			want:      "A=1\n",
+			conflicts: []MergeConflict{{Key: "A", Theirs: "1", Yours: "2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, _ := Parse(tt.base)
+			theirs, _ := Parse(tt.theirs)
+			yours, _ := Parse(tt.yours)
+
+			merged, conflicts := Merge(base, theirs, yours)
+			if got := merged.String(); got != tt.want {
+				t.Errorf("merged = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(conflicts, tt.conflicts) {
+				t.Errorf("conflicts = %+v, want %+v", conflicts, tt.conflicts)
+			}
+			// Merging does not modify the version of the other side
+			if got := theirs.String(); got != tt.theirs {
+				t.Errorf("theirs changed to %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package envfile
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value types understood by Rule.Type
+const (
+	TypeString   = "string"
+	TypeInt      = "int"
+	TypeBool     = "bool"
+	TypeURL      = "url"
+	TypeDuration = "duration"
+)
+
+// Types lists the supported value types
+var Types = []string{TypeString, TypeInt, TypeBool, TypeURL, TypeDuration}
+
+// boolValues are the spellings accepted for bool values
+var boolValues = map[string]bool{
+	"true": true, "false": true, "1": true, "0": true,
+	"yes": true, "no": true, "on": true, "off": true,
+}
+
+// Rule describes the expected value of a key
+type Rule struct {
+	Key         string
+	Required    bool
+	Type        string
+	Pattern     string   // regular expression the whole value must match
+	Enum        []string // allowed values, empty allows any
+	Default     string
+	Description string
+	Source      string // where the rule comes from, e.g. the example file
+}
+
+// Schema is a set of rules for the keys of an env file
+type Schema struct {
+	Rules []Rule
+}
+
+// Violation is a value or key that does not match the schema. Line is 0
+// for missing keys.
+type Violation struct {
+	Key     string
+	Line    int
+	Message string
+}
+
+// Check verifies that the rule itself is usable: the type is known, the
+// pattern compiles and the default is a valid value
+func (r Rule) Check() error {
+	if !ValidKey(r.Key) {
+		return fmt.Errorf("invalid key %q", r.Key)
+	}
+	known := false
+	for _, t := range Types {
+		known = known || r.Type == t
+	}
+	if !known {
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+	if r.Pattern != "" {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+	}
+	if r.Default != "" {
+		if err := r.ValidateValue(r.Default); err != nil {
+			return fmt.Errorf("invalid default: %v", err)
+		}
+	}
+	return nil
+}
+
+// ValidateValue checks a value against the type, enum and pattern of the
+// rule. Errors do not include the value, which may be secret.
+func (r Rule) ValidateValue(value string) error {
+	switch r.Type {
+	case TypeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return errors.New("must be an integer")
+		}
+	case TypeBool:
+		if !boolValues[strings.ToLower(value)] {
+			return errors.New("must be a boolean (true/false, 1/0, yes/no, on/off)")
+		}
+	case TypeURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			return errors.New("must be an absolute URL")
+		}
+	case TypeDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return errors.New("must be a duration such as 30s or 5m")
+		}
+	}
+
+	if len(r.Enum) > 0 {
+		allowed := false
+		for _, option := range r.Enum {
+			allowed = allowed || value == option
+		}
+		if !allowed {
+			return fmt.Errorf("must be one of %s", strings.Join(r.Enum, ", "))
+		}
+	}
+
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+		if !pattern.MatchString(value) {
+			return fmt.Errorf("must match %s", r.Pattern)
+		}
+	}
+	return nil
+}
+
+// Rule returns the rule of a key
+func (s Schema) Rule(key string) (Rule, bool) {
+	for _, rule := range s.Rules {
+		if rule.Key == key {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Validate checks a file against the schema. Missing required keys are
+// reported after the violations of existing keys, sorted by key.
+func (s Schema) Validate(f *File) []Violation {
+	var violations []Violation
+	for _, line := range f.Variables() {
+		rule, ok := s.Rule(line.Key)
+		if !ok {
+			continue
+		}
+		if line.Value == "" {
+			if rule.Required {
+				violations = append(violations, Violation{Key: line.Key, Line: line.Number, Message: "a value is required"})
+			}
+			continue
+		}
+		if err := rule.ValidateValue(line.Value); err != nil {
+			violations = append(violations, Violation{Key: line.Key, Line: line.Number, Message: err.Error()})
+		}
+	}
+
+	var missing []Violation
+	for _, rule := range s.Missing(f) {
+		message := "required key is missing"
+		if rule.Source != "" {
+			message += " (from " + rule.Source + ")"
+		}
+		missing = append(missing, Violation{Key: rule.Key, Message: message})
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Key < missing[j].Key })
+	return append(violations, missing...)
+}
+
+// Missing returns the rules of required keys that the file does not define
+func (s Schema) Missing(f *File) []Rule {
+	var missing []Rule
+	for _, rule := range s.Rules {
+		if _, ok := f.Lookup(rule.Key); rule.Required && !ok {
+			missing = append(missing, rule)
+		}
+	}
+	return missing
+}
+
+// KeyDiff compares the keys of a file with the keys of a reference file
+// such as .env.example
+type KeyDiff struct {
+	Missing []string // keys of the reference that the file lacks
+	Extra   []string // keys of the file that the reference lacks
+}
+
+// CompareKeys reports the keys that differ between a file and a reference
+func CompareKeys(f, reference *File) KeyDiff {
+	var diff KeyDiff
+	for _, key := range reference.Keys() {
+		if _, ok := f.Lookup(key); !ok {
+			diff.Missing = append(diff.Missing, key)
+		}
+	}
+	for _, key := range f.Keys() {
+		if _, ok := reference.Lookup(key); !ok {
+			diff.Extra = append(diff.Extra, key)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,118 @@
+package envfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRuleValidateValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		value   string
+		wantErr string
+	}{
+		{"any string", Rule{Type: TypeString}, "anything at all", ""},
+		{"int", Rule{Type: TypeInt}, "-42", ""},
+		{"not an int", Rule{Type: TypeInt}, "4.2", "must be an integer"},
+		{"bool", Rule{Type: TypeBool}, "Yes", ""},
+		{"not a bool", Rule{Type: TypeBool}, "maybe", "must be a boolean (true/false, 1/0, yes/no, on/off)"},
+		{"url", Rule{Type: TypeURL}, "https://example.com/path", ""},
+		{"opaque url", Rule{Type: TypeURL}, "mailto:ops@example.com", ""},
+		{"relative url", Rule{Type: TypeURL}, "/path", "must be an absolute URL"},
+		{"duration", Rule{Type: TypeDuration}, "1h30m", ""},
+		{"not a duration", Rule{Type: TypeDuration}, "10", "must be a duration such as 30s or 5m"},
+		{"enum", Rule{Type: TypeString, Enum: []string{"debug", "info"}}, "info", ""},
+		{"not in enum", Rule{Type: TypeString, Enum: []string{"debug", "info"}}, "trace", "must be one of debug, info"},
+		{"pattern", Rule{Type: TypeString, Pattern: `[a-z]+`}, "abc", ""},
+		// The pattern has to match the whole value
+		{"partial pattern match", Rule{Type: TypeString, Pattern: `[a-z]+`}, "abc1", "must match [a-z]+"},
+		{"alternation is anchored", Rule{Type: TypeString, Pattern: `a|b`}, "ab", "must match a|b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.ValidateValue(tt.value)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("ValidateValue(%q) = %q, want %q", tt.value, got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr string
+	}{
+		{"valid", Rule{Key: "PORT", Type: TypeInt, Default: "8080"}, ""},
+		{"invalid key", Rule{Key: "1PORT", Type: TypeInt}, `invalid key "1PORT"`},
+		{"unknown type", Rule{Key: "PORT", Type: "float"}, `unknown type "float"`},
+		{"bad pattern", Rule{Key: "PORT", Type: TypeString, Pattern: "("}, "invalid pattern: error parsing regexp: missing closing ): `(`"},
+		{"bad default", Rule{Key: "PORT", Type: TypeInt, Default: "http"}, "invalid default: must be an integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Check()
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("Check() = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := Schema{Rules: []Rule{
+		{Key: "PORT", Type: TypeInt, Required: true},
+		{Key: "DEBUG", Type: TypeBool},
+		{Key: "NAME", Type: TypeString, Required: true},
+		{Key: "URL", Type: TypeURL, Required: true, Source: ".env.example"},
+		{Key: "API_KEY", Type: TypeString, Required: true},
+	}}
+	f, _ := Parse("PORT=http\nDEBUG=\nNAME=\nUNKNOWN=x\n")
+
+	want := []Violation{
+		{Key: "PORT", Line: 1, Message: "must be an integer"},
+		{Key: "NAME", Line: 3, Message: "a value is required"},
+		{Key: "API_KEY", Message: "required key is missing"},
+		{Key: "URL", Message: "required key is missing (from .env.example)"},
+	}
+	if got := schema.Validate(f); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %+v, want %+v", got, want)
+	}
+
+	valid, _ := Parse("PORT=80\nNAME=app\nURL=https://example.com\nAPI_KEY=k\n")
+	if got := schema.Validate(valid); len(got) != 0 {
+		t.Errorf("Validate() of a valid file = %+v", got)
+	}
+}
+
+func TestSchemaValidateDoesNotLeakValues(t *testing.T) {
+	schema := Schema{Rules: []Rule{{Key: "PASSWORD", Type: TypeInt}}}
+	f, _ := Parse("PASSWORD=hunter2\n")
+	for _, v := range schema.Validate(f) {
+		if strings.Contains(v.Message, "hunter2") {
+			t.Errorf("violation reveals the value: %q", v.Message)
+		}
+	}
+}
+
+func TestCompareKeys(t *testing.T) {
+	f, _ := Parse("A=1\nB=2\nLOCAL=3\n")
+	example, _ := Parse("# example\nA=\nB=\nC=\n")
+
+	got := CompareKeys(f, example)
+	want := KeyDiff{Missing: []string{"C"}, Extra: []string{"LOCAL"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CompareKeys() = %+v, want %+v", got, want)
+	}
+}
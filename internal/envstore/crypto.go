@@ -0,0 +1,170 @@
+package envstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"golang.org/x/crypto/argon2"
+	"gorm.io/gorm"
+)
+
+// encryptedPrefix marks revision content that is encrypted at rest. The
+// project and file name of the revision are authenticated with it.
+const encryptedPrefix = "enc:v2:"
+
+// legacyPrefix marks content encrypted by older versions, without
+// associated data and with a passphrase hashed by SHA-256. It is still
+// read and re-encrypted at startup.
+const legacyPrefix = "enc:v1:"
+
+// Argon2id parameters for passphrases (RFC 9106, second recommendation)
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+)
+
+// ErrDecrypt is returned when stored content cannot be decrypted, which
+// usually means ENV_ENCRYPTION_KEY changed
+var ErrDecrypt = errors.New("failed to decrypt revision, check ENV_ENCRYPTION_KEY")
+
+// sealer encrypts revision content with AES-256-GCM
+type sealer struct {
+	aead    cipher.AEAD
+	legacy  cipher.AEAD // opens content of the legacy format
+	hashKey []byte
+}
+
+// newSealer derives the encryption key from the configured value. A
+// base64 encoded 32 byte key is used as is, anything else is treated as
+// a passphrase and stretched with Argon2id and salt. An empty value
+// disables encryption.
+func newSealer(secret string, salt []byte) (*sealer, error) {
+	if secret == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(secret)
+	legacyKey := key
+	if err != nil || len(key) != 32 {
+		key = argon2.IDKey([]byte(secret), salt, argonTime, argonMemory, argonThreads, 32)
+		sum := sha256.Sum256([]byte(secret))
+		legacyKey = sum[:]
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := newGCM(legacyKey)
+	if err != nil {
+		return nil, err
+	}
+	// Hashes use a separate key derived from the encryption key
+	hashKey := sha256.Sum256(append([]byte("sysara-env-hash:"), key...))
+	return &sealer{aead: aead, legacy: legacy, hashKey: hashKey[:]}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keySalt returns the salt of the install, creating it on first use
+func keySalt(db *gorm.DB) ([]byte, error) {
+	var row models.EnvKeySalt
+	result := db.Order("id").Limit(1).Find(&row)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return row.Salt, nil
+	}
+
+	row.Salt = make([]byte, 16)
+	if _, err := rand.Read(row.Salt); err != nil {
+		return nil, err
+	}
+	if err := db.Create(&row).Error; err != nil {
+		return nil, err
+	}
+	return row.Salt, nil
+}
+
+// revisionAAD binds encrypted content to the file it belongs to, so that
+// it cannot be moved to another file or project
+func revisionAAD(projectID uint, filename string) []byte {
+	return []byte("sysara-env-revision\x00" + strconv.FormatUint(uint64(projectID), 10) + "\x00" + filename)
+}
+
+// seal encrypts plaintext; without a key the content is stored as is
+func (s *sealer) seal(plaintext string, aad []byte) (string, error) {
+	if s == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), aad)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts stored content; plaintext from before encryption was
+// enabled is returned unchanged
+func (s *sealer) open(stored string, aad []byte) (string, error) {
+	var aead cipher.AEAD
+	var encoded string
+	switch {
+	case strings.HasPrefix(stored, encryptedPrefix):
+		encoded = stored[len(encryptedPrefix):]
+		if s != nil {
+			aead = s.aead
+		}
+	case strings.HasPrefix(stored, legacyPrefix):
+		encoded, aad = stored[len(legacyPrefix):], nil
+		if s != nil {
+			aead = s.legacy
+		}
+	default:
+		return stored, nil
+	}
+	if aead == nil {
+		return "", ErrDecrypt
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plaintext), nil
+}
+
+// hash returns the content hash stored with each revision. With a key it
+// is an HMAC so that the hash cannot be used to guess secret values.
+func (s *sealer) hash(content string) string {
+	if s == nil {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
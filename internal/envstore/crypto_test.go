@@ -0,0 +1,187 @@
+package envstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const passphrase = "correct horse battery staple"
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sysara.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.EnvRevision{}, &models.EnvKeySalt{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// legacySeal encrypts content the way older versions did: with the
+// SHA-256 of the passphrase and without associated data
+func legacySeal(t *testing.T, secret, plaintext string) string {
+	t.Helper()
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return legacyPrefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(plaintext), nil))
+}
+
+func TestSealerBindsContentToFile(t *testing.T) {
+	s, err := newSealer(passphrase, []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := s.seal("DB_PASSWORD=secret\n", revisionAAD(1, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, encryptedPrefix) || strings.Contains(sealed, "secret") {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	if got, err := s.open(sealed, revisionAAD(1, ".env")); err != nil || got != "DB_PASSWORD=secret\n" {
+		t.Errorf("open: %q, %v", got, err)
+	}
+
+	// Moved to another file or project the content no longer opens
+	for _, aad := range [][]byte{revisionAAD(1, ".env.production"), revisionAAD(2, ".env"), revisionAAD(12, "env"), nil} {
+		if _, err := s.open(sealed, aad); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("open with %q: got %v, want ErrDecrypt", aad, err)
+		}
+	}
+}
+
+func TestSealerPassphraseUsesSalt(t *testing.T) {
+	a, _ := newSealer(passphrase, []byte("0123456789abcdef"))
+	b, _ := newSealer(passphrase, []byte("fedcba9876543210"))
+	sealed, _ := a.seal("KEY=value\n", revisionAAD(1, ".env"))
+	if _, err := b.open(sealed, revisionAAD(1, ".env")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("another salt: got %v, want ErrDecrypt", err)
+	}
+	if a.hash("KEY=value\n") == b.hash("KEY=value\n") {
+		t.Error("hashes do not depend on the salt")
+	}
+
+	// A base64 encoded 32 byte key is used as is
+	raw := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	c, _ := newSealer(raw, []byte("0123456789abcdef"))
+	d, _ := newSealer(raw, []byte("fedcba9876543210"))
+	sealed, _ = c.seal("KEY=value\n", revisionAAD(1, ".env"))
+	if got, err := d.open(sealed, revisionAAD(1, ".env")); err != nil || got != "KEY=value\n" {
+		t.Errorf("raw key: %q, %v", got, err)
+	}
+}
+
+func TestKeySaltIsStored(t *testing.T) {
+	db := newTestDB(t)
+	first, err := keySalt(db)
+	if err != nil || len(first) != 16 {
+		t.Fatalf("keySalt: %x, %v", first, err)
+	}
+	again, err := keySalt(db)
+	if err != nil || string(again) != string(first) {
+		t.Errorf("second call: %x, %v, want %x", again, err, first)
+	}
+}
+
+func TestStoreEncryptsWithFileBinding(t *testing.T) {
+	db := newTestDB(t)
+	store, err := NewStore(db, 0, 0, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := File{ProjectID: 1, Name: ".env", Path: filepath.Join(t.TempDir(), ".env")}
+	revision, err := store.Save(file, "DB_PASSWORD=secret\n", nil, "initial")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopened with the same passphrase the revision is readable
+	store, err = NewStore(db, 0, 0, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Revision(file, revision.ID)
+	if err != nil || got.Content != "DB_PASSWORD=secret\n" {
+		t.Fatalf("Revision: %+v, %v", got, err)
+	}
+
+	// A revision moved to another project is refused
+	db.Model(&models.EnvRevision{}).Where("id = ?", revision.ID).Update("project_id", 2)
+	if _, err := store.Revision(File{ProjectID: 2, Name: ".env"}, revision.ID); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("moved revision: got %v, want ErrDecrypt", err)
+	}
+}
+
+func TestEncryptExistingMigratesLegacyRevisions(t *testing.T) {
+	db := newTestDB(t)
+	legacy := models.EnvRevision{ProjectID: 1, Filename: ".env", Content: legacySeal(t, passphrase, "OLD=1\n"), Hash: "legacy"}
+	plain := models.EnvRevision{ProjectID: 1, Filename: ".env", Content: "PLAIN=1\n", Hash: "plain"}
+	foreign := models.EnvRevision{ProjectID: 1, Filename: ".env", Content: legacySeal(t, "another passphrase", "OTHER=1\n"), Hash: "foreign"}
+	for _, revision := range []*models.EnvRevision{&legacy, &plain, &foreign} {
+		if err := db.Create(revision).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := NewStore(db, 0, 0, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := File{ProjectID: 1, Name: ".env"}
+
+	// Legacy revisions are readable before they are migrated
+	if got, err := store.Revision(file, legacy.ID); err != nil || got.Content != "OLD=1\n" {
+		t.Errorf("legacy revision: %+v, %v", got, err)
+	}
+
+	n, err := store.EncryptExisting()
+	if err != nil || n != 2 {
+		t.Fatalf("EncryptExisting: %d, %v, want 2", n, err)
+	}
+	for _, tt := range []struct {
+		revision models.EnvRevision
+		content  string
+	}{{legacy, "OLD=1\n"}, {plain, "PLAIN=1\n"}} {
+		var stored models.EnvRevision
+		db.First(&stored, tt.revision.ID)
+		if !strings.HasPrefix(stored.Content, encryptedPrefix) || stored.Hash != store.Hash(tt.content) {
+			t.Errorf("revision %d: content %q, hash %q", stored.ID, stored.Content, stored.Hash)
+		}
+		if got, err := store.Revision(file, stored.ID); err != nil || got.Content != tt.content {
+			t.Errorf("revision %d: %+v, %v", stored.ID, got, err)
+		}
+	}
+
+	// Content of another key is left alone
+	var stored models.EnvRevision
+	db.First(&stored, foreign.ID)
+	if stored.Content != foreign.Content {
+		t.Error("a revision of another key was rewritten")
+	}
+	if n, err := store.EncryptExisting(); err != nil || n != 0 {
+		t.Errorf("second run: %d, %v, want 0", n, err)
+	}
+}
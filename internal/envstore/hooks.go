@@ -0,0 +1,322 @@
+package envstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrHookNotFound is returned when a hook does not belong to a file
+var ErrHookNotFound = errors.New("hook not found")
+
+// maxHookTimeout caps the timeout of a single hook
+const maxHookTimeout = 5 * time.Minute
+
+// maxHookOutput caps the output recorded for a hook run
+const maxHookOutput = 64 * 1024
+
+// HookTypes lists the supported hook types in display order
+var HookTypes = []string{models.EnvHookSystemd, models.EnvHookSignal, models.EnvHookCommand, models.EnvHookHTTP}
+
+var unitName = regexp.MustCompile(`^[A-Za-z0-9@._:-]+$`)
+
+// Hooks runs the commands configured for a file after it was saved, so
+// that the application using the file picks up the new values
+type Hooks struct {
+	db       *gorm.DB
+	commands []string // absolute paths of the commands hooks may run
+	timeout  time.Duration
+	client   *http.Client
+}
+
+// NewHooks creates a hook runner. Command hooks may only run the given
+// commands; timeout applies to hooks without their own.
+func NewHooks(db *gorm.DB, commands []string, timeout time.Duration) *Hooks {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Hooks{db: db, commands: commands, timeout: timeout, client: &http.Client{}}
+}
+
+// Commands returns the allowlisted commands
+func (h *Hooks) Commands() []string {
+	return h.commands
+}
+
+// List returns the hooks of a file in the order they run
+func (h *Hooks) List(file File) ([]models.EnvHook, error) {
+	var hooks []models.EnvHook
+	err := h.db.Where("project_id = ? AND filename = ?", file.ProjectID, file.Name).Order("id").Find(&hooks).Error
+	return hooks, err
+}
+
+// Create validates and adds a hook to a file
+func (h *Hooks) Create(file File, hook *models.EnvHook) error {
+	hook.Target = strings.TrimSpace(hook.Target)
+	hook.Argument = strings.TrimSpace(hook.Argument)
+	if err := h.check(hook); err != nil {
+		return err
+	}
+	hook.ID = 0
+	hook.ProjectID = file.ProjectID
+	hook.Filename = file.Name
+	return h.db.Create(hook).Error
+}
+
+// Delete removes a hook of a file
+func (h *Hooks) Delete(file File, id uint) error {
+	result := h.db.Where("project_id = ? AND filename = ? AND id = ?", file.ProjectID, file.Name, id).Delete(&models.EnvHook{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrHookNotFound
+	}
+	return nil
+}
+
+// Runs returns the most recent hook runs of a file, newest first
+func (h *Hooks) Runs(file File, limit int) ([]models.EnvHookRun, error) {
+	var runs []models.EnvHookRun
+	err := h.db.Where("project_id = ? AND filename = ?", file.ProjectID, file.Name).Order("id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// LastRuns returns the hook runs of the most recently hooked revision
+func (h *Hooks) LastRuns(file File) ([]models.EnvHookRun, error) {
+	var runs []models.EnvHookRun
+	latest := h.db.Model(&models.EnvHookRun{}).Select("MAX(revision_id)").Where("project_id = ? AND filename = ?", file.ProjectID, file.Name)
+	err := h.db.Where("project_id = ? AND filename = ? AND revision_id = (?)", file.ProjectID, file.Name, latest).Order("id").Find(&runs).Error
+	return runs, err
+}
+
+// Run executes the hooks of a file in order after revision was saved,
+// stopping at the first failure. Every run is recorded. It reports
+// whether all hooks succeeded.
+func (h *Hooks) Run(file File, revision *models.EnvRevision) ([]models.EnvHookRun, bool) {
+	hooks, err := h.List(file)
+	if err != nil {
+		return []models.EnvHookRun{{Hook: "load hooks", Output: err.Error()}}, false
+	}
+
+	var runs []models.EnvHookRun
+	for _, hook := range hooks {
+		run := h.run(file, hook, revision)
+		h.db.Create(&run)
+		runs = append(runs, run)
+		if !run.Success {
+			return runs, false
+		}
+	}
+	return runs, true
+}
+
+// run executes a single hook with its timeout
+func (h *Hooks) run(file File, hook models.EnvHook, revision *models.EnvRevision) models.EnvHookRun {
+	timeout := h.timeout
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+	// Not tied to the request so that a closed browser tab cannot abort a restart
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	var output string
+	err := h.check(&hook)
+	if err == nil {
+		switch hook.Type {
+		case models.EnvHookSystemd:
+			output, err = runCommand(exec.CommandContext(ctx, "systemctl", "restart", "--", hook.Target))
+		case models.EnvHookSignal:
+			output, err = signalPIDFile(hook.Target, HookSignals[hook.Argument])
+		case models.EnvHookCommand:
+			cmd := exec.CommandContext(ctx, hook.Target, strings.Fields(hook.Argument)...)
+			cmd.Dir = filepath.Dir(file.Path)
+			cmd.Env = append(os.Environ(),
+				"SYSARA_ENV_FILE="+file.Path,
+				"SYSARA_REVISION="+strconv.FormatUint(uint64(revision.ID), 10),
+			)
+			output, err = runCommand(cmd)
+		case models.EnvHookHTTP:
+			output, err = h.call(ctx, file, hook, revision)
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	run := models.EnvHookRun{
+		HookID:     hook.ID,
+		ProjectID:  file.ProjectID,
+		Filename:   file.Name,
+		RevisionID: revision.ID,
+		Hook:       DescribeHook(hook),
+		Success:    err == nil,
+		Output:     output,
+		Duration:   time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		run.Output = strings.TrimSpace(output + "\n" + err.Error())
+	}
+	return run
+}
+
+// check validates a hook against its type and the command allowlist
+func (h *Hooks) check(hook *models.EnvHook) error {
+	if hook.Timeout < 0 || time.Duration(hook.Timeout)*time.Second > maxHookTimeout {
+		return fmt.Errorf("timeout must be between 0 and %d seconds", int(maxHookTimeout.Seconds()))
+	}
+
+	switch hook.Type {
+	case models.EnvHookSystemd:
+		if !unitName.MatchString(hook.Target) {
+			return errors.New("invalid systemd unit name")
+		}
+	case models.EnvHookSignal:
+		if !filepath.IsAbs(hook.Target) {
+			return errors.New("PID file must be an absolute path")
+		}
+		if hook.Argument == "" {
+			hook.Argument = "HUP"
+		}
+		hook.Argument = strings.TrimPrefix(strings.ToUpper(hook.Argument), "SIG")
+		if _, ok := HookSignals[hook.Argument]; !ok {
+			return fmt.Errorf("unsupported signal %s", hook.Argument)
+		}
+	case models.EnvHookCommand:
+		allowed := false
+		for _, command := range h.commands {
+			allowed = allowed || command == hook.Target
+		}
+		if !allowed {
+			return errors.New("command is not in the allowlist (ENV_HOOK_COMMANDS)")
+		}
+	case models.EnvHookHTTP:
+		u, err := url.Parse(hook.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("URL must be an absolute http or https URL")
+		}
+		if hook.Argument == "" {
+			hook.Argument = http.MethodPost
+		}
+		hook.Argument = strings.ToUpper(hook.Argument)
+		if hook.Argument != http.MethodGet && hook.Argument != http.MethodPost && hook.Argument != http.MethodPut {
+			return errors.New("HTTP method must be GET, POST or PUT")
+		}
+	default:
+		return fmt.Errorf("unknown hook type %q", hook.Type)
+	}
+	return nil
+}
+
+// call requests the endpoint of an HTTP hook. POST and PUT send the
+// file and revision as JSON, never the content.
+func (h *Hooks) call(ctx context.Context, file File, hook models.EnvHook, revision *models.EnvRevision) (string, error) {
+	var body io.Reader
+	if hook.Argument != http.MethodGet {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"project_id": file.ProjectID,
+			"file":       file.Name,
+			"revision":   revision.ID,
+		})
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, hook.Argument, hook.Target, body)
+	if err != nil {
+		return "", err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", "Sysara")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	content, _ := io.ReadAll(io.LimitReader(resp.Body, maxHookOutput))
+	output := strings.TrimSpace("HTTP " + resp.Status + "\n" + string(content))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return output, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return output, nil
+}
+
+// DescribeHook returns a short description of what a hook does
+func DescribeHook(hook models.EnvHook) string {
+	switch hook.Type {
+	case models.EnvHookSystemd:
+		return "restart " + hook.Target
+	case models.EnvHookSignal:
+		return "send SIG" + hook.Argument + " to " + hook.Target
+	case models.EnvHookCommand:
+		return strings.TrimSpace(hook.Target + " " + hook.Argument)
+	case models.EnvHookHTTP:
+		return hook.Argument + " " + hook.Target
+	}
+	return hook.Type
+}
+
+// runCommand runs cmd and returns its combined, truncated output
+func runCommand(cmd *exec.Cmd) (string, error) {
+	var output limitedBuffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	return strings.TrimSpace(output.String()), err
+}
+
+// signalPIDFile sends sig to the process whose ID is in the PID file
+func signalPIDFile(path string, sig syscall.Signal) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 1 {
+		return "", fmt.Errorf("%s does not contain a valid process ID", path)
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return "", err
+	}
+	if err := process.Signal(sig); err != nil {
+		return "", fmt.Errorf("process %d: %w", pid, err)
+	}
+	return fmt.Sprintf("sent %s to process %d", sig, pid), nil
+}
+
+// limitedBuffer keeps the first maxHookOutput bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxHookOutput - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
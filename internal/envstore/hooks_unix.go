@@ -0,0 +1,15 @@
+//go:build !windows
+
+package envstore
+
+import "syscall"
+
+// HookSignals are the signals a hook may send, by name
+var HookSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+}
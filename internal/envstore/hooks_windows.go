@@ -0,0 +1,11 @@
+//go:build windows
+
+package envstore
+
+import "syscall"
+
+// HookSignals are the signals a hook may send, by name. Windows can only
+// terminate processes.
+var HookSignals = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM,
+}
@@ -0,0 +1,307 @@
+package envstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/teams"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrProjectNotFound is returned for unknown project IDs
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrInvalidName is returned for names that are not env files directly inside the root
+	ErrInvalidName = errors.New("invalid environment file name")
+	// ErrOutsideRoot is returned when a path resolves outside the project root
+	ErrOutsideRoot = errors.New("environment file resolves outside the project root")
+)
+
+// File identifies an env file inside a project
+type File struct {
+	ProjectID uint
+	Name      string // file name relative to the project root
+	Path      string // resolved absolute path
+}
+
+// String returns a stable identifier used for audit entries
+func (f File) String() string {
+	return fmt.Sprintf("project:%d/%s", f.ProjectID, f.Name)
+}
+
+// Projects manages env projects, their permissions and path confinement
+type Projects struct {
+	db *gorm.DB
+}
+
+// NewProjects creates a new project manager
+func NewProjects(db *gorm.DB) *Projects {
+	return &Projects{db: db}
+}
+
+// List returns all projects ordered by name
+func (p *Projects) List() ([]models.EnvProject, error) {
+	var projects []models.EnvProject
+	err := p.db.Preload("Team").Order("name").Find(&projects).Error
+	return projects, err
+}
+
+// Get returns a project with its permissions
+func (p *Projects) Get(id uint) (*models.EnvProject, error) {
+	var project models.EnvProject
+	err := p.db.Preload("Team").Preload("Permissions.User").First(&project, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// Create registers a new project
+func (p *Projects) Create(project *models.EnvProject) error {
+	if err := validateProject(project); err != nil {
+		return err
+	}
+	return p.db.Create(project).Error
+}
+
+// Update saves the name, root, description, example file and owning team
+// of a project
+func (p *Projects) Update(project *models.EnvProject) error {
+	if err := validateProject(project); err != nil {
+		return err
+	}
+	return p.db.Model(project).Select("name", "root", "description", "example_file", "team_id", "team_level").Updates(project).Error
+}
+
+// Delete removes a project with its permissions, revisions, secret flags
+// and schema rules.
+// Files on disk are left untouched.
+func (p *Projects) Delete(id uint) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.EnvProjectPermission{}, &models.EnvRevision{}, &models.EnvSecret{}, &models.EnvSchemaRule{}, &models.EnvHook{}, &models.EnvHookRun{}} {
+			if err := tx.Where("project_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&models.EnvProject{}, id).Error
+	})
+}
+
+// SetPermission grants a user read or write access to a project
+func (p *Projects) SetPermission(projectID, userID uint, level string) error {
+	if level != models.EnvAccessRead && level != models.EnvAccessWrite {
+		return fmt.Errorf("invalid access level %q", level)
+	}
+	permission := models.EnvProjectPermission{ProjectID: projectID, UserID: userID, Level: level}
+	return p.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"level", "updated_at"}),
+	}).Create(&permission).Error
+}
+
+// RemovePermission revokes a user's access to a project
+func (p *Projects) RemovePermission(projectID, userID uint) error {
+	return p.db.Where("project_id = ? AND user_id = ?", projectID, userID).
+		Delete(&models.EnvProjectPermission{}).Error
+}
+
+// Access returns the access level of a user to a project, or "" for none.
+// The higher of the user's own permission and the access of the owning team
+// applies.
+func (p *Projects) Access(user *models.User, projectID uint) string {
+	if user.IsAdmin() {
+		return models.EnvAccessWrite
+	}
+	level := ""
+	var permission models.EnvProjectPermission
+	if err := p.db.Where("project_id = ? AND user_id = ?", projectID, user.ID).First(&permission).Error; err == nil {
+		level = permission.Level
+	}
+	var project models.EnvProject
+	if err := p.db.Where("id = ? AND team_id IN (?)", projectID, teams.MemberOf(p.db, user.ID)).First(&project).Error; err == nil {
+		level = higherAccess(level, project.TeamLevel)
+	}
+	return level
+}
+
+// Accessible returns the projects a user can see with the access level to each
+func (p *Projects) Accessible(user *models.User) ([]models.EnvProject, map[uint]string, error) {
+	projects, err := p.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	levels := map[uint]string{}
+	if user.IsAdmin() {
+		for _, project := range projects {
+			levels[project.ID] = models.EnvAccessWrite
+		}
+		return projects, levels, nil
+	}
+
+	var permissions []models.EnvProjectPermission
+	if err := p.db.Where("user_id = ?", user.ID).Find(&permissions).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, permission := range permissions {
+		levels[permission.ProjectID] = permission.Level
+	}
+	var teamIDs []uint
+	if err := teams.MemberOf(p.db, user.ID).Pluck("team_id", &teamIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	member := map[uint]bool{}
+	for _, id := range teamIDs {
+		member[id] = true
+	}
+	for _, project := range projects {
+		if project.TeamID != nil && member[*project.TeamID] {
+			levels[project.ID] = higherAccess(levels[project.ID], project.TeamLevel)
+		}
+	}
+
+	visible := projects[:0]
+	for _, project := range projects {
+		if levels[project.ID] != "" {
+			visible = append(visible, project)
+		}
+	}
+	return visible, levels, nil
+}
+
+// higherAccess returns the higher of two access levels
+func higherAccess(a, b string) string {
+	if a == models.EnvAccessWrite || b == models.EnvAccessWrite {
+		return models.EnvAccessWrite
+	}
+	if a == models.EnvAccessRead || b == models.EnvAccessRead {
+		return models.EnvAccessRead
+	}
+	return ""
+}
+
+// Files lists the env files directly inside the project root
+func (p *Projects) Files(project *models.EnvProject) ([]string, error) {
+	entries, err := os.ReadDir(project.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !ValidName(entry.Name()) {
+			continue
+		}
+		// Only list files that would also be allowed for editing
+		if _, err := p.Resolve(project, entry.Name()); err != nil {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Resolve confines name to the project root. The name must be a plain
+// file name; symlinks are followed and must stay inside the root.
+func (p *Projects) Resolve(project *models.EnvProject, name string) (File, error) {
+	if !ValidName(name) {
+		return File{}, ErrInvalidName
+	}
+
+	root, err := filepath.EvalSymlinks(project.Root)
+	if err != nil {
+		return File{}, fmt.Errorf("project root is not accessible: %w", err)
+	}
+
+	path := filepath.Join(root, name)
+	resolved, err := filepath.EvalSymlinks(path)
+	switch {
+	case os.IsNotExist(err):
+		// New file; dangling symlinks are rejected
+		if _, lerr := os.Lstat(path); lerr == nil {
+			return File{}, ErrOutsideRoot
+		}
+		resolved = path
+	case err != nil:
+		return File{}, err
+	default:
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return File{}, err
+		}
+		if !info.Mode().IsRegular() {
+			return File{}, ErrInvalidName
+		}
+	}
+
+	if !within(root, resolved) {
+		return File{}, ErrOutsideRoot
+	}
+	return File{ProjectID: project.ID, Name: name, Path: resolved}, nil
+}
+
+// ValidName reports whether name is an env file name without any path
+// components. Old "<file>.backup.<pid>" copies are not env files.
+func ValidName(name string) bool {
+	if name != filepath.Base(name) || strings.ContainsAny(name, `/\`) {
+		return false
+	}
+	if !strings.HasPrefix(name, ".env") || strings.Contains(name, ".backup.") {
+		return false
+	}
+	return name != "." && name != ".."
+}
+
+// within reports whether path is inside dir
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// validateProject checks the root and example file of a project
+func validateProject(project *models.EnvProject) error {
+	root, err := validateRoot(project.Root)
+	if err != nil {
+		return err
+	}
+	project.Root = root
+	project.ExampleFile = strings.TrimSpace(project.ExampleFile)
+	if project.ExampleFile != "" && !ValidName(project.ExampleFile) {
+		return errors.New("the example file must be an env file name such as .env.example")
+	}
+	if project.TeamID == nil {
+		project.TeamLevel = ""
+	} else if project.TeamLevel != models.EnvAccessRead && project.TeamLevel != models.EnvAccessWrite {
+		return fmt.Errorf("invalid access level %q", project.TeamLevel)
+	}
+	return nil
+}
+
+// validateRoot checks that root is an existing absolute directory
+func validateRoot(root string) (string, error) {
+	root = strings.TrimSpace(root)
+	if !filepath.IsAbs(root) {
+		return "", errors.New("the project root must be an absolute path")
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("the project root is not accessible: %w", err)
+	}
+	if !info.IsDir() {
+		return "", errors.New("the project root is not a directory")
+	}
+	return filepath.Clean(root), nil
+}
@@ -0,0 +1,122 @@
+package envstore
+
+import (
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/envfile"
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Schemas stores the schema rules of env files and combines them with
+// the keys of the project's example file
+type Schemas struct {
+	db       *gorm.DB
+	projects *Projects
+}
+
+// NewSchemas creates a new schema store
+func NewSchemas(db *gorm.DB, projects *Projects) *Schemas {
+	return &Schemas{db: db, projects: projects}
+}
+
+// Rules returns the stored rules of a file ordered by key
+func (s *Schemas) Rules(file File) ([]models.EnvSchemaRule, error) {
+	var rules []models.EnvSchemaRule
+	err := s.db.Where("project_id = ? AND filename = ?", file.ProjectID, file.Name).Order("key").Find(&rules).Error
+	return rules, err
+}
+
+// SaveRule validates a rule and creates or replaces the rule of its key
+func (s *Schemas) SaveRule(file File, rule *models.EnvSchemaRule) error {
+	rule.ProjectID = file.ProjectID
+	rule.Filename = file.Name
+	rule.Key = strings.TrimSpace(rule.Key)
+	if rule.Type == "" {
+		rule.Type = envfile.TypeString
+	}
+	if err := toRule(*rule).Check(); err != nil {
+		return err
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_id"}, {Name: "filename"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"required", "type", "pattern", "enum", "default", "description", "updated_at"}),
+	}).Create(rule).Error
+}
+
+// DeleteRule removes the rule of a key
+func (s *Schemas) DeleteRule(file File, key string) error {
+	return s.db.Where(&models.EnvSchemaRule{ProjectID: file.ProjectID, Filename: file.Name, Key: key}).
+		Delete(&models.EnvSchemaRule{}).Error
+}
+
+// Schema returns the effective schema of a file: its stored rules plus
+// every key of the project's example file as a required key. The values
+// of the example file serve as defaults.
+func (s *Schemas) Schema(project *models.EnvProject, file File) (envfile.Schema, error) {
+	rules, err := s.Rules(file)
+	if err != nil {
+		return envfile.Schema{}, err
+	}
+
+	var schema envfile.Schema
+	for _, rule := range rules {
+		schema.Rules = append(schema.Rules, toRule(rule))
+	}
+
+	example, err := s.Example(project, file)
+	if err != nil || example == nil {
+		return schema, err
+	}
+	for _, line := range example.Variables() {
+		if _, ok := schema.Rule(line.Key); ok {
+			continue
+		}
+		schema.Rules = append(schema.Rules, envfile.Rule{
+			Key:      line.Key,
+			Required: true,
+			Type:     envfile.TypeString,
+			Default:  line.Value,
+			Source:   project.ExampleFile,
+		})
+	}
+	return schema, nil
+}
+
+// Example returns the parsed example file of the project, or nil if the
+// project has none, it does not exist or file is the example itself
+func (s *Schemas) Example(project *models.EnvProject, file File) (*envfile.File, error) {
+	if project.ExampleFile == "" || project.ExampleFile == file.Name {
+		return nil, nil
+	}
+	example, err := s.projects.Resolve(project, project.ExampleFile)
+	if err != nil {
+		return nil, err
+	}
+	content, exists, err := readFile(example.Path)
+	if err != nil || !exists {
+		return nil, err
+	}
+	parsed, _ := envfile.Parse(content)
+	return parsed, nil
+}
+
+// toRule converts a stored rule for validation
+func toRule(rule models.EnvSchemaRule) envfile.Rule {
+	var enum []string
+	for _, option := range strings.Split(rule.Enum, ",") {
+		if option = strings.TrimSpace(option); option != "" {
+			enum = append(enum, option)
+		}
+	}
+	return envfile.Rule{
+		Key:         rule.Key,
+		Required:    rule.Required,
+		Type:        rule.Type,
+		Pattern:     rule.Pattern,
+		Enum:        enum,
+		Default:     rule.Default,
+		Description: rule.Description,
+	}
+}
@@ -0,0 +1,173 @@
+package envstore
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/envfile"
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaskedValue replaces secret values in the UI. Submitting it back
+// keeps the stored value unchanged.
+const MaskedValue = "********"
+
+// maskedChanged marks a secret that differs between two compared versions
+const maskedChanged = MaskedValue + " (changed)"
+
+// DefaultSecretPatterns are matched case-insensitively against key names
+var DefaultSecretPatterns = []string{
+	"*PASSWORD*", "*PASSWD*", "*SECRET*", "*TOKEN*", "*API_KEY*", "*APIKEY*",
+	"*PRIVATE_KEY*", "*CREDENTIAL*", "*_DSN", "DATABASE_URL",
+}
+
+// Secrets decides which keys hold secret values, either because their
+// name matches a pattern or because they were explicitly marked
+type Secrets struct {
+	db       *gorm.DB
+	patterns []string
+}
+
+// NewSecrets creates a secret detector; without patterns the defaults are used
+func NewSecrets(db *gorm.DB, patterns []string) *Secrets {
+	if len(patterns) == 0 {
+		patterns = DefaultSecretPatterns
+	}
+	upper := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			upper = append(upper, strings.ToUpper(pattern))
+		}
+	}
+	return &Secrets{db: db, patterns: upper}
+}
+
+// MatchesPattern reports whether key looks like a secret by its name
+func (s *Secrets) MatchesPattern(key string) bool {
+	key = strings.ToUpper(key)
+	for _, pattern := range s.patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Marked returns the keys of a file that were explicitly marked secret
+func (s *Secrets) Marked(file File) (map[string]bool, error) {
+	var secrets []models.EnvSecret
+	if err := s.db.Where("project_id = ? AND filename = ?", file.ProjectID, file.Name).Find(&secrets).Error; err != nil {
+		return nil, err
+	}
+	marked := make(map[string]bool, len(secrets))
+	for _, secret := range secrets {
+		marked[secret.Key] = true
+	}
+	return marked, nil
+}
+
+// Mark marks or unmarks a key of a file as secret
+func (s *Secrets) Mark(file File, key string, secret bool) error {
+	flag := models.EnvSecret{ProjectID: file.ProjectID, Filename: file.Name, Key: key}
+	if !secret {
+		return s.db.Where(&flag).Delete(&models.EnvSecret{}).Error
+	}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&flag).Error
+}
+
+// Checker returns a function reporting whether a key of the file is secret
+func (s *Secrets) Checker(file File) (func(key string) bool, error) {
+	marked, err := s.Marked(file)
+	if err != nil {
+		return nil, err
+	}
+	return func(key string) bool {
+		return marked[key] || s.MatchesPattern(key)
+	}, nil
+}
+
+// Mask replaces the values of secret keys in content with MaskedValue
+func Mask(content string, isSecret func(string) bool) string {
+	file, _ := envfile.Parse(content)
+	for _, line := range file.Variables() {
+		if isSecret(line.Key) && line.Value != "" {
+			line.SetValue(MaskedValue)
+		}
+	}
+	return file.String()
+}
+
+// MaskPair masks two versions of a file for comparison. Secrets that
+// differ are masked with a distinct marker so that the change is still
+// visible without revealing either value.
+func MaskPair(from, to string, isSecret func(string) bool) (string, string) {
+	fromFile, _ := envfile.Parse(from)
+	toFile, _ := envfile.Parse(to)
+	fromValues := fromFile.Map()
+
+	for _, line := range fromFile.Variables() {
+		if isSecret(line.Key) && line.Value != "" {
+			line.SetValue(MaskedValue)
+		}
+	}
+	for _, line := range toFile.Variables() {
+		if !isSecret(line.Key) || line.Value == "" {
+			continue
+		}
+		if previous, ok := fromValues[line.Key]; ok && previous != line.Value {
+			line.SetValue(maskedChanged)
+		} else {
+			line.SetValue(MaskedValue)
+		}
+	}
+	return fromFile.String(), toFile.String()
+}
+
+// Unmask restores masked values in submitted content from the current
+// content of the file, matching variables by key
+func Unmask(content, current string) (string, error) {
+	file, _ := envfile.Parse(content)
+	currentFile, _ := envfile.Parse(current)
+
+	changed := false
+	for _, line := range file.Variables() {
+		if line.Value != MaskedValue {
+			continue
+		}
+		saved, ok := currentFile.Lookup(line.Key)
+		if !ok {
+			return "", fmt.Errorf("the value of %s is masked but the key has no saved value, please enter it again", line.Key)
+		}
+		// Keep the saved quoting, the mask itself may have needed quotes
+		line.Quote = saved.Quote
+		line.SetValue(saved.Value)
+		changed = true
+	}
+	if !changed {
+		return content, nil
+	}
+	return file.String(), nil
+}
+
+// MaskComparison masks the values of secret keys in a key comparison.
+// The kind of each row is kept so that changed secrets remain visible.
+func MaskComparison(rows []envfile.KeyComparison, isSecret func(string) bool) {
+	for i := range rows {
+		row := &rows[i]
+		if !isSecret(row.Key) {
+			continue
+		}
+		if row.Left != "" {
+			row.Left = MaskedValue
+		}
+		if row.Right != "" {
+			row.Right = MaskedValue
+			if row.Kind == envfile.DiffChanged {
+				row.Right = maskedChanged
+			}
+		}
+	}
+}
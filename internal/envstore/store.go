@@ -0,0 +1,286 @@
+package envstore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/utils"
+	"gorm.io/gorm"
+)
+
+// ErrRevisionNotFound is returned when a revision does not belong to a file
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Store writes environment files and keeps a revision of every save.
+// Revision content is encrypted at rest when an encryption key is set.
+type Store struct {
+	db     *gorm.DB
+	limit  int           // revisions kept per file, 0 keeps all
+	maxAge time.Duration // age after which revisions are pruned, 0 keeps all
+	sealer *sealer
+
+	mu    sync.Mutex
+	locks map[string]*fileLock // by path, while held or waited for
+}
+
+// fileLock serialises the changes of one file
+type fileLock struct {
+	sync.Mutex
+	users int
+}
+
+// NewStore creates a new revision store
+func NewStore(db *gorm.DB, limit int, maxAge time.Duration, encryptionKey string) (*Store, error) {
+	var salt []byte
+	if encryptionKey != "" {
+		var err error
+		if salt, err = keySalt(db); err != nil {
+			return nil, err
+		}
+	}
+	sealer, err := newSealer(encryptionKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, limit: limit, maxAge: maxAge, sealer: sealer, locks: map[string]*fileLock{}}, nil
+}
+
+// Lock serialises changes of a file and returns the function releasing
+// it. Hold it from reading the content a change is based on until the
+// change is saved, so that concurrent changes cannot overwrite each other.
+func (s *Store) Lock(file File) func() {
+	s.mu.Lock()
+	lock, ok := s.locks[file.Path]
+	if !ok {
+		lock = &fileLock{}
+		s.locks[file.Path] = lock
+	}
+	lock.users++
+	s.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		s.mu.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(s.locks, file.Path)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Encrypted reports whether revisions are encrypted at rest
+func (s *Store) Encrypted() bool {
+	return s.sealer != nil
+}
+
+// Hash returns the content hash stored with each revision
+func (s *Store) Hash(content string) string {
+	return s.sealer.hash(content)
+}
+
+// Save writes content to the file and records it as a new revision. If
+// the file on disk does not match the latest revision (it predates
+// Sysara or was edited by hand), its content is recorded first so that
+// it can still be restored. Callers hold the Lock of the file.
+func (s *Store) Save(file File, content string, author *models.User, message string) (*models.EnvRevision, error) {
+	current, exists, err := readFile(file.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := s.Latest(file)
+	if err != nil {
+		return nil, err
+	}
+	if exists && (latest == nil || latest.Hash != s.Hash(current)) {
+		note := "Recorded existing content"
+		if latest != nil {
+			note = "Recorded changes made outside Sysara"
+		}
+		if latest, err = s.record(file, current, nil, note); err != nil {
+			return nil, err
+		}
+	}
+
+	// Nothing to do if the content is unchanged
+	if exists && latest != nil && latest.Hash == s.Hash(content) {
+		return latest, nil
+	}
+
+	// Written atomically; the owner and mode of an existing file are kept
+	if err := utils.WriteFileAtomic(file.Path, []byte(content), 0600); err != nil {
+		return nil, err
+	}
+
+	revision, err := s.record(file, content, author, message)
+	if err != nil {
+		return nil, err
+	}
+	s.prune(file)
+	return revision, nil
+}
+
+// Revisions returns the revisions of a file, newest first
+func (s *Store) Revisions(file File) ([]models.EnvRevision, error) {
+	var revisions []models.EnvRevision
+	if err := s.db.Preload("Author").Where("project_id = ? AND filename = ?", file.ProjectID, file.Name).Order("id DESC").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	for i := range revisions {
+		if err := s.decrypt(&revisions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+// Revision returns a single revision of a file
+func (s *Store) Revision(file File, id uint) (*models.EnvRevision, error) {
+	var revision models.EnvRevision
+	err := s.db.Preload("Author").Where("project_id = ? AND filename = ? AND id = ?", file.ProjectID, file.Name, id).First(&revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decrypt(&revision); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// RevisionByHash returns the newest revision of a file with the given
+// content hash
+func (s *Store) RevisionByHash(file File, hash string) (*models.EnvRevision, error) {
+	var revisions []models.EnvRevision
+	if err := s.db.Where("project_id = ? AND filename = ? AND hash = ?", file.ProjectID, file.Name, hash).Order("id DESC").Limit(1).Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, ErrRevisionNotFound
+	}
+	if err := s.decrypt(&revisions[0]); err != nil {
+		return nil, err
+	}
+	return &revisions[0], nil
+}
+
+// Latest returns the newest revision of a file, or nil if there is none
+func (s *Store) Latest(file File) (*models.EnvRevision, error) {
+	var revisions []models.EnvRevision
+	if err := s.db.Where("project_id = ? AND filename = ?", file.ProjectID, file.Name).Order("id DESC").Limit(1).Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, nil
+	}
+	return &revisions[0], nil
+}
+
+// record stores a revision without touching the file
+func (s *Store) record(file File, content string, author *models.User, message string) (*models.EnvRevision, error) {
+	sealed, err := s.sealer.seal(content, revisionAAD(file.ProjectID, file.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	revision := &models.EnvRevision{
+		ProjectID: file.ProjectID,
+		Filename:  file.Name,
+		Content:   sealed,
+		Hash:      s.Hash(content),
+		Message:   message,
+	}
+	if author != nil {
+		revision.AuthorID = &author.ID
+	}
+	if err := s.db.Create(revision).Error; err != nil {
+		return nil, err
+	}
+	revision.Content = content
+	return revision, nil
+}
+
+// decrypt replaces the stored content of a revision with its plaintext
+func (s *Store) decrypt(revision *models.EnvRevision) error {
+	content, err := s.sealer.open(revision.Content, revisionAAD(revision.ProjectID, revision.Filename))
+	if err != nil {
+		return err
+	}
+	revision.Content = content
+	return nil
+}
+
+// EncryptExisting encrypts revisions that were stored before encryption
+// was enabled or in the legacy format, and rehashes them with the keyed
+// hash. Revisions that cannot be decrypted with the current key are left
+// as they are.
+func (s *Store) EncryptExisting() (int, error) {
+	if s.sealer == nil {
+		return 0, nil
+	}
+
+	var revisions []models.EnvRevision
+	if err := s.db.Where("content NOT LIKE ?", encryptedPrefix+"%").Find(&revisions).Error; err != nil {
+		return 0, err
+	}
+	encrypted := 0
+	for _, revision := range revisions {
+		if err := s.decrypt(&revision); err != nil {
+			continue
+		}
+		sealed, err := s.sealer.seal(revision.Content, revisionAAD(revision.ProjectID, revision.Filename))
+		if err != nil {
+			return encrypted, err
+		}
+		err = s.db.Model(&models.EnvRevision{}).Where("id = ?", revision.ID).
+			Updates(map[string]interface{}{"content": sealed, "hash": s.Hash(revision.Content)}).Error
+		if err != nil {
+			return encrypted, err
+		}
+		encrypted++
+	}
+	return encrypted, nil
+}
+
+// prune enforces the retention limits. The newest revision is always
+// kept because it describes the content on disk.
+func (s *Store) prune(file File) {
+	var ids []uint
+	s.db.Model(&models.EnvRevision{}).Where("project_id = ? AND filename = ?", file.ProjectID, file.Name).Order("id DESC").Pluck("id", &ids)
+	if len(ids) <= 1 {
+		return
+	}
+
+	if s.limit > 0 && len(ids) > s.limit {
+		s.db.Where("id IN ?", ids[s.limit:]).Delete(&models.EnvRevision{})
+	}
+	if s.maxAge > 0 {
+		s.db.Where("project_id = ? AND filename = ? AND id <> ? AND created_at < ?", file.ProjectID, file.Name, ids[0], time.Now().Add(-s.maxAge)).
+			Delete(&models.EnvRevision{})
+	}
+}
+
+// Read returns the content of the file, or "" if it does not exist yet
+func (s *Store) Read(file File) (string, error) {
+	content, _, err := readFile(file.Path)
+	return content, err
+}
+
+// readFile returns the content of a file and whether it exists
+func readFile(path string) (string, bool, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(content), true, nil
+}
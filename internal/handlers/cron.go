@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/crontab"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// CronHandler handles crontab management
+type CronHandler struct {
+	manager *crontab.Manager
+}
+
+// NewCronHandler creates a new cron handler
+func NewCronHandler(manager *crontab.Manager) *CronHandler {
+	return &CronHandler{manager: manager}
+}
+
+// ListEntries displays the entries of all system and user crontabs
+func (h *CronHandler) ListEntries(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.renderList(c, userModel, http.StatusOK, "")
+}
+
+// PreviewSchedule returns the next run times of a schedule (HTMX endpoint)
+func (h *CronHandler) PreviewSchedule(c *gin.Context) {
+	schedule := c.Query("schedule")
+	runs, err := crontab.NextRuns(schedule, time.Now(), 5)
+
+	data := templ.CronPreviewData{Schedule: schedule, NextRuns: runs}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(http.StatusOK)
+	templ.CronPreviewPartial(data).Render(c.Request.Context(), c.Writer)
+}
+
+// ShowCreateEntry displays the form for a new cron entry
+func (h *CronHandler) ShowCreateEntry(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	data := templ.CronFormData{
+		AuthData: templ.AuthData{
+			Title:       "Add Cron Job - Sysara",
+			PageTitle:   "Add Cron Job",
+			CurrentUser: *userModel,
+		},
+		Targets:  h.targets(),
+		Schedule: "0 * * * *",
+		User:     "root",
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(http.StatusOK)
+	templ.CronForm(data).Render(c.Request.Context(), c.Writer)
+}
+
+// CreateEntry appends a new entry to a crontab
+func (h *CronHandler) CreateEntry(c *gin.Context) {
+	target := c.PostForm("file")
+	owner := strings.TrimSpace(c.PostForm("owner"))
+	entry := crontab.Entry{
+		Schedule: strings.TrimSpace(c.PostForm("schedule")),
+		User:     strings.TrimSpace(c.PostForm("user")),
+		Command:  strings.TrimSpace(c.PostForm("command")),
+	}
+
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	data := templ.CronFormData{
+		AuthData: templ.AuthData{
+			Title:       "Add Cron Job - Sysara",
+			PageTitle:   "Add Cron Job",
+			CurrentUser: *userModel,
+		},
+		Targets:  h.targets(),
+		File:     target,
+		Owner:    owner,
+		Schedule: entry.Schedule,
+		User:     entry.User,
+		Command:  entry.Command,
+	}
+
+	var file *crontab.File
+	var err error
+	if target == "" {
+		file, err = h.manager.UserFile(owner)
+	} else {
+		file, err = h.manager.Load(target)
+	}
+	if err != nil {
+		data.Error = cronErrorMessage(err, "Failed to read crontab")
+		c.Header("Content-Type", "text/html")
+		c.Status(http.StatusBadRequest)
+		templ.CronForm(data).Render(c.Request.Context(), c.Writer)
+		return
+	}
+
+	if err := file.Add(entry); err != nil {
+		data.Error = err.Error()
+		c.Header("Content-Type", "text/html")
+		c.Status(http.StatusBadRequest)
+		templ.CronForm(data).Render(c.Request.Context(), c.Writer)
+		return
+	}
+
+	if err := h.manager.Save(file); err != nil {
+		data.Error = cronErrorMessage(err, "Failed to save crontab")
+		c.Header("Content-Type", "text/html")
+		c.Status(http.StatusInternalServerError)
+		templ.CronForm(data).Render(c.Request.Context(), c.Writer)
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/cron")
+}
+
+// ShowEditEntry displays the form for an existing cron entry
+func (h *CronHandler) ShowEditEntry(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	file, entry, err := h.lookup(c.Query("file"), c.Query("line"), "")
+	if err != nil {
+		h.renderList(c, userModel, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data := templ.CronFormData{
+		AuthData: templ.AuthData{
+			Title:       "Edit Cron Job - Sysara",
+			PageTitle:   "Edit Cron Job",
+			CurrentUser: *userModel,
+		},
+		IsEdit:   true,
+		File:     file.Path,
+		System:   file.System,
+		Index:    entry.Index,
+		Original: file.Lines[entry.Index],
+		Schedule: entry.Schedule,
+		User:     entry.User,
+		Command:  entry.Command,
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(http.StatusOK)
+	templ.CronForm(data).Render(c.Request.Context(), c.Writer)
+}
+
+// UpdateEntry saves changes to an existing cron entry
+func (h *CronHandler) UpdateEntry(c *gin.Context) {
+	entry := crontab.Entry{
+		Schedule: strings.TrimSpace(c.PostForm("schedule")),
+		User:     strings.TrimSpace(c.PostForm("user")),
+		Command:  strings.TrimSpace(c.PostForm("command")),
+	}
+	original := c.PostForm("original")
+
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	file, existing, err := h.lookup(c.PostForm("file"), c.PostForm("line"), original)
+	if err != nil {
+		h.renderList(c, userModel, http.StatusConflict, err.Error())
+		return
+	}
+
+	data := templ.CronFormData{
+		AuthData: templ.AuthData{
+			Title:       "Edit Cron Job - Sysara",
+			PageTitle:   "Edit Cron Job",
+			CurrentUser: *userModel,
+		},
+		IsEdit:   true,
+		File:     file.Path,
+		System:   file.System,
+		Index:    existing.Index,
+		Original: original,
+		Schedule: entry.Schedule,
+		User:     entry.User,
+		Command:  entry.Command,
+	}
+
+	if err := file.Update(existing.Index, entry); err != nil {
+		data.Error = err.Error()
+		c.Header("Content-Type", "text/html")
+		c.Status(http.StatusBadRequest)
+		templ.CronForm(data).Render(c.Request.Context(), c.Writer)
+		return
+	}
+
+	if err := h.manager.Save(file); err != nil {
+		data.Error = cronErrorMessage(err, "Failed to save crontab")
+		c.Header("Content-Type", "text/html")
+		c.Status(http.StatusInternalServerError)
+		templ.CronForm(data).Render(c.Request.Context(), c.Writer)
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/cron")
+}
+
+// ToggleEntry disables an enabled entry or re-enables a disabled one
+func (h *CronHandler) ToggleEntry(c *gin.Context) {
+	h.modifyEntry(c, func(file *crontab.File, entry crontab.Entry) error {
+		return file.SetDisabled(entry.Index, !entry.Disabled)
+	})
+}
+
+// DeleteEntry removes an entry from its crontab
+func (h *CronHandler) DeleteEntry(c *gin.Context) {
+	h.modifyEntry(c, func(file *crontab.File, entry crontab.Entry) error {
+		return file.Remove(entry.Index)
+	})
+}
+
+// modifyEntry applies a change to the posted entry and saves its file
+func (h *CronHandler) modifyEntry(c *gin.Context, change func(*crontab.File, crontab.Entry) error) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	file, entry, err := h.lookup(c.PostForm("file"), c.PostForm("line"), c.PostForm("original"))
+	if err != nil {
+		h.renderList(c, userModel, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err := change(file, entry); err != nil {
+		h.renderList(c, userModel, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.manager.Save(file); err != nil {
+		h.renderList(c, userModel, http.StatusInternalServerError, cronErrorMessage(err, "Failed to save crontab"))
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/cron")
+}
+
+// lookup loads a crontab and finds the entry on the given line. When
+// original is set, the line must still match it so that edits made
+// elsewhere in the meantime are not overwritten.
+func (h *CronHandler) lookup(path, line, original string) (*crontab.File, crontab.Entry, error) {
+	index, err := strconv.Atoi(line)
+	if err != nil {
+		return nil, crontab.Entry{}, errors.New("invalid line number")
+	}
+
+	file, err := h.manager.Load(path)
+	if err != nil {
+		return nil, crontab.Entry{}, errors.New(cronErrorMessage(err, "Failed to read crontab"))
+	}
+
+	entry, ok := file.Entry(index)
+	if !ok {
+		return nil, crontab.Entry{}, errors.New("cron entry not found")
+	}
+	if original != "" && file.Lines[index] != original {
+		return nil, crontab.Entry{}, errors.New("the crontab was changed by someone else, please review it and try again")
+	}
+	return file, entry, nil
+}
+
+// renderList renders the crontab overview with an optional error
+func (h *CronHandler) renderList(c *gin.Context, user *models.User, status int, errMsg string) {
+	files, loadErrs := h.manager.Files()
+	now := time.Now()
+
+	views := make([]templ.CronFileView, 0, len(files))
+	for _, file := range files {
+		view := templ.CronFileView{File: file}
+		for _, entry := range file.Entries {
+			entryView := templ.CronEntryView{
+				Entry:    entry,
+				Original: file.Lines[entry.Index],
+			}
+			if entry.Error == "" && !entry.Disabled {
+				entryView.NextRuns, _ = crontab.NextRuns(entry.Schedule, now, 3)
+			}
+			view.Entries = append(view.Entries, entryView)
+		}
+		views = append(views, view)
+	}
+
+	var warnings []string
+	for _, err := range loadErrs {
+		warnings = append(warnings, err.Error())
+	}
+
+	data := templ.CronListData{
+		AuthData: templ.AuthData{
+			Title:       "Cron Jobs - Sysara",
+			PageTitle:   "Cron Jobs",
+			CurrentUser: *user,
+		},
+		Files:    views,
+		Warnings: warnings,
+		Error:    errMsg,
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.CronList(data).Render(c.Request.Context(), c.Writer)
+}
+
+// targets lists the crontab files a new entry can be added to
+func (h *CronHandler) targets() []templ.CronTarget {
+	files, _ := h.manager.Files()
+	targets := make([]templ.CronTarget, 0, len(files))
+	for _, file := range files {
+		targets = append(targets, templ.CronTarget{Path: file.Path, System: file.System})
+	}
+	return targets
+}
+
+// cronErrorMessage turns file system errors into user-facing messages
+func cronErrorMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, crontab.ErrUnknownFile):
+		return "Unknown crontab file"
+	case errors.Is(err, fs.ErrPermission):
+		return fallback + ": permission denied"
+	default:
+		return fallback + ": " + err.Error()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/teams"
 	templ "github.com/alpemreelmas/sysara/templ"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -30,9 +31,16 @@ func (h *DashboardHandler) ShowDashboard(c *gin.Context) {
 
 	// Get some basic statistics
 	var userCount, sshKeyCount, serverCount int64
-	h.db.Model(&models.User{}).Count(&userCount)
-	h.db.Model(&models.SSHKey{}).Count(&sshKeyCount)
-	h.db.Model(&models.Server{}).Count(&serverCount)
+	h.db.Model(&models.User{}).Where("status <> ?", models.UserDeleted).Count(&userCount)
+	sshKeys := h.db.Model(&models.SSHKey{}).Where("revoked_at IS NULL")
+	servers := h.db.Model(&models.Server{})
+	if !userModel.IsAdmin() {
+		// Other users only see their own keys and those of their teams
+		sshKeys = sshKeys.Where("user_id = ? OR team_id IN (?)", userModel.ID, teams.MemberOf(h.db, userModel.ID))
+		servers = servers.Where("team_id IN (?)", teams.MemberOf(h.db, userModel.ID))
+	}
+	sshKeys.Count(&sshKeyCount)
+	servers.Count(&serverCount)
 
 	data := templ.DashboardData{
 		AuthData: templ.AuthData{
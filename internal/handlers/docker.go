@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/docker"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// DockerHandler handles Docker container operations
+type DockerHandler struct {
+	client *docker.Client
+}
+
+// NewDockerHandler creates a new Docker handler
+func NewDockerHandler(client *docker.Client) *DockerHandler {
+	return &DockerHandler{client: client}
+}
+
+// ListContainers displays all containers with their status and resource usage
+func (h *DockerHandler) ListContainers(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.renderContainerList(c, userModel, http.StatusOK, "")
+}
+
+// GetContainers returns the container table (HTMX endpoint)
+func (h *DockerHandler) GetContainers(c *gin.Context) {
+	rows, err := h.collectContainers(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, docker.ErrUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Docker is not available"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list containers"})
+		return
+	}
+
+	if c.GetHeader("HX-Request") == "true" {
+		c.Header("Content-Type", "text/html")
+		c.Status(http.StatusOK)
+		templ.ContainerTablePartial(templ.ContainerTableData{Containers: rows}).Render(c.Request.Context(), c.Writer)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"containers": rows})
+}
+
+// ShowContainer displays the inspect details of a container
+func (h *DockerHandler) ShowContainer(c *gin.Context) {
+	id := c.Param("id")
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	details, err := h.client.InspectContainer(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if docker.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		h.renderContainerList(c, userModel, status, dockerErrorMessage(err, "Failed to inspect container"))
+		return
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, details.Raw, "", "  "); err != nil {
+		pretty.Write(details.Raw)
+	}
+
+	data := templ.ContainerDetailData{
+		AuthData: templ.AuthData{
+			Title:       details.DisplayName() + " - Sysara",
+			PageTitle:   "Container Details",
+			CurrentUser: *userModel,
+		},
+		Details: *details,
+		RawJSON: pretty.String(),
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(http.StatusOK)
+	templ.ContainerDetail(data).Render(c.Request.Context(), c.Writer)
+}
+
+// ShowLogs displays the most recent log lines of a container
+func (h *DockerHandler) ShowLogs(c *gin.Context) {
+	id := c.Param("id")
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	tail := parseTail(c.Query("tail"))
+
+	details, err := h.client.InspectContainer(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if docker.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		h.renderContainerList(c, userModel, status, dockerErrorMessage(err, "Failed to read container logs"))
+		return
+	}
+
+	logs, err := h.client.ContainerLogs(c.Request.Context(), id, tail, false)
+	if err != nil {
+		h.renderContainerList(c, userModel, http.StatusInternalServerError, dockerErrorMessage(err, "Failed to read container logs"))
+		return
+	}
+	defer logs.Close()
+
+	content, err := io.ReadAll(logs)
+	if err != nil {
+		h.renderContainerList(c, userModel, http.StatusInternalServerError, "Failed to read container logs")
+		return
+	}
+
+	data := templ.ContainerLogsData{
+		AuthData: templ.AuthData{
+			Title:       details.DisplayName() + " Logs - Sysara",
+			PageTitle:   "Container Logs",
+			CurrentUser: *userModel,
+		},
+		ID:      details.ID,
+		Name:    details.DisplayName(),
+		Running: details.State.Running,
+		Tail:    tail,
+		Logs:    string(content),
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(http.StatusOK)
+	templ.ContainerLogs(data).Render(c.Request.Context(), c.Writer)
+}
+
+// StreamLogs follows a container's logs as server-sent events
+func (h *DockerHandler) StreamLogs(c *gin.Context) {
+	id := c.Param("id")
+
+	// Only new lines are streamed; the page already shows the backlog
+	logs, err := h.client.ContainerLogs(c.Request.Context(), id, 0, true)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": dockerErrorMessage(err, "Failed to follow container logs")})
+		return
+	}
+	defer logs.Close()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(logs)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}()
+
+	c.Stream(func(w io.Writer) bool {
+		line, ok := <-lines
+		if !ok {
+			c.SSEvent("end", "")
+			return false
+		}
+		c.SSEvent("log", line)
+		return true
+	})
+}
+
+// StartContainer starts a container
+func (h *DockerHandler) StartContainer(c *gin.Context) {
+	h.containerAction(c, h.client.StartContainer, "Failed to start container")
+}
+
+// StopContainer stops a container
+func (h *DockerHandler) StopContainer(c *gin.Context) {
+	h.containerAction(c, h.client.StopContainer, "Failed to stop container")
+}
+
+// RestartContainer restarts a container
+func (h *DockerHandler) RestartContainer(c *gin.Context) {
+	h.containerAction(c, h.client.RestartContainer, "Failed to restart container")
+}
+
+// RemoveContainer removes a container
+func (h *DockerHandler) RemoveContainer(c *gin.Context) {
+	force := c.PostForm("force") == "true"
+	h.containerAction(c, func(ctx context.Context, id string) error {
+		return h.client.RemoveContainer(ctx, id, force)
+	}, "Failed to remove container")
+}
+
+// containerAction runs a lifecycle action and returns to the container list
+func (h *DockerHandler) containerAction(c *gin.Context, action func(context.Context, string) error, failure string) {
+	id := c.Param("id")
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	if err := action(c.Request.Context(), id); err != nil {
+		status := http.StatusInternalServerError
+		if docker.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		h.renderContainerList(c, userModel, status, dockerErrorMessage(err, failure))
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/containers")
+}
+
+// renderContainerList renders the container list page with an optional error
+func (h *DockerHandler) renderContainerList(c *gin.Context, user *models.User, status int, errMsg string) {
+	data := templ.ContainerListData{
+		AuthData: templ.AuthData{
+			Title:       "Containers - Sysara",
+			PageTitle:   "Containers",
+			CurrentUser: *user,
+		},
+		SocketPath: h.client.SocketPath(),
+		Available:  true,
+		Error:      errMsg,
+	}
+
+	rows, err := h.collectContainers(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, docker.ErrUnavailable) {
+			data.Available = false
+		} else if data.Error == "" {
+			data.Error = "Failed to list containers"
+			status = http.StatusInternalServerError
+		}
+	}
+	data.Containers = rows
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.ContainerList(data).Render(c.Request.Context(), c.Writer)
+}
+
+// collectContainers lists containers and samples resource usage of running ones
+func (h *DockerHandler) collectContainers(ctx context.Context) ([]templ.ContainerRow, error) {
+	if err := h.client.Ping(ctx); err != nil {
+		return nil, err
+	}
+
+	containers, err := h.client.ListContainers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]templ.ContainerRow, len(containers))
+	var wg sync.WaitGroup
+	for i, container := range containers {
+		rows[i].Container = container
+		if container.State != "running" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			statsCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			if stats, err := h.client.ContainerStats(statsCtx, id); err == nil {
+				rows[i].Stats = stats
+			}
+		}(i, container.ID)
+	}
+	wg.Wait()
+
+	return rows, nil
+}
+
+// dockerErrorMessage prefers the daemon's message over a generic fallback
+func dockerErrorMessage(err error, fallback string) string {
+	var apiErr *docker.APIError
+	if errors.As(err, &apiErr) {
+		return fallback + ": " + apiErr.Message
+	}
+	if errors.Is(err, docker.ErrUnavailable) {
+		return "Docker is not available"
+	}
+	return fallback
+}
+
+// parseTail reads the number of log lines to show, defaulting to 200
+func parseTail(value string) int {
+	tail, err := strconv.Atoi(value)
+	if err != nil || tail <= 0 {
+		return 200
+	}
+	if tail > 5000 {
+		return 5000
+	}
+	return tail
+}
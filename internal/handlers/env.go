@@ -1,26 +1,39 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/envfile"
+	"github.com/alpemreelmas/sysara/internal/envstore"
 	"github.com/alpemreelmas/sysara/internal/models"
 	templ "github.com/alpemreelmas/sysara/templ"
 	"github.com/gin-gonic/gin"
 )
 
 // EnvHandler handles environment file operations
-type EnvHandler struct{}
+type EnvHandler struct {
+	projects *envstore.Projects
+	store    *envstore.Store
+	secrets  *envstore.Secrets
+	schemas  *envstore.Schemas
+	hooks    *envstore.Hooks
+	audit    *audit.Logger
+}
 
 // NewEnvHandler creates a new environment handler
-func NewEnvHandler() *EnvHandler {
-	return &EnvHandler{}
+func NewEnvHandler(projects *envstore.Projects, store *envstore.Store, secrets *envstore.Secrets, schemas *envstore.Schemas, hooks *envstore.Hooks, auditLog *audit.Logger) *EnvHandler {
+	return &EnvHandler{projects: projects, store: store, secrets: secrets, schemas: schemas, hooks: hooks, audit: auditLog}
 }
 
-// ShowEnvFiles displays available environment files
+// ShowEnvFiles displays the environment files of every accessible project
 func (h *EnvHandler) ShowEnvFiles(c *gin.Context) {
 	currentUser, _ := c.Get("current_user")
 	userModel, ok := currentUser.(*models.User)
@@ -29,48 +42,42 @@ func (h *EnvHandler) ShowEnvFiles(c *gin.Context) {
 		return
 	}
 
-	// Get list of .env files in the current directory
-	envFiles := []string{}
-	files, err := os.ReadDir(".")
-	if err == nil {
-		for _, file := range files {
-			if strings.HasPrefix(file.Name(), ".env") {
-				envFiles = append(envFiles, file.Name())
-			}
-		}
+	h.renderList(c, userModel, http.StatusOK, "")
+}
+
+// CreateEnvFile creates a new environment file in a project
+func (h *EnvHandler) CreateEnvFile(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
 	}
 
-	// Add common env files if they don't exist
-	//commonEnvFiles := []string{".env", ".env.production", ".env.testing", ".env.development"}
-	//for _, envFile := range commonEnvFiles {
-	//	found := false
-	//	for _, existing := range envFiles {
-	//		if existing == envFile {
-	//			found = true
-	//			break
-	//		}
-	//	}
-	//	if !found {
-	//		envFiles = append(envFiles, envFile)
-	//	}
-	//}
+	project, file, status, err := h.target(c, userModel, strings.TrimSpace(c.PostForm("filename")), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
 
-	data := templ.EnvListData{
-		AuthData: templ.AuthData{
-			Title:       "Environment Files - Sysara",
-			PageTitle:   "Environment Files",
-			CurrentUser: *userModel,
-		},
-		EnvFiles: envFiles,
+	// Create empty file, failing if it already exists
+	f, err := os.OpenFile(file.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			h.renderList(c, userModel, http.StatusBadRequest, "File already exists")
+		} else {
+			h.renderList(c, userModel, http.StatusInternalServerError, "Failed to create file")
+		}
+		return
 	}
-	c.Header("Content-Type", "text/html")
-	c.Status(http.StatusOK)
-	templ.EnvList(data).Render(c.Request.Context(), c.Writer)
+	f.Close()
+
+	c.Redirect(http.StatusSeeOther, templ.EnvFileURL(project.ID, "edit", file.Name))
 }
 
 // ShowEditEnv displays the environment file editor
 func (h *EnvHandler) ShowEditEnv(c *gin.Context) {
-	filename := c.Param("filename")
+	mode := editorMode(c.Query("mode"))
 	currentUser, _ := c.Get("current_user")
 	userModel, ok := currentUser.(*models.User)
 	if !ok {
@@ -78,63 +85,276 @@ func (h *EnvHandler) ShowEditEnv(c *gin.Context) {
 		return
 	}
 
-	// Validate filename to prevent directory traversal
-	if !strings.HasPrefix(filename, ".env") {
-		data := templ.EnvEditData{
-			AuthData: templ.AuthData{
-				Title:       "Edit Environment - Sysara",
-				PageTitle:   "Edit Environment",
-				CurrentUser: *userModel,
-			},
-			Filename: filename,
-			Error:    "Invalid environment file name",
-		}
-		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusBadRequest)
-		templ.EnvEdit(data).Render(c.Request.Context(), c.Writer)
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessRead)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
 		return
 	}
 
 	// Read file content
-	content := ""
-	if _, err := os.Stat(filename); err == nil {
-		contentBytes, err := ioutil.ReadFile(filename)
+	content, err := h.store.Read(file)
+	if err != nil {
+		h.renderEdit(c, userModel, http.StatusInternalServerError, project, file, templ.EnvEditData{
+			Mode:  mode,
+			Error: "Failed to read environment file",
+		})
+		return
+	}
+
+	h.renderEdit(c, userModel, http.StatusOK, project, file, templ.EnvEditData{
+		Mode:    mode,
+		Content: content,
+	})
+}
+
+// UpdateEnv saves changes to an environment file, either from the raw
+// editor or from the key/value table
+func (h *EnvHandler) UpdateEnv(c *gin.Context) {
+	mode := editorMode(c.DefaultPostForm("mode", templ.EnvModeRaw))
+	content := c.PostForm("content")
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	// Held until saved, so that no other save can slip in after the check
+	unlock := h.store.Lock(file)
+	defer unlock()
+	current, err := h.store.Read(file)
+	if err != nil {
+		h.renderEdit(c, userModel, http.StatusInternalServerError, project, file, templ.EnvEditData{
+			Mode:  mode,
+			Error: "Failed to read environment file",
+		})
+		return
+	}
+
+	// Reject saves based on an outdated version of the file
+	if base := c.PostForm("base_hash"); base != h.store.Hash(current) {
+		h.renderMerge(c, userModel, project, file, mode, base, current, content)
+		return
+	}
+
+	var marks map[string]bool
+	if mode == templ.EnvModeTable {
+		isSecret, err := h.secrets.Checker(file)
 		if err != nil {
+			// Fail closed: treat every value as secret
+			isSecret = func(string) bool { return true }
+		}
+		content, marks, err = applyTableEdits(current, isSecret, c)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errEnvChanged) {
+				status = http.StatusConflict
+			}
+			h.renderEdit(c, userModel, status, project, file, templ.EnvEditData{
+				Mode:    mode,
+				Content: current,
+				Error:   err.Error(),
+			})
+			return
+		}
+	} else {
+		// Masked secrets keep their saved values
+		unmasked, err := envstore.Unmask(content, current)
+		if err != nil {
+			h.renderEdit(c, userModel, http.StatusBadRequest, project, file, templ.EnvEditData{
+				Mode:    mode,
+				Content: content,
+				Error:   err.Error(),
+			})
+			return
+		}
+		content = unmasked
+
+		if _, issues := envfile.Parse(content); len(issues) > 0 && c.PostForm("force") != "1" {
+			// Refuse to save broken files unless the user explicitly insists
+			h.renderEdit(c, userModel, http.StatusBadRequest, project, file, templ.EnvEditData{
+				Mode:     mode,
+				Content:  content,
+				Error:    "The file contains errors. Fix them or choose to save anyway.",
+				CanForce: true,
+			})
+			return
+		}
+	}
+
+	// Validate against the schema on every save
+	if c.PostForm("force") != "1" {
+		schema, err := h.schemas.Schema(project, file)
+		if err != nil {
+			log.Printf("env: failed to load schema of %s: %v", file, err)
+		}
+		parsed, _ := envfile.Parse(content)
+		if violations := schema.Validate(parsed); len(violations) > 0 {
 			data := templ.EnvEditData{
-				AuthData: templ.AuthData{
-					Title:       "Edit Environment - Sysara",
-					PageTitle:   "Edit Environment",
-					CurrentUser: *userModel,
-				},
-				Filename: filename,
-				Error:    "Failed to read environment file",
+				Mode:       mode,
+				Content:    content,
+				Violations: violations,
+				Error:      "The file does not match its schema. Fix the problems or choose to save anyway.",
+				CanForce:   true,
 			}
-			c.Header("Content-Type", "text/html")
-			c.Status(http.StatusInternalServerError)
-			templ.EnvEdit(data).Render(c.Request.Context(), c.Writer)
+			if mode == templ.EnvModeTable {
+				// Rows are tied to the lines of the saved file
+				data.Content = current
+			}
+			h.renderEdit(c, userModel, http.StatusBadRequest, project, file, data)
 			return
 		}
-		content = string(contentBytes)
 	}
 
-	data := templ.EnvEditData{
-		AuthData: templ.AuthData{
-			Title:       fmt.Sprintf("Edit %s - Sysara", filename),
-			PageTitle:   "Edit Environment",
-			CurrentUser: *userModel,
-		},
-		Filename: filename,
+	// Write the file and record it as a new revision
+	data := templ.EnvEditData{Mode: mode}
+	revision, status, err := h.save(file, content, current, userModel, strings.TrimSpace(c.PostForm("message")), &data)
+	if err != nil {
+		h.renderEdit(c, userModel, http.StatusInternalServerError, project, file, templ.EnvEditData{
+			Mode:    mode,
+			Content: content,
+			Error:   "Failed to save environment file",
+		})
+		return
+	}
+
+	for key, secret := range marks {
+		if err := h.secrets.Mark(file, key, secret); err != nil {
+			log.Printf("env: failed to update secret flag of %s in %s: %v", key, file, err)
+		}
+	}
+
+	if status == http.StatusOK {
+		data.Success = fmt.Sprintf("Changes saved as revision #%d", revision.ID)
+	}
+	h.renderEdit(c, userModel, status, project, file, data)
+}
+
+// save writes content over previous, the content the change is based on,
+// as a new revision and runs the post-save hooks of the file. If a hook
+// fails, previous is restored so that the next restart of the application
+// does not break it. Every change of an env file is saved here, holding
+// the lock of the file since previous was read. The content and hook
+// outcome are recorded in data and the status to render it with is
+// returned; an error means that nothing was saved.
+func (h *EnvHandler) save(file envstore.File, content, previous string, user *models.User, message string, data *templ.EnvEditData) (*models.EnvRevision, int, error) {
+	revision, err := h.store.Save(file, content, user, message)
+	if err != nil {
+		return nil, 0, err
+	}
+	data.Content = content
+	if content == previous {
+		return revision, http.StatusOK, nil
+	}
+
+	runs, ok := h.hooks.Run(file, revision)
+	data.HookRuns = runs
+	if ok {
+		return revision, http.StatusOK, nil
+	}
+
+	restored, err := h.store.Save(file, previous, user, fmt.Sprintf("Automatic rollback of revision #%d after a failed hook", revision.ID))
+	if err != nil {
+		log.Printf("env: failed to roll back %s after a failed hook: %v", file, err)
+		data.Error = fmt.Sprintf("A post-save hook failed and revision #%d could not be rolled back", revision.ID)
+	} else {
+		data.Content = previous
+		data.Error = fmt.Sprintf("A post-save hook failed. Revision #%d was rolled back as revision #%d.", revision.ID, restored.ID)
+	}
+	return revision, http.StatusInternalServerError, nil
+}
+
+// RevealSecrets shows the raw editor with secret values unmasked
+func (h *EnvHandler) RevealSecrets(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	content, err := h.store.Read(file)
+	if err != nil {
+		h.renderEdit(c, userModel, http.StatusInternalServerError, project, file, templ.EnvEditData{
+			Mode:  templ.EnvModeRaw,
+			Error: "Failed to read environment file",
+		})
+		return
+	}
+
+	// Secrets are only shown once the reveal has been recorded
+	if err := h.audit.Record(userModel, audit.ActionEnvReveal, file.String(), "all secrets", c.ClientIP()); err != nil {
+		h.renderEdit(c, userModel, http.StatusInternalServerError, project, file, templ.EnvEditData{
+			Mode:    templ.EnvModeRaw,
+			Content: content,
+			Error:   "Failed to record audit log entry, secrets were not revealed",
+		})
+		return
+	}
+
+	h.renderEdit(c, userModel, http.StatusOK, project, file, templ.EnvEditData{
+		Mode:     templ.EnvModeRaw,
 		Content:  content,
+		Revealed: true,
+	})
+}
+
+// RevealValue returns the unmasked value of a single variable (HTMX endpoint)
+func (h *EnvHandler) RevealValue(c *gin.Context) {
+	key := c.PostForm("key")
+	id := c.PostForm("row")
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	content, err := h.store.Read(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read environment file"})
+		return
 	}
+
+	parsed, _ := envfile.Parse(content)
+	index, err := strconv.Atoi(id)
+	if err != nil || index < 0 || index >= len(parsed.Lines) ||
+		parsed.Lines[index].Kind != envfile.Variable || parsed.Lines[index].Key != key {
+		c.JSON(http.StatusConflict, gin.H{"error": errEnvChanged.Error()})
+		return
+	}
+
+	if err := h.audit.Record(userModel, audit.ActionEnvReveal, file.String(), key, c.ClientIP()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log entry"})
+		return
+	}
+
+	row := templ.EnvRow{ID: id, Key: key, Value: parsed.Lines[index].Value, Secret: true}
 	c.Header("Content-Type", "text/html")
 	c.Status(http.StatusOK)
-	templ.EnvEdit(data).Render(c.Request.Context(), c.Writer)
+	templ.EnvValueCell(project.ID, file.Name, row).Render(c.Request.Context(), c.Writer)
 }
 
-// UpdateEnv saves changes to an environment file
-func (h *EnvHandler) UpdateEnv(c *gin.Context) {
-	filename := c.Param("filename")
-	content := c.PostForm("content")
+// ShowHistory lists the revisions of an environment file
+func (h *EnvHandler) ShowHistory(c *gin.Context) {
 	currentUser, _ := c.Get("current_user")
 	userModel, ok := currentUser.(*models.User)
 	if !ok {
@@ -142,107 +362,440 @@ func (h *EnvHandler) UpdateEnv(c *gin.Context) {
 		return
 	}
 
-	// Validate filename to prevent directory traversal
-	if !strings.HasPrefix(filename, ".env") {
-		data := templ.EnvEditData{
-			AuthData: templ.AuthData{
-				Title:       "Edit Environment - Sysara",
-				PageTitle:   "Edit Environment",
-				CurrentUser: *userModel,
-			},
-			Filename: filename,
-			Content:  content,
-			Error:    "Invalid environment file name",
-		}
-		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusBadRequest)
-		templ.EnvEdit(data).Render(c.Request.Context(), c.Writer)
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessRead)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
 		return
 	}
 
-	// Create backup of existing file
-	if _, err := os.Stat(filename); err == nil {
-		backupName := fmt.Sprintf("%s.backup.%d", filename, os.Getpid())
-		if err := copyFile(filename, backupName); err != nil {
-			data := templ.EnvEditData{
-				AuthData: templ.AuthData{
-					Title:       fmt.Sprintf("Edit %s - Sysara", filename),
-					PageTitle:   "Edit Environment",
-					CurrentUser: *userModel,
-				},
-				Filename: filename,
-				Content:  content,
-				Error:    "Failed to create backup",
-			}
-			c.Header("Content-Type", "text/html")
-			c.Status(http.StatusInternalServerError)
-			templ.EnvEdit(data).Render(c.Request.Context(), c.Writer)
+	h.renderHistory(c, userModel, http.StatusOK, project, file, "", "")
+}
+
+// ShowDiff displays a side-by-side diff between two revisions. Without
+// parameters the latest revision is compared with the one before it.
+func (h *EnvHandler) ShowDiff(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessRead)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	revisions, err := h.store.Revisions(file)
+	if err != nil {
+		h.renderHistory(c, userModel, http.StatusInternalServerError, project, file, envErrorMessage(err, "Failed to load revisions"), "")
+		return
+	}
+	if len(revisions) == 0 {
+		h.renderHistory(c, userModel, http.StatusNotFound, project, file, "This file has no revisions yet", "")
+		return
+	}
+
+	to := &revisions[0]
+	from := to
+	if len(revisions) > 1 {
+		from = &revisions[1]
+	}
+	if id := c.Query("to"); id != "" {
+		if to, err = h.revision(file, id); err != nil {
+			h.renderHistory(c, userModel, http.StatusNotFound, project, file, err.Error(), "")
 			return
 		}
 	}
-
-	// Write new content
-	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
-		data := templ.EnvEditData{
-			AuthData: templ.AuthData{
-				Title:       fmt.Sprintf("Edit %s - Sysara", filename),
-				PageTitle:   "Edit Environment",
-				CurrentUser: *userModel,
-			},
-			Filename: filename,
-			Content:  content,
-			Error:    "Failed to save environment file",
+	if id := c.Query("from"); id != "" {
+		if from, err = h.revision(file, id); err != nil {
+			h.renderHistory(c, userModel, http.StatusNotFound, project, file, err.Error(), "")
+			return
 		}
-		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusInternalServerError)
-		templ.EnvEdit(data).Render(c.Request.Context(), c.Writer)
-		return
 	}
 
-	data := templ.EnvEditData{
+	isSecret, err := h.secrets.Checker(file)
+	if err != nil {
+		isSecret = func(string) bool { return true }
+	}
+	fromContent, toContent := envstore.MaskPair(from.Content, to.Content, isSecret)
+
+	data := templ.EnvDiffData{
 		AuthData: templ.AuthData{
-			Title:       fmt.Sprintf("Edit %s - Sysara", filename),
-			PageTitle:   "Edit Environment",
+			Title:       fmt.Sprintf("Diff %s - Sysara", file.Name),
+			PageTitle:   "Environment History",
 			CurrentUser: *userModel,
 		},
-		Filename: filename,
-		Content:  content,
+		Project:   *project,
+		Filename:  file.Name,
+		From:      *from,
+		To:        *to,
+		Revisions: revisions,
+		Rows:      envfile.Diff(fromContent, toContent),
 	}
 	c.Header("Content-Type", "text/html")
 	c.Status(http.StatusOK)
-	templ.EnvEdit(data).Render(c.Request.Context(), c.Writer)
+	templ.EnvDiff(data).Render(c.Request.Context(), c.Writer)
 }
 
-// CreateEnvFile creates a new environment file
-func (h *EnvHandler) CreateEnvFile(c *gin.Context) {
-	filename := c.PostForm("filename")
+// RollbackEnv restores an earlier revision of an environment file
+func (h *EnvHandler) RollbackEnv(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
 
-	// Validate filename
-	if !strings.HasPrefix(filename, ".env") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Environment file must start with .env"})
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
 		return
 	}
 
-	// Check if file already exists
-	if _, err := os.Stat(filename); err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File already exists"})
+	target, err := h.revision(file, c.PostForm("revision"))
+	if err != nil {
+		h.renderHistory(c, userModel, http.StatusNotFound, project, file, err.Error(), "")
 		return
 	}
 
-	// Create empty file
-	if err := ioutil.WriteFile(filename, []byte(""), 0644); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file"})
+	unlock := h.store.Lock(file)
+	defer unlock()
+	current, err := h.store.Read(file)
+	if err != nil {
+		h.renderHistory(c, userModel, http.StatusInternalServerError, project, file, "Failed to read environment file", "")
 		return
 	}
+	var data templ.EnvEditData
+	revision, status, err := h.save(file, target.Content, current, userModel, fmt.Sprintf("Rollback to revision #%d", target.ID), &data)
+	if err != nil {
+		h.renderHistory(c, userModel, http.StatusInternalServerError, project, file, "Failed to restore revision", "")
+		return
+	}
+	if status != http.StatusOK {
+		h.renderHistory(c, userModel, status, project, file, data.Error, "")
+		return
+	}
+
+	h.renderHistory(c, userModel, http.StatusOK, project, file, "",
+		fmt.Sprintf("Revision #%d restored as revision #%d", target.ID, revision.ID))
+}
+
+// target resolves the project and file of a request and checks that the
+// user has the required access level. The returned status code is meant
+// for the error response.
+func (h *EnvHandler) target(c *gin.Context, user *models.User, filename, level string) (*models.EnvProject, envstore.File, int, error) {
+	return h.resolve(user, c.Param("project"), filename, level)
+}
+
+// resolve is target for a project ID that does not come from the route
+func (h *EnvHandler) resolve(user *models.User, id, filename, level string) (*models.EnvProject, envstore.File, int, error) {
+	projectID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, envstore.File{}, http.StatusNotFound, envstore.ErrProjectNotFound
+	}
+
+	access := h.projects.Access(user, uint(projectID))
+	if access == "" {
+		// Do not reveal whether the project exists
+		return nil, envstore.File{}, http.StatusNotFound, envstore.ErrProjectNotFound
+	}
+	if level == models.EnvAccessWrite && access != models.EnvAccessWrite {
+		return nil, envstore.File{}, http.StatusForbidden, errors.New("you only have read access to this project")
+	}
+
+	project, err := h.projects.Get(uint(projectID))
+	if err != nil {
+		if errors.Is(err, envstore.ErrProjectNotFound) {
+			return nil, envstore.File{}, http.StatusNotFound, err
+		}
+		return nil, envstore.File{}, http.StatusInternalServerError, errors.New("failed to load project")
+	}
+
+	file, err := h.projects.Resolve(project, filename)
+	if err != nil {
+		if errors.Is(err, envstore.ErrInvalidName) || errors.Is(err, envstore.ErrOutsideRoot) {
+			return nil, envstore.File{}, http.StatusBadRequest, err
+		}
+		return nil, envstore.File{}, http.StatusInternalServerError, err
+	}
+	return project, file, http.StatusOK, nil
+}
+
+// revision loads a revision of a file from a string ID
+func (h *EnvHandler) revision(file envstore.File, id string) (*models.EnvRevision, error) {
+	revisionID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, envstore.ErrRevisionNotFound
+	}
+	revision, err := h.store.Revision(file, uint(revisionID))
+	if err != nil && !errors.Is(err, envstore.ErrRevisionNotFound) {
+		return nil, errors.New(envErrorMessage(err, "Failed to load revision"))
+	}
+	return revision, err
+}
+
+// renderList renders the project overview with an optional error
+func (h *EnvHandler) renderList(c *gin.Context, user *models.User, status int, errMsg string) {
+	data := templ.EnvListData{
+		AuthData: templ.AuthData{
+			Title:       "Environment Files - Sysara",
+			PageTitle:   "Environment Files",
+			CurrentUser: *user,
+		},
+		Error: errMsg,
+	}
+
+	projects, levels, err := h.projects.Accessible(user)
+	if err != nil {
+		data.Error = "Failed to load projects"
+		status = http.StatusInternalServerError
+	}
+	for _, project := range projects {
+		view := templ.EnvProjectView{
+			Project:  project,
+			CanWrite: levels[project.ID] == models.EnvAccessWrite,
+		}
+		if view.Files, err = h.projects.Files(&project); err != nil {
+			view.Error = "The project root cannot be read"
+		}
+		data.Projects = append(data.Projects, view)
+	}
 
-	c.Redirect(http.StatusSeeOther, "/env")
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvList(data).Render(c.Request.Context(), c.Writer)
 }
 
-// Helper function to copy files
-func copyFile(src, dst string) error {
-	data, err := ioutil.ReadFile(src)
+// renderHistory renders the revision list of a file
+func (h *EnvHandler) renderHistory(c *gin.Context, user *models.User, status int, project *models.EnvProject, file envstore.File, errMsg, success string) {
+	data := templ.EnvHistoryData{
+		AuthData: templ.AuthData{
+			Title:       fmt.Sprintf("History of %s - Sysara", file.Name),
+			PageTitle:   "Environment History",
+			CurrentUser: *user,
+		},
+		Project:  *project,
+		Filename: file.Name,
+		CanWrite: h.projects.Access(user, project.ID) == models.EnvAccessWrite,
+		Error:    errMsg,
+		Success:  success,
+	}
+
+	if revisions, err := h.store.Revisions(file); err != nil {
+		data.Error = envErrorMessage(err, "Failed to load revisions")
+		status = http.StatusInternalServerError
+	} else {
+		data.Revisions = revisions
+		data.Reveals, _ = h.audit.Recent(audit.ActionEnvReveal, file.String(), 10)
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvHistory(data).Render(c.Request.Context(), c.Writer)
+}
+
+// renderEdit parses the content for the table view and renders the editor
+func (h *EnvHandler) renderEdit(c *gin.Context, user *models.User, status int, project *models.EnvProject, file envstore.File, data templ.EnvEditData) {
+	data.AuthData = templ.AuthData{
+		Title:       fmt.Sprintf("Edit %s - Sysara", file.Name),
+		PageTitle:   "Edit Environment",
+		CurrentUser: *user,
+	}
+	data.Project = *project
+	data.Filename = file.Name
+	data.CanWrite = h.projects.Access(user, project.ID) == models.EnvAccessWrite
+
+	isSecret, err := h.secrets.Checker(file)
 	if err != nil {
-		return err
+		// Fail closed: treat every value as secret
+		isSecret = func(string) bool { return true }
+	}
+	if len(data.Own) > 0 {
+		checker := isSecret
+		isSecret = func(key string) bool { return !data.Own[key] && checker(key) }
+	}
+
+	// Saves are rejected unless they are based on the content on disk
+	if data.BaseHash == "" {
+		if current, err := h.store.Read(file); err == nil {
+			data.BaseHash = h.store.Hash(current)
+		}
+	}
+
+	schema, err := h.schemas.Schema(project, file)
+	if err != nil {
+		log.Printf("env: failed to load schema of %s: %v", file, err)
+	}
+
+	if data.HookRuns == nil {
+		if data.HookRuns, err = h.hooks.LastRuns(file); err != nil {
+			log.Printf("env: failed to load hook runs of %s: %v", file, err)
+		}
+	}
+
+	parsed, issues := envfile.Parse(data.Content)
+	data.Issues = issues
+	if data.Violations == nil {
+		data.Violations = schema.Validate(parsed)
+	}
+	data.Missing = schema.Missing(parsed)
+	for i, line := range parsed.Lines {
+		switch line.Kind {
+		case envfile.Variable:
+			row := templ.EnvRow{
+				ID:      strconv.Itoa(i),
+				Line:    line.Number,
+				Key:     line.Key,
+				Value:   line.Value,
+				Export:  line.Export,
+				Comment: line.Comment,
+				Secret:  isSecret(line.Key),
+				Pattern: h.secrets.MatchesPattern(line.Key),
+			}
+			if rule, ok := schema.Rule(line.Key); ok {
+				row.Rule = &rule
+			}
+			for _, violation := range data.Violations {
+				if violation.Key == line.Key && violation.Line != 0 {
+					row.Errors = append(row.Errors, violation.Message)
+				}
+			}
+			if row.Secret && row.Value != "" && !data.Revealed {
+				row.Value = envstore.MaskedValue
+				row.Masked = true
+				data.MaskedCount++
+			}
+			data.Rows = append(data.Rows, row)
+		case envfile.Invalid:
+			data.InvalidLines++
+		}
+	}
+	if !data.Revealed {
+		data.Content = envstore.Mask(data.Content, isSecret)
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvEdit(data).Render(c.Request.Context(), c.Writer)
+}
+
+var errEnvChanged = errors.New("the file was changed by someone else, please review it and try again")
+
+// applyTableEdits applies the rows posted by the table editor to the
+// current file content. Rows are identified by their line index and the
+// key they had when the page was rendered; new rows use a "new-" prefix.
+// A secret whose value stays masked keeps its secret flag, even when its
+// key is renamed or the box is unchecked, so that the value is never shown
+// without an audited reveal.
+func applyTableEdits(current string, isSecret func(string) bool, c *gin.Context) (string, map[string]bool, error) {
+	file, _ := envfile.Parse(current)
+	keys := c.PostFormMap("key")
+	values := c.PostFormMap("value")
+	originals := c.PostFormMap("original")
+	exports := c.PostFormMap("export")
+	comments := c.PostFormMap("comment")
+	deletes := c.PostFormMap("delete")
+	secrets := c.PostFormMap("secret")
+	marks := map[string]bool{}
+
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool { return rowOrder(ids[a]) < rowOrder(ids[b]) })
+
+	var removed []*envfile.Line
+	var renamed []string
+	for _, id := range ids {
+		key := strings.TrimSpace(keys[id])
+		value := values[id]
+
+		if strings.HasPrefix(id, "new-") {
+			if key == "" || deletes[id] != "" {
+				continue
+			}
+			line, err := file.Append(key, value)
+			if err != nil {
+				return "", nil, err
+			}
+			line.SetExport(exports[id] != "")
+			line.SetComment(comments[id])
+			marks[key] = secrets[id] != ""
+			continue
+		}
+
+		index, err := strconv.Atoi(id)
+		if err != nil || index < 0 || index >= len(file.Lines) {
+			return "", nil, errEnvChanged
+		}
+		line := file.Lines[index]
+		if line.Kind != envfile.Variable || line.Key != originals[id] {
+			return "", nil, errEnvChanged
+		}
+
+		if deletes[id] != "" {
+			removed = append(removed, line)
+			continue
+		}
+		masked := value == envstore.MaskedValue && isSecret(line.Key)
+		if key != line.Key {
+			renamed = append(renamed, line.Key)
+		}
+		if err := line.Rename(key); err != nil {
+			return "", nil, err
+		}
+		// Masked secrets keep their saved value
+		if value != envstore.MaskedValue {
+			line.SetValue(value)
+		}
+		line.SetExport(exports[id] != "")
+		line.SetComment(comments[id])
+		marks[key] = secrets[id] != "" || masked
+	}
+	for _, line := range removed {
+		file.Remove(line)
+	}
+	// The flag moves with a renamed key, unless another row took its name
+	for _, key := range renamed {
+		if _, ok := marks[key]; !ok {
+			marks[key] = false
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, line := range file.Variables() {
+		if seen[line.Key] {
+			return "", nil, fmt.Errorf("duplicate key %s", line.Key)
+		}
+		seen[line.Key] = true
+	}
+	return file.String(), marks, nil
+}
+
+// rowOrder sorts existing rows by line index and new rows after them
+func rowOrder(id string) int {
+	if n, ok := strings.CutPrefix(id, "new-"); ok {
+		index, _ := strconv.Atoi(n)
+		return 1<<30 + index
+	}
+	index, _ := strconv.Atoi(id)
+	return index
+}
+
+// editorMode normalizes the requested editor mode
+func editorMode(mode string) string {
+	if mode == templ.EnvModeRaw {
+		return templ.EnvModeRaw
+	}
+	return templ.EnvModeTable
+}
+
+// envErrorMessage turns store errors into user-facing messages
+func envErrorMessage(err error, fallback string) string {
+	if errors.Is(err, envstore.ErrDecrypt) {
+		return "Revision history cannot be decrypted, check ENV_ENCRYPTION_KEY"
 	}
-	return ioutil.WriteFile(dst, data, 0644)
+	return fallback
 }
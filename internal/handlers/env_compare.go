@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/envfile"
+	"github.com/alpemreelmas/sysara/internal/envstore"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// errInvalidRef is returned for malformed "<project>/<file>" references
+var errInvalidRef = errors.New("select the files to compare")
+
+// envRef is a resolved file reference used by the compare view
+type envRef struct {
+	project *models.EnvProject
+	file    envstore.File
+}
+
+// ShowCompare aligns two env files by key
+func (h *EnvHandler) ShowCompare(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.renderCompare(c, userModel, http.StatusOK, templ.EnvCompareData{
+		Left:  c.Query("left"),
+		Right: c.Query("right"),
+	})
+}
+
+// PromoteKeys copies the selected keys from one file to another and saves
+// the target as a single revision
+func (h *EnvHandler) PromoteKeys(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	data := templ.EnvCompareData{Left: c.PostForm("left"), Right: c.PostForm("right")}
+	sourceRef, targetRef := data.Left, data.Right
+	if c.PostForm("direction") == "left" {
+		sourceRef, targetRef = targetRef, sourceRef
+	}
+
+	source, status, err := h.resolveRef(userModel, sourceRef, models.EnvAccessRead)
+	if err != nil {
+		data.Error = err.Error()
+		h.renderCompare(c, userModel, status, data)
+		return
+	}
+	target, status, err := h.resolveRef(userModel, targetRef, models.EnvAccessWrite)
+	if err != nil {
+		data.Error = err.Error()
+		h.renderCompare(c, userModel, status, data)
+		return
+	}
+	if source.file == target.file {
+		data.Error = "Choose two different files"
+		h.renderCompare(c, userModel, http.StatusBadRequest, data)
+		return
+	}
+
+	keys := c.PostFormArray("keys")
+	if len(keys) == 0 {
+		data.Error = "Select the keys to promote"
+		h.renderCompare(c, userModel, http.StatusBadRequest, data)
+		return
+	}
+
+	// Writers can reveal secrets, so copying one into the target needs the
+	// same access to the source
+	if h.projects.Access(userModel, source.project.ID) != models.EnvAccessWrite {
+		isSecret, err := h.secrets.Checker(source.file)
+		if err != nil {
+			data.Error = "Failed to load the secrets of " + source.file.Name
+			h.renderCompare(c, userModel, http.StatusInternalServerError, data)
+			return
+		}
+		for _, key := range keys {
+			if isSecret(key) {
+				data.Error = fmt.Sprintf("%s is secret in %s; promoting secrets needs write access to both files", key, source.file.Name)
+				h.renderCompare(c, userModel, http.StatusForbidden, data)
+				return
+			}
+		}
+	}
+
+	sourceContent, err := h.store.Read(source.file)
+	if err != nil {
+		data.Error = "Failed to read " + source.file.Name
+		h.renderCompare(c, userModel, http.StatusInternalServerError, data)
+		return
+	}
+	unlock := h.store.Lock(target.file)
+	defer unlock()
+	targetContent, err := h.store.Read(target.file)
+	if err != nil {
+		data.Error = "Failed to read " + target.file.Name
+		h.renderCompare(c, userModel, http.StatusInternalServerError, data)
+		return
+	}
+
+	sourceFile, _ := envfile.Parse(sourceContent)
+	targetFile, _ := envfile.Parse(targetContent)
+	for _, key := range keys {
+		value, ok := sourceFile.Get(key)
+		if !ok {
+			data.Error = fmt.Sprintf("%s is not defined in %s", key, source.file.Name)
+			h.renderCompare(c, userModel, http.StatusConflict, data)
+			return
+		}
+		if err := targetFile.Set(key, value); err != nil {
+			data.Error = err.Error()
+			h.renderCompare(c, userModel, http.StatusBadRequest, data)
+			return
+		}
+	}
+
+	message := strings.TrimSpace(c.PostForm("message"))
+	if message == "" {
+		message = fmt.Sprintf("Promoted %s from %s", strings.Join(keys, ", "), source.file.Name)
+	}
+	var edit templ.EnvEditData
+	revision, status, err := h.save(target.file, targetFile.String(), targetContent, userModel, message, &edit)
+	if err != nil {
+		data.Error = "Failed to save " + target.file.Name
+		h.renderCompare(c, userModel, http.StatusInternalServerError, data)
+		return
+	}
+	if status != http.StatusOK {
+		data.Error = edit.Error
+		h.renderCompare(c, userModel, status, data)
+		return
+	}
+
+	// Keys marked secret in the source stay secret in the target
+	if marked, err := h.secrets.Marked(source.file); err == nil {
+		for _, key := range keys {
+			if marked[key] {
+				if err := h.secrets.Mark(target.file, key, true); err != nil {
+					log.Printf("env: failed to mark %s secret in %s: %v", key, target.file, err)
+				}
+			}
+		}
+	}
+
+	data.Success = fmt.Sprintf("Promoted %d key(s) to %s as revision #%d", len(keys), target.file.Name, revision.ID)
+	h.renderCompare(c, userModel, http.StatusOK, data)
+}
+
+// resolveRef resolves a "<project>/<file>" reference
+func (h *EnvHandler) resolveRef(user *models.User, ref, level string) (envRef, int, error) {
+	id, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		return envRef{}, http.StatusBadRequest, errInvalidRef
+	}
+	project, file, status, err := h.resolve(user, id, name, level)
+	if err != nil {
+		return envRef{}, status, err
+	}
+	return envRef{project: project, file: file}, http.StatusOK, nil
+}
+
+// fileRefs lists the env files of every project the user can access
+func (h *EnvHandler) fileRefs(user *models.User) ([]templ.EnvFileRef, error) {
+	projects, levels, err := h.projects.Accessible(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []templ.EnvFileRef
+	for _, project := range projects {
+		files, err := h.projects.Files(&project)
+		if err != nil {
+			continue
+		}
+		for _, name := range files {
+			refs = append(refs, templ.EnvFileRef{
+				ProjectID: project.ID,
+				Project:   project.Name,
+				Name:      name,
+				CanWrite:  levels[project.ID] == models.EnvAccessWrite,
+			})
+		}
+	}
+	return refs, nil
+}
+
+// renderCompare renders the compare view for the files selected in data
+func (h *EnvHandler) renderCompare(c *gin.Context, user *models.User, status int, data templ.EnvCompareData) {
+	data.AuthData = templ.AuthData{
+		Title:       "Compare Environment Files - Sysara",
+		PageTitle:   "Compare Environment Files",
+		CurrentUser: *user,
+	}
+
+	refs, err := h.fileRefs(user)
+	if err != nil {
+		data.Error = "Failed to load projects"
+		status = http.StatusInternalServerError
+	}
+	data.Files = refs
+
+	if data.Left == "" || data.Right == "" {
+		c.Header("Content-Type", "text/html")
+		c.Status(status)
+		templ.EnvCompare(data).Render(c.Request.Context(), c.Writer)
+		return
+	}
+
+	rows, err := h.compareRows(user, data.Left, data.Right)
+	if err != nil {
+		if data.Error == "" {
+			data.Error = err.Error()
+		}
+		if status == http.StatusOK {
+			status = http.StatusBadRequest
+		}
+	}
+	data.Rows = rows
+	for _, ref := range refs {
+		data.CanWriteLeft = data.CanWriteLeft || (ref.Ref() == data.Left && ref.CanWrite)
+		data.CanWriteRight = data.CanWriteRight || (ref.Ref() == data.Right && ref.CanWrite)
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvCompare(data).Render(c.Request.Context(), c.Writer)
+}
+
+// compareRows reads both files and aligns them by key with secrets masked
+func (h *EnvHandler) compareRows(user *models.User, left, right string) ([]envfile.KeyComparison, error) {
+	var files [2]*envfile.File
+	var checkers [2]func(string) bool
+	for i, ref := range []string{left, right} {
+		resolved, _, err := h.resolveRef(user, ref, models.EnvAccessRead)
+		if err != nil {
+			return nil, err
+		}
+		content, err := h.store.Read(resolved.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s", resolved.file.Name)
+		}
+		files[i], _ = envfile.Parse(content)
+		if checkers[i], err = h.secrets.Checker(resolved.file); err != nil {
+			// Fail closed: treat every value as secret
+			checkers[i] = func(string) bool { return true }
+		}
+	}
+
+	rows := envfile.Compare(files[0], files[1])
+	envstore.MaskComparison(rows, func(key string) bool {
+		return checkers[0](key) || checkers[1](key)
+	})
+	return rows, nil
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/envstore"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// ShowHooks displays the post-save hooks of an env file (admin only)
+func (h *EnvHandler) ShowHooks(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	h.renderHooks(c, userModel, http.StatusOK, project, file, templ.EnvHooksData{})
+}
+
+// CreateHook adds a post-save hook to an env file
+func (h *EnvHandler) CreateHook(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	hook := models.EnvHook{
+		Type:     c.PostForm("type"),
+		Target:   c.PostForm("target"),
+		Argument: c.PostForm("argument"),
+	}
+	if timeout := c.PostForm("timeout"); timeout != "" {
+		if hook.Timeout, err = strconv.Atoi(timeout); err != nil {
+			h.renderHooks(c, userModel, http.StatusBadRequest, project, file, templ.EnvHooksData{
+				Form:  hook,
+				Error: "Timeout must be a number of seconds",
+			})
+			return
+		}
+	}
+	if err := h.hooks.Create(file, &hook); err != nil {
+		h.renderHooks(c, userModel, http.StatusBadRequest, project, file, templ.EnvHooksData{
+			Form:  hook,
+			Error: err.Error(),
+		})
+		return
+	}
+
+	h.audit.Record(userModel, audit.ActionEnvHookCreate, file.String(), envstore.DescribeHook(hook), c.ClientIP())
+	h.renderHooks(c, userModel, http.StatusOK, project, file, templ.EnvHooksData{
+		Success: "Hook added. It runs after the next save.",
+	})
+}
+
+// DeleteHook removes a post-save hook of an env file
+func (h *EnvHandler) DeleteHook(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	id, err := strconv.ParseUint(c.PostForm("id"), 10, 32)
+	if err != nil {
+		h.renderHooks(c, userModel, http.StatusNotFound, project, file, templ.EnvHooksData{Error: envstore.ErrHookNotFound.Error()})
+		return
+	}
+	if err := h.hooks.Delete(file, uint(id)); err != nil {
+		status, message := http.StatusInternalServerError, "Failed to delete hook"
+		if errors.Is(err, envstore.ErrHookNotFound) {
+			status, message = http.StatusNotFound, err.Error()
+		}
+		h.renderHooks(c, userModel, status, project, file, templ.EnvHooksData{Error: message})
+		return
+	}
+
+	h.audit.Record(userModel, audit.ActionEnvHookDelete, file.String(), fmt.Sprintf("hook #%d", id), c.ClientIP())
+	h.renderHooks(c, userModel, http.StatusOK, project, file, templ.EnvHooksData{Success: "Hook deleted"})
+}
+
+// renderHooks renders the hooks of a file with their recent runs
+func (h *EnvHandler) renderHooks(c *gin.Context, user *models.User, status int, project *models.EnvProject, file envstore.File, data templ.EnvHooksData) {
+	data.AuthData = templ.AuthData{
+		Title:       fmt.Sprintf("Hooks of %s - Sysara", file.Name),
+		PageTitle:   "Environment Hooks",
+		CurrentUser: *user,
+	}
+	data.Project = *project
+	data.Filename = file.Name
+	data.Commands = h.hooks.Commands()
+	// The type is used in an Alpine expression, only known types are kept
+	known := false
+	for _, hookType := range envstore.HookTypes {
+		known = known || hookType == data.Form.Type
+	}
+	if !known {
+		data.Form.Type = models.EnvHookSystemd
+	}
+
+	hooks, err := h.hooks.List(file)
+	if err != nil {
+		data.Error = "Failed to load hooks"
+		status = http.StatusInternalServerError
+	}
+	data.Hooks = hooks
+
+	runs, err := h.hooks.Runs(file, 20)
+	if err != nil {
+		data.Error = "Failed to load hook runs"
+		status = http.StatusInternalServerError
+	}
+	data.Runs = runs
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvHooks(data).Render(c.Request.Context(), c.Writer)
+}
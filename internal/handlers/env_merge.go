@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/alpemreelmas/sysara/internal/envfile"
+	"github.com/alpemreelmas/sysara/internal/envstore"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// renderMerge handles a save that was based on an outdated version of
+// the file. The submitted changes are merged into the current content by
+// key and shown for review in the raw editor; nothing is written.
+func (h *EnvHandler) renderMerge(c *gin.Context, user *models.User, project *models.EnvProject, file envstore.File, mode, base, current, submitted string) {
+	data := templ.EnvEditData{
+		Mode:    templ.EnvModeRaw,
+		Content: current,
+		Merge:   &templ.EnvMerge{},
+		Error:   "This file was changed since you opened it. Your changes were not saved.",
+	}
+
+	if latest, err := h.store.Latest(file); err == nil && latest != nil {
+		data.Merge.Outside = latest.Hash != h.store.Hash(current)
+		if revision, err := h.store.Revision(file, latest.ID); err == nil {
+			data.Merge.Revision = revision
+		}
+	}
+
+	// The revision the form was rendered from is the common ancestor
+	baseContent := current
+	if revision, err := h.store.RevisionByHash(file, base); err == nil {
+		baseContent = revision.Content
+		data.Merge.BaseKnown = true
+	} else if !errors.Is(err, envstore.ErrRevisionNotFound) {
+		log.Printf("env: failed to load base revision of %s: %v", file, err)
+	}
+
+	isSecret, err := h.secrets.Checker(file)
+	if err != nil {
+		isSecret = func(string) bool { return true }
+	}
+
+	var yours string
+	if mode == templ.EnvModeTable {
+		if !data.Merge.BaseKnown {
+			// Rows refer to lines of a version that is no longer known
+			data.Mode = templ.EnvModeTable
+			data.Error = "This file was changed since you opened it. Your changes could not be applied, please make them again."
+			h.renderEdit(c, user, http.StatusConflict, project, file, data)
+			return
+		}
+		yours, _, err = applyTableEdits(baseContent, isSecret, c)
+	} else {
+		// Masked secrets stand for the values of the version the user saw
+		yours, err = envstore.Unmask(submitted, baseContent)
+	}
+	if err != nil {
+		data.Error = "This file was changed since you opened it and your changes could not be merged: " + err.Error()
+		h.renderEdit(c, user, http.StatusConflict, project, file, data)
+		return
+	}
+
+	theirs, _ := envfile.Parse(current)
+	baseFile, _ := envfile.Parse(baseContent)
+	yoursFile, _ := envfile.Parse(yours)
+	merged, conflicts := envfile.Merge(baseFile, theirs, yoursFile)
+	data.Content = merged.String()
+
+	from, to := envstore.MaskPair(current, data.Content, isSecret)
+	if from != to {
+		data.Merge.Rows = envfile.Diff(from, to)
+	}
+	for _, conflict := range conflicts {
+		if isSecret(conflict.Key) && conflict.Theirs != "" {
+			conflict.Theirs = envstore.MaskedValue
+		}
+		data.Merge.Conflicts = append(data.Merge.Conflicts, conflict)
+	}
+
+	// Values taken from the submission are the user's own and stay visible,
+	// so that saving the merge result does not replace them with the mask
+	currentValues := theirs.Map()
+	data.Own = map[string]bool{}
+	for key, value := range merged.Map() {
+		if previous, ok := currentValues[key]; !ok || previous != value {
+			data.Own[key] = true
+		}
+	}
+
+	h.renderEdit(c, user, http.StatusConflict, project, file, data)
+}
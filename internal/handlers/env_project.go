@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/envstore"
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/teams"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// EnvProjectHandler handles the registration of env project roots and
+// their permissions (admin only)
+type EnvProjectHandler struct {
+	db       *gorm.DB
+	projects *envstore.Projects
+	teams    *teams.Manager
+}
+
+// NewEnvProjectHandler creates a new env project handler
+func NewEnvProjectHandler(db *gorm.DB, projects *envstore.Projects, teamManager *teams.Manager) *EnvProjectHandler {
+	return &EnvProjectHandler{db: db, projects: projects, teams: teamManager}
+}
+
+// ListProjects displays all registered projects
+func (h *EnvProjectHandler) ListProjects(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.renderList(c, userModel, http.StatusOK, "")
+}
+
+// ShowCreateProject displays the create project form
+func (h *EnvProjectHandler) ShowCreateProject(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.renderForm(c, userModel, http.StatusOK, templ.EnvProjectFormData{
+		Project: models.EnvProject{ExampleFile: ".env.example"},
+	})
+}
+
+// CreateProject registers a new project root
+func (h *EnvProjectHandler) CreateProject(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project := models.EnvProject{
+		Name:        strings.TrimSpace(c.PostForm("name")),
+		Root:        c.PostForm("root"),
+		Description: strings.TrimSpace(c.PostForm("description")),
+		ExampleFile: c.PostForm("example_file"),
+		TeamLevel:   c.PostForm("team_level"),
+	}
+	if project.Name == "" {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: project, Error: "Name is required"})
+		return
+	}
+	if !h.setTeam(userModel, &project, c.PostForm("team_id")) {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: project, Error: "Team not found"})
+		return
+	}
+	if err := h.projects.Create(&project); err != nil {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: project, Error: projectErrorMessage(err)})
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/env/projects/"+strconv.FormatUint(uint64(project.ID), 10)+"/edit")
+}
+
+// ShowEditProject displays the edit form and permissions of a project
+func (h *EnvProjectHandler) ShowEditProject(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, ok := h.project(c, userModel)
+	if !ok {
+		return
+	}
+	h.renderForm(c, userModel, http.StatusOK, templ.EnvProjectFormData{Project: *project})
+}
+
+// UpdateProject saves the settings of a project
+func (h *EnvProjectHandler) UpdateProject(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, ok := h.project(c, userModel)
+	if !ok {
+		return
+	}
+
+	project.Name = strings.TrimSpace(c.PostForm("name"))
+	project.Root = c.PostForm("root")
+	project.Description = strings.TrimSpace(c.PostForm("description"))
+	project.ExampleFile = c.PostForm("example_file")
+	project.TeamLevel = c.PostForm("team_level")
+	if project.Name == "" {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: *project, Error: "Name is required"})
+		return
+	}
+	if !h.setTeam(userModel, project, c.PostForm("team_id")) {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: *project, Error: "Team not found"})
+		return
+	}
+	if err := h.projects.Update(project); err != nil {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: *project, Error: projectErrorMessage(err)})
+		return
+	}
+
+	h.renderForm(c, userModel, http.StatusOK, templ.EnvProjectFormData{Project: *project, Success: "Project updated"})
+}
+
+// DeleteProject removes a project with its revisions and permissions.
+// The files on disk are not touched.
+func (h *EnvProjectHandler) DeleteProject(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, ok := h.project(c, userModel)
+	if !ok {
+		return
+	}
+	if err := h.projects.Delete(project.ID); err != nil {
+		h.renderList(c, userModel, http.StatusInternalServerError, "Failed to delete project")
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/env/projects")
+}
+
+// SetPermission grants a user read or write access to a project
+func (h *EnvProjectHandler) SetPermission(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, ok := h.project(c, userModel)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.PostForm("user_id"), 10, 32)
+	if err != nil {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: *project, Error: "Select a user"})
+		return
+	}
+	var user models.User
+	if err := h.db.First(&user, uint(userID)).Error; err != nil {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: *project, Error: "User not found"})
+		return
+	}
+	if err := h.projects.SetPermission(project.ID, user.ID, c.PostForm("level")); err != nil {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: *project, Error: err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/env/projects/"+c.Param("id")+"/edit")
+}
+
+// RemovePermission revokes a user's access to a project
+func (h *EnvProjectHandler) RemovePermission(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, ok := h.project(c, userModel)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("user"), 10, 32)
+	if err != nil {
+		h.renderForm(c, userModel, http.StatusBadRequest, templ.EnvProjectFormData{Project: *project, Error: "Invalid user ID"})
+		return
+	}
+	if err := h.projects.RemovePermission(project.ID, uint(userID)); err != nil {
+		h.renderForm(c, userModel, http.StatusInternalServerError, templ.EnvProjectFormData{Project: *project, Error: "Failed to remove permission"})
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/env/projects/"+c.Param("id")+"/edit")
+}
+
+// project loads the project from the :id parameter, rendering the list
+// with an error if it does not exist
+func (h *EnvProjectHandler) project(c *gin.Context, user *models.User) (*models.EnvProject, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.renderList(c, user, http.StatusNotFound, envstore.ErrProjectNotFound.Error())
+		return nil, false
+	}
+	project, err := h.projects.Get(uint(id))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, envstore.ErrProjectNotFound) {
+			status = http.StatusNotFound
+		}
+		h.renderList(c, user, status, projectErrorMessage(err))
+		return nil, false
+	}
+	return project, true
+}
+
+// setTeam sets the owner team of a project from the team_id form value,
+// reporting false for unknown teams
+func (h *EnvProjectHandler) setTeam(user *models.User, project *models.EnvProject, value string) bool {
+	teamID, err := teamParam(value)
+	if err == nil {
+		err = h.teams.Assignable(user, teamID)
+	}
+	if err != nil {
+		return false
+	}
+	project.TeamID = teamID
+	project.Team = nil
+	return true
+}
+
+// renderList renders the project list with an optional error
+func (h *EnvProjectHandler) renderList(c *gin.Context, user *models.User, status int, errMsg string) {
+	data := templ.EnvProjectListData{
+		AuthData: templ.AuthData{
+			Title:       "Env Projects - Sysara",
+			PageTitle:   "Env Projects",
+			CurrentUser: *user,
+		},
+		Error: errMsg,
+	}
+	projects, err := h.projects.List()
+	if err != nil {
+		data.Error = "Failed to load projects"
+		status = http.StatusInternalServerError
+	}
+	data.Projects = projects
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvProjectList(data).Render(c.Request.Context(), c.Writer)
+}
+
+// renderForm renders the create or edit form of a project
+func (h *EnvProjectHandler) renderForm(c *gin.Context, user *models.User, status int, data templ.EnvProjectFormData) {
+	data.AuthData = templ.AuthData{
+		Title:       "Create Env Project - Sysara",
+		PageTitle:   "Env Projects",
+		CurrentUser: *user,
+	}
+	if data.Project.ID != 0 {
+		data.AuthData.Title = "Edit " + data.Project.Name + " - Sysara"
+		// Admins always have write access and are not listed
+		h.db.Where("role <> ? AND status <> ?", models.RoleAdmin, models.UserDeleted).Order("name").Find(&data.Users)
+	}
+	if teamList, err := h.teams.List(); err == nil {
+		data.Teams = teamList
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvProjectForm(data).Render(c.Request.Context(), c.Writer)
+}
+
+// projectErrorMessage turns project errors into user-facing messages
+func projectErrorMessage(err error) string {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return "A project with this name already exists"
+	}
+	return err.Error()
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/envfile"
+	"github.com/alpemreelmas/sysara/internal/envstore"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// ShowSchema displays the schema rules of an environment file and the
+// keys it is missing compared to the project's example file
+func (h *EnvHandler) ShowSchema(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessRead)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	// Editing an existing rule prefills the form
+	data := templ.EnvSchemaData{}
+	if key := c.Query("key"); key != "" {
+		rules, _ := h.schemas.Rules(file)
+		for _, rule := range rules {
+			if rule.Key == key {
+				data.Form = rule
+			}
+		}
+	}
+	h.renderSchema(c, userModel, http.StatusOK, project, file, data)
+}
+
+// SaveSchemaRule creates or replaces the rule of a key
+func (h *EnvHandler) SaveSchemaRule(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	rule := models.EnvSchemaRule{
+		Key:         c.PostForm("key"),
+		Required:    c.PostForm("required") == "1",
+		Type:        c.PostForm("type"),
+		Pattern:     strings.TrimSpace(c.PostForm("pattern")),
+		Enum:        strings.TrimSpace(c.PostForm("enum")),
+		Default:     c.PostForm("default"),
+		Description: strings.TrimSpace(c.PostForm("description")),
+	}
+	if err := h.schemas.SaveRule(file, &rule); err != nil {
+		h.renderSchema(c, userModel, http.StatusBadRequest, project, file, templ.EnvSchemaData{
+			Form:  rule,
+			Error: err.Error(),
+		})
+		return
+	}
+
+	h.renderSchema(c, userModel, http.StatusOK, project, file, templ.EnvSchemaData{
+		Success: fmt.Sprintf("Rule for %s saved", rule.Key),
+	})
+}
+
+// DeleteSchemaRule removes the rule of a key
+func (h *EnvHandler) DeleteSchemaRule(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	key := c.PostForm("key")
+	if err := h.schemas.DeleteRule(file, key); err != nil {
+		h.renderSchema(c, userModel, http.StatusInternalServerError, project, file, templ.EnvSchemaData{
+			Error: "Failed to delete rule",
+		})
+		return
+	}
+
+	h.renderSchema(c, userModel, http.StatusOK, project, file, templ.EnvSchemaData{
+		Success: fmt.Sprintf("Rule for %s deleted", key),
+	})
+}
+
+// AddMissingKeys appends the required keys that the file lacks, using
+// their defaults as values, and saves the result as a revision
+func (h *EnvHandler) AddMissingKeys(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+	mode := editorMode(c.PostForm("mode"))
+
+	unlock := h.store.Lock(file)
+	defer unlock()
+	current, err := h.store.Read(file)
+	if err != nil {
+		h.renderEdit(c, userModel, http.StatusInternalServerError, project, file, templ.EnvEditData{
+			Mode:  mode,
+			Error: "Failed to read environment file",
+		})
+		return
+	}
+	schema, err := h.schemas.Schema(project, file)
+	if err != nil {
+		h.renderEdit(c, userModel, http.StatusInternalServerError, project, file, templ.EnvEditData{
+			Mode:    mode,
+			Content: current,
+			Error:   "Failed to load the schema",
+		})
+		return
+	}
+
+	parsed, _ := envfile.Parse(current)
+	missing := schema.Missing(parsed)
+	if len(missing) == 0 {
+		h.renderEdit(c, userModel, http.StatusOK, project, file, templ.EnvEditData{
+			Mode:    mode,
+			Content: current,
+			Success: "No required keys are missing",
+		})
+		return
+	}
+	for _, rule := range missing {
+		if _, err := parsed.Append(rule.Key, rule.Default); err != nil {
+			h.renderEdit(c, userModel, http.StatusBadRequest, project, file, templ.EnvEditData{
+				Mode:    mode,
+				Content: current,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	data := templ.EnvEditData{Mode: mode}
+	revision, status, err := h.save(file, parsed.String(), current, userModel, fmt.Sprintf("Added %d missing key(s) from the schema", len(missing)), &data)
+	if err != nil {
+		h.renderEdit(c, userModel, http.StatusInternalServerError, project, file, templ.EnvEditData{
+			Mode:    mode,
+			Content: current,
+			Error:   "Failed to save environment file",
+		})
+		return
+	}
+	if status == http.StatusOK {
+		data.Success = fmt.Sprintf("Added %d missing key(s) as revision #%d", len(missing), revision.ID)
+	}
+	h.renderEdit(c, userModel, status, project, file, data)
+}
+
+// renderSchema renders the schema page of a file
+func (h *EnvHandler) renderSchema(c *gin.Context, user *models.User, status int, project *models.EnvProject, file envstore.File, data templ.EnvSchemaData) {
+	data.AuthData = templ.AuthData{
+		Title:       fmt.Sprintf("Schema of %s - Sysara", file.Name),
+		PageTitle:   "Environment Schema",
+		CurrentUser: *user,
+	}
+	data.Project = *project
+	data.Filename = file.Name
+	data.CanWrite = h.projects.Access(user, project.ID) == models.EnvAccessWrite
+	if data.Form.Type == "" {
+		data.Form.Type = envfile.TypeString
+	}
+
+	rules, err := h.schemas.Rules(file)
+	if err != nil {
+		data.Error = "Failed to load schema rules"
+		status = http.StatusInternalServerError
+	}
+	data.Rules = rules
+
+	content, err := h.store.Read(file)
+	if err != nil {
+		data.Error = "Failed to read environment file"
+		status = http.StatusInternalServerError
+	}
+	parsed, _ := envfile.Parse(content)
+
+	if schema, err := h.schemas.Schema(project, file); err == nil {
+		data.Violations = schema.Validate(parsed)
+	}
+	if example, err := h.schemas.Example(project, file); err != nil {
+		data.ExampleError = err.Error()
+	} else if example != nil {
+		report := envfile.CompareKeys(parsed, example)
+		data.Report = &report
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvSchema(data).Render(c.Request.Context(), c.Writer)
+}
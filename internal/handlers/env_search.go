@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/envfile"
+	"github.com/alpemreelmas/sysara/internal/envstore"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchResults limits the number of lines a search returns
+const maxSearchResults = 500
+
+// Bulk operations of the search view
+const (
+	searchRename = "rename"
+	searchUpdate = "update"
+)
+
+// ShowSearch searches the keys or values of every accessible env file
+func (h *EnvHandler) ShowSearch(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.renderSearch(c, userModel, http.StatusOK, searchQuery(c.Query), templ.EnvSearchData{})
+}
+
+// BulkEdit renames a key or sets its value in every selected file. Each
+// changed file is saved as its own revision.
+func (h *EnvHandler) BulkEdit(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	query := searchQuery(c.PostForm)
+	operation := c.PostForm("operation")
+	key := strings.TrimSpace(c.PostForm("key"))
+	newKey := strings.TrimSpace(c.PostForm("new_key"))
+	value := c.PostForm("value")
+
+	var data templ.EnvSearchData
+	switch {
+	case operation != searchRename && operation != searchUpdate:
+		data.Error = "Unknown operation"
+	case !envfile.ValidKey(key):
+		data.Error = "Enter the key to change"
+	case operation == searchRename && !envfile.ValidKey(newKey):
+		data.Error = "Enter a valid new key name"
+	case operation == searchRename && newKey == key:
+		data.Error = "The new key name is the same as the old one"
+	}
+	refs := uniqueRefs(c.PostFormArray("files"))
+	if data.Error == "" && len(refs) == 0 {
+		data.Error = "Select the files to change"
+	}
+	if data.Error != "" {
+		h.renderSearch(c, userModel, http.StatusBadRequest, query, data)
+		return
+	}
+
+	message := strings.TrimSpace(c.PostForm("message"))
+	if message == "" {
+		if operation == searchRename {
+			message = fmt.Sprintf("Renamed %s to %s", key, newKey)
+		} else {
+			message = fmt.Sprintf("Updated %s", key)
+		}
+	}
+
+	changed := 0
+	for _, ref := range refs {
+		label, err := h.bulkEditFile(userModel, ref, operation, key, newKey, value, message)
+		if err != nil {
+			data.Failures = append(data.Failures, label+": "+err.Error())
+			continue
+		}
+		changed++
+	}
+
+	status := http.StatusOK
+	if len(data.Failures) > 0 {
+		status = http.StatusConflict
+		data.Error = fmt.Sprintf("%d of %d file(s) were not changed", len(data.Failures), len(refs))
+	}
+	if changed > 0 {
+		data.Success = fmt.Sprintf("Saved %d file(s) as new revisions", changed)
+	}
+	if operation == searchRename && changed > 0 && query.Field == templ.EnvSearchKey && query.Query == key {
+		// Follow the key to its new name
+		query.Query = newKey
+	}
+	h.renderSearch(c, userModel, status, query, data)
+}
+
+// bulkEditFile applies a bulk operation to one file and returns the label
+// of the file for messages
+func (h *EnvHandler) bulkEditFile(user *models.User, ref, operation, key, newKey, value, message string) (string, error) {
+	target, _, err := h.resolveRef(user, ref, models.EnvAccessWrite)
+	if err != nil {
+		return ref, err
+	}
+	label := target.project.Name + " / " + target.file.Name
+
+	unlock := h.store.Lock(target.file)
+	defer unlock()
+	content, err := h.store.Read(target.file)
+	if err != nil {
+		return label, fmt.Errorf("failed to read the file")
+	}
+	parsed, _ := envfile.Parse(content)
+	if _, ok := parsed.Lookup(key); !ok {
+		return label, fmt.Errorf("%s is not defined", key)
+	}
+	if operation == searchRename {
+		if _, ok := parsed.Lookup(newKey); ok {
+			return label, fmt.Errorf("%s is already defined", newKey)
+		}
+	}
+
+	// Every definition is changed so that duplicates stay consistent
+	for _, line := range parsed.Variables() {
+		if line.Key != key {
+			continue
+		}
+		if operation == searchRename {
+			if err := line.Rename(newKey); err != nil {
+				return label, err
+			}
+		} else {
+			line.SetValue(value)
+		}
+	}
+	updated := parsed.String()
+	if updated == content {
+		return label, fmt.Errorf("%s already has this value", key)
+	}
+
+	var edit templ.EnvEditData
+	_, status, err := h.save(target.file, updated, content, user, message, &edit)
+	if err != nil {
+		return label, fmt.Errorf("failed to save the file")
+	}
+	if status != http.StatusOK {
+		// A failed hook restored the previous content
+		return label, fmt.Errorf("%s", edit.Error)
+	}
+
+	if operation == searchRename {
+		// A secret key stays secret under its new name
+		if marked, err := h.secrets.Marked(target.file); err == nil && marked[key] {
+			if err := h.secrets.Mark(target.file, newKey, true); err != nil {
+				log.Printf("env: failed to mark %s secret in %s: %v", newKey, target.file, err)
+			}
+			if err := h.secrets.Mark(target.file, key, false); err != nil {
+				log.Printf("env: failed to unmark %s in %s: %v", key, target.file, err)
+			}
+		}
+	}
+	return label, nil
+}
+
+// searchQuery reads the search parameters from a query string or form
+func searchQuery(get func(string) string) templ.EnvSearchQuery {
+	query := templ.EnvSearchQuery{
+		Query:   strings.TrimSpace(get("q")),
+		Field:   get("field"),
+		Regex:   get("regex") == "1",
+		Secrets: get("secrets") == "1",
+	}
+	if query.Field != templ.EnvSearchValue {
+		query.Field = templ.EnvSearchKey
+	}
+	return query
+}
+
+// uniqueRefs removes repeated file references, keeping their order
+func uniqueRefs(refs []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, ref := range refs {
+		if ref != "" && !seen[ref] {
+			seen[ref] = true
+			unique = append(unique, ref)
+		}
+	}
+	return unique
+}
+
+// search finds the variable lines matching the query in every file the
+// user can access. Secret values are only searched in projects the user
+// can write to and when asked for, and are always shown masked.
+func (h *EnvHandler) search(user *models.User, query templ.EnvSearchQuery) ([]templ.EnvSearchResult, bool, error) {
+	pattern := regexp.QuoteMeta(query.Query)
+	if query.Regex {
+		pattern = query.Query
+	}
+	matcher, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	projects, levels, err := h.projects.Accessible(user)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load projects")
+	}
+
+	var results []templ.EnvSearchResult
+	for i := range projects {
+		project := &projects[i]
+		canWrite := levels[project.ID] == models.EnvAccessWrite
+		names, err := h.projects.Files(project)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			file, err := h.projects.Resolve(project, name)
+			if err != nil {
+				continue
+			}
+			content, err := h.store.Read(file)
+			if err != nil {
+				log.Printf("env: search failed to read %s: %v", file, err)
+				continue
+			}
+			isSecret, err := h.secrets.Checker(file)
+			if err != nil {
+				isSecret = func(string) bool { return true }
+			}
+
+			parsed, _ := envfile.Parse(content)
+			for _, line := range parsed.Variables() {
+				secret := isSecret(line.Key)
+				if query.Field == templ.EnvSearchValue {
+					if secret && !(query.Secrets && canWrite) {
+						continue
+					}
+					if !matcher.MatchString(line.Value) {
+						continue
+					}
+				} else if !matcher.MatchString(line.Key) {
+					continue
+				}
+
+				result := templ.EnvSearchResult{
+					File: templ.EnvFileRef{
+						ProjectID: project.ID,
+						Project:   project.Name,
+						Name:      name,
+						CanWrite:  canWrite,
+					},
+					Line:   line.Number,
+					Key:    line.Key,
+					Value:  line.Value,
+					Secret: secret,
+				}
+				if secret && result.Value != "" {
+					result.Value = envstore.MaskedValue
+				}
+				results = append(results, result)
+				if len(results) == maxSearchResults {
+					return results, true, nil
+				}
+			}
+		}
+	}
+	return results, false, nil
+}
+
+// renderSearch runs the query, if any, and renders the search view
+func (h *EnvHandler) renderSearch(c *gin.Context, user *models.User, status int, query templ.EnvSearchQuery, data templ.EnvSearchData) {
+	data.AuthData = templ.AuthData{
+		Title:       "Search Environment Files - Sysara",
+		PageTitle:   "Search Environment Files",
+		CurrentUser: *user,
+	}
+	data.EnvSearchQuery = query
+
+	if _, levels, err := h.projects.Accessible(user); err == nil {
+		for _, level := range levels {
+			data.CanSearchSecrets = data.CanSearchSecrets || level == models.EnvAccessWrite
+		}
+	}
+
+	if query.Query != "" {
+		if query.Field == templ.EnvSearchValue && query.Secrets && data.CanSearchSecrets {
+			// Matching secret values discloses information about them
+			if err := h.audit.Record(user, audit.ActionEnvSearch, "env", "searched secret values for "+query.Query, c.ClientIP()); err != nil {
+				query.Secrets = false
+				data.EnvSearchQuery.Secrets = false
+				data.Error = "Failed to record audit log entry, secret values were not searched"
+			}
+		}
+
+		results, truncated, err := h.search(user, query)
+		if err != nil {
+			data.Error = err.Error()
+			if status == http.StatusOK {
+				status = http.StatusBadRequest
+			}
+		}
+		data.Searched = true
+		data.Results = results
+		data.Truncated = truncated
+		for _, result := range results {
+			data.CanEdit = data.CanEdit || result.File.CanWrite
+		}
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvSearch(data).Render(c.Request.Context(), c.Writer)
+}
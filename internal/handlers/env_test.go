@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tableForm returns a context carrying a table editor form
+func tableForm(form url.Values) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c
+}
+
+func TestApplyTableEditsKeepsMaskedSecrets(t *testing.T) {
+	current := "API=a1\nNOTE=hello\n"
+	isSecret := func(key string) bool { return key == "API" }
+
+	tests := []struct {
+		name    string
+		form    url.Values
+		content string
+		marks   map[string]bool
+	}{
+		{
+			name:    "unchecked while masked",
+			form:    url.Values{"key[0]": {"API"}, "original[0]": {"API"}, "value[0]": {"********"}, "key[1]": {"NOTE"}, "original[1]": {"NOTE"}, "value[1]": {"hello"}},
+			content: current,
+			marks:   map[string]bool{"API": true, "NOTE": false},
+		},
+		{
+			name:    "renamed while masked",
+			form:    url.Values{"key[0]": {"KEY"}, "original[0]": {"API"}, "value[0]": {"********"}, "key[1]": {"NOTE"}, "original[1]": {"NOTE"}, "value[1]": {"hello"}},
+			content: "KEY=a1\nNOTE=hello\n",
+			marks:   map[string]bool{"KEY": true, "API": false, "NOTE": false},
+		},
+		{
+			name:    "unchecked with a new value",
+			form:    url.Values{"key[0]": {"API"}, "original[0]": {"API"}, "value[0]": {"public"}, "key[1]": {"NOTE"}, "original[1]": {"NOTE"}, "value[1]": {"hello"}, "secret[1]": {"1"}},
+			content: "API=public\nNOTE=hello\n",
+			marks:   map[string]bool{"API": false, "NOTE": true},
+		},
+		{
+			name:    "renamed onto a key that was renamed away",
+			form:    url.Values{"key[0]": {"OLD"}, "original[0]": {"API"}, "value[0]": {"********"}, "key[1]": {"API"}, "original[1]": {"NOTE"}, "value[1]": {"hello"}},
+			content: "OLD=a1\nAPI=hello\n",
+			marks:   map[string]bool{"OLD": true, "API": false, "NOTE": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, marks, err := applyTableEdits(current, isSecret, tableForm(tt.form))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != tt.content {
+				t.Errorf("content = %q, want %q", content, tt.content)
+			}
+			if !reflect.DeepEqual(marks, tt.marks) {
+				t.Errorf("marks = %v, want %v", marks, tt.marks)
+			}
+		})
+	}
+}
+
+func TestApplyTableEditsRejectsChangedFiles(t *testing.T) {
+	form := url.Values{"key[0]": {"API"}, "original[0]": {"TOKEN"}, "value[0]": {"x"}}
+	if _, _, err := applyTableEdits("API=a1\n", func(string) bool { return false }, tableForm(form)); !errors.Is(err, errEnvChanged) {
+		t.Errorf("got %v, want errEnvChanged", err)
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/envfile"
+	"github.com/alpemreelmas/sysara/internal/envstore"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize limits the size of uploaded files
+const maxImportSize = 1 << 20
+
+// ShowTransfer displays the import and export options of an env file
+func (h *EnvHandler) ShowTransfer(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessRead)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	h.renderTransfer(c, userModel, http.StatusOK, project, file, templ.EnvTransferData{})
+}
+
+// ExportEnv downloads an env file in another format. Secret values are
+// only included for users with write access and recorded as a reveal.
+func (h *EnvHandler) ExportEnv(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessRead)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	format := c.PostForm("format")
+	withSecrets := c.PostForm("secrets") == "1"
+	if withSecrets && h.projects.Access(userModel, project.ID) != models.EnvAccessWrite {
+		h.renderTransfer(c, userModel, http.StatusForbidden, project, file, templ.EnvTransferData{
+			Error: "Exporting secret values requires write access",
+		})
+		return
+	}
+
+	content, err := h.store.Read(file)
+	if err != nil {
+		h.renderTransfer(c, userModel, http.StatusInternalServerError, project, file, templ.EnvTransferData{
+			Error: "Failed to read environment file",
+		})
+		return
+	}
+	parsed, _ := envfile.Parse(content)
+	pairs := parsed.Pairs()
+
+	if withSecrets {
+		// Secrets are only exported once the reveal has been recorded
+		if err := h.audit.Record(userModel, audit.ActionEnvReveal, file.String(), "exported as "+format, c.ClientIP()); err != nil {
+			h.renderTransfer(c, userModel, http.StatusInternalServerError, project, file, templ.EnvTransferData{
+				Error: "Failed to record audit log entry, nothing was exported",
+			})
+			return
+		}
+	} else {
+		isSecret, err := h.secrets.Checker(file)
+		if err != nil {
+			isSecret = func(string) bool { return true }
+		}
+		for i := range pairs {
+			if isSecret(pairs[i].Key) {
+				pairs[i].Value = ""
+			}
+		}
+	}
+
+	name := envfile.ResourceName(project.Name + "-" + file.Name)
+	data, err := envfile.Export(pairs, format, name)
+	if err != nil {
+		h.renderTransfer(c, userModel, http.StatusBadRequest, project, file, templ.EnvTransferData{Error: err.Error()})
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if format == envfile.FormatJSON {
+		contentType = "application/json"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s"`, name, envfile.ExportExtension(format)))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// PreviewImport shows the changes an uploaded file would make
+func (h *EnvHandler) PreviewImport(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	data := templ.EnvTransferData{
+		Format:  c.PostForm("format"),
+		Replace: c.PostForm("replace") == "1",
+	}
+
+	source, upload := "pasted content", []byte(c.PostForm("content"))
+	if header, err := c.FormFile("file"); err == nil {
+		if header.Size > maxImportSize {
+			data.Error = "The uploaded file is too large"
+			h.renderTransfer(c, userModel, http.StatusBadRequest, project, file, data)
+			return
+		}
+		f, err := header.Open()
+		if err == nil {
+			upload, err = io.ReadAll(io.LimitReader(f, maxImportSize))
+			f.Close()
+		}
+		if err != nil {
+			data.Error = "Failed to read the uploaded file"
+			h.renderTransfer(c, userModel, http.StatusBadRequest, project, file, data)
+			return
+		}
+		source = header.Filename
+	}
+	if len(strings.TrimSpace(string(upload))) == 0 {
+		data.Error = "Choose a file or paste the content to import"
+		h.renderTransfer(c, userModel, http.StatusBadRequest, project, file, data)
+		return
+	}
+
+	format := data.Format
+	if format == "auto" {
+		format = ""
+	}
+	pairs, err := envfile.Import(upload, format, source)
+	if err != nil {
+		data.Error = "Cannot import " + source + ": " + err.Error()
+		h.renderTransfer(c, userModel, http.StatusBadRequest, project, file, data)
+		return
+	}
+
+	data.Source = source
+	h.renderPreview(c, userModel, http.StatusOK, project, file, pairs, data)
+}
+
+// ApplyImport saves the previewed import as a new revision
+func (h *EnvHandler) ApplyImport(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	project, file, status, err := h.target(c, userModel, c.Param("filename"), models.EnvAccessWrite)
+	if err != nil {
+		h.renderList(c, userModel, status, err.Error())
+		return
+	}
+
+	data := templ.EnvTransferData{
+		Source:  c.PostForm("source"),
+		Replace: c.PostForm("replace") == "1",
+	}
+	pairs, err := envfile.Import([]byte(c.PostForm("pairs")), envfile.FormatDotenv, "")
+	if err != nil {
+		data.Error = "The preview is invalid, please upload the file again"
+		h.renderTransfer(c, userModel, http.StatusBadRequest, project, file, data)
+		return
+	}
+
+	unlock := h.store.Lock(file)
+	defer unlock()
+	current, err := h.store.Read(file)
+	if err != nil {
+		data.Error = "Failed to read environment file"
+		h.renderTransfer(c, userModel, http.StatusInternalServerError, project, file, data)
+		return
+	}
+	if c.PostForm("base_hash") != h.store.Hash(current) {
+		data.Error = "The file was changed since the preview was made. Review the updated preview and apply it again."
+		h.renderPreview(c, userModel, http.StatusConflict, project, file, pairs, data)
+		return
+	}
+
+	parsed, _ := envfile.Parse(current)
+	if err := parsed.Apply(pairs, data.Replace); err != nil {
+		data.Error = err.Error()
+		h.renderTransfer(c, userModel, http.StatusBadRequest, project, file, data)
+		return
+	}
+	content := parsed.String()
+
+	message := strings.TrimSpace(c.PostForm("message"))
+	if message == "" {
+		message = fmt.Sprintf("Imported %d key(s) from %s", len(pairs), data.Source)
+	}
+	edit := templ.EnvEditData{Mode: templ.EnvModeTable}
+	revision, status, err := h.save(file, content, current, userModel, message, &edit)
+	if err != nil {
+		data.Error = "Failed to save environment file"
+		h.renderTransfer(c, userModel, http.StatusInternalServerError, project, file, data)
+		return
+	}
+	if status == http.StatusOK {
+		edit.Success = fmt.Sprintf("Imported %d key(s) as revision #%d", len(pairs), revision.ID)
+	}
+	h.renderEdit(c, userModel, status, project, file, edit)
+}
+
+// renderPreview renders the changes that importing pairs would make to
+// the current content of the file
+func (h *EnvHandler) renderPreview(c *gin.Context, user *models.User, status int, project *models.EnvProject, file envstore.File, pairs []envfile.Pair, data templ.EnvTransferData) {
+	current, err := h.store.Read(file)
+	if err != nil {
+		data.Error = "Failed to read environment file"
+		h.renderTransfer(c, user, http.StatusInternalServerError, project, file, data)
+		return
+	}
+	before, _ := envfile.Parse(current)
+	after, _ := envfile.Parse(current)
+	if err := after.Apply(pairs, data.Replace); err != nil {
+		data.Error = err.Error()
+		h.renderTransfer(c, user, http.StatusBadRequest, project, file, data)
+		return
+	}
+
+	isSecret, err := h.secrets.Checker(file)
+	if err != nil {
+		isSecret = func(string) bool { return true }
+	}
+	rows := envfile.Compare(before, after)
+	envstore.MaskComparison(rows, isSecret)
+
+	encoded, _ := envfile.Export(pairs, envfile.FormatDotenv, "")
+	preview := &templ.EnvImportPreview{
+		Pairs:    string(encoded),
+		Count:    len(pairs),
+		BaseHash: h.store.Hash(current),
+	}
+	for _, row := range rows {
+		if row.Kind != envfile.DiffEqual {
+			preview.Rows = append(preview.Rows, row)
+		}
+	}
+	if schema, err := h.schemas.Schema(project, file); err != nil {
+		log.Printf("env: failed to load schema of %s: %v", file, err)
+	} else {
+		preview.Violations = schema.Validate(after)
+	}
+	data.Preview = preview
+
+	h.renderTransfer(c, user, status, project, file, data)
+}
+
+// renderTransfer renders the import and export page of a file
+func (h *EnvHandler) renderTransfer(c *gin.Context, user *models.User, status int, project *models.EnvProject, file envstore.File, data templ.EnvTransferData) {
+	data.AuthData = templ.AuthData{
+		Title:       fmt.Sprintf("Import & Export %s - Sysara", file.Name),
+		PageTitle:   "Import & Export",
+		CurrentUser: *user,
+	}
+	data.Project = *project
+	data.Filename = file.Name
+	data.CanWrite = h.projects.Access(user, project.ID) == models.EnvAccessWrite
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.EnvTransfer(data).Render(c.Request.Context(), c.Writer)
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/auth"
+	"github.com/alpemreelmas/sysara/internal/mail"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// InvitationHandler handles invite-based onboarding
+type InvitationHandler struct {
+	authService *auth.AuthService
+	mailer      mail.Mailer
+	audit       *audit.Logger
+	appURL      string
+	ttl         time.Duration
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler(authService *auth.AuthService, mailer mail.Mailer, auditLog *audit.Logger, appURL string, ttl time.Duration) *InvitationHandler {
+	return &InvitationHandler{
+		authService: authService,
+		mailer:      mailer,
+		audit:       auditLog,
+		appURL:      appURL,
+		ttl:         ttl,
+	}
+}
+
+// ShowInvite displays the form to invite a user
+func (h *InvitationHandler) ShowInvite(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	h.renderInvite(c, http.StatusOK, userModel, templ.UserInviteData{Role: models.RoleUser})
+}
+
+// Invite creates an invitation and emails the link
+func (h *InvitationHandler) Invite(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	data := templ.UserInviteData{
+		Email: strings.TrimSpace(c.PostForm("email")),
+		Role:  userRole(c.PostForm("role")),
+	}
+	if data.Email == "" {
+		data.Error = "Enter an email address"
+		h.renderInvite(c, http.StatusBadRequest, userModel, data)
+		return
+	}
+
+	invitation, token, err := h.authService.CreateInvitation(data.Email, data.Role, userModel, h.ttl)
+	if err != nil {
+		status := http.StatusInternalServerError
+		data.Error = "Failed to create the invitation"
+		if errors.Is(err, auth.ErrUserExists) {
+			status = http.StatusConflict
+			data.Error = err.Error()
+		}
+		h.renderInvite(c, status, userModel, data)
+		return
+	}
+	h.send(invitation, token, userModel)
+	h.audit.Record(userModel, audit.ActionUserInvite, invitation.Email, "role "+invitation.Role, c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/users?invitation=sent")
+}
+
+// ResendInvitation sends a new link for a pending invitation
+func (h *InvitationHandler) ResendInvitation(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	invitation, token, err := h.authService.ResendInvitation(uint(id), h.ttl)
+	if err != nil {
+		c.JSON(invitationStatus(err), gin.H{"error": invitationError(err)})
+		return
+	}
+	h.send(invitation, token, userModel)
+	h.audit.Record(userModel, audit.ActionUserInvite, invitation.Email, "resent", c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/users?invitation=resent")
+}
+
+// RevokeInvitation deletes a pending invitation so that its link stops working
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	invitation, err := h.authService.RevokeInvitation(uint(id))
+	if err != nil {
+		c.JSON(invitationStatus(err), gin.H{"error": invitationError(err)})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionUserInviteRevoke, invitation.Email, "", c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/users?invitation=revoked")
+}
+
+// ShowAccept displays the form to accept an invitation
+func (h *InvitationHandler) ShowAccept(c *gin.Context) {
+	token := c.Query("token")
+	invitation, err := h.authService.InvitationByToken(token)
+	if err != nil {
+		h.renderAccept(c, http.StatusBadRequest, templ.AcceptInviteData{Error: acceptError(err)})
+		return
+	}
+	h.renderAccept(c, http.StatusOK, templ.AcceptInviteData{Token: token, Email: invitation.Email, Valid: true})
+}
+
+// Accept creates the invited account and signs it in
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	token := c.PostForm("token")
+	invitation, err := h.authService.InvitationByToken(token)
+	if err != nil {
+		h.renderAccept(c, http.StatusBadRequest, templ.AcceptInviteData{Error: acceptError(err)})
+		return
+	}
+
+	password := c.PostForm("password")
+	data := templ.AcceptInviteData{Token: token, Email: invitation.Email, Name: c.PostForm("name"), Valid: true}
+	if password != c.PostForm("confirm_password") {
+		data.Error = "Passwords do not match"
+		h.renderAccept(c, http.StatusBadRequest, data)
+		return
+	}
+
+	user, err := h.authService.AcceptInvitation(token, data.Name, password)
+	if err != nil {
+		var policyErr *auth.PolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			data.Error = err.Error()
+			h.renderAccept(c, http.StatusBadRequest, data)
+		case errors.Is(err, auth.ErrInvalidInvitation), errors.Is(err, auth.ErrUserExists):
+			h.renderAccept(c, http.StatusBadRequest, templ.AcceptInviteData{Error: err.Error()})
+		default:
+			log.Printf("auth: failed to accept invitation for %s: %v", invitation.Email, err)
+			data.Error = "Failed to create your account"
+			h.renderAccept(c, http.StatusInternalServerError, data)
+		}
+		return
+	}
+
+	if err := h.authService.Login(c, user); err != nil {
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/dashboard")
+}
+
+// send emails an invite link in the background
+func (h *InvitationHandler) send(invitation *models.Invitation, token string, invitedBy *models.User) {
+	link := h.appURL + "/invite?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hello,\n\n"+
+		"%s invited you to Sysara. Open the link below within %d hours to create your account:\n\n"+
+		"%s\n\n"+
+		"The link can only be used once. If you did not expect this invitation, you can ignore this email.\n",
+		invitedBy.Name, int(h.ttl.Hours()), link)
+	go func(to string) {
+		if err := h.mailer.Send(to, "You are invited to Sysara", body); err != nil {
+			log.Printf("mail: failed to send invitation email to %s: %v", to, err)
+		}
+	}(invitation.Email)
+}
+
+func invitationStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func invitationError(err error) string {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "Invitation not found"
+	}
+	return "Failed to update the invitation"
+}
+
+// acceptError returns the message shown for a failed token lookup
+func acceptError(err error) string {
+	if errors.Is(err, auth.ErrInvalidInvitation) {
+		return err.Error()
+	}
+	log.Printf("auth: failed to look up invitation: %v", err)
+	return "Failed to load the invitation, please try again"
+}
+
+func (h *InvitationHandler) renderInvite(c *gin.Context, status int, user *models.User, data templ.UserInviteData) {
+	data.AuthData = templ.AuthData{
+		Title:       "Invite User - Sysara",
+		PageTitle:   "Invite User",
+		CurrentUser: *user,
+	}
+	data.TTLHours = int(h.ttl.Hours())
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.UserInvite(data).Render(c.Request.Context(), c.Writer)
+}
+
+func (h *InvitationHandler) renderAccept(c *gin.Context, status int, data templ.AcceptInviteData) {
+	data.Title = "Accept Invitation - Sysara"
+	data.PasswordRules = h.authService.Policy().Rules()
+	// The token must not leak to other sites through the Referer header
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.AcceptInvite(data).Render(c.Request.Context(), c.Writer)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/auth"
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/oidc"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// SSOHandler signs users in with an OpenID Connect identity provider
+type SSOHandler struct {
+	authService *auth.AuthService
+	provider    *oidc.Provider
+	mapping     auth.GroupMapping
+	login       LoginOptions
+	audit       *audit.Logger
+}
+
+// NewSSOHandler creates a new single sign-on handler
+func NewSSOHandler(authService *auth.AuthService, provider *oidc.Provider, mapping auth.GroupMapping, login LoginOptions, auditLog *audit.Logger) *SSOHandler {
+	return &SSOHandler{
+		authService: authService,
+		provider:    provider,
+		mapping:     mapping,
+		login:       login,
+		audit:       auditLog,
+	}
+}
+
+// Login redirects to the identity provider
+func (h *SSOHandler) Login(c *gin.Context) {
+	if h.authService.IsAuthenticated(c) {
+		c.Redirect(http.StatusSeeOther, "/dashboard")
+		return
+	}
+
+	url, err := h.authService.BeginSSO(c, h.provider)
+	if err != nil {
+		log.Printf("oidc: failed to start sign-in: %v", err)
+		h.renderError(c, http.StatusBadGateway, "Single sign-on is unavailable, please try again later")
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// Link sends the signed-in user to the identity provider to link their
+// account to it. The current password is needed when the user has one.
+func (h *SSOHandler) Link(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	if h.authService.LocalPasswords() && !userModel.IsExternal() {
+		if err := h.authService.VerifyPassword(userModel.Password, c.PostForm("current_password")); err != nil {
+			c.Redirect(http.StatusSeeOther, "/profile?failed=link-password")
+			return
+		}
+	}
+	url, err := h.authService.BeginSSOLink(c, h.provider, userModel)
+	if errors.Is(err, auth.ErrAlreadyLinked) {
+		c.Redirect(http.StatusSeeOther, "/profile?failed=linked")
+		return
+	}
+	if err != nil {
+		log.Printf("oidc: failed to start linking %s: %v", userModel.Email, err)
+		c.Redirect(http.StatusSeeOther, "/profile?failed=sso-link")
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// Callback completes the sign-in or link when the identity provider
+// redirects back
+func (h *SSOHandler) Callback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		message := c.Query("error_description")
+		if message == "" {
+			message = reason
+		}
+		h.renderError(c, http.StatusUnauthorized, "Sign-in was cancelled or refused: "+message)
+		return
+	}
+
+	user, linked, err := h.authService.FinishSSO(c, h.provider, h.mapping)
+	if linked {
+		h.finishLink(c, user, err)
+		return
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		message := err.Error()
+		switch {
+		case errors.Is(err, auth.ErrAccessDenied), inactiveAccount(err):
+			status = http.StatusForbidden
+		case errors.Is(err, auth.ErrUserExists):
+			status = http.StatusConflict
+		case errors.Is(err, auth.ErrSSOState), errors.Is(err, auth.ErrNoEmail):
+		case errors.Is(err, oidc.ErrInvalidToken):
+			log.Printf("oidc: rejected ID token: %v", err)
+			status = http.StatusUnauthorized
+			message = "The identity provider returned an invalid sign-in"
+		default:
+			log.Printf("oidc: failed to complete sign-in: %v", err)
+			status = http.StatusBadGateway
+			message = "Failed to complete single sign-on, please try again"
+		}
+		h.renderError(c, status, message)
+		return
+	}
+
+	if err := h.authService.Login(c, user); err != nil {
+		h.renderError(c, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/dashboard")
+}
+
+// finishLink shows the outcome of linking the signed-in user to the
+// identity provider on their profile
+func (h *SSOHandler) finishLink(c *gin.Context, user *models.User, err error) {
+	failed := ""
+	switch {
+	case err == nil:
+		h.audit.Record(user, audit.ActionAccountLink, user.Email, "linked to "+h.login.SSOName, c.ClientIP())
+		c.Redirect(http.StatusSeeOther, "/profile?done=linked")
+		return
+	case errors.Is(err, auth.ErrSSOState):
+		failed = "sso-state"
+	case errors.Is(err, auth.ErrAccessDenied):
+		failed = "denied"
+	case errors.Is(err, auth.ErrIdentityInUse):
+		failed = "in-use"
+	case errors.Is(err, auth.ErrAlreadyLinked):
+		failed = "linked"
+	default:
+		log.Printf("oidc: failed to link account: %v", err)
+		failed = "sso-link"
+	}
+	c.Redirect(http.StatusSeeOther, "/profile?failed="+failed)
+}
+
+func (h *SSOHandler) renderError(c *gin.Context, status int, message string) {
+	data := h.login.loginData()
+	data.Error = message
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.Login(data).Render(c.Request.Context(), c.Writer)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/auth"
+	"github.com/alpemreelmas/sysara/internal/mail"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// resetRequestedMessage is shown whether or not the email belongs to a
+// user, so that the form cannot be used to find accounts
+const resetRequestedMessage = "If an account exists for that email address, a link to reset its password has been sent."
+
+// PasswordResetHandler handles forgotten passwords
+type PasswordResetHandler struct {
+	authService *auth.AuthService
+	mailer      mail.Mailer
+	audit       *audit.Logger
+	appURL      string
+	ttl         time.Duration
+	byIP        *auth.RateLimiter
+	byEmail     *auth.RateLimiter
+}
+
+// NewPasswordResetHandler creates a new password reset handler
+func NewPasswordResetHandler(authService *auth.AuthService, mailer mail.Mailer, auditLog *audit.Logger, appURL string, ttl time.Duration) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		authService: authService,
+		mailer:      mailer,
+		audit:       auditLog,
+		appURL:      appURL,
+		ttl:         ttl,
+		byIP:        auth.NewRateLimiter(5, 15*time.Minute),
+		byEmail:     auth.NewRateLimiter(3, time.Hour),
+	}
+}
+
+// ShowForgotPassword displays the form to request a reset link
+func (h *PasswordResetHandler) ShowForgotPassword(c *gin.Context) {
+	h.renderForgot(c, http.StatusOK, templ.ForgotPasswordData{})
+}
+
+// ForgotPassword sends a reset link to the user with the given email
+func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
+	email := strings.TrimSpace(c.PostForm("email"))
+	if email == "" {
+		h.renderForgot(c, http.StatusBadRequest, templ.ForgotPasswordData{Error: "Enter your email address"})
+		return
+	}
+
+	if !h.byIP.Allow(c.ClientIP()) || !h.byEmail.Allow(strings.ToLower(email)) {
+		h.renderForgot(c, http.StatusTooManyRequests, templ.ForgotPasswordData{
+			Error: "Too many reset requests, please try again later",
+			Email: email,
+		})
+		return
+	}
+
+	user, token, err := h.authService.CreateResetToken(email, c.ClientIP(), h.ttl)
+	if err != nil {
+		log.Printf("auth: failed to create password reset token: %v", err)
+		h.renderForgot(c, http.StatusInternalServerError, templ.ForgotPasswordData{
+			Error: "Failed to create a reset link, please try again",
+			Email: email,
+		})
+		return
+	}
+
+	if user != nil {
+		link := h.appURL + "/reset-password?token=" + url.QueryEscape(token)
+		body := fmt.Sprintf("Hello %s,\n\n"+
+			"A password reset was requested for your Sysara account. Open the link below within %d minutes to choose a new password:\n\n"+
+			"%s\n\n"+
+			"The link can only be used once. If you did not request a reset, you can ignore this email and your password stays unchanged.\n",
+			user.Name, int(h.ttl.Minutes()), link)
+		// Sent in the background so that the response time does not reveal
+		// whether the account exists
+		go func(to string) {
+			if err := h.mailer.Send(to, "Reset your Sysara password", body); err != nil {
+				log.Printf("mail: failed to send password reset email to %s: %v", to, err)
+			}
+		}(user.Email)
+	}
+
+	h.renderForgot(c, http.StatusOK, templ.ForgotPasswordData{Success: resetRequestedMessage})
+}
+
+// ShowResetPassword displays the form to choose a new password
+func (h *PasswordResetHandler) ShowResetPassword(c *gin.Context) {
+	token := c.Query("token")
+	if _, err := h.authService.ResetTokenUser(token); err != nil {
+		h.renderReset(c, http.StatusBadRequest, templ.ResetPasswordData{Error: resetError(err)})
+		return
+	}
+	h.renderReset(c, http.StatusOK, templ.ResetPasswordData{Token: token, Valid: true})
+}
+
+// ResetPassword sets a new password using a reset token
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	token := c.PostForm("token")
+	password := c.PostForm("password")
+	data := templ.ResetPasswordData{Token: token, Valid: true}
+
+	if password != c.PostForm("confirm_password") {
+		data.Error = "Passwords do not match"
+		h.renderReset(c, http.StatusBadRequest, data)
+		return
+	}
+	user, err := h.authService.ResetTokenUser(token)
+	if err != nil {
+		h.renderReset(c, http.StatusBadRequest, templ.ResetPasswordData{Error: resetError(err)})
+		return
+	}
+	if err := h.authService.ValidatePassword(user, password); err != nil {
+		var policyErr *auth.PolicyError
+		if !errors.As(err, &policyErr) {
+			log.Printf("Failed to check password of %s: %v", user.Email, err)
+			data.Error = "Failed to check password"
+			h.renderReset(c, http.StatusInternalServerError, data)
+			return
+		}
+		data.Error = err.Error()
+		h.renderReset(c, http.StatusBadRequest, data)
+		return
+	}
+
+	user, err = h.authService.ResetPassword(token, password)
+	if err != nil {
+		h.renderReset(c, http.StatusBadRequest, templ.ResetPasswordData{Error: resetError(err)})
+		return
+	}
+	h.audit.Record(user, audit.ActionPasswordReset, user.Email, "password reset by email link", c.ClientIP())
+
+	// Drop the session of whoever was logged in on this browser
+	h.authService.Logout(c)
+	c.Redirect(http.StatusSeeOther, "/login?reset=1")
+}
+
+// resetError returns the message shown for a failed token lookup
+func resetError(err error) string {
+	if errors.Is(err, auth.ErrInvalidResetToken) {
+		return err.Error()
+	}
+	log.Printf("auth: password reset failed: %v", err)
+	return "Failed to reset the password, please try again"
+}
+
+func (h *PasswordResetHandler) renderForgot(c *gin.Context, status int, data templ.ForgotPasswordData) {
+	data.Title = "Forgot Password - Sysara"
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.ForgotPassword(data).Render(c.Request.Context(), c.Writer)
+}
+
+func (h *PasswordResetHandler) renderReset(c *gin.Context, status int, data templ.ResetPasswordData) {
+	data.Title = "Reset Password - Sysara"
+	data.PasswordRules = h.authService.Policy().Rules()
+	// The token must not leak to other sites through the Referer header
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.ResetPassword(data).Render(c.Request.Context(), c.Writer)
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/auth"
+	"github.com/alpemreelmas/sysara/internal/mail"
+	"github.com/alpemreelmas/sysara/internal/mail/mailtest"
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// resetLink matches the reset link of a password reset email
+var resetLink = regexp.MustCompile(`http://sysara\.test/reset-password\?token=(\S+)`)
+
+// resetTest is a password reset handler that mails through an SMTP stand-in
+type resetTest struct {
+	db     *gorm.DB
+	auth   *auth.AuthService
+	smtp   *mailtest.Server
+	router *gin.Engine
+	user   models.User
+}
+
+func newResetTest(t *testing.T) *resetTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sysara.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.PasswordResetToken{}, &models.Session{}, &models.PasswordHistory{}, &models.AuditLog{}, &models.APIToken{}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := auth.NewPasswordPolicy(8, 1, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authService := auth.NewAuthService(db, auth.NewSessionStore(db, time.Hour, time.Hour), auth.Lockout{}, policy, true)
+
+	hash, err := authService.HashPassword("Old-Passw0rd!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Email: "user@example.com", Name: "User", Password: hash, Role: models.RoleUser}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	mailer := mail.NewSMTPMailer(server.Host(), server.Port(), "", "", "sysara@example.com")
+
+	h := NewPasswordResetHandler(authService, mailer, audit.NewLogger(db), "http://sysara.test", time.Hour)
+	router := gin.New()
+	router.POST("/forgot-password", h.ForgotPassword)
+	router.GET("/reset-password", h.ShowResetPassword)
+	router.POST("/reset-password", h.ResetPassword)
+
+	return &resetTest{db: db, auth: authService, smtp: server, router: router, user: user}
+}
+
+// post submits a form and returns the response
+func (rt *resetTest) post(path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	rt.router.ServeHTTP(w, req)
+	return w
+}
+
+// requestToken asks for a reset link and returns the token mailed for it
+func (rt *resetTest) requestToken(t *testing.T) string {
+	t.Helper()
+	sent := len(rt.smtp.Messages())
+	if w := rt.post("/forgot-password", url.Values{"email": {rt.user.Email}}); w.Code != http.StatusOK {
+		t.Fatalf("forgot password: status %d", w.Code)
+	}
+	messages := rt.smtp.Wait(sent+1, 5*time.Second)
+	if len(messages) != sent+1 {
+		t.Fatalf("no reset email was sent")
+	}
+	msg := messages[sent]
+	if len(msg.To) != 1 || msg.To[0] != rt.user.Email {
+		t.Fatalf("email sent to %v", msg.To)
+	}
+	match := resetLink.FindStringSubmatch(msg.Data)
+	if match == nil {
+		t.Fatalf("email has no reset link: %q", msg.Data)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// reset submits a new password with token
+func (rt *resetTest) reset(token, password string) *httptest.ResponseRecorder {
+	return rt.post("/reset-password", url.Values{"token": {token}, "password": {password}, "confirm_password": {password}})
+}
+
+func TestPasswordResetByEmail(t *testing.T) {
+	rt := newResetTest(t)
+	token := rt.requestToken(t)
+
+	// Only a hash of the token is stored
+	var stored models.PasswordResetToken
+	if err := rt.db.Where("user_id = ?", rt.user.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.TokenHash == token || strings.Contains(stored.TokenHash, token) {
+		t.Errorf("the token is stored in plain text")
+	}
+
+	// Sessions and API tokens of the user end with the reset
+	if err := rt.db.Create(&models.Session{TokenHash: "session", UserID: &rt.user.ID, ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rt.auth.CreateAPIToken(&rt.user, "deploy", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	w := rt.reset(token, "New-Passw0rd!")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?reset=1" {
+		t.Fatalf("reset: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	var user models.User
+	rt.db.First(&user, rt.user.ID)
+	if err := rt.auth.VerifyPassword(user.Password, "New-Passw0rd!"); err != nil {
+		t.Errorf("the new password was not stored")
+	}
+	var sessions int64
+	rt.db.Model(&models.Session{}).Where("user_id = ?", rt.user.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("%d sessions survived the reset", sessions)
+	}
+	var apiTokens int64
+	rt.db.Model(&models.APIToken{}).Where("user_id = ?", rt.user.ID).Count(&apiTokens)
+	if apiTokens != 0 {
+		t.Errorf("%d API tokens survived the reset", apiTokens)
+	}
+
+	// The token works once
+	if w := rt.reset(token, "Other-Passw0rd!"); w.Code != http.StatusBadRequest {
+		t.Errorf("second use: status %d, want 400", w.Code)
+	}
+}
+
+func TestPasswordResetNewTokenRevokesOld(t *testing.T) {
+	rt := newResetTest(t)
+	first := rt.requestToken(t)
+	second := rt.requestToken(t)
+
+	if w := rt.reset(first, "New-Passw0rd!"); w.Code != http.StatusBadRequest {
+		t.Errorf("superseded token: status %d, want 400", w.Code)
+	}
+	if w := rt.reset(second, "New-Passw0rd!"); w.Code != http.StatusSeeOther {
+		t.Errorf("latest token: status %d, want 303", w.Code)
+	}
+}
+
+func TestPasswordResetExpiredToken(t *testing.T) {
+	rt := newResetTest(t)
+	token := rt.requestToken(t)
+	rt.db.Model(&models.PasswordResetToken{}).Where("user_id = ?", rt.user.ID).Update("expires_at", time.Now().Add(-time.Minute))
+
+	req := httptest.NewRequest(http.MethodGet, "/reset-password?token="+url.QueryEscape(token), nil)
+	w := httptest.NewRecorder()
+	rt.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("show expired token: status %d, want 400", w.Code)
+	}
+	if w := rt.reset(token, "New-Passw0rd!"); w.Code != http.StatusBadRequest {
+		t.Errorf("expired token: status %d, want 400", w.Code)
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	rt := newResetTest(t)
+
+	w := rt.post("/forgot-password", url.Values{"email": {"nobody@example.com"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "If an account exists") {
+		t.Errorf("unknown email: status %d", w.Code)
+	}
+	if messages := rt.smtp.Wait(1, 200*time.Millisecond); len(messages) != 0 {
+		t.Errorf("mail sent for an unknown email: %+v", messages)
+	}
+}
+
+func TestForgotPasswordRateLimit(t *testing.T) {
+	rt := newResetTest(t)
+	for i := 0; i < 3; i++ {
+		rt.requestToken(t)
+	}
+	if w := rt.post("/forgot-password", url.Values{"email": {rt.user.Email}}); w.Code != http.StatusTooManyRequests {
+		t.Errorf("fourth request: status %d, want 429", w.Code)
+	}
+}
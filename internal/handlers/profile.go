@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/auth"
+	"github.com/alpemreelmas/sysara/internal/mail"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// profileMessages are shown on the profile page after an action
+var profileMessages = map[string]string{
+	"updated":       "Your profile was updated",
+	"email-sent":    "Open the link we sent to your new email address to confirm the change",
+	"email":         "Your email address was changed",
+	"email-dropped": "The email change was cancelled",
+	"token-revoked": "The API token was revoked",
+	"linked":        "Your account is linked, sign in with your identity provider from now on",
+}
+
+// profileFailures are shown on the profile page after an action failed
+// outside of it, such as linking at the identity provider
+var profileFailures = map[string]string{
+	"link-password": "Enter your current password to link your account",
+	"linked":        "Your account already signs in with an identity provider",
+	"in-use":        "This identity is already linked to another account",
+	"denied":        "Your identity provider account is not allowed to use Sysara",
+	"sso-state":     "The link request expired or was not started here, please try again",
+	"sso-link":      "Failed to link your account, please try again",
+}
+
+// profileManagedMessage is shown to users whose name and email come from
+// their identity provider
+const profileManagedMessage = "Your name and email address are managed by your identity provider"
+
+// recentProfileLogins is how many login attempts the profile page shows
+const recentProfileLogins = 10
+
+// apiTokenLifetimes are the expiry choices of a new API token in days,
+// 0 never expires
+var apiTokenLifetimes = map[string]int{"7": 7, "30": 30, "90": 90, "365": 365, "0": 0}
+
+// ProfileHandler lets users manage their own account
+type ProfileHandler struct {
+	db          *gorm.DB
+	authService *auth.AuthService
+	mailer      mail.Mailer
+	audit       *audit.Logger
+	appURL      string
+	emailTTL    time.Duration
+	login       LoginOptions
+}
+
+// NewProfileHandler creates a new profile handler. The login options tell
+// which identity providers an account can be linked to.
+func NewProfileHandler(db *gorm.DB, authService *auth.AuthService, mailer mail.Mailer, auditLog *audit.Logger, appURL string, emailTTL time.Duration, login LoginOptions) *ProfileHandler {
+	return &ProfileHandler{
+		db:          db,
+		authService: authService,
+		mailer:      mailer,
+		audit:       auditLog,
+		appURL:      appURL,
+		emailTTL:    emailTTL,
+		login:       login,
+	}
+}
+
+// ShowProfile displays the profile of the current user
+func (h *ProfileHandler) ShowProfile(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.render(c, userModel, http.StatusOK, templ.ProfileData{
+		Success: profileMessages[c.Query("done")],
+		Error:   profileFailures[c.Query("failed")],
+	})
+}
+
+// LinkDirectory links the account of the current user to their directory
+// account, checked with the directory password. The current password is
+// needed as well when the user has one.
+func (h *ProfileHandler) LinkDirectory(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	if !h.login.Directory {
+		h.render(c, userModel, http.StatusNotFound, templ.ProfileData{Error: "Directory sign-in is not enabled"})
+		return
+	}
+	if h.authService.LocalPasswords() && !userModel.IsExternal() {
+		if err := h.authService.VerifyPassword(userModel.Password, c.PostForm("current_password")); err != nil {
+			h.render(c, userModel, http.StatusBadRequest, templ.ProfileData{Error: profileFailures["link-password"]})
+			return
+		}
+	}
+
+	login := strings.TrimSpace(c.PostForm("directory_login"))
+	err := h.authService.LinkDirectoryAccount(userModel, login, c.PostForm("directory_password"), c.ClientIP())
+	if err != nil {
+		status, message := http.StatusBadRequest, err.Error()
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			status, message = http.StatusUnauthorized, "Invalid directory username or password"
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			status = http.StatusTooManyRequests
+		case errors.Is(err, auth.ErrAccessDenied):
+			status = http.StatusForbidden
+		case errors.Is(err, auth.ErrIdentityInUse), errors.Is(err, auth.ErrAlreadyLinked):
+			status = http.StatusConflict
+		case errors.Is(err, auth.ErrAmbiguousUser):
+		default:
+			log.Printf("ldap: failed to link %s: %v", userModel.Email, err)
+			status, message = http.StatusBadGateway, "Failed to link your directory account, please try again"
+		}
+		h.render(c, userModel, status, templ.ProfileData{Error: message})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionAccountLink, userModel.Email, "linked to directory account "+login, c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/profile?done=linked")
+}
+
+// UpdateProfile changes the name of the current user and starts a change of
+// their email address, which needs the current password and is confirmed
+// from the new address
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	form := templ.ProfileData{
+		Name:  strings.TrimSpace(c.PostForm("name")),
+		Email: strings.TrimSpace(c.PostForm("email")),
+	}
+	if userModel.IsExternal() {
+		form.Error = profileManagedMessage
+		h.render(c, userModel, http.StatusForbidden, form)
+		return
+	}
+	if form.Name == "" {
+		form.Error = "Name is required"
+		h.render(c, userModel, http.StatusBadRequest, form)
+		return
+	}
+
+	done := "updated"
+	if !strings.EqualFold(form.Email, userModel.Email) {
+		if err := h.authService.VerifyPassword(userModel.Password, c.PostForm("current_password")); err != nil {
+			form.Error = "Enter your current password to change your email address"
+			h.render(c, userModel, http.StatusBadRequest, form)
+			return
+		}
+		change, token, err := h.authService.RequestEmailChange(userModel, form.Email, h.emailTTL)
+		if err != nil {
+			status, message := http.StatusBadRequest, emailChangeMessage(err)
+			if message == "" {
+				log.Printf("auth: failed to start email change of %s: %v", userModel.Email, err)
+				status, message = http.StatusInternalServerError, "Failed to change your email address"
+			}
+			form.Error = message
+			h.render(c, userModel, status, form)
+			return
+		}
+		h.sendEmailChange(userModel, change, token)
+		h.audit.Record(userModel, audit.ActionEmailChange, userModel.Email, "requested "+change.Email, c.ClientIP())
+		done = "email-sent"
+	}
+
+	if form.Name != userModel.Name {
+		if err := h.db.Model(userModel).Update("name", form.Name).Error; err != nil {
+			form.Error = "Failed to update your profile"
+			h.render(c, userModel, http.StatusInternalServerError, form)
+			return
+		}
+		h.audit.Record(userModel, audit.ActionProfileUpdate, userModel.Email, "name changed", c.ClientIP())
+	}
+
+	c.Redirect(http.StatusSeeOther, "/profile?done="+done)
+}
+
+// CancelEmailChange drops the pending email change of the current user
+func (h *ProfileHandler) CancelEmailChange(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	if err := h.authService.CancelEmailChange(userModel.ID); err != nil {
+		h.render(c, userModel, http.StatusInternalServerError, templ.ProfileData{Error: "Failed to cancel the email change"})
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/profile?done=email-dropped")
+}
+
+// VerifyEmail confirms a new email address from the link sent to it
+func (h *ProfileHandler) VerifyEmail(c *gin.Context) {
+	user, previous, err := h.authService.ConfirmEmailChange(c.Query("token"))
+	if err != nil {
+		status, message := http.StatusBadRequest, emailChangeMessage(err)
+		if message == "" {
+			log.Printf("auth: failed to confirm email change: %v", err)
+			status, message = http.StatusInternalServerError, "Failed to change your email address, please try again"
+		}
+		c.Header("Content-Type", "text/html")
+		c.Status(status)
+		templ.VerifyEmail(templ.VerifyEmailData{Title: "Confirm Email - Sysara", Error: message}).Render(c.Request.Context(), c.Writer)
+		return
+	}
+	h.audit.Record(user, audit.ActionEmailChange, user.Email, "confirmed, previously "+previous, c.ClientIP())
+
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"The email address of your Sysara account was changed to %s.\n\n"+
+		"If you did not make this change, contact an administrator.\n",
+		user.Name, user.Email)
+	go func() {
+		if err := h.mailer.Send(previous, "Your Sysara email address was changed", body); err != nil {
+			log.Printf("mail: failed to send email change notice to %s: %v", previous, err)
+		}
+	}()
+
+	if current, err := h.authService.GetCurrentUser(c); err == nil && current.ID == user.ID {
+		c.Redirect(http.StatusSeeOther, "/profile?done=email")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/login?email_changed=1")
+}
+
+// CreateAPIToken issues a personal API token. The token is shown once.
+func (h *ProfileHandler) CreateAPIToken(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	name := strings.TrimSpace(c.PostForm("token_name"))
+	if name == "" {
+		h.render(c, userModel, http.StatusBadRequest, templ.ProfileData{Error: "Token name is required"})
+		return
+	}
+	days, ok := apiTokenLifetimes[c.PostForm("expires")]
+	if !ok {
+		h.render(c, userModel, http.StatusBadRequest, templ.ProfileData{Error: "Choose when the token expires"})
+		return
+	}
+	var expiresAt *time.Time
+	if days > 0 {
+		t := time.Now().AddDate(0, 0, days)
+		expiresAt = &t
+	}
+
+	apiToken, token, err := h.authService.CreateAPIToken(userModel, name, expiresAt)
+	if err != nil {
+		h.render(c, userModel, http.StatusInternalServerError, templ.ProfileData{Error: "Failed to create the API token"})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionAPITokenCreate, userModel.Email, apiToken.Name, c.ClientIP())
+
+	h.render(c, userModel, http.StatusOK, templ.ProfileData{
+		NewToken: token,
+		Success:  "The API token " + apiToken.Name + " was created. Copy it now, it is not shown again.",
+	})
+}
+
+// DeleteAPIToken revokes an API token of the current user
+func (h *ProfileHandler) DeleteAPIToken(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.render(c, userModel, http.StatusNotFound, templ.ProfileData{Error: "API token not found"})
+		return
+	}
+	apiToken, err := h.authService.DeleteAPIToken(userModel.ID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		h.render(c, userModel, http.StatusNotFound, templ.ProfileData{Error: "API token not found"})
+		return
+	}
+	if err != nil {
+		h.render(c, userModel, http.StatusInternalServerError, templ.ProfileData{Error: "Failed to revoke the API token"})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionAPITokenDelete, userModel.Email, apiToken.Name, c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/profile?done=token-revoked")
+}
+
+// sendEmailChange mails the confirmation link to the new address and a
+// notice to the current one
+func (h *ProfileHandler) sendEmailChange(user *models.User, change *models.EmailChange, token string) {
+	link := h.appURL + "/verify-email?token=" + url.QueryEscape(token)
+	confirm := fmt.Sprintf("Hello %s,\n\n"+
+		"You asked to use this address for your Sysara account. Open the link below within %d hours to confirm the change:\n\n"+
+		"%s\n\n"+
+		"If you did not ask for this, you can ignore this email.\n",
+		user.Name, int(h.emailTTL.Hours()), link)
+	notice := fmt.Sprintf("Hello %s,\n\n"+
+		"A change of the email address of your Sysara account to %s was requested. It takes effect once the link sent to that address is opened.\n\n"+
+		"If you did not ask for this, change your password and contact an administrator.\n",
+		user.Name, change.Email)
+
+	go func(current, next string) {
+		if err := h.mailer.Send(next, "Confirm your new Sysara email address", confirm); err != nil {
+			log.Printf("mail: failed to send email confirmation to %s: %v", next, err)
+		}
+		if err := h.mailer.Send(current, "Your Sysara email address is being changed", notice); err != nil {
+			log.Printf("mail: failed to send email change notice to %s: %v", current, err)
+		}
+	}(user.Email, change.Email)
+}
+
+// render shows the profile page of user
+func (h *ProfileHandler) render(c *gin.Context, user *models.User, status int, data templ.ProfileData) {
+	data.AuthData = templ.AuthData{
+		Title:       "Profile - Sysara",
+		PageTitle:   "Profile",
+		CurrentUser: *user,
+	}
+	if data.Name == "" && data.Email == "" {
+		data.Name, data.Email = user.Name, user.Email
+	}
+	if user.IsExternal() {
+		data.Managed = profileManagedMessage
+	}
+	data.PasswordLogin = h.authService.LocalPasswords() && !user.IsExternal()
+	if !user.IsExternal() {
+		data.LinkDirectory = h.login.Directory
+		data.LinkSSO = h.login.SSOName
+	}
+
+	var err error
+	if data.PendingEmail, err = h.authService.PendingEmailChange(user.ID); err != nil {
+		data.Error = "Failed to load your profile"
+	}
+	if data.SecurityKeys, err = h.authService.WebAuthnCredentials(user.ID); err != nil {
+		data.Error = "Failed to load your security keys"
+	}
+	if data.APITokens, err = h.authService.APITokens(user.ID); err != nil {
+		data.Error = "Failed to load your API tokens"
+	}
+	if err = h.db.Preload("Team").Where("user_id = ?", user.ID).Order("created_at DESC").Find(&data.SSHKeys).Error; err != nil {
+		data.Error = "Failed to load your SSH keys"
+	}
+	if data.Attempts, err = h.authService.LoginAttempts(user.ID, recentProfileLogins); err != nil {
+		data.Error = "Failed to load your sign-in activity"
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.Profile(data).Render(c.Request.Context(), c.Writer)
+}
+
+// emailChangeMessage turns email change errors into user-facing messages,
+// or "" for unexpected errors
+func emailChangeMessage(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrInvalidEmail):
+		return "Enter a valid email address"
+	case errors.Is(err, auth.ErrEmailUnchanged):
+		return "This is already your email address"
+	case errors.Is(err, auth.ErrUserExists):
+		return "Another account already uses this email address"
+	case errors.Is(err, auth.ErrInvalidEmailChange):
+		return "This confirmation link is invalid or has expired"
+	case inactiveAccount(err):
+		return err.Error()
+	}
+	return ""
+}
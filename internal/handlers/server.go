@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/teams"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// serverFields are the fields of a server set by the server form
+var serverFields = []string{"name", "host", "port", "description", "is_active", "team_id"}
+
+// ServerHandler handles the servers of teams
+type ServerHandler struct {
+	db    *gorm.DB
+	teams *teams.Manager
+}
+
+// NewServerHandler creates a new server handler
+func NewServerHandler(db *gorm.DB, teamManager *teams.Manager) *ServerHandler {
+	return &ServerHandler{db: db, teams: teamManager}
+}
+
+// ListServers displays the servers of the teams of the current user, or all
+// servers for admins
+func (h *ServerHandler) ListServers(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	data := templ.ServerListData{
+		AuthData: templ.AuthData{
+			Title:       "Servers - Sysara",
+			PageTitle:   "Servers",
+			CurrentUser: *userModel,
+		},
+		Manageable: make(map[uint]bool),
+		CanCreate:  h.canCreate(userModel),
+	}
+	status := http.StatusOK
+	if err := h.visible(userModel).Preload("Team").Order("name").Find(&data.Servers).Error; err != nil {
+		data.Error = "Failed to fetch servers"
+		status = http.StatusInternalServerError
+	}
+	for _, server := range data.Servers {
+		data.Manageable[server.ID] = h.canManage(userModel, server.TeamID)
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.ServerList(data).Render(c.Request.Context(), c.Writer)
+}
+
+// ShowCreateServer displays the add server form
+func (h *ServerHandler) ShowCreateServer(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	if !h.canCreate(userModel) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and team maintainers can add servers"})
+		return
+	}
+
+	h.renderForm(c, userModel, http.StatusOK, templ.ServerFormData{Server: models.Server{Port: 22, IsActive: true}})
+}
+
+// CreateServer adds a server
+func (h *ServerHandler) CreateServer(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	form := templ.ServerFormData{}
+	if form.Error = h.bindServer(c, userModel, &form.Server); form.Error != "" {
+		h.renderForm(c, userModel, http.StatusBadRequest, form)
+		return
+	}
+	// Create leaves false to the column default, which is active, and reads
+	// the default back into the server
+	active := form.Server.IsActive
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&form.Server).Error; err != nil {
+			return err
+		}
+		return tx.Model(&form.Server).Update("is_active", active).Error
+	})
+	if err != nil {
+		form.Error = "Failed to save server"
+		h.renderForm(c, userModel, http.StatusInternalServerError, form)
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/servers")
+}
+
+// ShowEditServer displays the edit form of a server
+func (h *ServerHandler) ShowEditServer(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	server, ok := h.manageableServer(c, userModel)
+	if !ok {
+		return
+	}
+
+	h.renderForm(c, userModel, http.StatusOK, templ.ServerFormData{Server: *server})
+}
+
+// UpdateServer saves the changes to a server
+func (h *ServerHandler) UpdateServer(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	server, ok := h.manageableServer(c, userModel)
+	if !ok {
+		return
+	}
+
+	form := templ.ServerFormData{Server: *server}
+	if form.Error = h.bindServer(c, userModel, &form.Server); form.Error != "" {
+		h.renderForm(c, userModel, http.StatusBadRequest, form)
+		return
+	}
+	if err := h.db.Model(&form.Server).Select(serverFields).Updates(&form.Server).Error; err != nil {
+		form.Error = "Failed to save server"
+		h.renderForm(c, userModel, http.StatusInternalServerError, form)
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/servers")
+}
+
+// DeleteServer removes a server
+func (h *ServerHandler) DeleteServer(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	server, ok := h.manageableServer(c, userModel)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(server).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete server"})
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/servers")
+}
+
+// visible returns a query of the servers user can see: those of their teams,
+// or all servers for admins
+func (h *ServerHandler) visible(user *models.User) *gorm.DB {
+	query := h.db.Model(&models.Server{})
+	if !user.IsAdmin() {
+		query = query.Where("team_id IN (?)", teams.MemberOf(h.db, user.ID))
+	}
+	return query
+}
+
+// manageableServer loads the server of the request and checks that user may
+// change it. Servers the user cannot see answer 404. It writes the error
+// response and returns false when the request cannot go on.
+func (h *ServerHandler) manageableServer(c *gin.Context, user *models.User) (*models.Server, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return nil, false
+	}
+	var server models.Server
+	if err := h.visible(user).First(&server, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		return nil, false
+	}
+	if !h.canManage(user, server.TeamID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins and maintainers of the team can change this server"})
+		return nil, false
+	}
+	return &server, true
+}
+
+// canManage reports whether user may change the servers of a team: admins
+// and maintainers of the team. Servers without a team are managed by admins.
+func (h *ServerHandler) canManage(user *models.User, teamID *uint) bool {
+	if user.IsAdmin() {
+		return true
+	}
+	return teamID != nil && h.teams.CanManage(user, *teamID)
+}
+
+// canCreate reports whether user may add servers: admins and maintainers of
+// at least one team
+func (h *ServerHandler) canCreate(user *models.User) bool {
+	if user.IsAdmin() {
+		return true
+	}
+	var count int64
+	h.db.Model(&models.TeamMember{}).Where("user_id = ? AND role = ?", user.ID, models.TeamRoleMaintainer).Count(&count)
+	return count > 0
+}
+
+// bindServer reads the server form into server and checks that user may
+// give the server to the chosen team. It returns the message to show when
+// the form is not valid.
+func (h *ServerHandler) bindServer(c *gin.Context, user *models.User, server *models.Server) string {
+	server.Name = strings.TrimSpace(c.PostForm("name"))
+	server.Host = strings.TrimSpace(c.PostForm("host"))
+	server.Description = strings.TrimSpace(c.PostForm("description"))
+	server.IsActive = c.PostForm("is_active") == "1"
+
+	teamID, err := teamParam(c.PostForm("team_id"))
+	server.TeamID = teamID
+	if err == nil {
+		err = h.teams.Assignable(user, teamID)
+	}
+	if err != nil || !h.canManage(user, teamID) {
+		return "Choose a team you maintain"
+	}
+
+	port, err := strconv.Atoi(strings.TrimSpace(c.DefaultPostForm("port", "22")))
+	if err != nil || port < 1 || port > 65535 {
+		return "Port must be a number between 1 and 65535"
+	}
+	server.Port = port
+
+	if server.Name == "" {
+		return "Name is required"
+	}
+	if server.Host == "" || strings.ContainsAny(server.Host, " \t/") {
+		return "Enter a host name or IP address"
+	}
+	return ""
+}
+
+// renderForm shows the add or edit server form with the teams user can
+// give servers to
+func (h *ServerHandler) renderForm(c *gin.Context, user *models.User, status int, data templ.ServerFormData) {
+	title := "Add Server"
+	if data.Server.ID != 0 {
+		title = "Edit Server"
+	}
+	data.AuthData = templ.AuthData{
+		Title:       title + " - Sysara",
+		PageTitle:   title,
+		CurrentUser: *user,
+	}
+	if userTeams, err := h.teams.ForUser(user); err == nil {
+		for _, team := range userTeams {
+			if h.canManage(user, &team.ID) {
+				data.Teams = append(data.Teams, team)
+			}
+		}
+	}
+	data.NoTeam = user.IsAdmin()
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.ServerForm(data).Render(c.Request.Context(), c.Writer)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/teams"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// serverTest is a server handler with two teams: ops, maintained by
+// maintainer with member as a member, and web, which neither belongs to
+type serverTest struct {
+	db                        *gorm.DB
+	handler                   *ServerHandler
+	maintainer, member, admin models.User
+	ops, web                  models.Team
+}
+
+func newServerTest(t *testing.T) *serverTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sysara.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Team{}, &models.TeamMember{}, &models.Server{}); err != nil {
+		t.Fatal(err)
+	}
+	st := &serverTest{db: db}
+	manager := teams.NewManager(db)
+	st.handler = NewServerHandler(db, manager)
+
+	st.maintainer = models.User{Email: "maintainer@example.com", Name: "Maintainer", Role: models.RoleUser}
+	st.member = models.User{Email: "member@example.com", Name: "Member", Role: models.RoleUser}
+	st.admin = models.User{Email: "admin@example.com", Name: "Admin", Role: models.RoleAdmin}
+	for _, user := range []*models.User{&st.maintainer, &st.member, &st.admin} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	st.ops.Name, st.web.Name = "ops", "web"
+	for _, team := range []*models.Team{&st.ops, &st.web} {
+		if err := manager.Create(team); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.SetMember(st.ops.ID, st.maintainer.ID, models.TeamRoleMaintainer); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SetMember(st.ops.ID, st.member.ID, models.TeamRoleMember); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// do sends a request as user
+func (st *serverTest) do(user *models.User, method, path string, form url.Values) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("current_user", user)
+	})
+	router.GET("/servers", st.handler.ListServers)
+	router.POST("/servers/create", st.handler.CreateServer)
+	router.POST("/servers/:id/edit", st.handler.UpdateServer)
+	router.POST("/servers/:id/delete", st.handler.DeleteServer)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func serverForm(name string, team uint) url.Values {
+	return url.Values{"name": {name}, "host": {name + ".example.com"}, "port": {"22"}, "team_id": {strconv.FormatUint(uint64(team), 10)}}
+}
+
+func TestMaintainersAddServersOnlyToTheirTeams(t *testing.T) {
+	st := newServerTest(t)
+
+	if w := st.do(&st.maintainer, http.MethodPost, "/servers/create", serverForm("db", st.ops.ID)); w.Code != http.StatusSeeOther {
+		t.Fatalf("creating a server of ops answered %d", w.Code)
+	}
+	if w := st.do(&st.maintainer, http.MethodPost, "/servers/create", serverForm("www", st.web.ID)); w.Code != http.StatusBadRequest {
+		t.Errorf("creating a server of another team answered %d", w.Code)
+	}
+	if w := st.do(&st.member, http.MethodPost, "/servers/create", serverForm("cache", st.ops.ID)); w.Code != http.StatusBadRequest {
+		t.Errorf("creating a server as a member answered %d", w.Code)
+	}
+	noTeam := serverForm("bastion", 0)
+	noTeam.Set("team_id", "")
+	if w := st.do(&st.maintainer, http.MethodPost, "/servers/create", noTeam); w.Code != http.StatusBadRequest {
+		t.Errorf("creating a server without a team answered %d", w.Code)
+	}
+
+	var servers []models.Server
+	if err := st.db.Find(&servers).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 1 || servers[0].Name != "db" || servers[0].TeamID == nil || *servers[0].TeamID != st.ops.ID {
+		t.Fatalf("servers = %+v, want only db of ops", servers)
+	}
+}
+
+func TestServersAreScopedToTeams(t *testing.T) {
+	st := newServerTest(t)
+	opsServer := models.Server{Name: "db", Host: "db.example.com", Port: 22, TeamID: &st.ops.ID}
+	webServer := models.Server{Name: "www", Host: "www.example.com", Port: 22, TeamID: &st.web.ID}
+	for _, server := range []*models.Server{&opsServer, &webServer} {
+		if err := st.db.Create(server).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := st.do(&st.member, http.MethodGet, "/servers", nil).Body.String()
+	if !strings.Contains(body, "db.example.com") || strings.Contains(body, "www.example.com") {
+		t.Errorf("a member of ops does not see exactly the servers of ops")
+	}
+	body = st.do(&st.admin, http.MethodGet, "/servers", nil).Body.String()
+	if !strings.Contains(body, "db.example.com") || !strings.Contains(body, "www.example.com") {
+		t.Errorf("an admin does not see every server")
+	}
+
+	webPath := "/servers/" + strconv.FormatUint(uint64(webServer.ID), 10)
+	if w := st.do(&st.maintainer, http.MethodPost, webPath+"/delete", nil); w.Code != http.StatusNotFound {
+		t.Errorf("deleting a server of another team answered %d, want 404", w.Code)
+	}
+	opsPath := "/servers/" + strconv.FormatUint(uint64(opsServer.ID), 10)
+	if w := st.do(&st.member, http.MethodPost, opsPath+"/edit", serverForm("renamed", st.ops.ID)); w.Code != http.StatusForbidden {
+		t.Errorf("editing as a member answered %d, want 403", w.Code)
+	}
+	if w := st.do(&st.maintainer, http.MethodPost, opsPath+"/edit", serverForm("renamed", st.web.ID)); w.Code != http.StatusBadRequest {
+		t.Errorf("moving a server to another team answered %d, want 400", w.Code)
+	}
+
+	// Unchecking active is saved, also for new servers
+	inactive := serverForm("spare", st.ops.ID)
+	if w := st.do(&st.maintainer, http.MethodPost, "/servers/create", inactive); w.Code != http.StatusSeeOther {
+		t.Fatalf("creating an inactive server answered %d", w.Code)
+	}
+	var spare models.Server
+	if err := st.db.Where("name = ?", "spare").First(&spare).Error; err != nil {
+		t.Fatal(err)
+	}
+	if spare.IsActive {
+		t.Error("the new server is active although the box was not checked")
+	}
+}
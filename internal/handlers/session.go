@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/auth"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SessionHandler lists and revokes login sessions
+type SessionHandler struct {
+	db          *gorm.DB
+	authService *auth.AuthService
+	audit       *audit.Logger
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(db *gorm.DB, authService *auth.AuthService, auditLog *audit.Logger) *SessionHandler {
+	return &SessionHandler{
+		db:          db,
+		authService: authService,
+		audit:       auditLog,
+	}
+}
+
+// ShowSessions lists the sessions of the current user
+func (h *SessionHandler) ShowSessions(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.render(c, userModel, userModel, http.StatusOK, templ.SessionsData{})
+}
+
+// RevokeSession signs the current user out of one session
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.render(c, userModel, userModel, http.StatusNotFound, templ.SessionsData{Error: "Session not found"})
+		return
+	}
+
+	if current, err := h.authService.CurrentSession(c); err == nil && current.ID == uint(id) {
+		h.authService.Logout(c)
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+
+	if err := h.authService.Sessions().Revoke(userModel.ID, uint(id)); err != nil {
+		h.render(c, userModel, userModel, revokeStatus(err), templ.SessionsData{Error: revokeError(err)})
+		return
+	}
+	h.render(c, userModel, userModel, http.StatusOK, templ.SessionsData{Success: "The session was signed out"})
+}
+
+// RevokeOtherSessions signs the current user out everywhere else
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	var keep uint
+	if current, err := h.authService.CurrentSession(c); err == nil {
+		keep = current.ID
+	}
+	n, err := h.authService.Sessions().RevokeUser(userModel.ID, keep)
+	if err != nil {
+		h.render(c, userModel, userModel, http.StatusInternalServerError, templ.SessionsData{Error: "Failed to revoke sessions"})
+		return
+	}
+	h.render(c, userModel, userModel, http.StatusOK, templ.SessionsData{Success: fmt.Sprintf("Signed out of %d other session(s)", n)})
+}
+
+// ShowUserSessions lists the sessions of any user (admin function)
+func (h *SessionHandler) ShowUserSessions(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	target, ok := h.user(c)
+	if !ok {
+		return
+	}
+	h.render(c, userModel, target, http.StatusOK, templ.SessionsData{})
+}
+
+// RevokeUserSession signs a user out of one session (admin function)
+func (h *SessionHandler) RevokeUserSession(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	target, ok := h.user(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("session"), 10, 32)
+	if err != nil {
+		h.render(c, userModel, target, http.StatusNotFound, templ.SessionsData{Error: "Session not found"})
+		return
+	}
+	if current, err := h.authService.CurrentSession(c); err == nil && current.ID == uint(id) {
+		h.render(c, userModel, target, http.StatusBadRequest, templ.SessionsData{Error: "Use sign out to end your current session"})
+		return
+	}
+
+	if err := h.authService.Sessions().Revoke(target.ID, uint(id)); err != nil {
+		h.render(c, userModel, target, revokeStatus(err), templ.SessionsData{Error: revokeError(err)})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionSessionRevoke, target.Email, "session #"+strconv.FormatUint(id, 10), c.ClientIP())
+	h.render(c, userModel, target, http.StatusOK, templ.SessionsData{Success: "The session was signed out"})
+}
+
+// RevokeAllUserSessions signs a user out everywhere (admin function).
+// The admin's own current session is kept.
+func (h *SessionHandler) RevokeAllUserSessions(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	target, ok := h.user(c)
+	if !ok {
+		return
+	}
+	var keep uint
+	if current, err := h.authService.CurrentSession(c); err == nil {
+		keep = current.ID
+	}
+	n, err := h.authService.Sessions().RevokeUser(target.ID, keep)
+	if err != nil {
+		h.render(c, userModel, target, http.StatusInternalServerError, templ.SessionsData{Error: "Failed to revoke sessions"})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionSessionRevoke, target.Email, "all sessions", c.ClientIP())
+	h.render(c, userModel, target, http.StatusOK, templ.SessionsData{Success: fmt.Sprintf("Signed %s out of %d session(s)", target.Name, n)})
+}
+
+// user loads the user of the :id parameter, redirecting to the user list
+// if there is none
+func (h *SessionHandler) user(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return nil, false
+	}
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return nil, false
+	}
+	return &user, true
+}
+
+func revokeStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func revokeError(err error) string {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "Session not found"
+	}
+	return "Failed to revoke the session"
+}
+
+// render lists the sessions of target to user
+func (h *SessionHandler) render(c *gin.Context, user, target *models.User, status int, data templ.SessionsData) {
+	data.Own = user.ID == target.ID
+	title := "Your Sessions"
+	if !data.Own {
+		title = "Sessions of " + target.Name
+	}
+	data.AuthData = templ.AuthData{
+		Title:       title + " - Sysara",
+		PageTitle:   title,
+		CurrentUser: *user,
+	}
+	data.User = *target
+
+	sessions, err := h.authService.Sessions().List(target.ID)
+	if err != nil {
+		data.Error = "Failed to load sessions"
+		status = http.StatusInternalServerError
+	}
+	data.Sessions = sessions
+	if current, err := h.authService.CurrentSession(c); err == nil {
+		data.CurrentID = current.ID
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.Sessions(data).Render(c.Request.Context(), c.Writer)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/alpemreelmas/sysara/internal/auth"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupHandler creates the first administrator of a new install, or
+// claims the disabled default account of an old one
+type SetupHandler struct {
+	authService *auth.AuthService
+}
+
+// NewSetupHandler creates a new setup handler. The setup form only works
+// with the token set by AuthService.BeginSetup.
+func NewSetupHandler(authService *auth.AuthService) *SetupHandler {
+	return &SetupHandler{
+		authService: authService,
+	}
+}
+
+// ShowSetup displays the setup wizard
+func (h *SetupHandler) ShowSetup(c *gin.Context) {
+	if !h.authService.NeedsSetup() && !h.authService.NeedsClaim() {
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+	h.render(c, http.StatusOK, templ.SetupData{Token: c.Query("token")})
+}
+
+// Setup creates the first administrator and signs them in
+func (h *SetupHandler) Setup(c *gin.Context) {
+	if !h.authService.NeedsSetup() {
+		if h.authService.NeedsClaim() {
+			h.claim(c)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+
+	email := c.PostForm("email")
+	name := c.PostForm("name")
+	password := c.PostForm("password")
+	data := templ.SetupData{Name: name, Email: email, Token: c.PostForm("token")}
+
+	if err := h.authService.CheckSetupToken(data.Token); err != nil {
+		data.Error = "Invalid setup token, use SETUP_TOKEN or the one printed in the server log at startup"
+		h.render(c, http.StatusForbidden, data)
+		return
+	}
+	if password != c.PostForm("confirm_password") {
+		data.Error = "Passwords do not match"
+		h.render(c, http.StatusBadRequest, data)
+		return
+	}
+
+	user, err := h.authService.CreateFirstAdmin(email, name, password, false)
+	if err != nil {
+		var policyErr *auth.PolicyError
+		switch {
+		case errors.Is(err, auth.ErrSetupDone):
+			c.Redirect(http.StatusSeeOther, "/login")
+		case errors.As(err, &policyErr):
+			data.Error = err.Error()
+			h.render(c, http.StatusBadRequest, data)
+		default:
+			log.Printf("Failed to create the first administrator: %v", err)
+			data.Error = "Failed to create the administrator"
+			h.render(c, http.StatusInternalServerError, data)
+		}
+		return
+	}
+	log.Printf("Setup completed, %s is the first administrator", user.Email)
+
+	if err := h.authService.Login(c, user); err != nil {
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/dashboard")
+}
+
+// claim gives the disabled default account of an old install a new email
+// and password with the token printed at startup, and signs it in
+func (h *SetupHandler) claim(c *gin.Context) {
+	email := c.PostForm("email")
+	name := c.PostForm("name")
+	password := c.PostForm("password")
+	data := templ.SetupData{Name: name, Email: email, Token: c.PostForm("token")}
+
+	if password != c.PostForm("confirm_password") {
+		data.Error = "Passwords do not match"
+		h.render(c, http.StatusBadRequest, data)
+		return
+	}
+
+	user, err := h.authService.ClaimDefaultAdmin(data.Token, email, name, password)
+	if err != nil {
+		var policyErr *auth.PolicyError
+		switch {
+		case errors.Is(err, auth.ErrSetupDone):
+			c.Redirect(http.StatusSeeOther, "/login")
+		case errors.Is(err, auth.ErrInvalidClaimToken):
+			data.Error = "Invalid setup token, use the one printed in the server log at startup"
+			h.render(c, http.StatusForbidden, data)
+		case errors.Is(err, auth.ErrUserExists), errors.As(err, &policyErr):
+			data.Error = err.Error()
+			h.render(c, http.StatusBadRequest, data)
+		default:
+			log.Printf("Failed to claim the default administrator: %v", err)
+			data.Error = "Failed to save the administrator"
+			h.render(c, http.StatusInternalServerError, data)
+		}
+		return
+	}
+	log.Printf("Setup completed, the default administrator is now %s", user.Email)
+
+	if err := h.authService.Login(c, user); err != nil {
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/dashboard")
+}
+
+func (h *SetupHandler) render(c *gin.Context, status int, data templ.SetupData) {
+	data.Title = "Setup - Sysara"
+	data.Claim = !h.authService.NeedsSetup()
+	data.PasswordRules = h.authService.Policy().Rules()
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.Setup(data).Render(c.Request.Context(), c.Writer)
+}
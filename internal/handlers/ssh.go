@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/teams"
 	templ "github.com/alpemreelmas/sysara/templ"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -15,15 +16,17 @@ import (
 
 // SSHHandler handles SSH key operations
 type SSHHandler struct {
-	db *gorm.DB
+	db    *gorm.DB
+	teams *teams.Manager
 }
 
 // NewSSHHandler creates a new SSH handler
-func NewSSHHandler(db *gorm.DB) *SSHHandler {
-	return &SSHHandler{db: db}
+func NewSSHHandler(db *gorm.DB, teamManager *teams.Manager) *SSHHandler {
+	return &SSHHandler{db: db, teams: teamManager}
 }
 
-// ListKeys displays all SSH keys
+// ListKeys displays the SSH keys of the current user and of their teams, or
+// all SSH keys for admins
 func (h *SSHHandler) ListKeys(c *gin.Context) {
 	currentUser, _ := c.Get("current_user")
 	userModel, ok := currentUser.(*models.User)
@@ -32,8 +35,12 @@ func (h *SSHHandler) ListKeys(c *gin.Context) {
 		return
 	}
 
+	query := h.db.Preload("User").Preload("Team")
+	if !userModel.IsAdmin() {
+		query = query.Where("user_id = ? OR team_id IN (?)", userModel.ID, teams.MemberOf(h.db, userModel.ID))
+	}
 	var sshKeys []models.SSHKey
-	if err := h.db.Preload("User").Find(&sshKeys).Error; err != nil {
+	if err := query.Find(&sshKeys).Error; err != nil {
 		data := templ.SSHListData{
 			AuthData: templ.AuthData{
 				Title:       "SSH Keys - Sysara",
@@ -54,7 +61,11 @@ func (h *SSHHandler) ListKeys(c *gin.Context) {
 			PageTitle:   "SSH Keys",
 			CurrentUser: *userModel,
 		},
-		SSHKeys: sshKeys,
+		SSHKeys:    sshKeys,
+		Manageable: make(map[uint]bool),
+	}
+	for _, key := range sshKeys {
+		data.Manageable[key.ID] = h.canDelete(userModel, &key)
 	}
 	c.Header("Content-Type", "text/html")
 	c.Status(http.StatusOK)
@@ -70,16 +81,7 @@ func (h *SSHHandler) ShowCreateKey(c *gin.Context) {
 		return
 	}
 
-	data := templ.SSHCreateData{
-		AuthData: templ.AuthData{
-			Title:       "Add SSH Key - Sysara",
-			PageTitle:   "Add SSH Key",
-			CurrentUser: *userModel,
-		},
-	}
-	c.Header("Content-Type", "text/html")
-	c.Status(http.StatusOK)
-	templ.SSHCreate(data).Render(c.Request.Context(), c.Writer)
+	h.renderCreate(c, userModel, http.StatusOK, templ.SSHCreateData{})
 }
 
 // CreateKey handles SSH key creation
@@ -106,20 +108,21 @@ func (h *SSHHandler) CreateKey(c *gin.Context) {
 
 	// Validate public key format
 	publicKey = strings.TrimSpace(publicKey)
+	form := templ.SSHCreateData{Name: name, PublicKey: publicKey}
 	if !isValidSSHPublicKey(publicKey) {
-		data := templ.SSHCreateData{
-			AuthData: templ.AuthData{
-				Title:       "Add SSH Key - Sysara",
-				PageTitle:   "Add SSH Key",
-				CurrentUser: *user,
-			},
-			Name:      name,
-			PublicKey: publicKey,
-			Error:     "Invalid SSH public key format",
-		}
-		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusBadRequest)
-		templ.SSHCreate(data).Render(c.Request.Context(), c.Writer)
+		form.Error = "Invalid SSH public key format"
+		h.renderCreate(c, user, http.StatusBadRequest, form)
+		return
+	}
+
+	teamID, err := teamParam(c.PostForm("team_id"))
+	if err == nil {
+		err = h.teams.Assignable(user, teamID)
+	}
+	form.TeamID = teamID
+	if err != nil {
+		form.Error = "Choose one of your teams"
+		h.renderCreate(c, user, http.StatusBadRequest, form)
 		return
 	}
 
@@ -129,19 +132,8 @@ func (h *SSHHandler) CreateKey(c *gin.Context) {
 	// Check if key already exists
 	var existingKey models.SSHKey
 	if err := h.db.Where("fingerprint = ?", fingerprint).First(&existingKey).Error; err == nil {
-		data := templ.SSHCreateData{
-			AuthData: templ.AuthData{
-				Title:       "Add SSH Key - Sysara",
-				PageTitle:   "Add SSH Key",
-				CurrentUser: *user,
-			},
-			Name:      name,
-			PublicKey: publicKey,
-			Error:     "SSH key already exists",
-		}
-		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusBadRequest)
-		templ.SSHCreate(data).Render(c.Request.Context(), c.Writer)
+		form.Error = "SSH key already exists"
+		h.renderCreate(c, user, http.StatusBadRequest, form)
 		return
 	}
 
@@ -151,22 +143,12 @@ func (h *SSHHandler) CreateKey(c *gin.Context) {
 		PublicKey:   publicKey,
 		Fingerprint: fingerprint,
 		UserID:      user.ID,
+		TeamID:      teamID,
 	}
 
 	if err := h.db.Create(&sshKey).Error; err != nil {
-		data := templ.SSHCreateData{
-			AuthData: templ.AuthData{
-				Title:       "Add SSH Key - Sysara",
-				PageTitle:   "Add SSH Key",
-				CurrentUser: *user,
-			},
-			Name:      name,
-			PublicKey: publicKey,
-			Error:     "Failed to save SSH key",
-		}
-		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusInternalServerError)
-		templ.SSHCreate(data).Render(c.Request.Context(), c.Writer)
+		form.Error = "Failed to save SSH key"
+		h.renderCreate(c, user, http.StatusInternalServerError, form)
 		return
 	}
 
@@ -181,7 +163,7 @@ func (h *SSHHandler) DeleteKey(c *gin.Context) {
 		return
 	}
 
-	// Check if key exists and belongs to current user or user is admin
+	// Check if key exists and may be deleted by the current user
 	var sshKey models.SSHKey
 	if err := h.db.First(&sshKey, uint(id)).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "SSH key not found"})
@@ -191,10 +173,8 @@ func (h *SSHHandler) DeleteKey(c *gin.Context) {
 	currentUser, exists := c.Get("current_user")
 	if exists {
 		if user, ok := currentUser.(*models.User); ok {
-			// Allow deletion if it's the user's own key
-			// In a more complex system, you might have admin roles
-			if sshKey.UserID != user.ID {
-				c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own SSH keys"})
+			if !h.canDelete(user, &sshKey) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own SSH keys and those of teams you maintain"})
 				return
 			}
 		}
@@ -208,6 +188,31 @@ func (h *SSHHandler) DeleteKey(c *gin.Context) {
 	c.Redirect(http.StatusSeeOther, "/ssh")
 }
 
+// canDelete reports whether user may delete key: its owner, admins and
+// maintainers of the team of the key
+func (h *SSHHandler) canDelete(user *models.User, key *models.SSHKey) bool {
+	if key.UserID == user.ID || user.IsAdmin() {
+		return true
+	}
+	return key.TeamID != nil && h.teams.CanManage(user, *key.TeamID)
+}
+
+// renderCreate shows the create SSH key form with the teams of user
+func (h *SSHHandler) renderCreate(c *gin.Context, user *models.User, status int, data templ.SSHCreateData) {
+	data.AuthData = templ.AuthData{
+		Title:       "Add SSH Key - Sysara",
+		PageTitle:   "Add SSH Key",
+		CurrentUser: *user,
+	}
+	if userTeams, err := h.teams.ForUser(user); err == nil {
+		data.Teams = userTeams
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.SSHCreate(data).Render(c.Request.Context(), c.Writer)
+}
+
 // isValidSSHPublicKey validates SSH public key format
 func isValidSSHPublicKey(key string) bool {
 	parts := strings.Fields(key)
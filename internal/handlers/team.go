@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/teams"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TeamHandler handles teams and their members. Admins create and delete
+// teams; admins and team maintainers manage the members.
+type TeamHandler struct {
+	db    *gorm.DB
+	teams *teams.Manager
+	audit *audit.Logger
+}
+
+// NewTeamHandler creates a new team handler
+func NewTeamHandler(db *gorm.DB, teamManager *teams.Manager, auditLog *audit.Logger) *TeamHandler {
+	return &TeamHandler{db: db, teams: teamManager, audit: auditLog}
+}
+
+// ListTeams displays the teams of the current user, or all teams for admins
+func (h *TeamHandler) ListTeams(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.renderList(c, userModel, http.StatusOK, "")
+}
+
+// ShowCreateTeam displays the create team form
+func (h *TeamHandler) ShowCreateTeam(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.renderCreate(c, userModel, http.StatusOK, models.Team{}, "")
+}
+
+// CreateTeam adds a new team
+func (h *TeamHandler) CreateTeam(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	team := models.Team{
+		Name:        strings.TrimSpace(c.PostForm("name")),
+		Description: c.PostForm("description"),
+	}
+	if team.Name == "" {
+		h.renderCreate(c, userModel, http.StatusBadRequest, team, "Name is required")
+		return
+	}
+	if err := h.teams.Create(&team); err != nil {
+		h.renderCreate(c, userModel, http.StatusBadRequest, team, teamErrorMessage(err))
+		return
+	}
+	h.audit.Record(userModel, audit.ActionTeamCreate, team.Name, "", c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, teamURL(team.ID))
+}
+
+// ShowTeam displays the members and resources of a team
+func (h *TeamHandler) ShowTeam(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	team, ok := h.team(c, userModel)
+	if !ok {
+		return
+	}
+	h.renderTeam(c, userModel, http.StatusOK, team, templ.TeamData{Success: teamMessages[c.Query("done")]})
+}
+
+// UpdateTeam saves the name and description of a team
+func (h *TeamHandler) UpdateTeam(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	team, ok := h.team(c, userModel)
+	if !ok {
+		return
+	}
+	name := team.Name
+	team.Name = strings.TrimSpace(c.PostForm("name"))
+	team.Description = c.PostForm("description")
+	if team.Name == "" {
+		h.renderTeam(c, userModel, http.StatusBadRequest, team, templ.TeamData{Error: "Name is required"})
+		return
+	}
+	if err := h.teams.Update(team); err != nil {
+		h.renderTeam(c, userModel, http.StatusBadRequest, team, templ.TeamData{Error: teamErrorMessage(err)})
+		return
+	}
+	detail := ""
+	if name != team.Name {
+		detail = "renamed from " + name
+	}
+	h.audit.Record(userModel, audit.ActionTeamUpdate, team.Name, detail, c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, teamURL(team.ID)+"?done=updated")
+}
+
+// DeleteTeam removes a team. Its SSH keys, servers and env projects are
+// kept without an owning team.
+func (h *TeamHandler) DeleteTeam(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	team, ok := h.team(c, userModel)
+	if !ok {
+		return
+	}
+	if err := h.teams.Delete(team.ID); err != nil {
+		h.renderList(c, userModel, http.StatusInternalServerError, "Failed to delete team")
+		return
+	}
+	h.audit.Record(userModel, audit.ActionTeamDelete, team.Name, "", c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/teams")
+}
+
+// SetMember adds a user to a team or changes their role
+func (h *TeamHandler) SetMember(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	team, ok := h.managedTeam(c, userModel)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.PostForm("user_id"), 10, 32)
+	if err != nil {
+		h.renderTeam(c, userModel, http.StatusBadRequest, team, templ.TeamData{Error: "Select a user"})
+		return
+	}
+	var user models.User
+	if err := h.db.Where("status <> ?", models.UserDeleted).First(&user, uint(userID)).Error; err != nil {
+		h.renderTeam(c, userModel, http.StatusBadRequest, team, templ.TeamData{Error: "User not found"})
+		return
+	}
+	role := c.PostForm("role")
+	if err := h.teams.SetMember(team.ID, user.ID, role); err != nil {
+		h.renderTeam(c, userModel, http.StatusBadRequest, team, templ.TeamData{Error: err.Error()})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionTeamMember, team.Name, user.Email+" as "+role, c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, teamURL(team.ID)+"?done=member")
+}
+
+// RemoveMember removes a user from a team
+func (h *TeamHandler) RemoveMember(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	team, ok := h.managedTeam(c, userModel)
+	if !ok {
+		return
+	}
+	userID, _ := strconv.ParseUint(c.Param("user"), 10, 32)
+	var member *models.TeamMember
+	for i := range team.Members {
+		if team.Members[i].UserID == uint(userID) {
+			member = &team.Members[i]
+		}
+	}
+	if member == nil {
+		h.renderTeam(c, userModel, http.StatusNotFound, team, templ.TeamData{Error: "This user is not a member of the team"})
+		return
+	}
+	if err := h.teams.RemoveMember(team.ID, member.UserID); err != nil {
+		h.renderTeam(c, userModel, http.StatusInternalServerError, team, templ.TeamData{Error: "Failed to remove the member"})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionTeamMemberRemove, team.Name, member.User.Email, c.ClientIP())
+
+	// Maintainers who leave can no longer see the team
+	if !userModel.IsAdmin() && member.UserID == userModel.ID {
+		c.Redirect(http.StatusSeeOther, "/teams")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, teamURL(team.ID)+"?done=removed")
+}
+
+// team loads the team from the :id parameter. Users who are not members
+// and not admins get a not found error, like for unknown teams.
+func (h *TeamHandler) team(c *gin.Context, user *models.User) (*models.Team, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.renderList(c, user, http.StatusNotFound, teams.ErrTeamNotFound.Error())
+		return nil, false
+	}
+	team, err := h.teams.Get(uint(id))
+	if err == nil && !user.IsAdmin() && h.teams.Role(user.ID, team.ID) == "" {
+		err = teams.ErrTeamNotFound
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, teams.ErrTeamNotFound) {
+			status = http.StatusNotFound
+		}
+		h.renderList(c, user, status, teamErrorMessage(err))
+		return nil, false
+	}
+	return team, true
+}
+
+// managedTeam loads the team from the :id parameter for a change of its
+// members, which requires an admin or a maintainer of the team
+func (h *TeamHandler) managedTeam(c *gin.Context, user *models.User) (*models.Team, bool) {
+	team, ok := h.team(c, user)
+	if !ok {
+		return nil, false
+	}
+	if !h.teams.CanManage(user, team.ID) {
+		h.renderTeam(c, user, http.StatusForbidden, team, templ.TeamData{Error: "Only maintainers of this team can change its members"})
+		return nil, false
+	}
+	return team, true
+}
+
+// renderList renders the teams of user with an optional error
+func (h *TeamHandler) renderList(c *gin.Context, user *models.User, status int, errMsg string) {
+	data := templ.TeamListData{
+		AuthData: templ.AuthData{
+			Title:       "Teams - Sysara",
+			PageTitle:   "Teams",
+			CurrentUser: *user,
+		},
+		Error: errMsg,
+	}
+	list, err := h.teams.ForUser(user)
+	if err != nil {
+		data.Error = "Failed to load teams"
+		status = http.StatusInternalServerError
+	}
+	data.Teams = list
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.TeamList(data).Render(c.Request.Context(), c.Writer)
+}
+
+// renderCreate renders the create team form
+func (h *TeamHandler) renderCreate(c *gin.Context, user *models.User, status int, team models.Team, errMsg string) {
+	data := templ.TeamCreateData{
+		AuthData: templ.AuthData{
+			Title:       "Create Team - Sysara",
+			PageTitle:   "Teams",
+			CurrentUser: *user,
+		},
+		Team:  team,
+		Error: errMsg,
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.TeamCreate(data).Render(c.Request.Context(), c.Writer)
+}
+
+// renderTeam renders the page of a team with its members and resources
+func (h *TeamHandler) renderTeam(c *gin.Context, user *models.User, status int, team *models.Team, data templ.TeamData) {
+	data.AuthData = templ.AuthData{
+		Title:       team.Name + " - Sysara",
+		PageTitle:   "Teams",
+		CurrentUser: *user,
+	}
+	data.Team = *team
+	data.CanManage = h.teams.CanManage(user, team.ID)
+
+	if err := h.db.Preload("User").Where("team_id = ?", team.ID).Order("name").Find(&data.SSHKeys).Error; err != nil {
+		data.Error = "Failed to load SSH keys"
+	}
+	if err := h.db.Where("team_id = ?", team.ID).Order("name").Find(&data.Projects).Error; err != nil {
+		data.Error = "Failed to load env projects"
+	}
+	if err := h.db.Where("team_id = ?", team.ID).Order("name").Find(&data.Servers).Error; err != nil {
+		data.Error = "Failed to load servers"
+	}
+	if data.CanManage {
+		h.db.Where("status <> ? AND id NOT IN (?)", models.UserDeleted, h.db.Model(&models.TeamMember{}).Select("user_id").Where("team_id = ?", team.ID)).
+			Order("name").Find(&data.Users)
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.Team(data).Render(c.Request.Context(), c.Writer)
+}
+
+// teamMessages are shown on the team page after an action
+var teamMessages = map[string]string{
+	"updated": "Team updated",
+	"member":  "The member was saved",
+	"removed": "The member was removed",
+}
+
+func teamURL(id uint) string {
+	return "/teams/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// teamParam parses the owning team selected in a form. An empty value
+// means no team.
+func teamParam(value string) (*uint, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, teams.ErrTeamNotFound
+	}
+	teamID := uint(id)
+	return &teamID, nil
+}
+
+// teamErrorMessage turns team errors into user-facing messages
+func teamErrorMessage(err error) string {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return "A team with this name already exists"
+	}
+	return err.Error()
+}
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/alpemreelmas/sysara/internal/audit"
 	"github.com/alpemreelmas/sysara/internal/auth"
 	"github.com/alpemreelmas/sysara/internal/models"
 	templ "github.com/alpemreelmas/sysara/templ"
@@ -11,17 +16,64 @@ import (
 	"gorm.io/gorm"
 )
 
+// LoginOptions are the ways to sign in offered on the login page
+type LoginOptions struct {
+	Registration   bool   // anyone can create an account at /register
+	LocalPasswords bool   // users can sign in with a Sysara password
+	Directory      bool   // users can sign in with a directory password
+	SSOName        string // name of the single sign-on provider, empty when it is off
+	Passkeys       bool   // users can sign in with a passkey alone
+}
+
+// loginData returns the login page data for these options
+func (o LoginOptions) loginData() templ.LoginData {
+	return templ.LoginData{
+		Title:               "Login - Sysara",
+		RegistrationEnabled: o.Registration,
+		PasswordLogin:       o.LocalPasswords || o.Directory,
+		UsernameLogin:       o.Directory,
+		PasswordReset:       o.LocalPasswords,
+		SSOName:             o.SSOName,
+		Passkeys:            o.Passkeys,
+	}
+}
+
 // UserHandler handles user-related operations
 type UserHandler struct {
 	db          *gorm.DB
 	authService *auth.AuthService
+	audit       *audit.Logger
+	login       LoginOptions
 }
 
+// invitationMessages are shown on the users page after an invitation action
+var invitationMessages = map[string]string{
+	"sent":    "The invitation was sent",
+	"resent":  "A new invitation link was sent",
+	"revoked": "The invitation was revoked",
+}
+
+// accountMessages are shown on the edit page after an account state change
+var accountMessages = map[string]string{
+	"status":   "The account status was updated",
+	"restored": "The account was restored",
+}
+
+// passwordManagedMessage is shown to users who cannot change their password here
+const passwordManagedMessage = "Your password is managed by your identity provider"
+
+// recentLoginAttempts is how many login attempts the edit page shows
+const recentLoginAttempts = 20
+
 // NewUserHandler creates a new user handler
-func NewUserHandler(db *gorm.DB, authService *auth.AuthService) *UserHandler {
+func NewUserHandler(db *gorm.DB, authService *auth.AuthService, auditLog *audit.Logger, login LoginOptions) *UserHandler {
+	// Accounts created at /register sign in with a password
+	login.Registration = login.Registration && login.LocalPasswords
 	return &UserHandler{
 		db:          db,
 		authService: authService,
+		audit:       auditLog,
+		login:       login,
 	}
 }
 
@@ -33,8 +85,12 @@ func (h *UserHandler) ShowLogin(c *gin.Context) {
 		return
 	}
 
-	data := templ.LoginData{
-		Title: "Login - Sysara",
+	data := h.login.loginData()
+	if c.Query("reset") == "1" {
+		data.Success = "Your password was changed. Please sign in with your new password."
+	}
+	if c.Query("email_changed") == "1" {
+		data.Success = "Your email address was changed. Please sign in with your new address."
 	}
 	c.Header("Content-Type", "text/html")
 	c.Status(http.StatusOK)
@@ -46,36 +102,63 @@ func (h *UserHandler) Login(c *gin.Context) {
 	email := c.PostForm("email")
 	password := c.PostForm("password")
 
-	user, err := h.authService.AuthenticateUser(email, password)
+	user, err := h.authService.AuthenticateUser(email, password, c.ClientIP())
 	if err != nil {
-		data := templ.LoginData{
-			Title: "Login - Sysara",
-			Error: err.Error(),
-			Email: email,
+		status := http.StatusBadRequest
+		message := err.Error()
+		switch {
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			status = http.StatusTooManyRequests
+		case errors.Is(err, auth.ErrPasswordLoginDisabled), errors.Is(err, auth.ErrAccessDenied):
+			status = http.StatusForbidden
+		case errors.Is(err, auth.ErrUserExists):
+			status = http.StatusConflict
+		case errors.Is(err, auth.ErrNoEmail):
+		case !errors.Is(err, auth.ErrInvalidCredentials):
+			log.Printf("Failed to authenticate %s: %v", email, err)
+			status = http.StatusInternalServerError
+			message = "Failed to sign in"
 		}
+		data := h.login.loginData()
+		data.Error = message
+		data.Email = email
 		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusBadRequest)
+		c.Status(status)
 		templ.Login(data).Render(c.Request.Context(), c.Writer)
 		return
 	}
 
-	if err := h.authService.Login(c, user); err != nil {
-		data := templ.LoginData{
-			Title: "Login - Sysara",
-			Error: "Failed to create session",
-			Email: email,
-		}
+	// Users with security keys confirm the login with one of them
+	secondFactor, err := h.authService.SecondFactorRequired(user)
+	if err == nil && secondFactor {
+		err = h.authService.BeginSecondFactor(c, user)
+	} else if err == nil {
+		err = h.authService.Login(c, user)
+	}
+	if err != nil {
+		data := h.login.loginData()
+		data.Error = "Failed to create session"
+		data.Email = email
 		c.Header("Content-Type", "text/html")
 		c.Status(http.StatusInternalServerError)
 		templ.Login(data).Render(c.Request.Context(), c.Writer)
 		return
 	}
 
+	if secondFactor {
+		c.Redirect(http.StatusSeeOther, "/login/verify")
+		return
+	}
 	c.Redirect(http.StatusSeeOther, "/dashboard")
 }
 
 // ShowRegister displays the registration page
 func (h *UserHandler) ShowRegister(c *gin.Context) {
+	if !h.login.Registration {
+		h.registrationDisabled(c)
+		return
+	}
+
 	// If already logged in, redirect to dashboard
 	if h.authService.IsAuthenticated(c) {
 		c.Redirect(http.StatusSeeOther, "/dashboard")
@@ -83,7 +166,8 @@ func (h *UserHandler) ShowRegister(c *gin.Context) {
 	}
 
 	data := templ.RegisterData{
-		Title: "Register - Sysara",
+		Title:         "Register - Sysara",
+		PasswordRules: h.authService.Policy().Rules(),
 	}
 	c.Header("Content-Type", "text/html")
 	c.Status(http.StatusOK)
@@ -92,6 +176,11 @@ func (h *UserHandler) ShowRegister(c *gin.Context) {
 
 // Register handles user registration
 func (h *UserHandler) Register(c *gin.Context) {
+	if !h.login.Registration {
+		h.registrationDisabled(c)
+		return
+	}
+
 	email := c.PostForm("email")
 	name := c.PostForm("name")
 	password := c.PostForm("password")
@@ -100,24 +189,11 @@ func (h *UserHandler) Register(c *gin.Context) {
 	// Validate passwords match
 	if password != confirmPassword {
 		data := templ.RegisterData{
-			Title: "Register - Sysara",
-			Error: "Passwords do not match",
-			Email: email,
-			Name:  name,
-		}
-		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusBadRequest)
-		templ.Register(data).Render(c.Request.Context(), c.Writer)
-		return
-	}
-
-	// Validate password length
-	if len(password) < 6 {
-		data := templ.RegisterData{
-			Title: "Register - Sysara",
-			Error: "Password must be at least 6 characters long",
-			Email: email,
-			Name:  name,
+			Title:         "Register - Sysara",
+			Error:         "Passwords do not match",
+			Email:         email,
+			Name:          name,
+			PasswordRules: h.authService.Policy().Rules(),
 		}
 		c.Header("Content-Type", "text/html")
 		c.Status(http.StatusBadRequest)
@@ -128,10 +204,11 @@ func (h *UserHandler) Register(c *gin.Context) {
 	user, err := h.authService.RegisterUser(email, name, password)
 	if err != nil {
 		data := templ.RegisterData{
-			Title: "Register - Sysara",
-			Error: err.Error(),
-			Email: email,
-			Name:  name,
+			Title:         "Register - Sysara",
+			Error:         err.Error(),
+			Email:         email,
+			Name:          name,
+			PasswordRules: h.authService.Policy().Rules(),
 		}
 		c.Header("Content-Type", "text/html")
 		c.Status(http.StatusBadRequest)
@@ -148,6 +225,15 @@ func (h *UserHandler) Register(c *gin.Context) {
 	c.Redirect(http.StatusSeeOther, "/dashboard")
 }
 
+// registrationDisabled shows the login page when self-registration is off
+func (h *UserHandler) registrationDisabled(c *gin.Context) {
+	data := h.login.loginData()
+	data.Error = "Registration is disabled. Ask an administrator for an invitation."
+	c.Header("Content-Type", "text/html")
+	c.Status(http.StatusNotFound)
+	templ.Login(data).Render(c.Request.Context(), c.Writer)
+}
+
 // Logout handles user logout
 func (h *UserHandler) Logout(c *gin.Context) {
 	if err := h.authService.Logout(c); err != nil {
@@ -157,32 +243,9 @@ func (h *UserHandler) Logout(c *gin.Context) {
 	c.Redirect(http.StatusSeeOther, "/login")
 }
 
-// ListUsers displays all users (admin function)
+// ListUsers displays all users (admin function). Deleted users are listed
+// separately with ?deleted=1.
 func (h *UserHandler) ListUsers(c *gin.Context) {
-	var users []models.User
-	if err := h.db.Find(&users).Error; err != nil {
-		currentUser, _ := c.Get("current_user")
-		userModel, ok := currentUser.(*models.User)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
-			return
-		}
-
-		data := templ.UserListData{
-			AuthData: templ.AuthData{
-				Title:       "Users - Sysara",
-				PageTitle:   "Users",
-				CurrentUser: *userModel,
-			},
-			Users: users,
-			Error: "Failed to fetch users",
-		}
-		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusInternalServerError)
-		templ.UserList(data).Render(c.Request.Context(), c.Writer)
-		return
-	}
-
 	currentUser, _ := c.Get("current_user")
 	userModel, ok := currentUser.(*models.User)
 	if !ok {
@@ -190,13 +253,35 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		return
 	}
 
+	deleted := c.Query("deleted") == "1"
+	query := h.db.Where("status <> ?", models.UserDeleted)
+	if deleted {
+		query = h.db.Where("status = ?", models.UserDeleted)
+	}
+
 	data := templ.UserListData{
 		AuthData: templ.AuthData{
 			Title:       "Users - Sysara",
 			PageTitle:   "Users",
 			CurrentUser: *userModel,
 		},
-		Users: users,
+		Deleted: deleted,
+		Success: invitationMessages[c.Query("invitation")],
+	}
+	if err := query.Find(&data.Users).Error; err != nil {
+		data.Error = "Failed to fetch users"
+		c.Header("Content-Type", "text/html")
+		c.Status(http.StatusInternalServerError)
+		templ.UserList(data).Render(c.Request.Context(), c.Writer)
+		return
+	}
+
+	if !deleted {
+		invitations, err := h.authService.PendingInvitations()
+		if err != nil {
+			data.Error = "Failed to fetch invitations"
+		}
+		data.Invitations = invitations
 	}
 	c.Header("Content-Type", "text/html")
 	c.Status(http.StatusOK)
@@ -229,6 +314,8 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	email := c.PostForm("email")
 	name := c.PostForm("name")
 	password := c.PostForm("password")
+	role := userRole(c.PostForm("role"))
+	mustChange := c.PostForm("must_change_password") == "1"
 
 	currentUser, _ := c.Get("current_user")
 	userModel, ok := currentUser.(*models.User)
@@ -237,17 +324,19 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	// Validate password length
-	if len(password) < 6 {
+	user, err := h.authService.RegisterUser(email, name, password)
+	if err != nil {
 		data := templ.UserCreateData{
 			AuthData: templ.AuthData{
 				Title:       "Create User - Sysara",
 				PageTitle:   "Create User",
 				CurrentUser: *userModel,
 			},
-			Error: "Password must be at least 6 characters long",
-			Email: email,
-			Name:  name,
+			Error:              err.Error(),
+			Email:              email,
+			Name:               name,
+			Role:               role,
+			MustChangePassword: mustChange,
 		}
 		c.Header("Content-Type", "text/html")
 		c.Status(http.StatusBadRequest)
@@ -255,22 +344,11 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	_, err := h.authService.RegisterUser(email, name, password)
-	if err != nil {
-		data := templ.UserCreateData{
-			AuthData: templ.AuthData{
-				Title:       "Create User - Sysara",
-				PageTitle:   "Create User",
-				CurrentUser: *userModel,
-			},
-			Error: err.Error(),
-			Email: email,
-			Name:  name,
+	if role != models.RoleUser || mustChange {
+		if err := h.db.Model(user).Updates(map[string]interface{}{"role": role, "must_change_password": mustChange}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set user role"})
+			return
 		}
-		c.Header("Content-Type", "text/html")
-		c.Status(http.StatusBadRequest)
-		templ.UserCreate(data).Render(c.Request.Context(), c.Writer)
-		return
 	}
 
 	c.Redirect(http.StatusSeeOther, "/users")
@@ -305,11 +383,89 @@ func (h *UserHandler) ShowEditUser(c *gin.Context) {
 		},
 		User: user,
 	}
+	if c.Query("unlocked") == "1" {
+		data.Success = "The account was unlocked"
+	}
+	if c.Query("keys_reset") == "1" {
+		data.Success = "The security keys were removed"
+	}
+	if message, ok := accountMessages[c.Query("account")]; ok {
+		data.Success = message
+	}
+	if data.Attempts, err = h.authService.LoginAttempts(user.ID, recentLoginAttempts); err != nil {
+		data.Error = "Failed to load login attempts"
+	}
+	if credentials, err := h.authService.WebAuthnCredentials(user.ID); err != nil {
+		data.Error = "Failed to load security keys"
+	} else {
+		data.SecurityKeys = len(credentials)
+	}
 	c.Header("Content-Type", "text/html")
 	c.Status(http.StatusOK)
 	templ.UserEdit(data).Render(c.Request.Context(), c.Writer)
 }
 
+// UnlockUser clears the failed login count and lock of a user
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return
+	}
+
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	if err := h.authService.Unlock(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionUserUnlock, user.Email, strconv.Itoa(user.FailedLogins)+" failed logins", c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/users/"+strconv.FormatUint(id, 10)+"/edit?unlocked=1")
+}
+
+// ResetSecurityKeys removes all security keys of a user who lost them
+func (h *UserHandler) ResetSecurityKeys(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return
+	}
+
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	n, err := h.authService.DeleteWebAuthnCredentials(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove security keys"})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionWebAuthnReset, user.Email, strconv.FormatInt(n, 10)+" security keys", c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/users/"+strconv.FormatUint(id, 10)+"/edit?keys_reset=1")
+}
+
 // UpdateUser handles user updates
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -335,26 +491,14 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	// Update password if provided
+	// Check a new password against the policy, including the user's history
 	if password != "" {
-		if len(password) < 6 {
-			data := templ.UserEditData{
-				AuthData: templ.AuthData{
-					Title:       "Edit User - Sysara",
-					PageTitle:   "Edit User",
-					CurrentUser: *userModel,
-				},
-				User:  user,
-				Error: "Password must be at least 6 characters long",
+		if err := h.authService.ValidatePassword(&user, password); err != nil {
+			status, message := http.StatusBadRequest, err.Error()
+			var policyErr *auth.PolicyError
+			if !errors.As(err, &policyErr) {
+				status, message = http.StatusInternalServerError, "Failed to check password"
 			}
-			c.Header("Content-Type", "text/html")
-			c.Status(http.StatusBadRequest)
-			templ.UserEdit(data).Render(c.Request.Context(), c.Writer)
-			return
-		}
-
-		hashedPassword, err := h.authService.HashPassword(password)
-		if err != nil {
 			data := templ.UserEditData{
 				AuthData: templ.AuthData{
 					Title:       "Edit User - Sysara",
@@ -362,19 +506,24 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 					CurrentUser: *userModel,
 				},
 				User:  user,
-				Error: "Failed to hash password",
+				Error: message,
 			}
 			c.Header("Content-Type", "text/html")
-			c.Status(http.StatusInternalServerError)
+			c.Status(status)
 			templ.UserEdit(data).Render(c.Request.Context(), c.Writer)
 			return
 		}
-		user.Password = hashedPassword
 	}
 
 	// Update fields
 	user.Email = email
 	user.Name = name
+	// Admins cannot demote themselves and lock everyone out
+	if user.ID != userModel.ID {
+		user.Role = userRole(c.PostForm("role"))
+	}
+	mustChange := c.PostForm("must_change_password") == "1"
+	user.MustChangePassword = mustChange
 
 	if err := h.db.Save(&user).Error; err != nil {
 		data := templ.UserEditData{
@@ -392,10 +541,86 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// A changed password signs the user out everywhere; admins changing
+	// their own password stay signed in on this browser
+	if password != "" {
+		if err := h.authService.SetPassword(&user, password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+			return
+		}
+		if mustChange {
+			h.db.Model(&user).Update("must_change_password", true)
+		}
+		h.authService.Sessions().RevokeUser(user.ID, 0)
+		if user.ID == userModel.ID {
+			h.authService.Login(c, &user)
+		}
+	}
+
 	c.Redirect(http.StatusSeeOther, "/users")
 }
 
-// DeleteUser handles user deletion
+// ChangeUserStatus enables, disables or suspends a user and sets the expiry
+// date of the account. Users who are no longer active are signed out at once.
+func (h *UserHandler) ChangeUserStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return
+	}
+
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	if user.ID == userModel.ID {
+		h.renderEdit(c, http.StatusBadRequest, userModel, user, "You cannot change the status of your own account")
+		return
+	}
+	if user.Status == models.UserDeleted {
+		h.renderEdit(c, http.StatusBadRequest, userModel, user, "Restore the account before changing its status")
+		return
+	}
+
+	change := auth.AccountChange{
+		Status: c.PostForm("status"),
+		Reason: strings.TrimSpace(c.PostForm("reason")),
+	}
+	if change.Status == models.UserSuspended {
+		if change.SuspendedUntil, err = parseLocalTime(c.PostForm("suspended_until")); err != nil {
+			h.renderEdit(c, http.StatusBadRequest, userModel, user, "Invalid end of the suspension")
+			return
+		}
+	}
+	if change.ExpiresAt, err = parseLocalTime(c.PostForm("expires_at")); err != nil {
+		h.renderEdit(c, http.StatusBadRequest, userModel, user, "Invalid expiry date")
+		return
+	}
+
+	if !h.changeAccountState(c, userModel, &user, change) {
+		return
+	}
+	revoked, err := h.revokeSSHKeys(c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke SSH keys"})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionUserStatus, user.Email, accountDetail(&user, revoked), c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/users/"+strconv.FormatUint(id, 10)+"/edit?account=status")
+}
+
+// DeleteUser soft-deletes a user. The account stays in the database so that
+// its SSH keys and audit history keep their owner, and it can be restored.
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -403,19 +628,281 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
 	// Don't allow users to delete themselves
-	currentUser, exists := c.Get("current_user")
-	if exists {
-		if user, ok := currentUser.(*models.User); ok && user.ID == uint(id) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete your own account"})
+	if userModel.ID == uint(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete your own account"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return
+	}
+
+	change := auth.AccountChange{
+		Status:    models.UserDeleted,
+		ExpiresAt: user.ExpiresAt,
+		Reason:    strings.TrimSpace(c.PostForm("reason")),
+	}
+	if !h.changeAccountState(c, userModel, &user, change) {
+		return
+	}
+	revoked, err := h.revokeSSHKeys(c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke SSH keys"})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionUserDelete, user.Email, accountDetail(&user, revoked), c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/users")
+}
+
+// RestoreUser brings back a soft-deleted user as an active account
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/users")
+		return
+	}
+
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	if user.Status != models.UserDeleted {
+		c.Redirect(http.StatusSeeOther, "/users/"+strconv.FormatUint(id, 10)+"/edit")
+		return
+	}
+	if !h.changeAccountState(c, userModel, &user, auth.AccountChange{Status: models.UserActive, ExpiresAt: user.ExpiresAt}) {
+		return
+	}
+	h.audit.Record(userModel, audit.ActionUserRestore, user.Email, "", c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/users/"+strconv.FormatUint(id, 10)+"/edit?account=restored")
+}
+
+// PurgeUser permanently removes a soft-deleted user with its SSH keys,
+// credentials and project permissions. Audit entries of the user are kept.
+func (h *UserHandler) PurgeUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.Redirect(http.StatusSeeOther, "/users?deleted=1")
+		return
+	}
+
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	if user.Status != models.UserDeleted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only deleted users can be removed permanently"})
+		return
+	}
+
+	if err := h.db.Delete(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+	h.db.Where("user_id = ?", user.ID).Delete(&models.PasswordResetToken{})
+	h.db.Where("user_id = ?", user.ID).Delete(&models.LoginAttempt{})
+	h.db.Where("user_id = ?", user.ID).Delete(&models.PasswordHistory{})
+	h.db.Where("user_id = ?", user.ID).Delete(&models.WebAuthnCredential{})
+	h.db.Where("user_id = ?", user.ID).Delete(&models.SSHKey{})
+	h.db.Where("user_id = ?", user.ID).Delete(&models.EnvProjectPermission{})
+	h.db.Where("user_id = ?", user.ID).Delete(&models.TeamMember{})
+	h.db.Where("user_id = ?", user.ID).Delete(&models.APIToken{})
+	h.db.Where("user_id = ?", user.ID).Delete(&models.EmailChange{})
+	h.authService.Sessions().RevokeUser(user.ID, 0)
+	h.audit.Record(userModel, audit.ActionUserPurge, user.Email, "", c.ClientIP())
+
+	c.Redirect(http.StatusSeeOther, "/users?deleted=1")
+}
+
+// changeAccountState applies change to user and renders the edit page with
+// the error when it is refused
+func (h *UserHandler) changeAccountState(c *gin.Context, current *models.User, user *models.User, change auth.AccountChange) bool {
+	err := h.authService.ChangeAccountState(user, change)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, auth.ErrLastAdmin), errors.Is(err, auth.ErrSuspensionEnded), errors.Is(err, auth.ErrInvalidStatus):
+		h.renderEdit(c, http.StatusBadRequest, current, *user, err.Error())
+	default:
+		log.Printf("Failed to change the account state of %s: %v", user.Email, err)
+		h.renderEdit(c, http.StatusInternalServerError, current, *user, "Failed to update the account status")
+	}
+	return false
+}
+
+// revokeSSHKeys marks the SSH keys of an inactive user as revoked when the
+// form asks for it, and returns how many were revoked. The keys are kept so
+// that it stays known which keys the user had; Sysara does not manage the
+// authorized_keys of servers, so they still have to be removed there.
+func (h *UserHandler) revokeSSHKeys(c *gin.Context, user *models.User) (int64, error) {
+	if c.PostForm("revoke_ssh_keys") != "1" || user.IsActive() {
+		return 0, nil
+	}
+	result := h.db.Model(&models.SSHKey{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// renderEdit shows the edit page of user with an error
+func (h *UserHandler) renderEdit(c *gin.Context, status int, current *models.User, user models.User, message string) {
+	data := templ.UserEditData{
+		AuthData: templ.AuthData{
+			Title:       "Edit User - Sysara",
+			PageTitle:   "Edit User",
+			CurrentUser: *current,
+		},
+		User:  user,
+		Error: message,
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.UserEdit(data).Render(c.Request.Context(), c.Writer)
+}
+
+// ShowChangePassword displays the form to change the current user's password
+func (h *UserHandler) ShowChangePassword(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	if userModel.IsExternal() || !h.authService.LocalPasswords() {
+		h.renderChangePassword(c, http.StatusOK, userModel, passwordManagedMessage)
+		return
+	}
+	h.renderChangePassword(c, http.StatusOK, userModel, "")
+}
+
+// ChangePassword changes the current user's password. Other sessions are
+// signed out and this one is reissued.
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	if userModel.IsExternal() || !h.authService.LocalPasswords() {
+		h.renderChangePassword(c, http.StatusForbidden, userModel, passwordManagedMessage)
+		return
+	}
+	password := c.PostForm("password")
+	if err := h.authService.VerifyPassword(userModel.Password, c.PostForm("current_password")); err != nil {
+		h.renderChangePassword(c, http.StatusBadRequest, userModel, "Current password is incorrect")
+		return
+	}
+	if password != c.PostForm("confirm_password") {
+		h.renderChangePassword(c, http.StatusBadRequest, userModel, "Passwords do not match")
+		return
+	}
+	if err := h.authService.ValidatePassword(userModel, password); err != nil {
+		var policyErr *auth.PolicyError
+		if !errors.As(err, &policyErr) {
+			h.renderChangePassword(c, http.StatusInternalServerError, userModel, "Failed to check password")
 			return
 		}
+		h.renderChangePassword(c, http.StatusBadRequest, userModel, err.Error())
+		return
 	}
 
-	if err := h.db.Delete(&models.User{}, uint(id)).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+	if err := h.authService.SetPassword(userModel, password); err != nil {
+		h.renderChangePassword(c, http.StatusInternalServerError, userModel, "Failed to change password")
+		return
+	}
+	h.authService.Sessions().RevokeUser(userModel.ID, 0)
+	if err := h.authService.Login(c, userModel); err != nil {
+		c.Redirect(http.StatusSeeOther, "/login")
 		return
 	}
 
-	c.Redirect(http.StatusSeeOther, "/users")
+	c.Redirect(http.StatusSeeOther, "/dashboard")
+}
+
+func (h *UserHandler) renderChangePassword(c *gin.Context, status int, user *models.User, message string) {
+	data := templ.ChangePasswordData{
+		Title:         "Change Password - Sysara",
+		Error:         message,
+		Required:      h.authService.PasswordChangeRequired(user),
+		PasswordRules: h.authService.Policy().Rules(),
+	}
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.ChangePassword(data).Render(c.Request.Context(), c.Writer)
+}
+
+// inactiveAccount reports whether err refuses a sign-in because the account
+// is disabled, suspended or expired
+func inactiveAccount(err error) bool {
+	return errors.Is(err, auth.ErrAccountDisabled) || errors.Is(err, auth.ErrAccountSuspended) || errors.Is(err, auth.ErrAccountExpired)
+}
+
+// accountDetail describes an account state change for the audit log
+func accountDetail(user *models.User, revokedKeys int64) string {
+	parts := []string{user.State()}
+	if user.SuspendedUntil != nil {
+		parts = append(parts, "until "+user.SuspendedUntil.Format(time.RFC3339))
+	}
+	if user.ExpiresAt != nil {
+		parts = append(parts, "expires "+user.ExpiresAt.Format(time.RFC3339))
+	}
+	if user.StatusReason != "" {
+		parts = append(parts, user.StatusReason)
+	}
+	if revokedKeys > 0 {
+		parts = append(parts, strconv.FormatInt(revokedKeys, 10)+" SSH keys revoked")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// parseLocalTime parses the value of a datetime-local input in the server's
+// time zone. An empty value is nil.
+func parseLocalTime(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// userRole normalizes a submitted role, defaulting to a regular user
+func userRole(role string) string {
+	if role == models.RoleAdmin {
+		return models.RoleAdmin
+	}
+	return models.RoleUser
 }
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRevokeSSHKeysKeepsTheKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sysara.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.SSHKey{}); err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Email: "user@example.com", Name: "User", Role: models.RoleUser, Status: models.UserDisabled}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"laptop", "desktop"} {
+		if err := db.Create(&models.SSHKey{Name: name, PublicKey: name, Fingerprint: name, UserID: user.ID}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &UserHandler{db: db}
+
+	revoke := func(form url.Values) int64 {
+		t.Helper()
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(form.Encode()))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		n, err := h.revokeSSHKeys(c, &user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if n := revoke(url.Values{}); n != 0 {
+		t.Fatalf("revoked %d keys without being asked", n)
+	}
+	if n := revoke(url.Values{"revoke_ssh_keys": {"1"}}); n != 2 {
+		t.Fatalf("revoked %d keys, want 2", n)
+	}
+	// Keys that are already revoked keep the time they were revoked at
+	if n := revoke(url.Values{"revoke_ssh_keys": {"1"}}); n != 0 {
+		t.Fatalf("revoked %d keys again", n)
+	}
+
+	var keys []models.SSHKey
+	if err := db.Find(&keys).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("%d keys left, want 2", len(keys))
+	}
+	for _, key := range keys {
+		if !key.IsRevoked() {
+			t.Errorf("key %s is not revoked", key.Name)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/alpemreelmas/sysara/internal/audit"
+	"github.com/alpemreelmas/sysara/internal/auth"
+	"github.com/alpemreelmas/sysara/internal/models"
+	"github.com/alpemreelmas/sysara/internal/webauthn"
+	templ "github.com/alpemreelmas/sysara/templ"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// securityKeyMessages are shown on the security keys page after an action
+var securityKeyMessages = map[string]string{
+	"added": "The security key was added. You will be asked for it when you sign in with your password.",
+}
+
+// identityProviderKeysMessage is shown to single sign-on users, whose second
+// factor is up to the identity provider
+const identityProviderKeysMessage = "You sign in with single sign-on, so your identity provider manages your second factor"
+
+// WebAuthnHandler manages security keys and signs users in with them
+type WebAuthnHandler struct {
+	authService *auth.AuthService
+	audit       *audit.Logger
+	rp          *webauthn.RelyingParty
+}
+
+// NewWebAuthnHandler creates a new security key handler
+func NewWebAuthnHandler(authService *auth.AuthService, auditLog *audit.Logger, rp *webauthn.RelyingParty) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		authService: authService,
+		audit:       auditLog,
+		rp:          rp,
+	}
+}
+
+// registrationRequest is posted to finish registering a security key
+type registrationRequest struct {
+	Name       string                       `json:"name"`
+	Credential webauthn.AttestationResponse `json:"credential"`
+}
+
+// ShowSecurityKeys lists the security keys of the current user
+func (h *WebAuthnHandler) ShowSecurityKeys(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	h.render(c, userModel, http.StatusOK, templ.SecurityKeysData{Success: securityKeyMessages[c.Query("done")]})
+}
+
+// BeginRegistration returns the options for registering a security key
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	if userModel.AuthProvider == models.AuthOIDC {
+		c.JSON(http.StatusForbidden, gin.H{"error": identityProviderKeysMessage})
+		return
+	}
+
+	options, err := h.authService.BeginWebAuthnRegistration(c, h.rp, userModel)
+	if err != nil {
+		log.Printf("webauthn: failed to start registration for %s: %v", userModel.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start registration"})
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishRegistration verifies and stores a new security key
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+	if userModel.AuthProvider == models.AuthOIDC {
+		c.JSON(http.StatusForbidden, gin.H{"error": identityProviderKeysMessage})
+		return
+	}
+
+	var req registrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	credential, err := h.authService.FinishWebAuthnRegistration(c, h.rp, userModel, req.Name, &req.Credential)
+	if err != nil {
+		status, message := webauthnError(err, "register the security key")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionWebAuthnAdd, userModel.Email, credential.Name, c.ClientIP())
+
+	c.JSON(http.StatusOK, gin.H{"redirect": "/security-keys?done=added"})
+}
+
+// DeleteSecurityKey removes a security key of the current user
+func (h *WebAuthnHandler) DeleteSecurityKey(c *gin.Context) {
+	currentUser, _ := c.Get("current_user")
+	userModel, ok := currentUser.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current user"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.render(c, userModel, http.StatusNotFound, templ.SecurityKeysData{Error: "Security key not found"})
+		return
+	}
+	credential, err := h.authService.DeleteWebAuthnCredential(userModel.ID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		h.render(c, userModel, http.StatusNotFound, templ.SecurityKeysData{Error: "Security key not found"})
+		return
+	}
+	if err != nil {
+		h.render(c, userModel, http.StatusInternalServerError, templ.SecurityKeysData{Error: "Failed to remove the security key"})
+		return
+	}
+	h.audit.Record(userModel, audit.ActionWebAuthnDelete, userModel.Email, credential.Name, c.ClientIP())
+
+	h.render(c, userModel, http.StatusOK, templ.SecurityKeysData{Success: "The security key " + credential.Name + " was removed"})
+}
+
+// ShowVerify asks a user who passed the password check for a security key
+func (h *WebAuthnHandler) ShowVerify(c *gin.Context) {
+	user, err := h.authService.PendingSecondFactor(c)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+
+	c.Header("Content-Type", "text/html")
+	c.Status(http.StatusOK)
+	templ.LoginVerify(templ.LoginVerifyData{Title: "Verify Sign-in - Sysara", Email: user.Email}).Render(c.Request.Context(), c.Writer)
+}
+
+// BeginVerify returns the options for confirming a password login
+func (h *WebAuthnHandler) BeginVerify(c *gin.Context) {
+	user, err := h.authService.PendingSecondFactor(c)
+	if err != nil {
+		status, message := webauthnError(err, "start the verification")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	h.beginLogin(c, user)
+}
+
+// FinishVerify checks the security key and completes a password login
+func (h *WebAuthnHandler) FinishVerify(c *gin.Context) {
+	user, err := h.authService.PendingSecondFactor(c)
+	if err != nil {
+		status, message := webauthnError(err, "verify the sign-in")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	h.finishLogin(c, user)
+}
+
+// BeginPasskey returns the options for a passwordless sign-in
+func (h *WebAuthnHandler) BeginPasskey(c *gin.Context) {
+	if !h.authService.LocalPasswords() {
+		c.JSON(http.StatusForbidden, gin.H{"error": auth.ErrPasswordLoginDisabled.Error()})
+		return
+	}
+	h.beginLogin(c, nil)
+}
+
+// FinishPasskey checks the passkey and signs its user in
+func (h *WebAuthnHandler) FinishPasskey(c *gin.Context) {
+	h.finishLogin(c, nil)
+}
+
+func (h *WebAuthnHandler) beginLogin(c *gin.Context, user *models.User) {
+	options, err := h.authService.BeginWebAuthnLogin(c, h.rp, user)
+	if err != nil {
+		status, message := webauthnError(err, "start the sign-in")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+func (h *WebAuthnHandler) finishLogin(c *gin.Context, user *models.User) {
+	var response webauthn.AssertionResponse
+	if err := c.ShouldBindJSON(&response); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	user, err := h.authService.FinishWebAuthnLogin(c, h.rp, user, &response)
+	if err != nil {
+		status, message := webauthnError(err, "sign in")
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+	if err := h.authService.Login(c, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redirect": "/dashboard"})
+}
+
+// render lists the security keys of user
+func (h *WebAuthnHandler) render(c *gin.Context, user *models.User, status int, data templ.SecurityKeysData) {
+	data.AuthData = templ.AuthData{
+		Title:       "Security Keys - Sysara",
+		PageTitle:   "Security Keys",
+		CurrentUser: *user,
+	}
+	if user.AuthProvider == models.AuthOIDC {
+		data.Managed = identityProviderKeysMessage
+	}
+	credentials, err := h.authService.WebAuthnCredentials(user.ID)
+	if err != nil {
+		data.Error = "Failed to load security keys"
+		status = http.StatusInternalServerError
+	}
+	data.Credentials = credentials
+
+	c.Header("Content-Type", "text/html")
+	c.Status(status)
+	templ.SecurityKeys(data).Render(c.Request.Context(), c.Writer)
+}
+
+// webauthnError returns the status and message for an error of a ceremony
+func webauthnError(err error, action string) (int, string) {
+	switch {
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return http.StatusTooManyRequests, err.Error()
+	case errors.Is(err, auth.ErrPasswordLoginDisabled), inactiveAccount(err):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, auth.ErrCredentialExists):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, auth.ErrWebAuthnState), errors.Is(err, auth.ErrUnknownCredential):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, webauthn.ErrInvalidResponse), errors.Is(err, webauthn.ErrUnsupportedKey):
+		log.Printf("webauthn: rejected response: %v", err)
+		return http.StatusBadRequest, "The security key response was invalid, please try again"
+	case errors.Is(err, webauthn.ErrSignCount):
+		log.Printf("webauthn: %v", err)
+		return http.StatusUnauthorized, "This security key may have been cloned and cannot be used, please contact an administrator"
+	}
+	log.Printf("webauthn: failed to %s: %v", action, err)
+	return http.StatusInternalServerError, "Failed to " + action
+}
@@ -0,0 +1,189 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxMessageSize caps the size of a message read from the server
+const maxMessageSize = 4 << 20
+
+// BER tag classes and the constructed bit
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+)
+
+// Universal tags used by LDAP
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+)
+
+// element is a decoded BER element. Constructed elements hold the encoded
+// children in data.
+type element struct {
+	tag  byte
+	data []byte
+}
+
+// children decodes the elements inside a constructed element
+func (e element) children() ([]element, error) {
+	var elements []element
+	data := e.data
+	for len(data) > 0 {
+		child, rest, err := decodeElement(data)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, child)
+		data = rest
+	}
+	return elements, nil
+}
+
+// int decodes an INTEGER or ENUMERATED value
+func (e element) int() (int, error) {
+	if len(e.data) == 0 || len(e.data) > 4 {
+		return 0, errors.New("ldap: invalid integer")
+	}
+	value := int(int8(e.data[0]))
+	for _, b := range e.data[1:] {
+		value = value<<8 | int(b)
+	}
+	return value, nil
+}
+
+func (e element) string() string {
+	return string(e.data)
+}
+
+// decodeElement decodes the first element of data and returns the rest
+func decodeElement(data []byte) (element, []byte, error) {
+	if len(data) < 2 {
+		return element{}, nil, errors.New("ldap: truncated element")
+	}
+	tag := data[0]
+	if tag&0x1f == 0x1f {
+		return element{}, nil, errors.New("ldap: multi-byte tags are not supported")
+	}
+	length, n, err := decodeLength(data[1:])
+	if err != nil {
+		return element{}, nil, err
+	}
+	start := 1 + n
+	if length > len(data)-start {
+		return element{}, nil, errors.New("ldap: truncated element")
+	}
+	return element{tag: tag, data: data[start : start+length]}, data[start+length:], nil
+}
+
+// decodeLength decodes a definite length and returns it and its size
+func decodeLength(data []byte) (int, int, error) {
+	if data[0] < 0x80 {
+		return int(data[0]), 1, nil
+	}
+	n := int(data[0] & 0x7f)
+	if n == 0 || n > 4 || len(data) < 1+n {
+		return 0, 0, errors.New("ldap: unsupported length")
+	}
+	length := 0
+	for _, b := range data[1 : 1+n] {
+		length = length<<8 | int(b)
+	}
+	if length < 0 || length > maxMessageSize {
+		return 0, 0, errors.New("ldap: message too large")
+	}
+	return length, 1 + n, nil
+}
+
+// readElement reads one complete element from r
+func readElement(r *bufio.Reader) (element, error) {
+	header := make([]byte, 2, 6)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return element{}, err
+	}
+	if header[1]&0x80 != 0 {
+		n := int(header[1] & 0x7f)
+		if n == 0 || n > 4 {
+			return element{}, errors.New("ldap: unsupported length")
+		}
+		extra := make([]byte, n)
+		if _, err := io.ReadFull(r, extra); err != nil {
+			return element{}, err
+		}
+		header = append(header, extra...)
+	}
+	length, _, err := decodeLength(header[1:])
+	if err != nil {
+		return element{}, err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return element{}, err
+	}
+	return element{tag: header[0], data: data}, nil
+}
+
+// encode returns the BER encoding of an element with the given content
+func encode(tag byte, content ...[]byte) []byte {
+	size := 0
+	for _, part := range content {
+		size += len(part)
+	}
+	out := []byte{tag}
+	switch {
+	case size < 0x80:
+		out = append(out, byte(size))
+	case size < 0x100:
+		out = append(out, 0x81, byte(size))
+	case size < 0x10000:
+		out = append(out, 0x82, byte(size>>8), byte(size))
+	default:
+		out = append(out, 0x84, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+	}
+	for _, part := range content {
+		out = append(out, part...)
+	}
+	return out
+}
+
+// encodeInt encodes an INTEGER or ENUMERATED value
+func encodeInt(tag byte, value int) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(value)}, content...)
+		value >>= 8
+		if (value == 0 && content[0]&0x80 == 0) || (value == -1 && content[0]&0x80 != 0) {
+			break
+		}
+	}
+	return encode(tag, content)
+}
+
+func encodeString(tag byte, value string) []byte {
+	return encode(tag, []byte(value))
+}
+
+func encodeBool(value bool) []byte {
+	if value {
+		return encode(tagBoolean, []byte{0xff})
+	}
+	return encode(tagBoolean, []byte{0x00})
+}
+
+// expect checks that an element has the given tag
+func expect(e element, tag byte, what string) error {
+	if e.tag != tag {
+		return fmt.Errorf("ldap: expected %s, got tag 0x%02x", what, e.tag)
+	}
+	return nil
+}
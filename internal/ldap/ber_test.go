@@ -0,0 +1,225 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestIntRoundTrip(t *testing.T) {
+	tests := []struct {
+		value int
+		want  []byte
+	}{
+		{0, []byte{0x02, 0x01, 0x00}},
+		{127, []byte{0x02, 0x01, 0x7f}},
+		{128, []byte{0x02, 0x02, 0x00, 0x80}},
+		{256, []byte{0x02, 0x02, 0x01, 0x00}},
+		{-1, []byte{0x02, 0x01, 0xff}},
+		{-128, []byte{0x02, 0x01, 0x80}},
+		{-129, []byte{0x02, 0x02, 0xff, 0x7f}},
+		{1<<31 - 1, []byte{0x02, 0x04, 0x7f, 0xff, 0xff, 0xff}},
+		{-1 << 31, []byte{0x02, 0x04, 0x80, 0x00, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		encoded := encodeInt(tagInteger, tt.value)
+		if !bytes.Equal(encoded, tt.want) {
+			t.Errorf("encodeInt(%d) = % x, want % x", tt.value, encoded, tt.want)
+		}
+		e, rest, err := decodeElement(encoded)
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("decodeElement(% x): rest % x, %v", encoded, rest, err)
+		}
+		if got, err := e.int(); err != nil || got != tt.value {
+			t.Errorf("int() of % x = %d, %v, want %d", encoded, got, err, tt.value)
+		}
+	}
+
+	if _, err := (element{tag: tagInteger}).int(); err == nil {
+		t.Error("an empty integer was decoded")
+	}
+	if _, err := (element{tag: tagInteger, data: []byte{1, 2, 3, 4, 5}}).int(); err == nil {
+		t.Error("a five byte integer was decoded")
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 0x7f, 0x80, 0xff, 0x100, 0xffff, 0x10000} {
+		value := strings.Repeat("x", size)
+		encoded := encodeString(tagOctetString, value)
+
+		// Short form below 0x80, then one, two and four length bytes
+		header := 2
+		switch {
+		case size >= 0x10000:
+			header = 6
+		case size >= 0x100:
+			header = 4
+		case size >= 0x80:
+			header = 3
+		}
+		if len(encoded) != header+size {
+			t.Errorf("size %d: encoded to %d bytes, want %d", size, len(encoded), header+size)
+		}
+
+		e, rest, err := decodeElement(encoded)
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("size %d: decodeElement: rest %d bytes, %v", size, len(rest), err)
+		}
+		if e.tag != tagOctetString || e.string() != value {
+			t.Errorf("size %d: decoded tag 0x%02x and %d bytes", size, e.tag, len(e.data))
+		}
+
+		// The same element read from a stream
+		e, err = readElement(bufio.NewReader(bytes.NewReader(encoded)))
+		if err != nil || e.string() != value {
+			t.Errorf("size %d: readElement: %d bytes, %v", size, len(e.data), err)
+		}
+	}
+}
+
+func TestSequenceRoundTrip(t *testing.T) {
+	encoded := encode(tagSequence,
+		encodeInt(tagInteger, 7),
+		encode(opBindRequest,
+			encodeInt(tagInteger, 3),
+			encodeString(tagOctetString, "cn=admin,dc=example,dc=com"),
+			encodeString(authSimple, "secret"),
+		),
+		encodeBool(true),
+		encodeBool(false),
+	)
+
+	message, rest, err := decodeElement(append(encoded, 0xaa))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rest, []byte{0xaa}) {
+		t.Errorf("rest = % x, want aa", rest)
+	}
+	parts, err := message.children()
+	if err != nil || len(parts) != 4 {
+		t.Fatalf("children: %d, %v", len(parts), err)
+	}
+	if id, _ := parts[0].int(); id != 7 {
+		t.Errorf("message ID = %d, want 7", id)
+	}
+	if err := expect(parts[1], opBindRequest, "bind request"); err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(parts[2].data, []byte{0xff}) || !bytes.Equal(parts[3].data, []byte{0x00}) {
+		t.Errorf("booleans = % x, % x", parts[2].data, parts[3].data)
+	}
+	fields, err := parts[1].children()
+	if err != nil || len(fields) != 3 {
+		t.Fatalf("bind fields: %d, %v", len(fields), err)
+	}
+	if fields[1].string() != "cn=admin,dc=example,dc=com" || fields[2].tag != authSimple || fields[2].string() != "secret" {
+		t.Errorf("bind fields = %+v", fields)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := map[string][]byte{
+		"empty":               {},
+		"tag only":            {0x04},
+		"short content":       {0x04, 0x05, 'a', 'b'},
+		"short long length":   {0x04, 0x82, 0x01},
+		"indefinite length":   {0x30, 0x80, 0x00, 0x00},
+		"five length bytes":   {0x04, 0x85, 0, 0, 0, 0, 1, 'a'},
+		"multi-byte tag":      {0x1f, 0x81, 0x00},
+		"larger than allowed": {0x04, 0x84, 0x7f, 0xff, 0xff, 0xff},
+		"child runs past end": encode(tagSequence, []byte{0x04, 0x09, 'a'}),
+	}
+	for name, data := range tests {
+		e, _, err := decodeElement(data)
+		if err == nil {
+			_, err = e.children()
+		}
+		if err == nil {
+			t.Errorf("%s: % x was decoded", name, data)
+		}
+	}
+}
+
+func TestReadElementTruncated(t *testing.T) {
+	encoded := encode(tagSequence, encodeInt(tagInteger, 1), encodeString(tagOctetString, strings.Repeat("x", 300)))
+	for _, n := range []int{1, 2, 3, len(encoded) - 1} {
+		_, err := readElement(bufio.NewReader(bytes.NewReader(encoded[:n])))
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			t.Errorf("%d of %d bytes: got %v, want EOF", n, len(encoded), err)
+		}
+	}
+
+	// Two messages in a row are read one at a time
+	r := bufio.NewReader(bytes.NewReader(append(encodeInt(tagInteger, 1), encodeInt(tagInteger, 2)...)))
+	for want := 1; want <= 2; want++ {
+		e, err := readElement(r)
+		if got, _ := e.int(); err != nil || got != want {
+			t.Errorf("message %d: got %d, %v", want, got, err)
+		}
+	}
+	if _, err := readElement(r); err != io.EOF {
+		t.Errorf("after the last message: got %v, want EOF", err)
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []byte
+	}{
+		{"uid=jdoe", encode(filterEquality, encodeString(tagOctetString, "uid"), encodeString(tagOctetString, "jdoe"))},
+		{"(mail=*)", encodeString(filterPresent, "mail")},
+		{"(uidNumber>=1000)", encode(filterGreaterOrEqual, encodeString(tagOctetString, "uidNumber"), encodeString(tagOctetString, "1000"))},
+		{"(uidNumber<=1000)", encode(filterLessOrEqual, encodeString(tagOctetString, "uidNumber"), encodeString(tagOctetString, "1000"))},
+		{"(cn~=jon)", encode(filterApprox, encodeString(tagOctetString, "cn"), encodeString(tagOctetString, "jon"))},
+		{"(cn=J*o*e)", encode(filterSubstrings, encodeString(tagOctetString, "cn"), encode(tagSequence,
+			encodeString(classContext|0, "J"), encodeString(classContext|1, "o"), encodeString(classContext|2, "e")))},
+		{"(cn=*doe)", encode(filterSubstrings, encodeString(tagOctetString, "cn"), encode(tagSequence,
+			encodeString(classContext|2, "doe")))},
+		{`(cn=a\2ab)`, encode(filterEquality, encodeString(tagOctetString, "cn"), encodeString(tagOctetString, "a*b"))},
+		{"(&(objectClass=person)(!(uid=root)))", encode(filterAnd,
+			encode(filterEquality, encodeString(tagOctetString, "objectClass"), encodeString(tagOctetString, "person")),
+			encode(filterNot, encode(filterEquality, encodeString(tagOctetString, "uid"), encodeString(tagOctetString, "root"))))},
+		{"(|(uid=a)(mail=b))", encode(filterOr,
+			encode(filterEquality, encodeString(tagOctetString, "uid"), encodeString(tagOctetString, "a")),
+			encode(filterEquality, encodeString(tagOctetString, "mail"), encodeString(tagOctetString, "b")))},
+	}
+	for _, tt := range tests {
+		got, err := compileFilter(tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.filter, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: % x, want % x", tt.filter, got, tt.want)
+		}
+	}
+
+	for _, filter := range []string{"", "()", "(uid)", "(=x)", "(&)", "(uid=a", "(uid=a))", "(&(uid=a)", `(cn=\zz)`, `(cn=a\2)`} {
+		if _, err := compileFilter(filter); err == nil {
+			t.Errorf("%q was compiled", filter)
+		}
+	}
+}
+
+func TestEscapeFilter(t *testing.T) {
+	for _, value := range []string{"jdoe", "*", "*)(uid=*", `a\b`, "x)(|(objectClass=*", "nul\x00byte", "ünïcode"} {
+		escaped := EscapeFilter(value)
+		if strings.ContainsAny(escaped, "*()\x00") {
+			t.Errorf("EscapeFilter(%q) = %q keeps special characters", value, escaped)
+		}
+		got, err := compileFilter("(uid=" + escaped + ")")
+		if err != nil {
+			t.Errorf("%q: %v", value, err)
+			continue
+		}
+		want := encode(filterEquality, encodeString(tagOctetString, "uid"), encodeString(tagOctetString, value))
+		if !bytes.Equal(got, want) {
+			t.Errorf("%q: escaped filter is not an equality match on the value", value)
+		}
+	}
+}
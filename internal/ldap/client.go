@@ -0,0 +1,351 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Protocol operation tags (RFC 4511 section 4.2 onwards)
+const (
+	opBindRequest       = classApplication | constructed | 0
+	opBindResponse      = classApplication | constructed | 1
+	opUnbindRequest     = classApplication | 2
+	opSearchRequest     = classApplication | constructed | 3
+	opSearchResultEntry = classApplication | constructed | 4
+	opSearchResultDone  = classApplication | constructed | 5
+	opSearchResultRef   = classApplication | constructed | 19
+	opExtendedRequest   = classApplication | constructed | 23
+	opExtendedResponse  = classApplication | constructed | 24
+
+	authSimple          = classContext | 0 // simple password in a bind request
+	extendedRequestName = classContext | 0
+
+	startTLSOID = "1.3.6.1.4.1.1466.20037"
+)
+
+// Result codes
+const (
+	resultSuccess           = 0
+	resultSizeLimitExceeded = 4
+	resultNoSuchObject      = 32
+	resultInvalidCredential = 49
+)
+
+// Search scopes
+const (
+	ScopeBase     = 0
+	ScopeOneLevel = 1
+	ScopeSubtree  = 2
+)
+
+// ErrInvalidCredentials is returned by Bind for a wrong DN or password
+var ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+
+// ResultError is an unsuccessful result returned by the server
+type ResultError struct {
+	Code    int
+	Message string
+}
+
+func (e *ResultError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.Code)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// Entry is an entry returned by a search
+type Entry struct {
+	DN         string
+	Attributes map[string][]string // keyed by lowercase attribute name
+}
+
+// Values returns the values of an attribute
+func (e Entry) Values(name string) []string {
+	return e.Attributes[strings.ToLower(name)]
+}
+
+// Value returns the first value of an attribute, or ""
+func (e Entry) Value(name string) string {
+	if values := e.Values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// SearchRequest describes a search
+type SearchRequest struct {
+	BaseDN     string
+	Scope      int
+	Filter     string
+	Attributes []string
+	SizeLimit  int
+}
+
+// Conn is a connection to an LDAP server. Requests are sent one at a time.
+type Conn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	host    string
+	nextID  int
+}
+
+// Dial connects to an ldap:// or ldaps:// URL. tlsConfig is used for
+// ldaps and StartTLS and may be nil.
+func Dial(ctx context.Context, rawURL string, timeout time.Duration, tlsConfig *tls.Config) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid URL: %w", err)
+	}
+	host := u.Hostname()
+	port := u.Port()
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: tlsFor(tlsConfig, host)}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: %w", err)
+	}
+	return &Conn{conn: conn, reader: bufio.NewReader(conn), timeout: timeout, host: host}, nil
+}
+
+// tlsFor returns a copy of config with the server name set
+func tlsFor(config *tls.Config, host string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	return config
+}
+
+// StartTLS upgrades the connection to TLS
+func (c *Conn) StartTLS(tlsConfig *tls.Config) error {
+	id, err := c.send(encode(opExtendedRequest, encodeString(extendedRequestName, startTLSOID)))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if err := expect(op, opExtendedResponse, "extended response"); err != nil {
+		return err
+	}
+	if err := result(op); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(c.conn, tlsFor(tlsConfig, c.host))
+	tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("ldap: StartTLS: %w", err)
+	}
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// Bind authenticates the connection with a simple bind. An empty password
+// is refused, as servers treat it as an anonymous bind that always succeeds.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return ErrInvalidCredentials
+	}
+	id, err := c.send(encode(opBindRequest,
+		encodeInt(tagInteger, 3),
+		encodeString(tagOctetString, dn),
+		encodeString(authSimple, password),
+	))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if err := expect(op, opBindResponse, "bind response"); err != nil {
+		return err
+	}
+	err = result(op)
+	var resultErr *ResultError
+	if errors.As(err, &resultErr) && resultErr.Code == resultInvalidCredential {
+		return ErrInvalidCredentials
+	}
+	return err
+}
+
+// Search returns the entries matching a search request
+func (c *Conn) Search(req SearchRequest) ([]Entry, error) {
+	filter, err := compileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	attributes := make([][]byte, 0, len(req.Attributes))
+	for _, attr := range req.Attributes {
+		attributes = append(attributes, encodeString(tagOctetString, attr))
+	}
+	id, err := c.send(encode(opSearchRequest,
+		encodeString(tagOctetString, req.BaseDN),
+		encodeInt(tagEnumerated, req.Scope),
+		encodeInt(tagEnumerated, 0), // never dereference aliases
+		encodeInt(tagInteger, req.SizeLimit),
+		encodeInt(tagInteger, int(c.timeout/time.Second)),
+		encodeBool(false),
+		filter,
+		encode(tagSequence, attributes...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case opSearchResultEntry:
+			entry, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case opSearchResultRef:
+			// Referrals to other servers are not followed
+		case opSearchResultDone:
+			err := result(op)
+			var resultErr *ResultError
+			if errors.As(err, &resultErr) && (resultErr.Code == resultNoSuchObject || resultErr.Code == resultSizeLimitExceeded) {
+				return entries, nil
+			}
+			return entries, err
+		default:
+			return nil, fmt.Errorf("ldap: unexpected response tag 0x%02x", op.tag)
+		}
+	}
+}
+
+// Close unbinds and closes the connection
+func (c *Conn) Close() error {
+	c.send(encode(opUnbindRequest))
+	return c.conn.Close()
+}
+
+// send writes a request and returns its message ID
+func (c *Conn) send(op []byte) (int, error) {
+	c.nextID++
+	message := encode(tagSequence, encodeInt(tagInteger, c.nextID), op)
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(message); err != nil {
+		return 0, fmt.Errorf("ldap: %w", err)
+	}
+	return c.nextID, nil
+}
+
+// receive reads the next response to message id and returns its operation
+func (c *Conn) receive(id int) (element, error) {
+	for {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+		message, err := readElement(c.reader)
+		if err != nil {
+			return element{}, fmt.Errorf("ldap: %w", err)
+		}
+		if err := expect(message, tagSequence, "message"); err != nil {
+			return element{}, err
+		}
+		parts, err := message.children()
+		if err != nil {
+			return element{}, err
+		}
+		if len(parts) < 2 {
+			return element{}, errors.New("ldap: malformed message")
+		}
+		messageID, err := parts[0].int()
+		if err != nil {
+			return element{}, err
+		}
+		if messageID == 0 {
+			// Unsolicited notification, the server is closing the connection
+			return element{}, errors.New("ldap: connection closed by server")
+		}
+		if messageID == id {
+			return parts[1], nil
+		}
+	}
+}
+
+// result returns the error of an LDAPResult, or nil on success
+func result(op element) error {
+	parts, err := op.children()
+	if err != nil {
+		return err
+	}
+	if len(parts) < 3 {
+		return errors.New("ldap: malformed result")
+	}
+	code, err := parts[0].int()
+	if err != nil {
+		return err
+	}
+	if code != resultSuccess {
+		return &ResultError{Code: code, Message: parts[2].string()}
+	}
+	return nil
+}
+
+// parseEntry decodes a SearchResultEntry
+func parseEntry(op element) (Entry, error) {
+	parts, err := op.children()
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(parts) < 2 {
+		return Entry{}, errors.New("ldap: malformed entry")
+	}
+	entry := Entry{DN: parts[0].string(), Attributes: map[string][]string{}}
+	attributes, err := parts[1].children()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, attribute := range attributes {
+		fields, err := attribute.children()
+		if err != nil || len(fields) < 2 {
+			return Entry{}, errors.New("ldap: malformed attribute")
+		}
+		values, err := fields[1].children()
+		if err != nil {
+			return Entry{}, err
+		}
+		name := strings.ToLower(fields[0].string())
+		for _, value := range values {
+			entry.Attributes[name] = append(entry.Attributes[name], value.string())
+		}
+	}
+	return entry, nil
+}
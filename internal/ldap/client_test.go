@@ -0,0 +1,209 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testEntries is a small directory with a service account and two people
+var testEntries = []testEntry{
+	{dn: "cn=sysara,ou=services,dc=example,dc=com", password: "service-secret"},
+	{
+		dn:       "uid=jdoe,ou=people,dc=example,dc=com",
+		password: "jdoe-secret",
+		attributes: map[string][]string{
+			"objectclass": {"person", "inetOrgPerson"},
+			"uid":         {"jdoe"},
+			"mail":        {"jane@example.com"},
+			"cn":          {"Jane Doe"},
+			"memberof":    {"cn=ops,ou=groups,dc=example,dc=com", "cn=admins,ou=groups,dc=example,dc=com"},
+		},
+	},
+	{
+		dn:       "uid=jsmith,ou=people,dc=example,dc=com",
+		password: "jsmith-secret",
+		attributes: map[string][]string{
+			"objectclass": {"person", "inetOrgPerson"},
+			"uid":         {"jsmith"},
+			"mail":        {"john@example.com"},
+			"cn":          {"John Smith"},
+		},
+	},
+}
+
+// dial connects to a test directory
+func dial(t *testing.T, d *testDirectory) *Conn {
+	t.Helper()
+	conn, err := Dial(context.Background(), d.URL(), 5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestBind(t *testing.T) {
+	d := newTestDirectory(t, testEntries...)
+	conn := dial(t, d)
+
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "jdoe-secret"); err != nil {
+		t.Errorf("valid bind: %v", err)
+	}
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+	if err := conn.Bind("uid=nobody,ou=people,dc=example,dc=com", "jdoe-secret"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("unknown DN: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestBindRejectsAnonymous(t *testing.T) {
+	d := newTestDirectory(t, testEntries...)
+	conn := dial(t, d)
+
+	// The directory accepts an unauthenticated bind for any DN, so an empty
+	// password must never reach it
+	for _, dn := range []string{"uid=jdoe,ou=people,dc=example,dc=com", ""} {
+		if err := conn.Bind(dn, ""); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("empty password for %q: got %v, want ErrInvalidCredentials", dn, err)
+		}
+	}
+	if binds, _ := d.requests(); len(binds) != 0 {
+		t.Errorf("anonymous binds were sent: %q", binds)
+	}
+
+	// The connection is still usable afterwards
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "jdoe-secret"); err != nil {
+		t.Errorf("bind after a refused anonymous bind: %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	d := newTestDirectory(t, testEntries...)
+	conn := dial(t, d)
+	if err := conn.Bind("cn=sysara,ou=services,dc=example,dc=com", "service-secret"); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+
+	entries, err := conn.Search(SearchRequest{
+		BaseDN:     "ou=people,dc=example,dc=com",
+		Scope:      ScopeSubtree,
+		Filter:     "(&(objectClass=person)(|(uid=jdoe)(mail=jdoe)))",
+		Attributes: []string{"mail", "cn", "memberOf"},
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.DN != "uid=jdoe,ou=people,dc=example,dc=com" || entry.Value("mail") != "jane@example.com" || entry.Value("CN") != "Jane Doe" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if groups := entry.Values("memberof"); len(groups) != 2 || groups[1] != "cn=admins,ou=groups,dc=example,dc=com" {
+		t.Errorf("memberOf = %q", groups)
+	}
+	if entry.Value("uid") != "" {
+		t.Errorf("an attribute that was not requested was returned")
+	}
+
+	// Substrings and presence
+	entries, err = conn.Search(SearchRequest{BaseDN: "dc=example,dc=com", Scope: ScopeSubtree, Filter: "(&(mail=*)(cn=J*n*th))", Attributes: []string{"uid"}})
+	if err != nil || len(entries) != 1 || entries[0].Value("uid") != "jsmith" {
+		t.Errorf("substring search: %+v, %v", entries, err)
+	}
+
+	// A base that does not exist finds nothing rather than failing
+	entries, err = conn.Search(SearchRequest{BaseDN: "ou=nowhere,dc=example,dc=org", Scope: ScopeSubtree, Filter: "(uid=jdoe)"})
+	if err != nil || len(entries) != 0 {
+		t.Errorf("missing base: %+v, %v", entries, err)
+	}
+
+	if _, searches := d.requests(); len(searches) != 3 {
+		t.Errorf("%d searches received, want 3", len(searches))
+	}
+}
+
+func TestSearchSizeLimit(t *testing.T) {
+	d := newTestDirectory(t, testEntries...)
+	conn := dial(t, d)
+	if err := conn.Bind("cn=sysara,ou=services,dc=example,dc=com", "service-secret"); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+
+	// The entries found before the limit are returned
+	entries, err := conn.Search(SearchRequest{BaseDN: "dc=example,dc=com", Scope: ScopeSubtree, Filter: "(objectClass=person)", SizeLimit: 1})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("got %d entries, %v, want 1", len(entries), err)
+	}
+}
+
+func TestSearchEscapedInput(t *testing.T) {
+	d := newTestDirectory(t, testEntries...)
+	conn := dial(t, d)
+	if err := conn.Bind("cn=sysara,ou=services,dc=example,dc=com", "service-secret"); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+
+	// Sign-in names that would match every entry if they were not escaped
+	for _, login := range []string{"*", "j*", "*)(uid=*", "jdoe)(|(uid=*"} {
+		entries, err := conn.Search(SearchRequest{
+			BaseDN: "dc=example,dc=com",
+			Scope:  ScopeSubtree,
+			Filter: "(|(uid=" + EscapeFilter(login) + ")(mail=" + EscapeFilter(login) + "))",
+		})
+		if err != nil {
+			t.Errorf("%q: %v", login, err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("%q matched %d entries", login, len(entries))
+		}
+	}
+}
+
+func TestSearchAnonymousAccess(t *testing.T) {
+	d := newTestDirectory(t, testEntries...)
+	conn := dial(t, d)
+
+	// A directory that refuses anonymous searches answers with a result error
+	_, err := conn.Search(SearchRequest{BaseDN: "dc=example,dc=com", Scope: ScopeSubtree, Filter: "(uid=jdoe)"})
+	var resultErr *ResultError
+	if !errors.As(err, &resultErr) || resultErr.Code != resultInsufficientAccess {
+		t.Errorf("anonymous search: got %v, want result code %d", err, resultInsufficientAccess)
+	}
+
+	// A refused anonymous bind does not unlock searches either
+	conn.Bind("", "")
+	if _, err := conn.Search(SearchRequest{BaseDN: "dc=example,dc=com", Scope: ScopeSubtree, Filter: "(uid=jdoe)"}); !errors.As(err, &resultErr) {
+		t.Errorf("search after an anonymous bind: got %v, want a result error", err)
+	}
+
+	d.mu.Lock()
+	d.anonymous = true
+	d.mu.Unlock()
+	conn = dial(t, d)
+	entries, err := conn.Search(SearchRequest{BaseDN: "dc=example,dc=com", Scope: ScopeSubtree, Filter: "(uid=jdoe)"})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("search of an open directory: %d entries, %v", len(entries), err)
+	}
+}
+
+func TestSearchInvalidFilter(t *testing.T) {
+	d := newTestDirectory(t, testEntries...)
+	conn := dial(t, d)
+	if _, err := conn.Search(SearchRequest{BaseDN: "dc=example,dc=com", Filter: "(uid=jdoe"}); err == nil {
+		t.Error("an invalid filter was sent")
+	}
+	if _, searches := d.requests(); len(searches) != 0 {
+		t.Errorf("%d searches received for an invalid filter", len(searches))
+	}
+}
+
+func TestDialUnsupportedScheme(t *testing.T) {
+	if _, err := Dial(context.Background(), "http://127.0.0.1:389", time.Second, nil); err == nil {
+		t.Error("an http URL was dialed")
+	}
+}
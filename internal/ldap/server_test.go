@@ -0,0 +1,293 @@
+package ldap
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Result codes sent by the test directory only
+const (
+	resultOperationsError    = 1
+	resultProtocolError      = 2
+	resultInsufficientAccess = 50
+	resultUnwillingToPerform = 53
+)
+
+// testEntry is an entry of the test directory
+type testEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// testDirectory is an in-process LDAP server. It checks simple binds,
+// evaluates search filters against its entries and records the requests
+// it receives.
+type testDirectory struct {
+	entries  []testEntry
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu        sync.Mutex
+	anonymous bool     // allow searches without a bind, like most servers do
+	binds     []string // DNs of the bind requests received
+	searches  []string // base DNs of the search requests received
+}
+
+// newTestDirectory starts a directory on a loopback port
+func newTestDirectory(t *testing.T, entries ...testEntry) *testDirectory {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &testDirectory{entries: entries, listener: listener}
+	d.wg.Add(1)
+	go d.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		d.wg.Wait()
+	})
+	return d
+}
+
+// URL returns the ldap:// URL of the directory
+func (d *testDirectory) URL() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+// requests returns the bind DNs and search bases received so far
+func (d *testDirectory) requests() (binds, searches []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.binds...), append([]string(nil), d.searches...)
+}
+
+func (d *testDirectory) serve() {
+	defer d.wg.Done()
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.handle(conn)
+		}()
+	}
+}
+
+// handle answers the requests of one connection until it is unbound
+func (d *testDirectory) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	r := bufio.NewReader(conn)
+	bound := ""
+	reply := func(id int, op []byte) {
+		conn.Write(encode(tagSequence, encodeInt(tagInteger, id), op))
+	}
+	done := func(tag byte, code int, message string) []byte {
+		return encode(tag,
+			encodeInt(tagEnumerated, code),
+			encodeString(tagOctetString, ""),
+			encodeString(tagOctetString, message),
+		)
+	}
+
+	for {
+		message, err := readElement(r)
+		if err != nil {
+			return
+		}
+		parts, err := message.children()
+		if err != nil || len(parts) < 2 {
+			return
+		}
+		id, err := parts[0].int()
+		if err != nil {
+			return
+		}
+		op := parts[1]
+
+		switch op.tag {
+		case opBindRequest:
+			fields, err := op.children()
+			if err != nil || len(fields) < 3 || fields[2].tag != authSimple {
+				reply(id, done(opBindResponse, resultProtocolError, "simple binds only"))
+				continue
+			}
+			dn, password := fields[1].string(), fields[2].string()
+			d.mu.Lock()
+			d.binds = append(d.binds, dn)
+			d.mu.Unlock()
+			if password == "" {
+				// An unauthenticated bind (RFC 4513 section 5.1.2) succeeds
+				// for any DN, which is why clients must not send one to
+				// check a password
+				bound = ""
+				reply(id, done(opBindResponse, resultSuccess, ""))
+				continue
+			}
+			code := resultInvalidCredential
+			for _, entry := range d.entries {
+				if strings.EqualFold(entry.dn, dn) && entry.password != "" && entry.password == password {
+					code, bound = resultSuccess, entry.dn
+				}
+			}
+			reply(id, done(opBindResponse, code, ""))
+
+		case opSearchRequest:
+			fields, err := op.children()
+			if err != nil || len(fields) < 8 {
+				reply(id, done(opSearchResultDone, resultProtocolError, "malformed search"))
+				continue
+			}
+			base := fields[0].string()
+			sizeLimit, _ := fields[3].int()
+			d.mu.Lock()
+			d.searches = append(d.searches, base)
+			anonymous := d.anonymous
+			d.mu.Unlock()
+			if bound == "" && !anonymous {
+				reply(id, done(opSearchResultDone, resultInsufficientAccess, "anonymous access denied"))
+				continue
+			}
+			wanted, _ := fields[7].children()
+
+			found, matched := false, 0
+			code := resultSuccess
+			for _, entry := range d.entries {
+				if !strings.HasSuffix(strings.ToLower(entry.dn), strings.ToLower(base)) {
+					continue
+				}
+				found = true
+				ok, err := matchFilter(fields[6], entry)
+				if err != nil {
+					code = resultOperationsError
+					break
+				}
+				if !ok {
+					continue
+				}
+				if sizeLimit > 0 && matched == sizeLimit {
+					code = resultSizeLimitExceeded
+					break
+				}
+				matched++
+				reply(id, encodeEntry(entry, wanted))
+			}
+			if !found {
+				code = resultNoSuchObject
+			}
+			reply(id, done(opSearchResultDone, code, ""))
+
+		case opUnbindRequest:
+			return
+
+		default:
+			reply(id, done(opExtendedResponse, resultUnwillingToPerform, "unsupported operation"))
+		}
+	}
+}
+
+// encodeEntry encodes a SearchResultEntry with the requested attributes
+func encodeEntry(entry testEntry, wanted []element) []byte {
+	var attributes [][]byte
+	for _, name := range wanted {
+		values, ok := entry.attributes[strings.ToLower(name.string())]
+		if !ok {
+			continue
+		}
+		var encoded [][]byte
+		for _, value := range values {
+			encoded = append(encoded, encodeString(tagOctetString, value))
+		}
+		attributes = append(attributes, encode(tagSequence,
+			encodeString(tagOctetString, name.string()),
+			encode(tagSet, encoded...),
+		))
+	}
+	return encode(opSearchResultEntry,
+		encodeString(tagOctetString, entry.dn),
+		encode(tagSequence, attributes...),
+	)
+}
+
+// matchFilter evaluates an encoded filter against an entry. Ordering and
+// approximate matches are not supported.
+func matchFilter(filter element, entry testEntry) (bool, error) {
+	if filter.tag == filterPresent {
+		_, ok := entry.attributes[strings.ToLower(filter.string())]
+		return ok, nil
+	}
+	parts, err := filter.children()
+	if err != nil {
+		return false, err
+	}
+	switch filter.tag {
+	case filterAnd, filterOr:
+		for _, part := range parts {
+			ok, err := matchFilter(part, entry)
+			if err != nil {
+				return false, err
+			}
+			if ok == (filter.tag == filterOr) {
+				return ok, nil
+			}
+		}
+		return filter.tag == filterAnd, nil
+	case filterNot:
+		ok, err := matchFilter(parts[0], entry)
+		return !ok, err
+	case filterEquality:
+		for _, value := range entry.attributes[strings.ToLower(parts[0].string())] {
+			if strings.EqualFold(value, parts[1].string()) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case filterSubstrings:
+		subs, err := parts[1].children()
+		if err != nil {
+			return false, err
+		}
+		for _, value := range entry.attributes[strings.ToLower(parts[0].string())] {
+			if matchSubstrings(strings.ToLower(value), subs) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unsupported filter tag 0x%02x", filter.tag)
+}
+
+// matchSubstrings matches a value against initial, any and final parts
+func matchSubstrings(value string, subs []element) bool {
+	for _, sub := range subs {
+		part := strings.ToLower(sub.string())
+		switch sub.tag {
+		case classContext | 0:
+			if !strings.HasPrefix(value, part) {
+				return false
+			}
+			value = value[len(part):]
+		case classContext | 1:
+			i := strings.Index(value, part)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(part):]
+		case classContext | 2:
+			if !strings.HasSuffix(value, part) {
+				return false
+			}
+		}
+	}
+	return true
+}
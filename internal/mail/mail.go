@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer delivers plain text email
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer sends email through an SMTP server. STARTTLS is used when
+// the server offers it.
+type SMTPMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates a mailer for the given server
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Send delivers a message to a single recipient
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return smtp.SendMail(m.addr, auth, m.from, []string{to}, message(m.from, to, subject, body))
+}
+
+// LogMailer writes messages to the server log instead of sending them.
+// It stands in for a mail server during local development. Bodies carry
+// password reset and invitation links, so only the recipient and subject
+// are logged unless ShowBody is set.
+type LogMailer struct {
+	ShowBody bool
+}
+
+// Send logs the message
+func (m LogMailer) Send(to, subject, body string) error {
+	if !m.ShowBody {
+		log.Printf("mail: SMTP is not configured, message to %s not sent: %s", to, subject)
+		return nil
+	}
+	log.Printf("mail: SMTP is not configured, message to %s:\nSubject: %s\n\n%s", to, subject, body)
+	return nil
+}
+
+// New returns an SMTP mailer, or a LogMailer if no host is configured.
+// logBody makes the LogMailer write message bodies, for development only.
+func New(host string, port int, username, password, from string, logBody bool) Mailer {
+	if host == "" {
+		return LogMailer{ShowBody: logBody}
+	}
+	return NewSMTPMailer(host, port, username, password, from)
+}
+
+// message builds an RFC 5322 message with CRLF line endings
+func message(from, to, subject, body string) []byte {
+	var b strings.Builder
+	header := func(name, value string) {
+		// Line breaks in header values would start new headers
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", to)
+	header("Subject", subject)
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
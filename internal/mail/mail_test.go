@@ -0,0 +1,118 @@
+package mail
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/mail/mailtest"
+)
+
+// newServer starts an SMTP stand-in that is closed with the test
+func newServer(t *testing.T) *mailtest.Server {
+	t.Helper()
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newServer(t)
+	mailer := NewSMTPMailer(server.Host(), server.Port(), "sysara", "secret", "sysara@example.com")
+
+	body := "Hello,\n\nOpen http://localhost/reset-password?token=abc\n.\nBye\n"
+	if err := mailer.Send("user@example.com", "Reset your Sysara password", body); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.From != "sysara@example.com" || len(msg.To) != 1 || msg.To[0] != "user@example.com" {
+		t.Errorf("envelope from %q to %v", msg.From, msg.To)
+	}
+	if msg.Username != "sysara" {
+		t.Errorf("authenticated as %q, want sysara", msg.Username)
+	}
+
+	headers, content, ok := strings.Cut(msg.Data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message has no header separator: %q", msg.Data)
+	}
+	for _, want := range []string{"From: sysara@example.com", "To: user@example.com", "Subject: Reset your Sysara password", "Content-Type: text/plain; charset=utf-8"} {
+		if !strings.Contains(headers+"\r\n", want+"\r\n") {
+			t.Errorf("headers %q miss %q", headers, want)
+		}
+	}
+	if want := strings.ReplaceAll(body, "\n", "\r\n"); content != want {
+		t.Errorf("body = %q, want %q", content, want)
+	}
+}
+
+func TestSMTPMailerWithoutAuth(t *testing.T) {
+	server := newServer(t)
+	mailer := New(server.Host(), server.Port(), "", "", "sysara@example.com", false)
+
+	if err := mailer.Send("user@example.com", "Hello", "Hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 || messages[0].Username != "" {
+		t.Errorf("messages = %+v, want one without authentication", messages)
+	}
+}
+
+func TestMessageHeaderInjection(t *testing.T) {
+	msg := string(message("sysara@example.com", "user@example.com", "Hi\r\nBcc: attacker@example.com", "Body"))
+	headers, _, _ := strings.Cut(msg, "\r\n\r\n")
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Fatalf("subject started a new header: %q", headers)
+		}
+	}
+	if !strings.Contains(headers, "Subject: HiBcc: attacker@example.com") {
+		t.Errorf("headers = %q", headers)
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var out bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&out)
+
+	body := "Open http://localhost/reset-password?token=secret-token"
+	if err := New("", 0, "", "", "", false).Send("user@example.com", "Reset your Sysara password", body); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	logged := out.String()
+	if !strings.Contains(logged, "user@example.com") || !strings.Contains(logged, "Reset your Sysara password") {
+		t.Errorf("log %q misses the recipient or subject", logged)
+	}
+	if strings.Contains(logged, "secret-token") {
+		t.Errorf("log %q contains the body", logged)
+	}
+
+	out.Reset()
+	if err := New("", 0, "", "", "", true).Send("user@example.com", "Reset your Sysara password", body); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !strings.Contains(out.String(), "secret-token") {
+		t.Errorf("log %q misses the body although ShowBody is set", out.String())
+	}
+}
+
+func TestServerWait(t *testing.T) {
+	server := newServer(t)
+	mailer := NewSMTPMailer(server.Host(), server.Port(), "", "", "sysara@example.com")
+	go mailer.Send("user@example.com", "Later", "Body")
+	if messages := server.Wait(1, 5*time.Second); len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+}
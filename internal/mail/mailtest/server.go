@@ -0,0 +1,202 @@
+// Package mailtest provides a local SMTP server that records the messages
+// it receives, standing in for a real mail server in tests
+package mailtest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is an email received by the server
+type Message struct {
+	From     string
+	To       []string
+	Data     string // headers and body with CRLF line endings, dot-unstuffed
+	Username string // from AUTH PLAIN, "" without authentication
+}
+
+// Server is an SMTP server on a loopback port. It speaks enough SMTP for
+// net/smtp: EHLO, AUTH PLAIN, MAIL, RCPT, DATA, RSET, NOOP and QUIT.
+// STARTTLS is not offered.
+type Server struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []Message
+	received chan struct{}
+}
+
+// NewServer starts a server on 127.0.0.1 with a random port
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: listener, received: make(chan struct{}, 100)}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Host returns the host to connect to
+func (s *Server) Host() string {
+	return "127.0.0.1"
+}
+
+// Port returns the port the server listens on
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Addr returns the host:port address of the server
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.Host(), strconv.Itoa(s.Port()))
+}
+
+// Messages returns the messages received so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Wait waits until n messages were received or timeout passed, and
+// returns the messages received so far
+func (s *Server) Wait(n int, timeout time.Duration) []Message {
+	deadline := time.After(timeout)
+	for {
+		if messages := s.Messages(); len(messages) >= n {
+			return messages
+		}
+		select {
+		case <-s.received:
+		case <-deadline:
+			return s.Messages()
+		}
+	}
+}
+
+// Close stops the server and waits for open connections to finish
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle runs one SMTP session
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for i, line := range lines {
+			sep := " "
+			if i < len(lines)-1 {
+				sep = "-"
+			}
+			conn.Write([]byte(line[:3] + sep + line[4:] + "\r\n"))
+		}
+	}
+
+	var msg Message
+	reply("220 mailtest ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250 mailtest", "250 AUTH PLAIN", "250 8BITMIME")
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			if !strings.EqualFold(mechanism, "PLAIN") {
+				reply("504 unsupported mechanism")
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(decoded), "\x00")
+			if err != nil || len(parts) != 3 {
+				reply("501 malformed credentials")
+				continue
+			}
+			msg.Username = parts[1]
+			reply("235 authenticated")
+		case "MAIL":
+			msg.From = address(arg)
+			msg.To = nil
+			reply("250 ok")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			reply("250 ok")
+		case "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			select {
+			case s.received <- struct{}{}:
+			default:
+			}
+			msg = Message{Username: msg.Username}
+			reply("250 queued")
+		case "RSET":
+			msg = Message{Username: msg.Username}
+			reply("250 ok")
+		case "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// address returns the address of a "FROM:<a@b>" or "TO:<a@b>" argument
+func address(arg string) string {
+	if start := strings.Index(arg, "<"); start >= 0 {
+		if end := strings.Index(arg[start:], ">"); end > 0 {
+			return arg[start+1 : start+end]
+		}
+	}
+	_, value, _ := strings.Cut(arg, ":")
+	return strings.TrimSpace(value)
+}
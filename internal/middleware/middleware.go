@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/alpemreelmas/sysara/internal/auth"
+	"github.com/alpemreelmas/sysara/internal/models"
+	templ "github.com/alpemreelmas/sysara/templ"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
 )
@@ -17,15 +20,29 @@ func SessionMiddleware(store sessions.Store) gin.HandlerFunc {
 	})
 }
 
-// CORSMiddleware handles CORS headers
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware allows cross-origin requests from the given origins only.
+// Requests from other origins get no CORS headers, so browsers block them.
+func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
+
 	return gin.HandlerFunc(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		origin := c.GetHeader("Origin")
+		c.Writer.Header().Add("Vary", "Origin")
+		if origin != "" && allowed[origin] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		}
 
 		if c.Request.Method == "OPTIONS" {
+			if origin != "" && !allowed[origin] {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
 			c.AbortWithStatus(204)
 			return
 		}
@@ -34,10 +51,71 @@ func CORSMiddleware() gin.HandlerFunc {
 	})
 }
 
+// CSRFMiddleware makes the visitor's CSRF token available to templates and
+// rejects state-changing requests that do not carry it in the csrf_token
+// form field or the X-CSRF-Token header. Requests with an API token are
+// exempt: browsers never add the Authorization header on their own.
+func CSRFMiddleware(authService *auth.AuthService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if auth.BearerToken(c.Request) != "" {
+			c.Next()
+			return
+		}
+
+		token, err := authService.CSRFToken(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load session"})
+			return
+		}
+		c.Set("csrf_token", token)
+		c.Request = c.Request.WithContext(templ.WithCSRFToken(c.Request.Context(), token))
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		submitted := c.GetHeader(templ.CSRFHeaderName)
+		if submitted == "" {
+			submitted = c.PostForm(templ.CSRFFieldName)
+		}
+		if !auth.ValidCSRFToken(token, submitted) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid or missing CSRF token, reload the page and try again"})
+			return
+		}
+		c.Next()
+	})
+}
+
+// SetupMiddleware sends every request to the setup wizard until the first
+// administrator exists
+func SetupMiddleware(authService *auth.AuthService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if c.Request.URL.Path == "/setup" || !authService.NeedsSetup() {
+			c.Next()
+			return
+		}
+		if c.GetHeader("HX-Request") == "true" {
+			c.Header("HX-Redirect", "/setup")
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/setup")
+		c.Abort()
+	})
+}
+
 // AuthMiddleware checks if user is authenticated
 func AuthMiddleware(authService *auth.AuthService) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
-		if !authService.IsAuthenticated(c) {
+		user, err := authService.GetCurrentUser(c)
+		if err != nil {
+			// API clients get an error instead of the login page
+			if auth.BearerToken(c.Request) != "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API token"})
+				return
+			}
 			// For HTMX requests, return 401 to trigger client-side redirect
 			if c.GetHeader("HX-Request") == "true" {
 				c.Header("HX-Redirect", "/login")
@@ -51,19 +129,60 @@ func AuthMiddleware(authService *auth.AuthService) gin.HandlerFunc {
 		}
 		
 		// Add current user to context
-		user, err := authService.GetCurrentUser(c)
-		if err != nil {
-			if c.GetHeader("HX-Request") == "true" {
-				c.Header("HX-Redirect", "/login")
-				c.AbortWithStatus(http.StatusUnauthorized)
-				return
-			}
-			c.Redirect(http.StatusSeeOther, "/login")
-			c.Abort()
+		c.Set("current_user", user)
+		c.Next()
+	})
+}
+
+// RequirePasswordChange sends users whose password expired or must be
+// changed to the change password page. It must run after AuthMiddleware.
+func RequirePasswordChange(authService *auth.AuthService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		currentUser, _ := c.Get("current_user")
+		user, ok := currentUser.(*models.User)
+		if !ok || !authService.PasswordChangeRequired(user) {
+			c.Next()
+			return
+		}
+		switch c.Request.URL.Path {
+		case "/password", "/logout":
+			c.Next()
+			return
+		}
+		if auth.BearerToken(c.Request) != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Password change required, sign in to choose a new password"})
+			return
+		}
+		if c.GetHeader("HX-Request") == "true" {
+			c.Header("HX-Redirect", "/password")
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/password")
+		c.Abort()
+	})
+}
+
+// RequireSession refuses API tokens on routes that manage the account
+// itself, such as passwords and tokens. It must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if auth.BearerToken(c.Request) != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Sign in to manage your account, API tokens cannot be used here"})
+			return
+		}
+		c.Next()
+	})
+}
+
+// RequireAdmin restricts a route group to admins. It must run after AuthMiddleware.
+func RequireAdmin() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		currentUser, _ := c.Get("current_user")
+		if user, ok := currentUser.(*models.User); !ok || !user.IsAdmin() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
 			return
 		}
-		
-		c.Set("current_user", user)
 		c.Next()
 	})
 }
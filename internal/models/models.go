@@ -2,6 +2,8 @@ package models
 
 import (
 	"golang.org/x/crypto/bcrypt"
+	"log"
+	"os"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -10,26 +12,253 @@ import (
 
 var DB *gorm.DB
 
+// User roles
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Authentication providers of a user
+const (
+	AuthLocal = "local" // password stored by Sysara
+	AuthOIDC  = "oidc"  // OpenID Connect single sign-on
+	AuthLDAP  = "ldap"  // LDAP or Active Directory
+)
+
+// Account states of a user. UserExpired is never stored; State reports it
+// once the expiry date of an account passed.
+const (
+	UserActive    = "active"
+	UserDisabled  = "disabled"  // blocked until an administrator enables the account
+	UserSuspended = "suspended" // blocked until SuspendedUntil, or until enabled when it is nil
+	UserDeleted   = "deleted"   // removed, but kept for ownership and audit history
+	UserExpired   = "expired"
+)
+
+// DefaultAdminEmail is the account old installs were seeded with. While it
+// still has the default password it is disabled with DefaultAdminReason.
+const (
+	DefaultAdminEmail  = "admin@admin"
+	DefaultAdminReason = "Default password of an old install; claim the account with the setup token printed at startup"
+)
+
 // User represents a user in the system
 type User struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	Email              string     `gorm:"uniqueIndex;not null" json:"email" binding:"required,email"`
+	Name               string     `gorm:"not null" json:"name" binding:"required"`
+	Password           string     `gorm:"not null" json:"-"` // Hidden from JSON output
+	Role               string     `gorm:"not null;default:user" json:"role"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at"`                     // sessions created earlier are invalid
+	FailedLogins       int        `gorm:"not null;default:0" json:"failed_logins"` // consecutive failed logins
+	LastFailedLoginAt  *time.Time `json:"last_failed_login_at"`
+	LockedUntil        *time.Time `json:"locked_until"`
+	MustChangePassword bool       `gorm:"not null;default:false" json:"must_change_password"` // forced change at next login
+	AuthProvider       string     `gorm:"not null;default:local" json:"auth_provider"`
+	ExternalID         string     `gorm:"index" json:"external_id"` // subject at the identity provider
+	Status             string     `gorm:"not null;default:active;index" json:"status"`
+	StatusReason       string     `json:"status_reason"` // why an administrator changed the status
+	StatusChangedAt    *time.Time `json:"status_changed_at"`
+	SuspendedUntil     *time.Time `json:"suspended_until"` // end of a suspension, nil until enabled again
+	ExpiresAt          *time.Time `json:"expires_at"`      // the account stops working at this time
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// IsExternal reports whether the user signs in with an identity provider
+// instead of a Sysara password
+func (u User) IsExternal() bool {
+	return u.AuthProvider != "" && u.AuthProvider != AuthLocal
+}
+
+// State returns the effective account state: the stored status, with
+// finished suspensions counted as active and UserExpired after ExpiresAt
+func (u User) State() string {
+	switch u.Status {
+	case UserDisabled, UserDeleted:
+		return u.Status
+	case UserSuspended:
+		if u.SuspendedUntil == nil || u.SuspendedUntil.After(time.Now()) {
+			return UserSuspended
+		}
+	}
+	if u.ExpiresAt != nil && !u.ExpiresAt.After(time.Now()) {
+		return UserExpired
+	}
+	return UserActive
+}
+
+// IsActive reports whether the user may sign in and use Sysara
+func (u User) IsActive() bool {
+	return u.State() == UserActive
+}
+
+// IsLocked reports whether the account is locked after failed logins
+func (u User) IsLocked() bool {
+	return u.LockedUntil != nil && u.LockedUntil.After(time.Now())
+}
+
+// Invitation lets someone create an account with a given email and role.
+// Only a hash of the invite token is stored.
+type Invitation struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Email       string     `gorm:"index;not null" json:"email"`
+	Role        string     `gorm:"not null;default:user" json:"role"`
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	InvitedByID uint       `json:"invited_by_id"`
+	InvitedBy   User       `gorm:"foreignKey:InvitedByID" json:"invited_by"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	SentAt      time.Time  `json:"sent_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Expired reports whether the invitation can no longer be accepted
+func (i Invitation) Expired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+// PasswordHistory keeps hashes of previous passwords to prevent reuse
+type PasswordHistory struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	Email     string    `gorm:"uniqueIndex;not null" json:"email" binding:"required,email"`
-	Name      string    `gorm:"not null" json:"name" binding:"required"`
-	Password  string    `gorm:"not null" json:"-"` // Hidden from JSON output
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Hash      string    `gorm:"not null" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginAttempt records a login attempt. UserID is nil for unknown emails.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address"`
+	Success   bool      `json:"success"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// PasswordResetToken is a single-use token for resetting a forgotten
+// password. Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	IPAddress string     `json:"ip_address"` // address the reset was requested from
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Session is a server-side login session. The cookie holds a random
+// session ID of which only the SHA-256 hash is stored.
+type Session struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TokenHash  string    `gorm:"uniqueIndex;not null" json:"-"`
+	UserID     *uint     `gorm:"index" json:"user_id"`
+	Data       []byte    `json:"-"` // gob encoded session values
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `gorm:"index" json:"last_seen_at"`
+	ExpiresAt  time.Time `gorm:"index" json:"expires_at"`
+}
+
+// WebAuthnCredential is a security key or passkey registered by a user.
+// CredentialID is the base64url encoded ID chosen by the authenticator.
+type WebAuthnCredential struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"index;not null" json:"user_id"`
+	Name         string     `gorm:"not null" json:"name"`
+	CredentialID string     `gorm:"uniqueIndex;not null" json:"credential_id"`
+	PublicKey    []byte     `gorm:"not null" json:"-"` // COSE encoded
+	SignCount    uint32     `gorm:"not null;default:0" json:"sign_count"`
+	Transports   string     `json:"transports"` // comma separated hints such as usb,nfc
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// EmailChange is a change of email address that waits for a confirmation
+// link sent to the new address. Only the SHA-256 hash of the token is
+// stored.
+type EmailChange struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex;not null" json:"user_id"` // one pending change per user
+	Email     string    `gorm:"not null" json:"email"`
+	TokenHash string    `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIToken is a personal access token that authenticates API requests as
+// its user. Only the SHA-256 hash of the token is stored; Prefix is kept
+// to tell tokens apart.
+type APIToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"not null" json:"prefix"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at"` // nil never expires
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Expired reports whether the token can no longer be used
+func (t APIToken) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+// Roles of a team member
+const (
+	TeamRoleMember     = "member"
+	TeamRoleMaintainer = "maintainer" // manages the members and resources of the team
+)
+
+// Team is a group of users that owns SSH keys, servers and env projects
+type Team struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"name" binding:"required"`
+	Description string       `json:"description"`
+	Members     []TeamMember `gorm:"foreignKey:TeamID" json:"members,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// TeamMember makes a user a member of a team
+type TeamMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TeamID    uint      `gorm:"uniqueIndex:idx_team_member;not null" json:"team_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_team_member;not null" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	Role      string    `gorm:"not null;default:member" json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// SSHKey represents an SSH key for server access
+// SSHKey represents an SSH key for server access. Keys owned by a team are
+// visible to its members; other keys only to their user and admins.
 type SSHKey struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	Name        string    `gorm:"not null" json:"name" binding:"required"`
-	PublicKey   string    `gorm:"type:text;not null" json:"public_key" binding:"required"`
-	Fingerprint string    `gorm:"not null" json:"fingerprint"`
-	UserID      uint      `gorm:"not null" json:"user_id"`
-	User        User      `gorm:"foreignKey:UserID" json:"user"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Name        string     `gorm:"not null" json:"name" binding:"required"`
+	PublicKey   string     `gorm:"type:text;not null" json:"public_key" binding:"required"`
+	Fingerprint string     `gorm:"not null" json:"fingerprint"`
+	UserID      uint       `gorm:"not null" json:"user_id"`
+	User        User       `gorm:"foreignKey:UserID" json:"user"`
+	TeamID      *uint      `gorm:"index" json:"team_id"`
+	Team        *Team      `gorm:"foreignKey:TeamID" json:"team,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at"` // set when the owner stopped being active; the key is kept for the record
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// IsRevoked reports whether the key was revoked
+func (k SSHKey) IsRevoked() bool {
+	return k.RevokedAt != nil
 }
 
 // Server represents a monitored server
@@ -40,10 +269,141 @@ type Server struct {
 	Port        int       `gorm:"default:22" json:"port"`
 	Description string    `json:"description"`
 	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	TeamID      *uint     `gorm:"index" json:"team_id"`
+	Team        *Team     `gorm:"foreignKey:TeamID" json:"team,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// Access levels of an env project permission
+const (
+	EnvAccessRead  = "read"
+	EnvAccessWrite = "write"
+)
+
+// EnvProject is a named directory whose env files can be managed. Members
+// of the owning team get TeamLevel access in addition to the permissions of
+// single users.
+type EnvProject struct {
+	ID          uint                   `gorm:"primaryKey" json:"id"`
+	Name        string                 `gorm:"uniqueIndex;not null" json:"name" binding:"required"`
+	Root        string                 `gorm:"not null" json:"root" binding:"required"`
+	Description string                 `json:"description"`
+	ExampleFile string                 `gorm:"default:.env.example" json:"example_file"` // template whose keys are required in every other file
+	TeamID      *uint                  `gorm:"index" json:"team_id"`
+	Team        *Team                  `gorm:"foreignKey:TeamID" json:"team,omitempty"`
+	TeamLevel   string                 `json:"team_level"` // EnvAccessRead or EnvAccessWrite
+	Permissions []EnvProjectPermission `gorm:"foreignKey:ProjectID" json:"permissions,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+// EnvProjectPermission grants a user access to an env project. Admins
+// have write access to every project.
+type EnvProjectPermission struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProjectID uint      `gorm:"uniqueIndex:idx_env_project_user;not null" json:"project_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_env_project_user;not null" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	Level     string    `gorm:"not null" json:"level"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EnvRevision is a saved version of an environment file
+type EnvRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProjectID uint      `gorm:"index" json:"project_id"`
+	Filename  string    `gorm:"index;not null" json:"filename"`
+	Content   string    `gorm:"type:text" json:"-"`
+	Hash      string    `gorm:"not null" json:"hash"`
+	Message   string    `json:"message"`
+	AuthorID  *uint     `json:"author_id"`
+	Author    *User     `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EnvKeySalt is the salt from which the key encrypting env revisions is
+// derived when ENV_ENCRYPTION_KEY is a passphrase. An install has one.
+type EnvKeySalt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Salt      []byte    `gorm:"not null" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EnvSecret marks a key of an environment file as secret so that its
+// value is masked even if it does not match a secret pattern
+type EnvSecret struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProjectID uint      `gorm:"uniqueIndex:idx_env_project_secret" json:"project_id"`
+	Filename  string    `gorm:"uniqueIndex:idx_env_project_secret;not null" json:"filename"`
+	Key       string    `gorm:"uniqueIndex:idx_env_project_secret;not null" json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EnvSchemaRule describes the expected value of a key in an env file
+type EnvSchemaRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ProjectID   uint      `gorm:"uniqueIndex:idx_env_schema_rule" json:"project_id"`
+	Filename    string    `gorm:"uniqueIndex:idx_env_schema_rule;not null" json:"filename"`
+	Key         string    `gorm:"uniqueIndex:idx_env_schema_rule;not null" json:"key"`
+	Required    bool      `json:"required"`
+	Type        string    `gorm:"not null;default:string" json:"type"` // see envfile.Types
+	Pattern     string    `json:"pattern"`                             // regular expression the whole value must match
+	Enum        string    `json:"enum"`                                // comma-separated list of allowed values
+	Default     string    `json:"default"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Hook types run after an env file is saved
+const (
+	EnvHookSystemd = "systemd" // restart a systemd unit
+	EnvHookSignal  = "signal"  // signal the process of a PID file
+	EnvHookCommand = "command" // run an allowlisted command
+	EnvHookHTTP    = "http"    // call an HTTP endpoint
+)
+
+// EnvHook is run after every successful save of an environment file
+type EnvHook struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProjectID uint      `gorm:"index" json:"project_id"`
+	Filename  string    `gorm:"index;not null" json:"filename"`
+	Type      string    `gorm:"not null" json:"type"`
+	Target    string    `gorm:"not null" json:"target"` // unit, PID file, command path or URL
+	Argument  string    `json:"argument"`               // signal, command arguments or HTTP method
+	Timeout   int       `json:"timeout"`                // seconds, 0 uses the default
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EnvHookRun is the result of running a hook after a save
+type EnvHookRun struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	HookID     uint      `gorm:"index" json:"hook_id"`
+	ProjectID  uint      `gorm:"index" json:"project_id"`
+	Filename   string    `gorm:"index;not null" json:"filename"`
+	RevisionID uint      `json:"revision_id"`
+	Hook       string    `json:"hook"` // description of the hook when it ran
+	Success    bool      `json:"success"`
+	Output     string    `gorm:"type:text" json:"output"`
+	Duration   int64     `json:"duration"` // milliseconds
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuditLog records security relevant actions
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	User      *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Action    string    `gorm:"index;not null" json:"action"`
+	Target    string    `gorm:"index" json:"target"`
+	Detail    string    `json:"detail"`
+	IPAddress string    `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // InitDB initializes the database connection and runs migrations
 func InitDB() (*gorm.DB, error) {
 	var err error
@@ -54,32 +414,87 @@ func InitDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// Databases created before roles existed get their first user promoted
+	// to admin once, when the role column is added
+	promoteFirstUser := DB.Migrator().HasTable(&User{}) && !DB.Migrator().HasColumn(&User{}, "Role")
+
 	// Auto-migrate the schemas
-	err = DB.AutoMigrate(&User{}, &SSHKey{}, &Server{})
+	err = DB.AutoMigrate(&User{}, &SSHKey{}, &Server{}, &EnvProject{}, &EnvProjectPermission{}, &EnvRevision{}, &EnvKeySalt{}, &EnvSecret{}, &EnvSchemaRule{}, &EnvHook{}, &EnvHookRun{}, &AuditLog{}, &PasswordResetToken{}, &Session{}, &LoginAttempt{}, &PasswordHistory{}, &Invitation{}, &WebAuthnCredential{}, &Team{}, &TeamMember{}, &EmailChange{}, &APIToken{})
 	if err != nil {
 		return nil, err
 	}
 
-	// Create default admin user if no users exist
-	var userCount int64
-	DB.Model(&User{}).Count(&userCount)
-	if userCount == 0 {
-		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+	// Secret flags used to be unique per file name only
+	if DB.Migrator().HasIndex(&EnvSecret{}, "idx_env_secret") {
+		if err := DB.Migrator().DropIndex(&EnvSecret{}, "idx_env_secret"); err != nil {
+			return nil, err
+		}
+	}
 
-		admin := User{
-			Email:    "admin@admin",
-			Name:     "Administrator",
-			Password: string(hashedPassword),
+	// Installs from before first-run setup were seeded with admin@admin and
+	// the password "password"; disable that account until it is claimed
+	// with the one-time token the server prints at startup
+	var seeded User
+	if result := DB.Where("email = ? AND status <> ?", DefaultAdminEmail, UserDeleted).Limit(1).Find(&seeded); result.Error == nil && result.RowsAffected == 1 && seeded.StatusReason != DefaultAdminReason {
+		if bcrypt.CompareHashAndPassword([]byte(seeded.Password), []byte("password")) == nil {
+			log.Printf("Warning: %s still uses the default password and has been disabled", DefaultAdminEmail)
+			now := time.Now()
+			if err := DB.Model(&seeded).Updates(map[string]interface{}{
+				"status":               UserDisabled,
+				"status_reason":        DefaultAdminReason,
+				"status_changed_at":    now,
+				"must_change_password": true,
+			}).Error; err != nil {
+				return nil, err
+			}
 		}
+	}
 
-		if err := DB.Create(&admin).Error; err != nil {
-			return nil, err
+	if promoteFirstUser {
+		var first User
+		if err := DB.Order("id").First(&first).Error; err == nil {
+			if err := DB.Model(&first).Update("role", RoleAdmin).Error; err != nil {
+				return nil, err
+			}
 		}
 	}
 
+	if err := ensureDefaultEnvProject(); err != nil {
+		return nil, err
+	}
+
 	return DB, nil
 }
 
+// ensureDefaultEnvProject registers Sysara's working directory as the
+// first env project, which is where env files were managed before
+// projects existed, and moves existing revisions into it
+func ensureDefaultEnvProject() error {
+	var projectCount int64
+	DB.Model(&EnvProject{}).Count(&projectCount)
+	if projectCount > 0 {
+		return nil
+	}
+
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	project := EnvProject{
+		Name:        "Sysara",
+		Root:        root,
+		Description: "Sysara's own working directory",
+	}
+	if err := DB.Create(&project).Error; err != nil {
+		return err
+	}
+
+	if err := DB.Model(&EnvRevision{}).Where("project_id = 0").Update("project_id", project.ID).Error; err != nil {
+		return err
+	}
+	return DB.Model(&EnvSecret{}).Where("project_id = 0").Update("project_id", project.ID).Error
+}
+
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
 	return DB
@@ -0,0 +1,297 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidToken is returned for ID tokens that fail verification
+var ErrInvalidToken = errors.New("oidc: invalid ID token")
+
+// discoveryTTL is how long the provider metadata is cached
+const discoveryTTL = time.Hour
+
+// Claims are the identity claims Sysara uses from an ID token or the
+// userinfo endpoint
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// metadata is the part of the discovery document Sysara needs
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider signs users in with an OpenID Connect identity provider using
+// the authorization code flow with PKCE
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	groupsClaim  string
+	http         *http.Client
+
+	mu         sync.Mutex
+	meta       *metadata
+	metaLoaded time.Time
+	keys       *keySet
+}
+
+// New creates a provider for issuer. Discovery happens on first use, so
+// an unreachable provider does not prevent startup.
+func New(issuer, clientID, clientSecret, redirectURL string, scopes []string, groupsClaim string) *Provider {
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		issuer:       strings.TrimRight(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		groupsClaim:  groupsClaim,
+		http:         &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL returns the URL to send the user to. state and nonce bind
+// the response to this browser; verifier is the PKCE code verifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return meta.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified claims
+// of the user. nonce must be the value passed to AuthCodeURL.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"client_id":     {p.clientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("oidc: token request failed: %s %s (status %d)", token.Error, token.Description, status)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+
+	raw, err := p.verify(ctx, meta, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	claims := p.claims(raw)
+
+	// Some providers only return email and groups from the userinfo
+	// endpoint. Without groups the ID token alone is still usable.
+	if (claims.Email == "" || (p.groupsClaim != "" && claims.Groups == nil)) && meta.UserinfoEndpoint != "" && token.AccessToken != "" {
+		info, err := p.userinfo(ctx, meta, token.AccessToken)
+		if err != nil {
+			if claims.Email == "" {
+				return nil, err
+			}
+			return claims, nil
+		}
+		if sub, _ := info["sub"].(string); sub != claims.Subject {
+			return nil, errors.New("oidc: userinfo subject does not match the ID token")
+		}
+		extra := p.claims(info)
+		if claims.Email == "" {
+			claims.Email, claims.EmailVerified = extra.Email, extra.EmailVerified
+		}
+		if claims.Name == "" {
+			claims.Name = extra.Name
+		}
+		if claims.Groups == nil {
+			claims.Groups = extra.Groups
+		}
+	}
+	return claims, nil
+}
+
+// claims extracts the claims Sysara uses from a decoded claim set
+func (p *Provider) claims(raw map[string]interface{}) *Claims {
+	claims := &Claims{}
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Email, _ = raw["email"].(string)
+	claims.Name, _ = raw["name"].(string)
+	if claims.Name == "" {
+		claims.Name, _ = raw["preferred_username"].(string)
+	}
+	switch verified := raw["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = verified
+	case string:
+		claims.EmailVerified = verified == "true"
+	}
+	if p.groupsClaim == "" {
+		return claims
+	}
+	switch groups := raw[p.groupsClaim].(type) {
+	case []interface{}:
+		claims.Groups = []string{}
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				claims.Groups = append(claims.Groups, name)
+			}
+		}
+	case string:
+		claims.Groups = []string{groups}
+	}
+	return claims
+}
+
+// userinfo fetches the claims of the userinfo endpoint
+func (p *Provider) userinfo(ctx context.Context, meta *metadata, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info map[string]interface{}
+	status, err := p.doJSON(req, &info)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: userinfo request failed (status %d)", status)
+	}
+	return info, nil
+}
+
+// metadata returns the cached discovery document, fetching it when stale
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil && time.Since(p.metaLoaded) < discoveryTTL {
+		return p.meta, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var meta metadata
+	status, err := p.doJSON(req, &meta)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery failed (status %d)", status)
+	}
+	if strings.TrimRight(meta.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match %q", meta.Issuer, p.issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+
+	if p.keys == nil || p.keys.uri != meta.JWKSURI {
+		p.keys = newKeySet(meta.JWKSURI, p.http)
+	}
+	p.meta = &meta
+	p.metaLoaded = time.Now()
+	return p.meta, nil
+}
+
+// doJSON sends req and decodes a JSON response body into v
+func (p *Provider) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("oidc: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("oidc: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("oidc: invalid response from %s: %w", req.URL.Host, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// RandomString returns a random URL-safe string for state, nonce and PKCE
+// verifier values
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge returns the S256 PKCE code challenge of a verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
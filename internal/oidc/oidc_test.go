@@ -0,0 +1,240 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpemreelmas/sysara/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://sysara.test/auth/oidc/callback"
+
+// newTestProvider starts a mock identity provider and a client for it
+func newTestProvider(t *testing.T) (*oidctest.Provider, *Provider) {
+	t.Helper()
+	mock, err := oidctest.NewProvider("sysara", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mock.Close)
+	mock.Claims["email"] = "jane@example.com"
+	mock.Claims["email_verified"] = true
+	mock.Claims["name"] = "Jane Doe"
+	mock.Claims["groups"] = []string{"ops", "admins"}
+	return mock, New(mock.Issuer(), "sysara", "s3cret", redirectURL, nil, "groups")
+}
+
+// signIn runs the authorization code flow up to the callback and returns
+// the code, nonce and verifier the client redeems
+func signIn(t *testing.T, mock *oidctest.Provider, provider *Provider) (code, nonce, verifier string) {
+	t.Helper()
+	state, _ := RandomString()
+	nonce, _ = RandomString()
+	verifier, _ = RandomString()
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, gotState, err := mock.Authorize(authURL, "user-1")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if gotState != state {
+		t.Fatalf("state %q came back as %q", state, gotState)
+	}
+	return code, nonce, verifier
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	authURL, err := provider.AuthCodeURL(context.Background(), "the-state", "the-nonce", "the-verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, mock.Issuer()+"/authorize?") {
+		t.Errorf("URL %q does not use the discovered endpoint", authURL)
+	}
+	query := u.Query()
+	for key, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             "sysara",
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        Challenge("the-verifier"),
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if query.Has("code_verifier") {
+		t.Error("the PKCE verifier is sent to the browser")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	for _, alg := range []string{"RS256", "PS256", "ES256"} {
+		t.Run(alg, func(t *testing.T) {
+			mock, provider := newTestProvider(t)
+			mock.Alg = alg
+			code, nonce, verifier := signIn(t, mock, provider)
+
+			claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if claims.Subject != "user-1" || claims.Email != "jane@example.com" || !claims.EmailVerified || claims.Name != "Jane Doe" {
+				t.Errorf("claims = %+v", claims)
+			}
+			if len(claims.Groups) != 2 || claims.Groups[0] != "ops" || claims.Groups[1] != "admins" {
+				t.Errorf("groups = %v", claims.Groups)
+			}
+		})
+	}
+}
+
+func TestExchangeUserinfo(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	delete(mock.Claims, "email")
+	delete(mock.Claims, "groups")
+	mock.Userinfo = map[string]interface{}{"sub": "user-1", "email": "jane@example.com", "email_verified": "true", "groups": "ops"}
+	code, nonce, verifier := signIn(t, mock, provider)
+
+	claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Email != "jane@example.com" || !claims.EmailVerified || len(claims.Groups) != 1 || claims.Groups[0] != "ops" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// Userinfo of another subject is refused
+	mock.Userinfo["sub"] = "user-2"
+	code, nonce, verifier = signIn(t, mock, provider)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil {
+		t.Error("userinfo of another subject was accepted")
+	}
+}
+
+func TestExchangePKCE(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	code, nonce, _ := signIn(t, mock, provider)
+
+	other, _ := RandomString()
+	if _, err := provider.Exchange(context.Background(), code, other, nonce); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("wrong verifier: got %v, want invalid_grant", err)
+	}
+	if mock.Tokens() != 0 {
+		t.Error("a token was issued for a wrong verifier")
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	code, nonce, verifier := signIn(t, mock, provider)
+
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil {
+		t.Error("a code was redeemed twice")
+	}
+}
+
+func TestExchangeNonce(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	code, _, verifier := signIn(t, mock, provider)
+
+	other, _ := RandomString()
+	if _, err := provider.Exchange(context.Background(), code, verifier, other); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("nonce mismatch: got %v, want ErrInvalidToken", err)
+	}
+
+	// A token without a nonce is refused too
+	mock.Modify = func(claims map[string]interface{}) { delete(claims, "nonce") }
+	code, nonce, verifier := signIn(t, mock, provider)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("missing nonce: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestExchangeRejectedAlgorithms(t *testing.T) {
+	for _, alg := range []string{"none", "HS256"} {
+		t.Run(alg, func(t *testing.T) {
+			mock, provider := newTestProvider(t)
+			mock.Alg = alg
+			code, nonce, verifier := signIn(t, mock, provider)
+			_, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestExchangeClaimChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(claims map[string]interface{})
+		valid  bool
+	}{
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, false},
+		{"expired within clock skew", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, true},
+		{"no expiry", func(c map[string]interface{}) { delete(c, "exp") }, false},
+		{"not valid yet", func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Hour).Unix() }, false},
+		{"other audience", func(c map[string]interface{}) { c["aud"] = "someone-else" }, false},
+		{"audience list", func(c map[string]interface{}) { c["aud"] = []string{"someone-else", "sysara"} }, true},
+		{"audience list without client", func(c map[string]interface{}) { c["aud"] = []string{"someone-else"} }, false},
+		{"other issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, false},
+		{"no subject", func(c map[string]interface{}) { c["sub"] = "" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, provider := newTestProvider(t)
+			mock.Modify = tt.modify
+			code, nonce, verifier := signIn(t, mock, provider)
+			_, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if tt.valid && err != nil {
+				t.Errorf("got %v, want a valid token", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedToken(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	meta, err := provider.metadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]interface{}{
+		"iss": mock.Issuer(), "aud": "sysara", "sub": "user-1", "nonce": "n",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+	token := mock.IDToken(claims)
+	if _, err := provider.verify(context.Background(), meta, token, "n"); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// Swap the payload for one naming another subject
+	claims["sub"] = "admin"
+	parts := strings.Split(token, ".")
+	forged := strings.Split(mock.IDToken(claims), ".")
+	tampered := parts[0] + "." + forged[1] + "." + parts[2]
+	if _, err := provider.verify(context.Background(), meta, tampered, "n"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("tampered token: got %v, want ErrInvalidToken", err)
+	}
+}
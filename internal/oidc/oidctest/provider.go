@@ -0,0 +1,269 @@
+// Package oidctest provides a mock OpenID Connect provider for tests. It
+// serves discovery, signing keys, the token and the userinfo endpoint, and
+// checks PKCE like a real provider.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// grant is an authorization code waiting to be redeemed
+type grant struct {
+	subject     string
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// Provider is a mock identity provider on a local HTTP server
+type Provider struct {
+	ClientID     string
+	ClientSecret string
+
+	// Alg signs ID tokens: RS256, PS256, ES256, or the rejected none and
+	// HS256. Defaults to RS256.
+	Alg string
+	// Claims are added to every ID token, e.g. email, name and groups
+	Claims map[string]interface{}
+	// Userinfo is returned by the userinfo endpoint; nil answers 404
+	Userinfo map[string]interface{}
+	// Modify changes the claims of an ID token before it is signed
+	Modify func(claims map[string]interface{})
+
+	server *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+	tokens int // ID tokens issued
+}
+
+// NewProvider starts a provider for a client
+func NewProvider(clientID, clientSecret string) (*Provider, error) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Alg:          "RS256",
+		Claims:       map[string]interface{}{},
+		rsaKey:       rsaKey,
+		ecKey:        ecKey,
+		grants:       map[string]grant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/userinfo", p.userinfo)
+	p.server = httptest.NewServer(mux)
+	return p, nil
+}
+
+// Issuer returns the issuer URL of the provider
+func (p *Provider) Issuer() string {
+	return p.server.URL
+}
+
+// Close stops the provider
+func (p *Provider) Close() {
+	p.server.Close()
+}
+
+// Tokens returns the number of ID tokens issued
+func (p *Provider) Tokens() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tokens
+}
+
+// Authorize plays the user signing in at the authorization URL of a
+// client. It returns the code and state the provider redirects back with.
+func (p *Provider) Authorize(authURL, subject string) (code, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := u.Query()
+	switch {
+	case !strings.HasPrefix(authURL, p.server.URL+"/authorize"):
+		return "", "", errors.New("oidctest: not an authorization URL of this provider")
+	case query.Get("client_id") != p.ClientID:
+		return "", "", errors.New("oidctest: unknown client")
+	case query.Get("response_type") != "code":
+		return "", "", errors.New("oidctest: unsupported response type")
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		return "", "", errors.New("oidctest: PKCE with S256 is required")
+	}
+
+	code = randomString()
+	p.mu.Lock()
+	p.grants[code] = grant{
+		subject:     subject,
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+		redirectURI: query.Get("redirect_uri"),
+	}
+	p.mu.Unlock()
+	return code, query.Get("state"), nil
+}
+
+// IDToken signs an ID token with the given claims, using Alg
+func (p *Provider) IDToken(claims map[string]interface{}) string {
+	header := map[string]interface{}{"alg": p.Alg, "typ": "JWT"}
+	switch p.Alg {
+	case "ES256":
+		header["kid"] = "ec"
+	default:
+		header["kid"] = "rsa"
+	}
+	signed := segment(header) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch p.Alg {
+	case "RS256":
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest[:])
+	case "PS256":
+		signature, _ = rsa.SignPSS(rand.Reader, p.rsaKey, crypto.SHA256, digest[:], nil)
+	case "ES256":
+		r, s, _ := ecdsa.Sign(rand.Reader, p.ecKey, digest[:])
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case "HS256":
+		// The public key as an HMAC secret, as in key confusion attacks
+		mac := hmac.New(sha256.New, p.rsaKey.PublicKey.N.Bytes())
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.server.URL,
+		"authorization_endpoint": p.server.URL + "/authorize",
+		"token_endpoint":         p.server.URL + "/token",
+		"userinfo_endpoint":      p.server.URL + "/userinfo",
+		"jwks_uri":               p.server.URL + "/jwks",
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	encode := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(p.rsaKey.N), "e": encode(big.NewInt(int64(p.rsaKey.E)))},
+		{"kty": "EC", "kid": "ec", "use": "sig", "crv": "P-256", "x": encode(p.ecKey.X), "y": encode(p.ecKey.Y)},
+	}})
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if id, secret, _ := r.BasicAuth(); id != url.QueryEscape(p.ClientID) || secret != url.QueryEscape(p.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	// Codes are single-use, whatever the outcome
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	case !ok || r.PostForm.Get("redirect_uri") != g.redirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case challenge(r.PostForm.Get("code_verifier")) != g.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   p.server.URL,
+		"aud":   p.ClientID,
+		"sub":   g.subject,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": g.nonce,
+	}
+	for key, value := range p.Claims {
+		claims[key] = value
+	}
+	if p.Modify != nil {
+		p.Modify(claims)
+	}
+	p.mu.Lock()
+	p.tokens++
+	p.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access-" + g.subject,
+		"token_type":   "Bearer",
+		"id_token":     p.IDToken(claims),
+	})
+}
+
+func (p *Provider) userinfo(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer access-") || p.Userinfo == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, p.Userinfo)
+}
+
+// challenge returns the S256 PKCE challenge of a verifier
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// segment encodes v as a base64url JSON token segment
+func segment(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
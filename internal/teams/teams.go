@@ -0,0 +1,167 @@
+package teams
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alpemreelmas/sysara/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTeamNotFound is returned for unknown team IDs
+	ErrTeamNotFound = errors.New("team not found")
+	// ErrNotMember is returned when a user assigns a resource to a team they
+	// do not belong to
+	ErrNotMember = errors.New("you are not a member of this team")
+)
+
+// Manager manages teams, their members and the resources they own
+type Manager struct {
+	db *gorm.DB
+}
+
+// NewManager creates a new team manager
+func NewManager(db *gorm.DB) *Manager {
+	return &Manager{db: db}
+}
+
+// MemberOf returns a subquery of the IDs of the teams of a user, for
+// filtering resources by their owning team
+func MemberOf(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.TeamMember{}).Select("team_id").Where("user_id = ?", userID)
+}
+
+// List returns all teams ordered by name with their members
+func (m *Manager) List() ([]models.Team, error) {
+	var teams []models.Team
+	err := m.db.Preload("Members").Order("name").Find(&teams).Error
+	return teams, err
+}
+
+// ForUser returns the teams user belongs to, or every team for admins
+func (m *Manager) ForUser(user *models.User) ([]models.Team, error) {
+	if user.IsAdmin() {
+		return m.List()
+	}
+	var teams []models.Team
+	err := m.db.Preload("Members").
+		Where("id IN (?)", MemberOf(m.db, user.ID)).
+		Order("name").
+		Find(&teams).Error
+	return teams, err
+}
+
+// Get returns a team with its members, maintainers first
+func (m *Manager) Get(id uint) (*models.Team, error) {
+	var team models.Team
+	err := m.db.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("role, id")
+	}).Preload("Members.User").First(&team, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTeamNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// Create adds a new team
+func (m *Manager) Create(team *models.Team) error {
+	if err := validateTeam(team); err != nil {
+		return err
+	}
+	return m.db.Create(team).Error
+}
+
+// Update saves the name and description of a team
+func (m *Manager) Update(team *models.Team) error {
+	if err := validateTeam(team); err != nil {
+		return err
+	}
+	return m.db.Model(team).Select("name", "description").Updates(team).Error
+}
+
+// Delete removes a team and its memberships. SSH keys, servers and env
+// projects of the team are kept without an owning team.
+func (m *Manager) Delete(id uint) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.SSHKey{}, &models.Server{}, &models.EnvProject{}} {
+			if err := tx.Model(model).Where("team_id = ?", id).Update("team_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("team_id = ?", id).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Team{}, id).Error
+	})
+}
+
+// SetMember adds a user to a team or changes their role
+func (m *Manager) SetMember(teamID, userID uint, role string) error {
+	if role != models.TeamRoleMember && role != models.TeamRoleMaintainer {
+		return fmt.Errorf("invalid team role %q", role)
+	}
+	member := models.TeamMember{TeamID: teamID, UserID: userID, Role: role}
+	return m.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "team_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(&member).Error
+}
+
+// RemoveMember removes a user from a team
+func (m *Manager) RemoveMember(teamID, userID uint) error {
+	return m.db.Where("team_id = ? AND user_id = ?", teamID, userID).
+		Delete(&models.TeamMember{}).Error
+}
+
+// Role returns the role of a user in a team, or "" if they are not a member
+func (m *Manager) Role(userID, teamID uint) string {
+	var member models.TeamMember
+	if err := m.db.Where("team_id = ? AND user_id = ?", teamID, userID).First(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+// CanManage reports whether user may change the members and resources of a
+// team: admins and maintainers of the team
+func (m *Manager) CanManage(user *models.User, teamID uint) bool {
+	return user.IsAdmin() || m.Role(user.ID, teamID) == models.TeamRoleMaintainer
+}
+
+// Assignable checks that user may make a team the owner of a resource.
+// A nil team leaves the resource without an owning team.
+func (m *Manager) Assignable(user *models.User, teamID *uint) error {
+	if teamID == nil {
+		return nil
+	}
+	if user.IsAdmin() {
+		var count int64
+		if err := m.db.Model(&models.Team{}).Where("id = ?", *teamID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrTeamNotFound
+		}
+		return nil
+	}
+	if m.Role(user.ID, *teamID) == "" {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// validateTeam checks the name of a team
+func validateTeam(team *models.Team) error {
+	team.Name = strings.TrimSpace(team.Name)
+	team.Description = strings.TrimSpace(team.Description)
+	if team.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
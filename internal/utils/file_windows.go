@@ -0,0 +1,12 @@
+//go:build windows
+
+package utils
+
+import (
+	"os"
+)
+
+// copyOwner is a no-op on Windows, where files inherit the directory ACL
+func copyOwner(info os.FileInfo, f *os.File) error {
+	return nil
+}
@@ -0,0 +1,150 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// cborPair is a map entry for encodeCBOR, which keeps the entries in order
+type cborPair struct {
+	key, value interface{}
+}
+
+// encodeCBOR encodes test fixtures. Maps are given as []cborPair.
+func encodeCBOR(v interface{}) []byte {
+	head := func(major byte, arg uint64) []byte {
+		switch {
+		case arg < 24:
+			return []byte{major<<5 | byte(arg)}
+		case arg <= 0xff:
+			return []byte{major<<5 | 24, byte(arg)}
+		case arg <= 0xffff:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+		case arg <= 0xffffffff:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+		}
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+	}
+
+	switch v := v.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return head(cborNegative, uint64(-1-v))
+		}
+		return head(cborUnsigned, uint64(v))
+	case []byte:
+		return append(head(cborBytes, uint64(len(v))), v...)
+	case string:
+		return append(head(cborText, uint64(len(v))), v...)
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case []interface{}:
+		out := head(cborArray, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case []cborPair:
+		out := head(cborMap, uint64(len(v)))
+		for _, pair := range v {
+			out = append(append(out, encodeCBOR(pair.key)...), encodeCBOR(pair.value)...)
+		}
+		return out
+	}
+	panic("encodeCBOR: unsupported type")
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want interface{}
+	}{
+		{"small unsigned", "17", int64(23)},
+		{"one byte unsigned", "1818", int64(24)},
+		{"two byte unsigned", "190100", int64(256)},
+		{"eight byte unsigned", "1b7fffffffffffffff", int64(1<<63 - 1)},
+		{"negative", "20", int64(-1)},
+		{"cose algorithm", "390100", int64(-257)},
+		{"bytes", "43010203", []byte{1, 2, 3}},
+		{"text", "6161", "a"},
+		{"array", "820102", []interface{}{int64(1), int64(2)}},
+		{"map", "a201020326", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(-7)}},
+		{"text key", "a1616101", map[interface{}]interface{}{"a": int64(1)}},
+		{"tag", "c24101", []byte{1}},
+		{"false", "f4", false},
+		{"true", "f5", true},
+		{"null", "f6", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.hex)
+			got, rest, err := decodeCBOR(append(data, 0xff))
+			if err != nil {
+				t.Fatalf("decodeCBOR: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR() = %#v, want %#v", got, tt.want)
+			}
+			if !reflect.DeepEqual(rest, []byte{0xff}) {
+				t.Errorf("rest = %x, want ff", rest)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	deep := make([]byte, 0, cborMaxDepth+2)
+	for i := 0; i <= cborMaxDepth+1; i++ {
+		deep = append(deep, 0x81) // an array holding the next item
+	}
+	deep = append(deep, 0x01)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated argument", []byte{0x19, 0x01}},
+		{"unsigned overflow", []byte{0x1b, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{"negative overflow", []byte{0x3b, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{"truncated bytes", []byte{0x45, 1, 2}},
+		{"huge byte length", []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"truncated array", []byte{0x83, 0x01}},
+		{"huge array length", []byte{0x9a, 0xff, 0xff, 0xff, 0xff}},
+		{"truncated map", []byte{0xa1, 0x01}},
+		{"huge map length", []byte{0xba, 0xff, 0xff, 0xff, 0xff}},
+		{"byte string key", []byte{0xa1, 0x41, 0x00, 0x01}},
+		{"float", []byte{0xf9, 0x3c, 0x00}},
+		{"undefined simple value", []byte{0xf8, 0x20}},
+		{"indefinite length", []byte{0x9f, 0x01, 0xff}},
+		{"nested too deeply", deep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(tt.data); !errors.Is(err, errCBOR) {
+				t.Errorf("decodeCBOR(%x) = %v, want errCBOR", tt.data, err)
+			}
+		})
+	}
+}
+
+func TestEncodeCBORRoundTrip(t *testing.T) {
+	value := []cborPair{{"fmt", "none"}, {int64(-3), []byte{1, 2}}, {int64(1), []interface{}{true, int64(70000)}}}
+	got, rest, err := decodeCBOR(encodeCBOR(value))
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decodeCBOR: %v, rest %x", err, rest)
+	}
+	want := map[interface{}]interface{}{"fmt": "none", int64(-3): []byte{1, 2}, int64(1): []interface{}{true, int64(70000)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeCBOR() = %#v", got)
+	}
+}
@@ -0,0 +1,101 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// coseEC2 returns the COSE encoding of a P-256 public key
+func coseEC2(key *ecdsa.PublicKey) []byte {
+	return encodeCBOR([]cborPair{
+		{coseKeyType, keyTypeEC2},
+		{coseAlg, AlgES256},
+		{coseCurve, curveP256},
+		{coseX, key.X.FillBytes(make([]byte, 32))},
+		{coseY, key.Y.FillBytes(make([]byte, 32))},
+	})
+}
+
+func TestParsePublicKeyVerifies(t *testing.T) {
+	message := []byte("authenticator data and client data hash")
+	digest := sha256.Sum256(message)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecSignature, _ := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	edSignature := ed25519.Sign(edPrivate, message)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaSignature, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+
+	tests := []struct {
+		name      string
+		key       []byte
+		signature []byte
+	}{
+		{"ES256", coseEC2(&ecKey.PublicKey), ecSignature},
+		{"EdDSA", encodeCBOR([]cborPair{{coseKeyType, keyTypeOKP}, {coseAlg, AlgEdDSA}, {coseCurve, curveEd25519}, {coseX, []byte(edPublic)}}), edSignature},
+		{"RS256", encodeCBOR([]cborPair{{coseKeyType, keyTypeRSA}, {coseAlg, AlgRS256}, {coseCurve, rsaKey.N.Bytes()}, {coseX, big.NewInt(int64(rsaKey.E)).Bytes()}}), rsaSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parsePublicKey(tt.key)
+			if err != nil {
+				t.Fatalf("parsePublicKey: %v", err)
+			}
+			if !key.verify(message, tt.signature) {
+				t.Error("valid signature rejected")
+			}
+			if key.verify([]byte("another message"), tt.signature) {
+				t.Error("signature over another message accepted")
+			}
+			tampered := append([]byte(nil), tt.signature...)
+			tampered[len(tampered)-1] ^= 1
+			if key.verify(message, tampered) {
+				t.Error("tampered signature accepted")
+			}
+		})
+	}
+}
+
+func TestParsePublicKeyRejects(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	x := ecKey.X.FillBytes(make([]byte, 32))
+	y := ecKey.Y.FillBytes(make([]byte, 32))
+	offCurve := append([]byte(nil), y...)
+	offCurve[31] ^= 1
+
+	tests := []struct {
+		name string
+		key  []byte
+		want error
+	}{
+		{"not a map", encodeCBOR([]interface{}{int64(1)}), ErrUnsupportedKey},
+		{"unknown key type", encodeCBOR([]cborPair{{coseKeyType, 4}, {coseAlg, AlgES256}}), ErrUnsupportedKey},
+		{"algorithm not offered", encodeCBOR([]cborPair{{coseKeyType, keyTypeEC2}, {coseAlg, -35}, {coseCurve, curveP256}, {coseX, x}, {coseY, y}}), ErrUnsupportedKey},
+		{"other curve", encodeCBOR([]cborPair{{coseKeyType, keyTypeEC2}, {coseAlg, AlgES256}, {coseCurve, 2}, {coseX, x}, {coseY, y}}), ErrUnsupportedKey},
+		{"short coordinate", encodeCBOR([]cborPair{{coseKeyType, keyTypeEC2}, {coseAlg, AlgES256}, {coseCurve, curveP256}, {coseX, x[:31]}, {coseY, y}}), ErrUnsupportedKey},
+		{"point not on the curve", encodeCBOR([]cborPair{{coseKeyType, keyTypeEC2}, {coseAlg, AlgES256}, {coseCurve, curveP256}, {coseX, x}, {coseY, offCurve}}), ErrUnsupportedKey},
+		{"short Ed25519 key", encodeCBOR([]cborPair{{coseKeyType, keyTypeOKP}, {coseAlg, AlgEdDSA}, {coseCurve, curveEd25519}, {coseX, make([]byte, 31)}}), ErrUnsupportedKey},
+		{"small RSA modulus", encodeCBOR([]cborPair{{coseKeyType, keyTypeRSA}, {coseAlg, AlgRS256}, {coseCurve, make([]byte, 128)}, {coseX, []byte{1, 0, 1}}}), ErrUnsupportedKey},
+		{"large RSA exponent", encodeCBOR([]cborPair{{coseKeyType, keyTypeRSA}, {coseAlg, AlgRS256}, {coseCurve, make([]byte, 256)}, {coseX, make([]byte, 5)}}), ErrUnsupportedKey},
+		{"trailing data", append(coseEC2(&ecKey.PublicKey), 0x00), errCBOR},
+		{"malformed", []byte{0xa5, 0x01}, errCBOR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parsePublicKey(tt.key); !errors.Is(err, tt.want) {
+				t.Errorf("parsePublicKey() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
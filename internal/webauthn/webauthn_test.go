@@ -0,0 +1,306 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var testRP = &RelyingParty{ID: "sysara.test", Name: "Sysara", Origins: []string{"https://sysara.test"}}
+
+// authenticator is a software security key for one credential
+type authenticator struct {
+	key       *ecdsa.PrivateKey
+	id        []byte
+	signCount uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authenticator{key: key, id: []byte("credential-1"), signCount: 1}
+}
+
+// ceremony holds what the browser and the authenticator put into a response
+type ceremony struct {
+	clientType  string
+	challenge   []byte
+	origin      string
+	crossOrigin bool
+	rpID        string
+	flags       byte
+}
+
+func newCeremony(clientType string, challenge []byte) ceremony {
+	return ceremony{
+		clientType: clientType,
+		challenge:  challenge,
+		origin:     "https://sysara.test",
+		rpID:       "sysara.test",
+		flags:      flagUserPresent | flagUserVerified,
+	}
+}
+
+func (c ceremony) clientDataJSON() []byte {
+	data, _ := json.Marshal(clientData{
+		Type:        c.clientType,
+		Challenge:   base64.RawURLEncoding.EncodeToString(c.challenge),
+		Origin:      c.origin,
+		CrossOrigin: c.crossOrigin,
+	})
+	return data
+}
+
+// authData encodes authenticator data, with the attested credential when
+// attested is set
+func (a *authenticator) authData(c ceremony, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(c.rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+	flags := c.flags
+	if attested {
+		flags |= flagAttested
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.id)))
+		data = append(data, a.id...)
+		data = append(data, coseEC2(&a.key.PublicKey)...)
+	}
+	return data
+}
+
+func (a *authenticator) register(c ceremony) *AttestationResponse {
+	response := &AttestationResponse{ID: base64.RawURLEncoding.EncodeToString(a.id), RawID: a.id, Type: "public-key"}
+	response.Response.ClientDataJSON = c.clientDataJSON()
+	response.Response.AttestationObject = encodeCBOR([]cborPair{
+		{"fmt", "none"},
+		{"attStmt", []cborPair{}},
+		{"authData", a.authData(c, true)},
+	})
+	response.Response.Transports = []string{"usb"}
+	return response
+}
+
+func (a *authenticator) assert(c ceremony) *AssertionResponse {
+	response := &AssertionResponse{ID: base64.RawURLEncoding.EncodeToString(a.id), RawID: a.id, Type: "public-key"}
+	response.Response.ClientDataJSON = c.clientDataJSON()
+	response.Response.AuthenticatorData = a.authData(c, false)
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), response.Response.AuthenticatorData...), clientDataHash[:]...))
+	response.Response.Signature, _ = ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	return response
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := testRP.VerifyRegistration(challenge, a.register(newCeremony("webauthn.create", challenge)), true)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	if string(credential.ID) != string(a.id) || credential.SignCount != 1 || !credential.UserVerified || len(credential.Transports) != 1 {
+		t.Errorf("credential = %+v", credential)
+	}
+
+	a.signCount = 5
+	challenge, _ = NewChallenge()
+	signCount, err := testRP.VerifyAssertion(challenge, a.assert(newCeremony("webauthn.get", challenge)), credential.PublicKey, credential.SignCount, true)
+	if err != nil || signCount != 5 {
+		t.Errorf("VerifyAssertion() = %d, %v", signCount, err)
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge := []byte("a challenge of the relying party")
+	publicKey := coseEC2(&a.key.PublicKey)
+
+	tests := []struct {
+		name                    string
+		change                  func(c *ceremony)
+		response                func(r *AssertionResponse)
+		requireUserVerification bool
+		want                    string
+	}{
+		{name: "wrong origin", change: func(c *ceremony) { c.origin = "https://sysara.test.evil.example" }, want: `webauthn: invalid authenticator response: unexpected origin "https://sysara.test.evil.example"`},
+		{name: "http origin", change: func(c *ceremony) { c.origin = "http://sysara.test" }, want: `webauthn: invalid authenticator response: unexpected origin "http://sysara.test"`},
+		{name: "cross origin", change: func(c *ceremony) { c.crossOrigin = true }, want: "webauthn: invalid authenticator response: cross-origin request"},
+		{name: "wrong challenge", change: func(c *ceremony) { c.challenge = []byte("another challenge") }, want: "webauthn: invalid authenticator response: challenge mismatch"},
+		{name: "registration response", change: func(c *ceremony) { c.clientType = "webauthn.create" }, want: `webauthn: invalid authenticator response: unexpected ceremony "webauthn.create"`},
+		{name: "wrong rpIdHash", change: func(c *ceremony) { c.rpID = "evil.example" }, want: "webauthn: invalid authenticator response: credential belongs to another site"},
+		{name: "user not present", change: func(c *ceremony) { c.flags = flagUserVerified }, want: "webauthn: invalid authenticator response: user not present"},
+		{name: "user not verified", change: func(c *ceremony) { c.flags = flagUserPresent }, requireUserVerification: true, want: "webauthn: invalid authenticator response: user not verified"},
+		{name: "bad signature", response: func(r *AssertionResponse) { r.Response.Signature[len(r.Response.Signature)-1] ^= 1 }, want: "webauthn: invalid authenticator response: bad signature"},
+		{name: "signed data changed", response: func(r *AssertionResponse) { r.Response.AuthenticatorData[36]++ }, want: "webauthn: invalid authenticator response: bad signature"},
+		{name: "short authenticator data", response: func(r *AssertionResponse) { r.Response.AuthenticatorData = r.Response.AuthenticatorData[:36] }, want: "webauthn: invalid authenticator response: authenticator data too short"},
+		{name: "malformed client data", response: func(r *AssertionResponse) { r.Response.ClientDataJSON = []byte("{") }, want: "webauthn: invalid authenticator response: malformed client data"},
+		{name: "wrong type", response: func(r *AssertionResponse) { r.Type = "password" }, want: `webauthn: invalid authenticator response: unexpected credential type "password"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCeremony("webauthn.get", challenge)
+			if tt.change != nil {
+				tt.change(&c)
+			}
+			response := a.assert(c)
+			if tt.response != nil {
+				tt.response(response)
+			}
+			_, err := testRP.VerifyAssertion(challenge, response, publicKey, 0, tt.requireUserVerification)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("VerifyAssertion() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// Without required verification, presence is enough
+	c := newCeremony("webauthn.get", challenge)
+	c.flags = flagUserPresent
+	if _, err := testRP.VerifyAssertion(challenge, a.assert(c), publicKey, 0, false); err != nil {
+		t.Errorf("VerifyAssertion() without verification: %v", err)
+	}
+}
+
+func TestVerifyAssertionSignCount(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge := []byte("a challenge of the relying party")
+	publicKey := coseEC2(&a.key.PublicKey)
+
+	tests := []struct {
+		name   string
+		stored uint32
+		sent   uint32
+		want   error
+	}{
+		{"increased", 7, 8, nil},
+		{"unchanged", 7, 7, ErrSignCount},
+		{"went backwards", 7, 3, ErrSignCount},
+		{"reset to zero", 7, 0, ErrSignCount},
+		{"authenticator without counter", 0, 0, nil},
+		{"first use of a counter", 0, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.signCount = tt.sent
+			got, err := testRP.VerifyAssertion(challenge, a.assert(newCeremony("webauthn.get", challenge)), publicKey, tt.stored, false)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("VerifyAssertion() = %v, want %v", err, tt.want)
+			}
+			if err == nil && got != tt.sent {
+				t.Errorf("new counter = %d, want %d", got, tt.sent)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge := []byte("a challenge of the relying party")
+
+	tests := []struct {
+		name                    string
+		change                  func(c *ceremony)
+		response                func(r *AttestationResponse)
+		requireUserVerification bool
+		want                    string
+	}{
+		{name: "wrong origin", change: func(c *ceremony) { c.origin = "https://evil.example" }, want: `webauthn: invalid authenticator response: unexpected origin "https://evil.example"`},
+		{name: "sign-in response", change: func(c *ceremony) { c.clientType = "webauthn.get" }, want: `webauthn: invalid authenticator response: unexpected ceremony "webauthn.get"`},
+		{name: "wrong rpIdHash", change: func(c *ceremony) { c.rpID = "evil.example" }, want: "webauthn: invalid authenticator response: credential belongs to another site"},
+		{name: "user not present", change: func(c *ceremony) { c.flags = 0 }, want: "webauthn: invalid authenticator response: user not present"},
+		{name: "user not verified", change: func(c *ceremony) { c.flags = flagUserPresent }, requireUserVerification: true, want: "webauthn: invalid authenticator response: user not verified"},
+		{name: "malformed CBOR", response: func(r *AttestationResponse) {
+			r.Response.AttestationObject = r.Response.AttestationObject[:len(r.Response.AttestationObject)-10]
+		}, want: "webauthn: invalid authenticator response: malformed attestation object"},
+		{name: "trailing data", response: func(r *AttestationResponse) {
+			r.Response.AttestationObject = append(r.Response.AttestationObject, 0x00)
+		}, want: "webauthn: invalid authenticator response: malformed attestation object"},
+		{name: "no authenticator data", response: func(r *AttestationResponse) {
+			r.Response.AttestationObject = encodeCBOR([]cborPair{{"fmt", "none"}})
+		}, want: "webauthn: invalid authenticator response: authenticator data too short"},
+		{name: "no attested credential", response: func(r *AttestationResponse) {
+			r.Response.AttestationObject = encodeCBOR([]cborPair{{"authData", a.authData(newCeremony("webauthn.create", challenge), false)}})
+		}, want: "webauthn: invalid authenticator response: no attested credential"},
+		{name: "credential ID mismatch", response: func(r *AttestationResponse) { r.RawID = []byte("credential-2") }, want: "webauthn: invalid authenticator response: credential ID mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCeremony("webauthn.create", challenge)
+			if tt.change != nil {
+				tt.change(&c)
+			}
+			response := a.register(c)
+			if tt.response != nil {
+				tt.response(response)
+			}
+			_, err := testRP.VerifyRegistration(challenge, response, tt.requireUserVerification)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("VerifyRegistration() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAuthenticatorDataMalformed(t *testing.T) {
+	a := newAuthenticator(t)
+	valid := a.authData(newCeremony("webauthn.create", nil), true)
+	keyStart := 37 + 16 + 2 + len(a.id)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"short attested data", valid[:37+10], "webauthn: invalid authenticator response: attested credential data too short"},
+		{"credential ID longer than the data", valid[:keyStart-1], "webauthn: invalid authenticator response: invalid credential ID"},
+		{"empty credential ID", append(append(append([]byte(nil), valid[:37+16]...), 0, 0), valid[keyStart:]...), "webauthn: invalid authenticator response: invalid credential ID"},
+		{"truncated key", valid[:len(valid)-5], "webauthn: invalid authenticator response: malformed credential key"},
+		{"missing key", valid[:keyStart], "webauthn: invalid authenticator response: malformed credential key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAuthenticatorData(tt.data)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("parseAuthenticatorData() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// Extensions after the key are not part of it
+	authData, err := parseAuthenticatorData(append(append([]byte(nil), valid...), encodeCBOR([]cborPair{{"credProtect", int64(1)}})...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(authData.publicKey) != string(coseEC2(&a.key.PublicKey)) {
+		t.Errorf("public key = %x", authData.publicKey)
+	}
+}
+
+func TestBytesJSON(t *testing.T) {
+	data, err := json.Marshal(Bytes{0xfb, 0xff})
+	if err != nil || string(data) != `"-_8"` {
+		t.Errorf("Marshal() = %s, %v", data, err)
+	}
+	var b Bytes
+	if err := json.Unmarshal([]byte(`"-_8"`), &b); err != nil || string(b) != "\xfb\xff" {
+		t.Errorf("Unmarshal() = %x, %v", b, err)
+	}
+	if err := json.Unmarshal([]byte(`"+/8="`), &b); err == nil {
+		t.Error("standard base64 accepted")
+	}
+}
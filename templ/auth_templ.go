@@ -52,98 +52,173 @@ func Auth(data AuthData) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "</title><script src=\"https://cdn.tailwindcss.com\"></script><script src=\"https://unpkg.com/htmx.org@1.9.6\"></script><script src=\"https://unpkg.com/alpinejs@3.x.x/dist/cdn.min.js\" defer></script><link rel=\"stylesheet\" href=\"https://cdnjs.cloudflare.com/ajax/libs/font-awesome/6.4.0/css/all.min.css\"><link rel=\"stylesheet\" href=\"/static/css/style.css\"><style>\n\t\t\t.bg-gradient-sysara {\n\t\t\t\tbackground: linear-gradient(135deg, #667eea 0%, #764ba2 100%);\n\t\t\t}\n\t\t\t.htmx-indicator {\n\t\t\t\topacity: 0;\n\t\t\t\ttransition: opacity 0.3s ease-in-out;\n\t\t\t}\n\t\t\t.htmx-request .htmx-indicator {\n\t\t\t\topacity: 1;\n\t\t\t}\n\t\t</style></head><body class=\"bg-gray-100 font-sans antialiased\"><div class=\"flex h-screen bg-gray-50\" x-data=\"{ sidebarOpen: false }\"><!-- Sidebar --><div class=\"flex flex-col w-64 bg-gradient-sysara\" :class=\"{'block': sidebarOpen, 'hidden': !sidebarOpen}\" x-show=\"sidebarOpen\" x-transition:enter=\"transition ease-out duration-200\" x-transition:enter-start=\"-translate-x-full\" x-transition:enter-end=\"translate-x-0\" x-transition:leave=\"transition ease-in duration-200\" x-transition:leave-start=\"translate-x-0\" x-transition:leave-end=\"-translate-x-full\" @click.away=\"sidebarOpen = false\"><div class=\"flex items-center justify-center h-20 shadow-md\"><h1 class=\"text-3xl font-bold text-white\">Sysara</h1></div><nav class=\"flex-1 px-4 py-4 bg-gradient-sysara\"><a href=\"/dashboard\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-tachometer-alt mr-3\"></i> Dashboard</a> <a href=\"/users\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-users mr-3\"></i> Users</a> <a href=\"/env\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-cog mr-3\"></i> Environment</a> <a href=\"/ssh\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-key mr-3\"></i> SSH Keys</a> <a href=\"/monitor\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-chart-line mr-3\"></i> Monitoring</a></nav><div class=\"px-4 py-4 bg-gray-700 bg-opacity-25\"><div class=\"flex items-center\"><div class=\"flex-shrink-0\"><div class=\"w-8 h-8 bg-white rounded-full flex items-center justify-center\"><i class=\"fas fa-user text-gray-600\"></i></div></div><div class=\"ml-3\"><p class=\"text-sm font-medium text-white\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "</title>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = csrfMeta().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<script src=\"https://cdn.tailwindcss.com\"></script><script src=\"https://unpkg.com/htmx.org@1.9.6\"></script><script src=\"https://unpkg.com/alpinejs@3.x.x/dist/cdn.min.js\" defer></script><link rel=\"stylesheet\" href=\"https://cdnjs.cloudflare.com/ajax/libs/font-awesome/6.4.0/css/all.min.css\"><link rel=\"stylesheet\" href=\"/static/css/style.css\"><style>\n\t\t\t.bg-gradient-sysara {\n\t\t\t\tbackground: linear-gradient(135deg, #667eea 0%, #764ba2 100%);\n\t\t\t}\n\t\t\t.htmx-indicator {\n\t\t\t\topacity: 0;\n\t\t\t\ttransition: opacity 0.3s ease-in-out;\n\t\t\t}\n\t\t\t.htmx-request .htmx-indicator {\n\t\t\t\topacity: 1;\n\t\t\t}\n\t\t</style></head><body class=\"bg-gray-100 font-sans antialiased\"><div class=\"flex h-screen bg-gray-50\" x-data=\"{ sidebarOpen: false }\"><!-- Sidebar --><div class=\"flex flex-col w-64 bg-gradient-sysara\" :class=\"{'block': sidebarOpen, 'hidden': !sidebarOpen}\" x-show=\"sidebarOpen\" x-transition:enter=\"transition ease-out duration-200\" x-transition:enter-start=\"-translate-x-full\" x-transition:enter-end=\"translate-x-0\" x-transition:leave=\"transition ease-in duration-200\" x-transition:leave-start=\"translate-x-0\" x-transition:leave-end=\"-translate-x-full\" @click.away=\"sidebarOpen = false\"><div class=\"flex items-center justify-center h-20 shadow-md\"><h1 class=\"text-3xl font-bold text-white\">Sysara</h1></div><nav class=\"flex-1 px-4 py-4 bg-gradient-sysara\"><a href=\"/dashboard\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-tachometer-alt mr-3\"></i> Dashboard</a> ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if data.CurrentUser.IsAdmin() {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "<a href=\"/users\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-users mr-3\"></i> Users</a> ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<a href=\"/teams\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-users-cog mr-3\"></i> Teams</a> <a href=\"/env\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-cog mr-3\"></i> Environment</a> <a href=\"/ssh\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-key mr-3\"></i> SSH Keys</a> <a href=\"/servers\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-server mr-3\"></i> Servers</a> <a href=\"/monitor\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-chart-line mr-3\"></i> Monitoring</a> ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if data.CurrentUser.IsAdmin() {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "<a href=\"/cron\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-clock mr-3\"></i> Cron Jobs</a> <a href=\"/containers\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fab fa-docker mr-3\"></i> Containers</a>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "</nav><div class=\"px-4 py-4 bg-gray-700 bg-opacity-25\"><div class=\"flex items-center\"><div class=\"flex-shrink-0\"><div class=\"w-8 h-8 bg-white rounded-full flex items-center justify-center\"><i class=\"fas fa-user text-gray-600\"></i></div></div><div class=\"ml-3\"><p class=\"text-sm font-medium text-white\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var3 string
 		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(data.CurrentUser.Name)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 75, Col: 72}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 96, Col: 72}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</p><p class=\"text-xs text-gray-300\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</p><p class=\"text-xs text-gray-300\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var4 string
 		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(data.CurrentUser.Email)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 76, Col: 64}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 97, Col: 64}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</p></div></div><form method=\"POST\" action=\"/logout\" class=\"mt-3\"><button type=\"submit\" class=\"w-full text-left px-4 py-2 text-sm text-gray-300 hover:text-white hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-sign-out-alt mr-2\"></i> Sign out</button></form></div></div><!-- Mobile sidebar overlay --><div class=\"fixed inset-0 z-10 bg-gray-600 bg-opacity-75 lg:hidden\" x-show=\"sidebarOpen\" x-transition:enter=\"transition-opacity ease-linear duration-300\" x-transition:enter-start=\"opacity-0\" x-transition:enter-end=\"opacity-100\" x-transition:leave=\"transition-opacity ease-linear duration-300\" x-transition:leave-start=\"opacity-100\" x-transition:leave-end=\"opacity-0\" @click=\"sidebarOpen = false\" style=\"display: none;\"></div><!-- Desktop sidebar --><div class=\"hidden lg:flex lg:flex-col lg:w-64 bg-gradient-sysara\"><div class=\"flex items-center justify-center h-20 shadow-md\"><h1 class=\"text-3xl font-bold text-white\">Sysara</h1></div><nav class=\"flex-1 px-4 py-4\"><a href=\"/dashboard\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-tachometer-alt mr-3\"></i> Dashboard</a> <a href=\"/users\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-users mr-3\"></i> Users</a> <a href=\"/env\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-cog mr-3\"></i> Environment</a> <a href=\"/ssh\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-key mr-3\"></i> SSH Keys</a> <a href=\"/monitor\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-chart-line mr-3\"></i> Monitoring</a></nav><div class=\"px-4 py-4 bg-gray-700 bg-opacity-25\"><div class=\"flex items-center\"><div class=\"flex-shrink-0\"><div class=\"w-8 h-8 bg-white rounded-full flex items-center justify-center\"><i class=\"fas fa-user text-gray-600\"></i></div></div><div class=\"ml-3\"><p class=\"text-sm font-medium text-white\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "</p></div></div><form method=\"POST\" action=\"/logout\" class=\"mt-3\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = csrfField().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "<button type=\"submit\" class=\"w-full text-left px-4 py-2 text-sm text-gray-300 hover:text-white hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-sign-out-alt mr-2\"></i> Sign out</button></form></div></div><!-- Mobile sidebar overlay --><div class=\"fixed inset-0 z-10 bg-gray-600 bg-opacity-75 lg:hidden\" x-show=\"sidebarOpen\" x-transition:enter=\"transition-opacity ease-linear duration-300\" x-transition:enter-start=\"opacity-0\" x-transition:enter-end=\"opacity-100\" x-transition:leave=\"transition-opacity ease-linear duration-300\" x-transition:leave-start=\"opacity-100\" x-transition:leave-end=\"opacity-0\" @click=\"sidebarOpen = false\" style=\"display: none;\"></div><!-- Desktop sidebar --><div class=\"hidden lg:flex lg:flex-col lg:w-64 bg-gradient-sysara\"><div class=\"flex items-center justify-center h-20 shadow-md\"><h1 class=\"text-3xl font-bold text-white\">Sysara</h1></div><nav class=\"flex-1 px-4 py-4\"><a href=\"/dashboard\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-tachometer-alt mr-3\"></i> Dashboard</a> ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if data.CurrentUser.IsAdmin() {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "<a href=\"/users\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-users mr-3\"></i> Users</a> ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "<a href=\"/teams\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-users-cog mr-3\"></i> Teams</a> <a href=\"/env\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-cog mr-3\"></i> Environment</a> <a href=\"/ssh\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-key mr-3\"></i> SSH Keys</a> <a href=\"/servers\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-server mr-3\"></i> Servers</a> <a href=\"/monitor\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-chart-line mr-3\"></i> Monitoring</a> ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if data.CurrentUser.IsAdmin() {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "<a href=\"/cron\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-clock mr-3\"></i> Cron Jobs</a> <a href=\"/containers\" class=\"flex items-center px-4 py-2 mt-2 text-gray-100 hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fab fa-docker mr-3\"></i> Containers</a>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "</nav><div class=\"px-4 py-4 bg-gray-700 bg-opacity-25\"><div class=\"flex items-center\"><div class=\"flex-shrink-0\"><div class=\"w-8 h-8 bg-white rounded-full flex items-center justify-center\"><i class=\"fas fa-user text-gray-600\"></i></div></div><div class=\"ml-3\"><p class=\"text-sm font-medium text-white\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var5 string
 		templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(data.CurrentUser.Name)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 126, Col: 72}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 168, Col: 72}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</p><p class=\"text-xs text-gray-300\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "</p><p class=\"text-xs text-gray-300\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var6 string
 		templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(data.CurrentUser.Email)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 127, Col: 64}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 169, Col: 64}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</p></div></div><form method=\"POST\" action=\"/logout\" class=\"mt-3\"><button type=\"submit\" class=\"w-full text-left px-4 py-2 text-sm text-gray-300 hover:text-white hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-sign-out-alt mr-2\"></i> Sign out</button></form></div></div><!-- Main content --><div class=\"flex flex-col flex-1 overflow-hidden\"><!-- Top bar --><header class=\"flex justify-between items-center py-4 px-6 bg-white border-b-4 border-indigo-600\"><div class=\"flex items-center\"><button @click=\"sidebarOpen = true\" class=\"text-gray-500 focus:outline-none lg:hidden\"><svg class=\"w-6 h-6\" viewBox=\"0 0 24 24\" fill=\"none\" xmlns=\"http://www.w3.org/2000/svg\"><path d=\"M4 6H20M4 12H20M4 18H11\" stroke=\"currentColor\" stroke-width=\"2\" stroke-linecap=\"round\" stroke-linejoin=\"round\"></path></svg></button><h2 class=\"text-xl font-semibold text-gray-800 ml-2 lg:ml-0\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "</p></div></div><form method=\"POST\" action=\"/logout\" class=\"mt-3\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = csrfField().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "<button type=\"submit\" class=\"w-full text-left px-4 py-2 text-sm text-gray-300 hover:text-white hover:bg-gray-700 hover:bg-opacity-25 rounded-lg\"><i class=\"fas fa-sign-out-alt mr-2\"></i> Sign out</button></form></div></div><!-- Main content --><div class=\"flex flex-col flex-1 overflow-hidden\"><!-- Top bar --><header class=\"flex justify-between items-center py-4 px-6 bg-white border-b-4 border-indigo-600\"><div class=\"flex items-center\"><button @click=\"sidebarOpen = true\" class=\"text-gray-500 focus:outline-none lg:hidden\"><svg class=\"w-6 h-6\" viewBox=\"0 0 24 24\" fill=\"none\" xmlns=\"http://www.w3.org/2000/svg\"><path d=\"M4 6H20M4 12H20M4 18H11\" stroke=\"currentColor\" stroke-width=\"2\" stroke-linecap=\"round\" stroke-linejoin=\"round\"></path></svg></button><h2 class=\"text-xl font-semibold text-gray-800 ml-2 lg:ml-0\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var7 string
 		templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(data.PageTitle)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 149, Col: 83}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 192, Col: 83}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "</h2></div><div class=\"flex items-center space-x-4\"><div class=\"relative\" x-data=\"{ open: false }\"><button @click=\"open = !open\" class=\"flex items-center text-sm text-gray-500 hover:text-gray-700 focus:outline-none\"><div class=\"w-8 h-8 bg-gray-300 rounded-full flex items-center justify-center\"><i class=\"fas fa-user text-gray-600\"></i></div><span class=\"ml-2\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "</h2></div><div class=\"flex items-center space-x-4\"><div class=\"relative\" x-data=\"{ open: false }\"><button @click=\"open = !open\" class=\"flex items-center text-sm text-gray-500 hover:text-gray-700 focus:outline-none\"><div class=\"w-8 h-8 bg-gray-300 rounded-full flex items-center justify-center\"><i class=\"fas fa-user text-gray-600\"></i></div><span class=\"ml-2\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var8 string
 		templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(data.CurrentUser.Name)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 157, Col: 50}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 200, Col: 50}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</span> <svg class=\"ml-1 w-4 h-4\" fill=\"currentColor\" viewBox=\"0 0 20 20\"><path fill-rule=\"evenodd\" d=\"M5.293 7.293a1 1 0 011.414 0L10 10.586l3.293-3.293a1 1 0 111.414 1.414l-4 4a1 1 0 01-1.414 0l-4-4a1 1 0 010-1.414z\" clip-rule=\"evenodd\"></path></svg></button><div x-show=\"open\" @click.away=\"open = false\" x-transition:enter=\"transition ease-out duration-100\" x-transition:enter-start=\"transform opacity-0 scale-95\" x-transition:enter-end=\"transform opacity-100 scale-100\" x-transition:leave=\"transition ease-in duration-75\" x-transition:leave-start=\"transform opacity-100 scale-100\" x-transition:leave-end=\"transform opacity-0 scale-95\" class=\"absolute right-0 mt-2 w-48 bg-white rounded-md shadow-lg z-50\" style=\"display: none;\"><div class=\"py-1\"><a href=\"")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "</span> <svg class=\"ml-1 w-4 h-4\" fill=\"currentColor\" viewBox=\"0 0 20 20\"><path fill-rule=\"evenodd\" d=\"M5.293 7.293a1 1 0 011.414 0L10 10.586l3.293-3.293a1 1 0 111.414 1.414l-4 4a1 1 0 01-1.414 0l-4-4a1 1 0 010-1.414z\" clip-rule=\"evenodd\"></path></svg></button><div x-show=\"open\" @click.away=\"open = false\" x-transition:enter=\"transition ease-out duration-100\" x-transition:enter-start=\"transform opacity-0 scale-95\" x-transition:enter-end=\"transform opacity-100 scale-100\" x-transition:leave=\"transition ease-in duration-75\" x-transition:leave-start=\"transform opacity-100 scale-100\" x-transition:leave-end=\"transform opacity-0 scale-95\" class=\"absolute right-0 mt-2 w-48 bg-white rounded-md shadow-lg z-50\" style=\"display: none;\"><div class=\"py-1\"><a href=\"/profile\" class=\"block px-4 py-2 text-sm text-gray-700 hover:bg-gray-100\">Profile</a> <a href=\"/sessions\" class=\"block px-4 py-2 text-sm text-gray-700 hover:bg-gray-100\">Sessions</a> ")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var9 templ.SafeURL
-		templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinURLErrs("/users/" + templ.EscapeString(string(rune(data.CurrentUser.ID))) + "/edit")
+		if data.CurrentUser.AuthProvider != models.AuthOIDC {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "<a href=\"/security-keys\" class=\"block px-4 py-2 text-sm text-gray-700 hover:bg-gray-100\">Security Keys</a> ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		if !data.CurrentUser.IsExternal() {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, "<a href=\"/password\" class=\"block px-4 py-2 text-sm text-gray-700 hover:bg-gray-100\">Change Password</a>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, "<form method=\"POST\" action=\"/logout\">")
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templ/auth.templ`, Line: 164, Col: 94}
+			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
+		templ_7745c5c3_Err = csrfField().Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "\" class=\"block px-4 py-2 text-sm text-gray-700 hover:bg-gray-100\">Profile</a><form method=\"POST\" action=\"/logout\"><button type=\"submit\" class=\"w-full text-left block px-4 py-2 text-sm text-gray-700 hover:bg-gray-100\">Sign out</button></form></div></div></div></div></header><!-- Page content --><main class=\"flex-1 overflow-x-hidden overflow-y-auto bg-gray-100 p-6\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, "<button type=\"submit\" class=\"w-full text-left block px-4 py-2 text-sm text-gray-700 hover:bg-gray-100\">Sign out</button></form></div></div></div></div></header><!-- Page content --><main class=\"flex-1 overflow-x-hidden overflow-y-auto bg-gray-100 p-6\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -151,7 +226,7 @@ func Auth(data AuthData) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "</main></div></div><!-- Loading indicator --><div id=\"loading-indicator\" class=\"htmx-indicator fixed top-4 right-4 bg-blue-500 text-white px-4 py-2 rounded-lg shadow-lg z-50\"><i class=\"fas fa-spinner fa-spin mr-2\"></i> Loading...</div><script>\n\t\t\t// Global HTMX configuration\n\t\t\tdocument.body.addEventListener('htmx:configRequest', function(evt) {\n\t\t\t\tevt.detail.headers['X-Requested-With'] = 'XMLHttpRequest';\n\t\t\t});\n\n\t\t\t// Auto-refresh for monitoring pages\n\t\t\tif (window.location.pathname === '/monitor') {\n\t\t\t\tsetInterval(function() {\n\t\t\t\t\thtmx.trigger('#system-stats', 'refresh');\n\t\t\t\t\thtmx.trigger('#process-list', 'refresh');\n\t\t\t\t}, 5000);\n\t\t\t}\n\n\t\t\t// Format bytes\n\t\t\tfunction formatBytes(bytes, decimals = 2) {\n\t\t\t\tif (bytes === 0) return '0 Bytes';\n\t\t\t\tconst k = 1024;\n\t\t\t\tconst dm = decimals < 0 ? 0 : decimals;\n\t\t\t\tconst sizes = ['Bytes', 'KB', 'MB', 'GB', 'TB'];\n\t\t\t\tconst i = Math.floor(Math.log(bytes) / Math.log(k));\n\t\t\t\treturn parseFloat((bytes / Math.pow(k, i)).toFixed(dm)) + ' ' + sizes[i];\n\t\t\t}\n\n\t\t\t// Format uptime\n\t\t\tfunction formatUptime(seconds) {\n\t\t\t\tconst days = Math.floor(seconds / 86400);\n\t\t\t\tconst hours = Math.floor((seconds % 86400) / 3600);\n\t\t\t\tconst minutes = Math.floor((seconds % 3600) / 60);\n\t\t\t\treturn `${days}d ${hours}h ${minutes}m`;\n\t\t\t}\n\t\t</script></body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, "</main></div></div><!-- Loading indicator --><div id=\"loading-indicator\" class=\"htmx-indicator fixed top-4 right-4 bg-blue-500 text-white px-4 py-2 rounded-lg shadow-lg z-50\"><i class=\"fas fa-spinner fa-spin mr-2\"></i> Loading...</div><script>\n\t\t\t// Global HTMX configuration\n\t\t\tdocument.body.addEventListener('htmx:configRequest', function(evt) {\n\t\t\t\tevt.detail.headers['X-Requested-With'] = 'XMLHttpRequest';\n\t\t\t\tevt.detail.headers['X-CSRF-Token'] = document.querySelector('meta[name=\"csrf-token\"]').content;\n\t\t\t});\n\n\t\t\t// Auto-refresh for monitoring pages\n\t\t\tif (window.location.pathname === '/monitor') {\n\t\t\t\tsetInterval(function() {\n\t\t\t\t\thtmx.trigger('#system-stats', 'refresh');\n\t\t\t\t\thtmx.trigger('#process-list', 'refresh');\n\t\t\t\t}, 5000);\n\t\t\t}\n\n\t\t\t// Format bytes\n\t\t\tfunction formatBytes(bytes, decimals = 2) {\n\t\t\t\tif (bytes === 0) return '0 Bytes';\n\t\t\t\tconst k = 1024;\n\t\t\t\tconst dm = decimals < 0 ? 0 : decimals;\n\t\t\t\tconst sizes = ['Bytes', 'KB', 'MB', 'GB', 'TB'];\n\t\t\t\tconst i = Math.floor(Math.log(bytes) / Math.log(k));\n\t\t\t\treturn parseFloat((bytes / Math.pow(k, i)).toFixed(dm)) + ' ' + sizes[i];\n\t\t\t}\n\n\t\t\t// Format uptime\n\t\t\tfunction formatUptime(seconds) {\n\t\t\t\tconst days = Math.floor(seconds / 86400);\n\t\t\t\tconst hours = Math.floor((seconds % 86400) / 3600);\n\t\t\t\tconst minutes = Math.floor((seconds % 3600) / 60);\n\t\t\t\treturn `${days}d ${hours}h ${minutes}m`;\n\t\t\t}\n\t\t</script></body></html>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}